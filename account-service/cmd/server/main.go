@@ -8,6 +8,7 @@ import (
 
 	"business-cart/account-service/internal/config"
 	"business-cart/account-service/internal/handler"
+	"business-cart/account-service/internal/migrations"
 	"business-cart/account-service/internal/storage"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -31,8 +32,15 @@ func init() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Apply pending schema migrations on cold start when enabled
+	if cfg.RunMigrations {
+		if err := migrations.Run(context.Background(), db.Database()); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
 	// Setup handler
-	h := handler.NewHandler(db, cfg.JWTSecret, cfg.JWTRefreshSecret)
+	h := handler.NewHandler(db, cfg.JWTSecret, cfg.JWTRefreshSecret, cfg.MaxSessions)
 
 	// Setup router
 	chiRouter = chi.NewRouter()
@@ -48,6 +56,13 @@ func adapter(ctx context.Context, req events.APIGatewayProxyRequest) (events.API
 	if err != nil {
 		return events.APIGatewayProxyResponse{StatusCode: 500}, err
 	}
+	// Carry query string parameters over so handlers can read r.URL.Query()
+	query := httpRequest.URL.Query()
+	for key, value := range req.QueryStringParameters {
+		query.Set(key, value)
+	}
+	httpRequest.URL.RawQuery = query.Encode()
+	httpRequest.RemoteAddr = req.RequestContext.Identity.SourceIP
 	// Copy headers from the API Gateway request to the http.Request
 	for key, value := range req.Headers {
 		httpRequest.Header.Set(key, value)
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -17,7 +19,12 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func GenerateJWT(userID, email, role, secret string, companyID string, associateCompanyIDs []string) (string, error) {
+// AccessTokenTTL is the lifetime of an access token issued at login or refresh.
+const AccessTokenTTL = 72 * time.Hour
+
+// GenerateJWT issues an access token. Its "sid" claim names the session (the
+// refresh token) it was issued for, so that revoking the session revokes it.
+func GenerateJWT(userID, email, role, secret string, companyID string, associateCompanyIDs []string, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
 		"user": map[string]interface{}{
 			"id":                    userID,
@@ -25,7 +32,8 @@ func GenerateJWT(userID, email, role, secret string, companyID string, associate
 			"role":                  role,
 			"associate_company_ids": associateCompanyIDs,
 		},
-		"exp": time.Now().Add(time.Hour * 72).Unix(),
+		"sid": sessionID,
+		"exp": time.Now().Add(AccessTokenTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -45,8 +53,43 @@ func GenerateRefreshToken(userID, email, role, secret, companyID string, associa
 			"role":                  role,
 			"associate_company_ids": associateCompanyIDs,
 		},
+		"jti": newTokenID(),
 		"exp": time.Now().Add(time.Hour * 24 * 7).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(secret))
 }
+
+// ImpersonationTTL is the lifetime of an access token issued through the
+// admin "act as" flow. It is deliberately much shorter than a normal login.
+const ImpersonationTTL = 15 * time.Minute
+
+// GenerateImpersonationJWT issues an access token for the target account that
+// carries an RFC 8693 style "act" claim naming the admin acting on its behalf.
+func GenerateImpersonationJWT(userID, email, role, secret string, associateCompanyIDs []string, actorID, actorEmail, impersonationID string, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"user": map[string]interface{}{
+			"id":                    userID,
+			"email":                 email,
+			"role":                  role,
+			"associate_company_ids": associateCompanyIDs,
+		},
+		"act": map[string]interface{}{
+			"sub":   actorID,
+			"email": actorEmail,
+		},
+		"jti": impersonationID,
+		"exp": expiresAt.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// newTokenID returns a random identifier so that two refresh tokens issued for
+// the same account within the same second are never identical.
+func newTokenID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
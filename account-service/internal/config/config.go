@@ -2,12 +2,19 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
+// defaultMaxSessions caps concurrent refresh tokens per account when
+// MAX_SESSIONS_PER_ACCOUNT is unset.
+const defaultMaxSessions = 5
+
 type Config struct {
 	MongoURI         string
 	JWTSecret        string
 	JWTRefreshSecret string
+	MaxSessions      int
+	RunMigrations    bool
 }
 
 func LoadConfig() (Config, error) {
@@ -15,6 +22,15 @@ func LoadConfig() (Config, error) {
 		MongoURI:         os.Getenv("MONGO_URI"),
 		JWTSecret:        os.Getenv("JWT_SECRET"),
 		JWTRefreshSecret: os.Getenv("JWT_REFRESH_SECRET"),
+		MaxSessions:      defaultMaxSessions,
+		RunMigrations:    os.Getenv("RUN_MIGRATIONS") == "true",
+	}
+	if v := os.Getenv("MAX_SESSIONS_PER_ACCOUNT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return config, err
+		}
+		config.MaxSessions = n
 	}
 	return config, nil
 }
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"business-cart/account-service/internal/storage"
+)
+
+/* ---------- CREDIT ---------- */
+
+// CreditTerms is the credit view of a seller/customer relationship. When the
+// seller has not set a per-customer limit, the limit comes from the seller's
+// CompanyData.CreditLimit.
+type CreditTerms struct {
+	SellerID         string  `json:"sellerId"`
+	CustomerID       string  `json:"customerId"`
+	CreditLimit      float64 `json:"creditLimit"`
+	PaymentTermsDays int     `json:"paymentTermsDays"`
+	OverLimitAction  string  `json:"overLimitAction"`
+	IsDefault        bool    `json:"isDefault"`
+}
+
+type SetCreditRequest struct {
+	SellerID         string  `json:"sellerId"` // admin only, companies always set their own
+	CreditLimit      float64 `json:"creditLimit"`
+	PaymentTermsDays int     `json:"paymentTermsDays"`
+	OverLimitAction  string  `json:"overLimitAction"` // reject (default) | flag
+}
+
+func creditTerms(terms TradingTerms, rel *storage.Relationship) CreditTerms {
+	return CreditTerms{
+		SellerID:         terms.SellerID,
+		CustomerID:       terms.CustomerID,
+		CreditLimit:      terms.CreditLimit,
+		PaymentTermsDays: terms.NetTermsDays,
+		OverLimitAction:  terms.OverLimitAction,
+		IsDefault:        rel.CreditLimit == nil,
+	}
+}
+
+// GetCredit returns the effective credit terms of a customer with a seller.
+func (h *Handler) GetCredit(w http.ResponseWriter, r *http.Request) {
+	customer, seller, ok := h.relationshipParties(w, r, r.URL.Query().Get("sellerId"))
+	if !ok {
+		return
+	}
+
+	rel, stored := h.loadRelationship(seller, customer)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creditTerms(effectiveTerms(seller, rel, stored), rel))
+}
+
+// SetCredit lets a seller set the credit limit and payment terms of a linked customer.
+func (h *Handler) SetCredit(w http.ResponseWriter, r *http.Request) {
+	var req SetCreditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != storage.RoleCompany && userClaims["role"] != storage.RoleAdmin {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	customer, seller, ok := h.relationshipParties(w, r, req.SellerID)
+	if !ok {
+		return
+	}
+
+	rel, _ := h.loadRelationship(seller, customer)
+	if msg := applyRelationshipUpdate(rel, seller.CompanyData, &UpdateRelationshipRequest{
+		CreditLimit:     &req.CreditLimit,
+		NetTermsDays:    &req.PaymentTermsDays,
+		OverLimitAction: &req.OverLimitAction,
+	}); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if err := h.db.SaveRelationship(rel); err != nil {
+		http.Error(w, "failed to save credit terms", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creditTerms(effectiveTerms(seller, rel, true), rel))
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -18,24 +20,43 @@ type Handler struct {
 	db               *storage.DB
 	jwtSecret        string
 	jwtRefreshSecret string
+	maxSessions      int
 }
 
-func NewHandler(db *storage.DB, jwtSecret, jwtRefreshSecret string) *Handler {
-	return &Handler{db: db, jwtSecret: jwtSecret, jwtRefreshSecret: jwtRefreshSecret}
+func NewHandler(db *storage.DB, jwtSecret, jwtRefreshSecret string, maxSessions int) *Handler {
+	return &Handler{db: db, jwtSecret: jwtSecret, jwtRefreshSecret: jwtRefreshSecret, maxSessions: maxSessions}
 }
 
 func (h *Handler) RegisterRoutes(router *chi.Mux) {
 	router.Post("/accounts/register", h.Register)
 	router.Post("/accounts/login", h.Login)
+	router.Post("/accounts/refresh", h.RefreshToken)
+	router.Post("/accounts/logout", h.LogoutUser)
 
 	router.Group(func(r chi.Router) {
-		r.Use(middleware.AuthMiddleware(h.jwtSecret))
+		r.Use(middleware.AuthMiddleware(h.jwtSecret, h.db))
 		r.Get("/accounts", h.GetAccounts)
 		r.Get("/accounts/{id}", h.GetAccountByID)
-		r.Patch("/accounts/{id}", h.UpdateAccount)
-		r.Delete("/accounts/{id}", h.DeleteAccount)
-		r.Post("/codes", h.CreateCode)    // admin only
-		r.Get("/codes/{code}", h.GetCode) // admin only
+		r.Get("/codes/{code}", h.GetCode)             // admin only
+		r.Get("/impersonations", h.GetImpersonations) // admin only
+		r.Post("/impersonation/end", h.EndImpersonation)
+		r.Get("/accounts/{id}/sessions", h.GetSessions)
+		r.Get("/accounts/{id}/credit", h.GetCredit)
+		r.Get("/accounts/{id}/relationships", h.GetRelationships)
+		r.Get("/accounts/{id}/relationships/{sellerId}", h.GetRelationship)
+
+		// sensitive operations are not available to impersonation tokens
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.BlockImpersonation)
+			r.Patch("/accounts/{id}", h.UpdateAccount)
+			r.Delete("/accounts/{id}", h.DeleteAccount)
+			r.Post("/accounts/{id}/impersonate", h.Impersonate) // admin only
+			r.Post("/codes", h.CreateCode)                      // admin only
+			r.Delete("/accounts/{id}/sessions", h.RevokeSessions)
+			r.Delete("/accounts/{id}/sessions/{sessionId}", h.RevokeSession)
+			r.Put("/accounts/{id}/credit", h.SetCredit)                              // company or admin
+			r.Patch("/accounts/{id}/relationships/{sellerId}", h.UpdateRelationship) // company or admin
+		})
 	})
 }
 
@@ -146,6 +167,9 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt:     time.Now(),
 	}
 
+	// customer-to-seller relationships created alongside a customer account
+	var relationships []*storage.Relationship
+
 	switch req.Role {
 	// TODO: Remove this admin registration logic before production.
 	case "admin":
@@ -187,6 +211,13 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 				CodeID: codeDoc.ID.Hex(),
 				Code:   codeDoc.CustomerCode,
 			})
+			// the company account shares its ID with the code it claimed
+			relationships = append(relationships, &storage.Relationship{
+				SellerID:   codeDoc.ID,
+				CustomerID: acc.ID,
+				CodeID:     codeDoc.ID.Hex(),
+				Status:     storage.RelationshipActive,
+			})
 			// customer codes are **never** marked as claimed
 		}
 		acc.CustomerData = &storage.CustomerData{CustomerCodes: entries}
@@ -218,6 +249,9 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to create account", http.StatusInternalServerError)
 		return
 	}
+	for _, rel := range relationships {
+		_ = h.db.SaveRelationship(rel)
+	}
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -248,15 +282,15 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	accessToken, err := auth.GenerateJWT(user.ID.Hex(), user.Email, user.Role, h.jwtSecret, "", associateCompanyIDs)
+	refreshToken, sessionID, err := h.generateAndStoreRefreshToken(user, r)
 	if err != nil {
-		http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+		http.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
 		return
 	}
 
-	refreshToken, err := h.generateAndStoreRefreshToken(user)
+	accessToken, err := auth.GenerateJWT(user.ID.Hex(), user.Email, user.Role, h.jwtSecret, "", associateCompanyIDs, sessionID.Hex())
 	if err != nil {
-		http.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
+		http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
 		return
 	}
 
@@ -278,7 +312,11 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, _ := h.db.GetAccountByID(rt.UserID)
+	user, err := h.db.GetAccountByID(rt.UserID)
+	if err != nil {
+		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
 	var associateCompanyIDs []string
 	if user.Role == storage.RoleCustomer && user.CustomerData != nil {
 		for _, codeEntry := range user.CustomerData.CustomerCodes {
@@ -286,10 +324,27 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	newAccess, _ := auth.GenerateJWT(user.ID.Hex(), user.Email, user.Role, h.jwtSecret, "", associateCompanyIDs)
-	newRefresh, _ := h.generateAndStoreRefreshToken(user)
+	// rotate the token in place so the session keeps its ID, and with it the
+	// "sid" of its access tokens
+	newRefresh, _ := auth.GenerateRefreshToken(user.ID.Hex(), user.Email, user.Role, h.jwtRefreshSecret, "", associateCompanyIDs)
+	expiresAt := primitive.NewDateTimeFromTime(time.Now().Add(7 * 24 * time.Hour))
+	err = h.db.RotateRefreshToken(rt.ID, rt.Token, newRefresh, expiresAt, r.UserAgent(), clientIP(r))
+	if errors.Is(err, storage.ErrRefreshTokenReused) {
+		// Someone else refreshed with the same token first. One of the two
+		// holds a stolen copy, so the whole session is revoked.
+		log.Printf("Refresh token of session %s reused, revoking the session", rt.ID.Hex())
+		h.blacklistSessions([]*storage.RefreshToken{rt})
+		_ = h.db.DeleteRefreshTokenByID(rt.ID)
+		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to rotate refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	newAccess, _ := auth.GenerateJWT(user.ID.Hex(), user.Email, user.Role, h.jwtSecret, "", associateCompanyIDs, rt.ID.Hex())
 
-	_ = h.db.DeleteRefreshToken(req.RefreshToken)
 	json.NewEncoder(w).Encode(map[string]string{"accessToken": newAccess, "refreshToken": newRefresh})
 }
 
@@ -300,11 +355,16 @@ func (h *Handler) LogoutUser(w http.ResponseWriter, r *http.Request) {
 	json.NewDecoder(r.Body).Decode(&req)
 
 	_ = h.db.BlacklistToken(&storage.BlacklistedToken{Token: req.RefreshToken, ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(72 * time.Hour))})
+	if rt, err := h.db.GetRefreshToken(req.RefreshToken); err == nil {
+		_ = h.db.RevokeTokenID(rt.ID.Hex(), time.Now().Add(auth.AccessTokenTTL))
+	}
 	_ = h.db.DeleteRefreshToken(req.RefreshToken)
 	w.WriteHeader(http.StatusOK)
 }
 
-func (h *Handler) generateAndStoreRefreshToken(user *storage.Account) (string, error) {
+// generateAndStoreRefreshToken starts a session for user and returns its
+// refresh token and ID.
+func (h *Handler) generateAndStoreRefreshToken(user *storage.Account, r *http.Request) (string, primitive.ObjectID, error) {
 	var associateCompanyIDs []string
 	if user.Role == storage.RoleCustomer && user.CustomerData != nil {
 		for _, codeEntry := range user.CustomerData.CustomerCodes {
@@ -313,12 +373,25 @@ func (h *Handler) generateAndStoreRefreshToken(user *storage.Account) (string, e
 	}
 
 	token, _ := auth.GenerateRefreshToken(user.ID.Hex(), user.Email, user.Role, h.jwtRefreshSecret, "", associateCompanyIDs)
+	now := time.Now()
+	sessionID := primitive.NewObjectID()
 	_ = h.db.CreateRefreshToken(&storage.RefreshToken{
-		UserID:    user.ID,
-		Token:     token,
-		ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(7 * 24 * time.Hour)),
+		ID:         sessionID,
+		UserID:     user.ID,
+		Token:      token,
+		ExpiresAt:  primitive.NewDateTimeFromTime(now.Add(7 * 24 * time.Hour)),
+		UserAgent:  r.UserAgent(),
+		IP:         clientIP(r),
+		CreatedAt:  now,
+		LastUsedAt: now,
 	})
-	return token, nil
+
+	// cap concurrent sessions, evicting the least recently used ones
+	if h.maxSessions > 0 {
+		evicted, _ := h.db.EvictLeastRecentlyUsedRefreshTokens(user.ID, h.maxSessions)
+		h.blacklistSessions(evicted)
+	}
+	return token, sessionID, nil
 }
 
 /* ---------- OTHER CRUD ---------- */
@@ -426,10 +499,29 @@ func (h *Handler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
 	id, _ := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	var upd map[string]interface{}
 	json.NewDecoder(r.Body).Decode(&upd)
+	if currency, ok := upd["company.currency"]; ok {
+		if code, _ := currency.(string); !validCurrency(code) {
+			http.Error(w, "company.currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+			return
+		}
+	}
 	_ = h.db.UpdateAccount(id, upd)
 	w.WriteHeader(http.StatusOK)
 }
 
+// validCurrency reports whether code looks like an ISO 4217 currency code.
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
 func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
 	id, _ := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	_ = h.db.DeleteAccount(id)
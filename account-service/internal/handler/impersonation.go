@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"business-cart/account-service/internal/auth"
+	"business-cart/account-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/* ---------- IMPERSONATION ---------- */
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason"` // mandatory, kept for the audit trail
+}
+
+// Impersonate issues a short-lived access token for the target account so that
+// support staff can see exactly what a customer or company sees. The token
+// carries an "act" claim identifying the admin and cannot be refreshed.
+func (h *Handler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != storage.RoleAdmin {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	adminID, err := primitive.ObjectIDFromHex(userClaims["id"].(string))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	adminEmail, _ := userClaims["email"].(string)
+
+	targetID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "reason required", http.StatusBadRequest)
+		return
+	}
+
+	target, err := h.db.GetAccountByID(targetID)
+	if err != nil {
+		http.Error(w, "account not found", http.StatusNotFound)
+		return
+	}
+	if target.Role == storage.RoleAdmin {
+		http.Error(w, "admin accounts cannot be impersonated", http.StatusForbidden)
+		return
+	}
+
+	var associateCompanyIDs []string
+	if target.Role == storage.RoleCustomer && target.CustomerData != nil {
+		for _, codeEntry := range target.CustomerData.CustomerCodes {
+			associateCompanyIDs = append(associateCompanyIDs, codeEntry.CodeID)
+		}
+	}
+
+	now := time.Now()
+	imp := &storage.Impersonation{
+		ID:          primitive.NewObjectID(),
+		AdminID:     adminID,
+		AdminEmail:  adminEmail,
+		TargetID:    target.ID,
+		TargetEmail: target.Email,
+		Reason:      req.Reason,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(auth.ImpersonationTTL),
+	}
+	if err := h.db.CreateImpersonation(imp); err != nil {
+		http.Error(w, "failed to record impersonation", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := auth.GenerateImpersonationJWT(target.ID.Hex(), target.Email, target.Role, h.jwtSecret, associateCompanyIDs, adminID.Hex(), adminEmail, imp.ID.Hex(), imp.ExpiresAt)
+	if err != nil {
+		http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Impersonate: admin %s (%s) issued token for %s (%s), reason: %q", adminID.Hex(), adminEmail, target.ID.Hex(), target.Email, req.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accessToken":     accessToken,
+		"expiresAt":       imp.ExpiresAt,
+		"impersonationId": imp.ID.Hex(),
+	})
+}
+
+// EndImpersonation revokes an impersonation token before it expires. Called
+// with the impersonation token itself it ends that impersonation; an admin
+// may end any by passing its impersonationId. The token is then turned away
+// by every service.
+func (h *Handler) EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	impersonationID, _ := r.Context().Value("impersonationId").(string)
+	if impersonationID == "" {
+		userClaims := r.Context().Value("user").(map[string]interface{})
+		if userClaims["role"] != storage.RoleAdmin {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			ImpersonationID string `json:"impersonationId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ImpersonationID == "" {
+			http.Error(w, "impersonationId required", http.StatusBadRequest)
+			return
+		}
+		impersonationID = req.ImpersonationID
+	}
+
+	id, err := primitive.ObjectIDFromHex(impersonationID)
+	if err != nil {
+		http.Error(w, "invalid impersonationId", http.StatusBadRequest)
+		return
+	}
+	imp, err := h.db.GetImpersonation(id)
+	if err != nil {
+		http.Error(w, "impersonation not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.db.RevokeTokenID(imp.ID.Hex(), imp.ExpiresAt); err != nil {
+		http.Error(w, "Failed to end impersonation", http.StatusInternalServerError)
+		return
+	}
+	_ = h.db.EndImpersonation(imp.ID, time.Now())
+	log.Printf("EndImpersonation: impersonation %s of %s by admin %s ended", imp.ID.Hex(), imp.TargetID.Hex(), imp.AdminID.Hex())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetImpersonations lists issued impersonation tokens for auditing. Optional
+// adminId and targetId query parameters narrow the result.
+func (h *Handler) GetImpersonations(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != storage.RoleAdmin {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filter := bson.M{}
+	for param, field := range map[string]string{"adminId": "adminId", "targetId": "targetId"} {
+		if v := r.URL.Query().Get(param); v != "" {
+			oid, err := primitive.ObjectIDFromHex(v)
+			if err != nil {
+				http.Error(w, "invalid "+param, http.StatusBadRequest)
+				return
+			}
+			filter[field] = oid
+		}
+	}
+
+	records, err := h.db.GetImpersonations(filter)
+	if err != nil {
+		http.Error(w, "Failed to retrieve impersonations", http.StatusInternalServerError)
+		return
+	}
+	if len(records) == 0 {
+		json.NewEncoder(w).Encode([]*storage.Impersonation{})
+		return
+	}
+	json.NewEncoder(w).Encode(records)
+}
@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"business-cart/account-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/* ---------- SELLER/CUSTOMER RELATIONSHIPS ---------- */
+
+// TradingTerms is the effective relationship between a seller and a linked
+// customer: the stored record with the seller's defaults filled in.
+type TradingTerms struct {
+	SellerID              string   `json:"sellerId"`
+	CustomerID            string   `json:"customerId"`
+	Status                string   `json:"status"`
+	AllowedPaymentMethods []string `json:"allowedPaymentMethods"`
+	NetTermsDays          int      `json:"netTermsDays"`
+	DefaultShippingMethod string   `json:"defaultShippingMethod,omitempty"`
+	DiscountTier          string   `json:"discountTier,omitempty"`
+	DiscountPercent       float64  `json:"discountPercent"`
+	TaxExempt             bool     `json:"taxExempt"`
+	SalesRep              string   `json:"salesRep,omitempty"`
+	Currency              string   `json:"currency,omitempty"` // the seller's
+	CreditLimit           float64  `json:"creditLimit"`
+	OverLimitAction       string   `json:"overLimitAction"`
+	IsDefault             bool     `json:"isDefault"`
+}
+
+type UpdateRelationshipRequest struct {
+	Status                *string   `json:"status"`
+	AllowedPaymentMethods *[]string `json:"allowedPaymentMethods"`
+	NetTermsDays          *int      `json:"netTermsDays"`
+	DefaultShippingMethod *string   `json:"defaultShippingMethod"`
+	DiscountTier          *string   `json:"discountTier"`
+	TaxExempt             *bool     `json:"taxExempt"`
+	SalesRep              *string   `json:"salesRep"`
+	CreditLimit           *float64  `json:"creditLimit"`
+	OverLimitAction       *string   `json:"overLimitAction"`
+}
+
+// linkedToSeller reports whether the customer registered with one of the
+// seller's customer codes.
+func linkedToSeller(customer *storage.Account, sellerID string) bool {
+	if customer.CustomerData == nil {
+		return false
+	}
+	for _, entry := range customer.CustomerData.CustomerCodes {
+		if entry.CodeID == sellerID {
+			return true
+		}
+	}
+	return false
+}
+
+// relationshipParties resolves the customer from the URL and the seller from
+// the caller's role, and checks the caller may see that relationship.
+func (h *Handler) relationshipParties(w http.ResponseWriter, r *http.Request, sellerParam string) (*storage.Account, *storage.Account, bool) {
+	customerID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	switch userClaims["role"] {
+	case storage.RoleAdmin:
+	case storage.RoleCompany:
+		if sellerParam != "" && sellerParam != userClaims["id"] {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return nil, nil, false
+		}
+		sellerParam = userClaims["id"].(string)
+	case storage.RoleCustomer:
+		if userClaims["id"] != customerID.Hex() {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return nil, nil, false
+		}
+	default:
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	sellerID, err := primitive.ObjectIDFromHex(sellerParam)
+	if err != nil {
+		http.Error(w, "sellerId required", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	customer, err := h.db.GetAccountByID(customerID)
+	if err != nil || customer.Role != storage.RoleCustomer {
+		http.Error(w, "customer not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if !linkedToSeller(customer, sellerID.Hex()) {
+		http.Error(w, "customer is not linked to this seller", http.StatusNotFound)
+		return nil, nil, false
+	}
+	seller, err := h.db.GetAccountByID(sellerID)
+	if err != nil || seller.Role != storage.RoleCompany {
+		http.Error(w, "seller not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return customer, seller, true
+}
+
+// loadRelationship returns the stored relationship or a new unsaved one.
+func (h *Handler) loadRelationship(seller, customer *storage.Account) (*storage.Relationship, bool) {
+	rel, err := h.db.GetRelationship(seller.ID, customer.ID)
+	if err == nil {
+		return rel, true
+	}
+	return &storage.Relationship{
+		SellerID:   seller.ID,
+		CustomerID: customer.ID,
+		CodeID:     seller.ID.Hex(),
+		Status:     storage.RelationshipActive,
+	}, false
+}
+
+// effectiveTerms fills in the seller's defaults for anything the relationship
+// does not override.
+func effectiveTerms(seller *storage.Account, rel *storage.Relationship, stored bool) TradingTerms {
+	company := seller.CompanyData
+	if company == nil {
+		company = &storage.CompanyData{}
+	}
+
+	terms := TradingTerms{
+		SellerID:              rel.SellerID.Hex(),
+		CustomerID:            rel.CustomerID.Hex(),
+		Status:                rel.Status,
+		AllowedPaymentMethods: rel.AllowedPaymentMethods,
+		NetTermsDays:          rel.NetTermsDays,
+		DefaultShippingMethod: rel.DefaultShippingMethod,
+		DiscountTier:          rel.DiscountTier,
+		TaxExempt:             rel.TaxExempt,
+		SalesRep:              rel.SalesRep,
+		Currency:              company.Currency,
+		CreditLimit:           company.CreditLimit,
+		OverLimitAction:       rel.OverLimitAction,
+		IsDefault:             !stored,
+	}
+	if len(terms.AllowedPaymentMethods) == 0 {
+		terms.AllowedPaymentMethods = company.PaymentMethods
+	}
+	if terms.AllowedPaymentMethods == nil {
+		terms.AllowedPaymentMethods = []string{}
+	}
+	if terms.SalesRep == "" {
+		terms.SalesRep = company.SaleRepresentative
+	}
+	if rel.CreditLimit != nil {
+		terms.CreditLimit = *rel.CreditLimit
+	}
+	if terms.OverLimitAction == "" {
+		terms.OverLimitAction = storage.OverLimitReject
+	}
+	for _, tier := range company.DiscountTiers {
+		if tier.Name == rel.DiscountTier {
+			terms.DiscountPercent = tier.Percent
+		}
+	}
+	return terms
+}
+
+// GetRelationships lists the trading terms of a customer with every seller
+// visible to the caller.
+func (h *Handler) GetRelationships(w http.ResponseWriter, r *http.Request) {
+	customerID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	role := userClaims["role"]
+	if role != storage.RoleAdmin && role != storage.RoleCompany && userClaims["id"] != customerID.Hex() {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	customer, err := h.db.GetAccountByID(customerID)
+	if err != nil || customer.Role != storage.RoleCustomer {
+		http.Error(w, "customer not found", http.StatusNotFound)
+		return
+	}
+
+	var sellerIDs []primitive.ObjectID
+	if customer.CustomerData != nil {
+		for _, entry := range customer.CustomerData.CustomerCodes {
+			if role == storage.RoleCompany && entry.CodeID != userClaims["id"] {
+				continue
+			}
+			if oid, err := primitive.ObjectIDFromHex(entry.CodeID); err == nil {
+				sellerIDs = append(sellerIDs, oid)
+			}
+		}
+	}
+
+	sellers, err := h.db.GetAccountCompaniesDataByIDs(sellerIDs)
+	if err != nil {
+		http.Error(w, "Failed to retrieve sellers", http.StatusInternalServerError)
+		return
+	}
+	stored, err := h.db.GetRelationships(bson.M{"customerId": customer.ID, "sellerId": bson.M{"$in": sellerIDs}})
+	if err != nil {
+		http.Error(w, "Failed to retrieve relationships", http.StatusInternalServerError)
+		return
+	}
+	bySeller := make(map[primitive.ObjectID]*storage.Relationship, len(stored))
+	for _, rel := range stored {
+		bySeller[rel.SellerID] = rel
+	}
+
+	out := make([]TradingTerms, 0, len(sellers))
+	for _, seller := range sellers {
+		rel, ok := bySeller[seller.ID]
+		if !ok {
+			rel, _ = h.loadRelationship(seller, customer)
+		}
+		out = append(out, effectiveTerms(seller, rel, ok))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// GetRelationship returns the trading terms between a customer and one seller.
+func (h *Handler) GetRelationship(w http.ResponseWriter, r *http.Request) {
+	customer, seller, ok := h.relationshipParties(w, r, chi.URLParam(r, "sellerId"))
+	if !ok {
+		return
+	}
+
+	rel, stored := h.loadRelationship(seller, customer)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(effectiveTerms(seller, rel, stored))
+}
+
+// UpdateRelationship lets a seller edit the terms negotiated with a linked customer.
+func (h *Handler) UpdateRelationship(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != storage.RoleCompany && userClaims["role"] != storage.RoleAdmin {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	customer, seller, ok := h.relationshipParties(w, r, chi.URLParam(r, "sellerId"))
+	if !ok {
+		return
+	}
+
+	var req UpdateRelationshipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rel, _ := h.loadRelationship(seller, customer)
+	if msg := applyRelationshipUpdate(rel, seller.CompanyData, &req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if err := h.db.SaveRelationship(rel); err != nil {
+		http.Error(w, "failed to save relationship", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(effectiveTerms(seller, rel, true))
+}
+
+// applyRelationshipUpdate validates the requested changes against the
+// seller's settings and applies them. It returns a message on invalid input.
+func applyRelationshipUpdate(rel *storage.Relationship, company *storage.CompanyData, req *UpdateRelationshipRequest) string {
+	if company == nil {
+		company = &storage.CompanyData{}
+	}
+
+	if req.Status != nil {
+		if *req.Status != storage.RelationshipActive && *req.Status != storage.RelationshipSuspended {
+			return "status must be active or suspended"
+		}
+		rel.Status = *req.Status
+	}
+	if req.AllowedPaymentMethods != nil {
+		for _, m := range *req.AllowedPaymentMethods {
+			if len(company.PaymentMethods) > 0 && !contains(company.PaymentMethods, m) {
+				return "payment method " + m + " is not offered by the seller"
+			}
+		}
+		rel.AllowedPaymentMethods = *req.AllowedPaymentMethods
+	}
+	if req.NetTermsDays != nil {
+		if *req.NetTermsDays < 0 {
+			return "netTermsDays must not be negative"
+		}
+		rel.NetTermsDays = *req.NetTermsDays
+	}
+	if req.DefaultShippingMethod != nil {
+		m := *req.DefaultShippingMethod
+		if m != "" && len(company.ShippingMethods) > 0 && !contains(company.ShippingMethods, m) {
+			return "shipping method " + m + " is not offered by the seller"
+		}
+		rel.DefaultShippingMethod = m
+	}
+	if req.DiscountTier != nil {
+		if t := *req.DiscountTier; t != "" {
+			found := false
+			for _, tier := range company.DiscountTiers {
+				found = found || tier.Name == t
+			}
+			if !found {
+				return "unknown discount tier " + t
+			}
+		}
+		rel.DiscountTier = *req.DiscountTier
+	}
+	if req.TaxExempt != nil {
+		rel.TaxExempt = *req.TaxExempt
+	}
+	if req.SalesRep != nil {
+		rel.SalesRep = *req.SalesRep
+	}
+	if req.CreditLimit != nil {
+		if *req.CreditLimit < 0 {
+			return "creditLimit must not be negative"
+		}
+		rel.CreditLimit = req.CreditLimit
+	}
+	if req.OverLimitAction != nil {
+		switch *req.OverLimitAction {
+		case "", storage.OverLimitReject, storage.OverLimitFlag:
+			rel.OverLimitAction = *req.OverLimitAction
+		default:
+			return "overLimitAction must be reject or flag"
+		}
+	}
+	return ""
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"business-cart/account-service/internal/auth"
+	"business-cart/account-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+/* ---------- SESSIONS ---------- */
+
+// sessionOwner resolves the {id} URL parameter and checks that the caller is
+// either that account or an admin.
+func sessionOwner(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != storage.RoleAdmin && userClaims["id"] != id.Hex() {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// GetSessions lists the active sessions (refresh tokens) of an account.
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	id, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.db.GetRefreshTokensByUser(id)
+	if err != nil {
+		http.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(sessions) == 0 {
+		json.NewEncoder(w).Encode([]*storage.RefreshToken{})
+		return
+	}
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSession signs a single device out.
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	id, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "sessionId"))
+	if err != nil {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.db.GetRefreshTokenByID(id, sessionID)
+	if err != nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	h.blacklistSessions([]*storage.RefreshToken{session})
+	if err := h.db.DeleteRefreshToken(session.Token); err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeSessions signs every device of the account out.
+func (h *Handler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	id, ok := sessionOwner(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.db.GetRefreshTokensByUser(id)
+	if err != nil {
+		http.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	h.blacklistSessions(sessions)
+	if err := h.db.DeleteRefreshTokensByUser(id); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// blacklistSessions records revoked refresh tokens until they would have
+// expired, and revokes the access tokens issued for their sessions.
+func (h *Handler) blacklistSessions(sessions []*storage.RefreshToken) {
+	accessExpiresAt := time.Now().Add(auth.AccessTokenTTL)
+	for _, s := range sessions {
+		_ = h.db.BlacklistToken(&storage.BlacklistedToken{Token: s.Token, ExpiresAt: s.ExpiresAt})
+		_ = h.db.RevokeTokenID(s.ID.Hex(), accessExpiresAt)
+	}
+}
+
+// clientIP returns the originating client address, preferring the first hop
+// recorded by API Gateway or a proxy in X-Forwarded-For.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -10,7 +10,15 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// Revocations tells whether a token ID has been revoked: that of the token
+// itself, its "jti", or that of the session it was issued for, its "sid".
+type Revocations interface {
+	IsRevoked(id string) (bool, error)
+}
+
+// AuthMiddleware accepts requests bearing a valid access token that has not
+// been revoked.
+func AuthMiddleware(jwtSecret string, revocations Revocations) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Try both "Authorization" and "authorization"
@@ -88,9 +96,49 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
+			for _, claim := range []string{"jti", "sid"} {
+				id, _ := claims[claim].(string)
+				if id == "" {
+					continue
+				}
+				revoked, err := revocations.IsRevoked(id)
+				if err != nil {
+					log.Printf("AuthMiddleware: Failed to check %s %s: %v", claim, id, err)
+					http.Error(w, "Failed to check token", http.StatusInternalServerError)
+					return
+				}
+				if revoked {
+					log.Printf("AuthMiddleware: %s %s has been revoked", claim, id)
+					http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+					return
+				}
+			}
+
 			log.Printf("AuthMiddleware: Successfully authenticated user: %v", user)
 			ctx := context.WithValue(r.Context(), "user", user)
+
+			// Impersonation tokens carry the acting admin in the "act" claim.
+			// Every such request is attributed to the admin in the logs.
+			if act, ok := claims["act"].(map[string]interface{}); ok {
+				log.Printf("AuthMiddleware: Impersonated request: admin %v (%v) acting as %v: %s %s", act["sub"], act["email"], user["id"], r.Method, r.URL.Path)
+				ctx = context.WithValue(ctx, "act", act)
+				ctx = context.WithValue(ctx, "impersonationId", claims["jti"])
+			}
+
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// BlockImpersonation rejects requests made with an impersonation token. It is
+// applied to sensitive routes such as credential changes and account deletion.
+func BlockImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if act, ok := r.Context().Value("act").(map[string]interface{}); ok {
+			log.Printf("BlockImpersonation: Denied %s %s for admin %v", r.Method, r.URL.Path, act["sub"])
+			http.Error(w, "Operation not permitted while impersonating", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
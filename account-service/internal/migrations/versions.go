@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrations lists every migration of the service. Append new entries with
+// the next version number; never renumber or edit an applied one.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "unique index on accounts.email",
+		Up: createIndexes("accounts",
+			mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "customer.customerCodes.codeId", Value: 1}}},
+		),
+	},
+	{
+		Version:     2,
+		Description: "refreshtokens TTL on expiresAt, unique token, sessions by user",
+		Up: createIndexes("refreshtokens",
+			mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			mongo.IndexModel{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastUsedAt", Value: -1}}},
+		),
+	},
+	{
+		Version:     3,
+		Description: "blacklistedtokens TTL on expiresAt and token lookup",
+		Up: createIndexes("blacklistedtokens",
+			mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			mongo.IndexModel{Keys: bson.D{{Key: "token", Value: 1}}},
+		),
+	},
+	{
+		Version:     4,
+		Description: "unique seller/customer pair on relationships",
+		Up: createIndexes("relationships",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerId", Value: 1}, {Key: "customerId", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "customerId", Value: 1}}},
+		),
+	},
+	{
+		Version:     5,
+		Description: "impersonation audit lookups by admin and target",
+		Up: createIndexes("impersonations",
+			mongo.IndexModel{Keys: bson.D{{Key: "adminId", Value: 1}, {Key: "createdAt", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		),
+	},
+	{
+		Version:     6,
+		Description: "codes lookups by companyCode, customerCode and partnerCode",
+		Up: createIndexes("codes",
+			mongo.IndexModel{Keys: bson.D{{Key: "companyCode", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "customerCode", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "partnerCode", Value: 1}}, Options: options.Index().SetSparse(true)},
+		),
+	},
+	{
+		Version:     7,
+		Description: "revokedtokens TTL on expiresAt",
+		Up: createIndexes("revokedtokens",
+			mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		),
+	},
+}
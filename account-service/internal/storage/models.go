@@ -39,25 +39,27 @@ type Address struct {
 
 // ---------- role sub-docs ----------
 type CompanyData struct {
-	Name                  string   `bson:"name" json:"name"`
-	Status                string   `bson:"status" json:"status"`
-	UniqueIdentifier      string   `bson:"uniqueIdentifier" json:"uniqueIdentifier"`
-	SaleRepresentative    string   `bson:"saleRepresentative" json:"saleRepresentative"`
-	CreditLimit           float64  `bson:"creditLimit" json:"creditLimit"`
-	ShippingMethods       []string `bson:"shippingMethods" json:"shippingMethods"`
-	PaymentMethods        []string `bson:"paymentMethods" json:"paymentMethods"`
-	DeliveryMethods       []string `bson:"deliveryMethods" json:"deliveryMethods"`
-	LeadTime              float64  `bson:"leadTime" json:"leadTime"`
-	MaxOrderAmountLimit   float64  `bson:"maxOrderAmountLimit" json:"maxOrderAmountLimit"`
-	MaxOrderQuantityLimit float64  `bson:"maxOrderQuantityLimit" json:"maxOrderQuantityLimit"`
-	MinOrderAmountLimit   float64  `bson:"minOrderAmountLimit" json:"minOrderAmountLimit"`
-	MinOrderQuantityLimit float64  `bson:"minOrderQuantityLimit" json:"minOrderQuantityLimit"`
-	MonthlyOrderLimit     float64  `bson:"monthlyOrderLimit" json:"monthlyOrderLimit"`
-	YearlyOrderLimit      float64  `bson:"yearlyOrderLimit" json:"yearlyOrderLimit"`
-	TaxableGoods          bool     `bson:"taxableGoods" json:"taxableGoods"`
-	QuotesAllowed         bool     `bson:"quotesAllowed" json:"quotesAllowed"`
-	CompanyCodeID         string   `bson:"companyCodeId,omitempty" json:"companyCodeId,omitempty"`
-	CompanyCode           string   `bson:"companyCode" json:"companyCode"`
+	Name                  string         `bson:"name" json:"name"`
+	Status                string         `bson:"status" json:"status"`
+	UniqueIdentifier      string         `bson:"uniqueIdentifier" json:"uniqueIdentifier"`
+	SaleRepresentative    string         `bson:"saleRepresentative" json:"saleRepresentative"`
+	CreditLimit           float64        `bson:"creditLimit" json:"creditLimit"`
+	ShippingMethods       []string       `bson:"shippingMethods" json:"shippingMethods"`
+	PaymentMethods        []string       `bson:"paymentMethods" json:"paymentMethods"`
+	DeliveryMethods       []string       `bson:"deliveryMethods" json:"deliveryMethods"`
+	LeadTime              float64        `bson:"leadTime" json:"leadTime"`
+	MaxOrderAmountLimit   float64        `bson:"maxOrderAmountLimit" json:"maxOrderAmountLimit"`
+	MaxOrderQuantityLimit float64        `bson:"maxOrderQuantityLimit" json:"maxOrderQuantityLimit"`
+	MinOrderAmountLimit   float64        `bson:"minOrderAmountLimit" json:"minOrderAmountLimit"`
+	MinOrderQuantityLimit float64        `bson:"minOrderQuantityLimit" json:"minOrderQuantityLimit"`
+	MonthlyOrderLimit     float64        `bson:"monthlyOrderLimit" json:"monthlyOrderLimit"`
+	YearlyOrderLimit      float64        `bson:"yearlyOrderLimit" json:"yearlyOrderLimit"`
+	TaxableGoods          bool           `bson:"taxableGoods" json:"taxableGoods"`
+	QuotesAllowed         bool           `bson:"quotesAllowed" json:"quotesAllowed"`
+	Currency              string         `bson:"currency,omitempty" json:"currency,omitempty"` // ISO 4217 code carts are priced in by default
+	DiscountTiers         []DiscountTier `bson:"discountTiers,omitempty" json:"discountTiers,omitempty"`
+	CompanyCodeID         string         `bson:"companyCodeId,omitempty" json:"companyCodeId,omitempty"`
+	CompanyCode           string         `bson:"companyCode" json:"companyCode"`
 	SellingArea           struct {
 		Radius float64 `bson:"radius" json:"radius"`
 		Center Coords  `bson:"center" json:"center"`
@@ -65,6 +67,12 @@ type CompanyData struct {
 	Address Address `bson:"address" json:"address"`
 }
 
+// DiscountTier is a named discount a company can assign to its customers.
+type DiscountTier struct {
+	Name    string  `bson:"name" json:"name"`
+	Percent float64 `bson:"percent" json:"percent"`
+}
+
 type CustomerCodeEntry struct {
 	CodeID string `bson:"codeId" json:"codeId"`
 	Code   string `bson:"customerCode" json:"customerCode"`
@@ -98,6 +106,37 @@ type Account struct {
 	Address      *Address      `bson:"address,omitempty" json:"address,omitempty"`
 }
 
+// ---------- seller/customer relationship ----------
+const (
+	RelationshipActive    = "active"
+	RelationshipSuspended = "suspended"
+)
+
+const (
+	OverLimitReject = "reject"
+	OverLimitFlag   = "flag"
+)
+
+// Relationship carries the terms a seller has negotiated with one linked
+// customer. Without a stored record the seller's defaults apply.
+type Relationship struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	SellerID              primitive.ObjectID `bson:"sellerId" json:"sellerId"`
+	CustomerID            primitive.ObjectID `bson:"customerId" json:"customerId"`
+	CodeID                string             `bson:"codeId" json:"codeId"`
+	Status                string             `bson:"status" json:"status"`
+	AllowedPaymentMethods []string           `bson:"allowedPaymentMethods,omitempty" json:"allowedPaymentMethods,omitempty"` // subset of the seller's payment methods, empty means all
+	NetTermsDays          int                `bson:"netTermsDays" json:"netTermsDays"`
+	DefaultShippingMethod string             `bson:"defaultShippingMethod,omitempty" json:"defaultShippingMethod,omitempty"`
+	DiscountTier          string             `bson:"discountTier,omitempty" json:"discountTier,omitempty"`
+	TaxExempt             bool               `bson:"taxExempt" json:"taxExempt"`
+	SalesRep              string             `bson:"salesRep,omitempty" json:"salesRep,omitempty"`
+	CreditLimit           *float64           `bson:"creditLimit,omitempty" json:"creditLimit,omitempty"` // nil falls back to CompanyData.CreditLimit
+	OverLimitAction       string             `bson:"overLimitAction,omitempty" json:"overLimitAction,omitempty"`
+	CreatedAt             time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt             time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
 // ---------- code & auth ----------
 type Code struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty"`
@@ -108,11 +147,17 @@ type Code struct {
 	CreatedAt    time.Time          `bson:"createdAt"`
 }
 
+// RefreshToken doubles as the record of an active session: it is rotated in
+// place on refresh so its ID stays stable for the lifetime of the login.
 type RefreshToken struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	UserID    primitive.ObjectID `bson:"userId"`
-	Token     string             `bson:"token"`
-	ExpiresAt primitive.DateTime `bson:"expiresAt"`
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserID     primitive.ObjectID `bson:"userId" json:"userId"`
+	Token      string             `bson:"token" json:"-"` // Do not expose token
+	ExpiresAt  primitive.DateTime `bson:"expiresAt" json:"expiresAt"`
+	UserAgent  string             `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	IP         string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt time.Time          `bson:"lastUsedAt" json:"lastUsedAt"`
 }
 
 type BlacklistedToken struct {
@@ -120,3 +165,16 @@ type BlacklistedToken struct {
 	Token     string             `bson:"token"`
 	ExpiresAt primitive.DateTime `bson:"expiresAt"`
 }
+
+// Impersonation records every "act as" token issued by an admin.
+type Impersonation struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	AdminID     primitive.ObjectID `bson:"adminId" json:"adminId"`
+	AdminEmail  string             `bson:"adminEmail" json:"adminEmail"`
+	TargetID    primitive.ObjectID `bson:"targetId" json:"targetId"`
+	TargetEmail string             `bson:"targetEmail" json:"targetEmail"`
+	Reason      string             `bson:"reason" json:"reason"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt   time.Time          `bson:"expiresAt" json:"expiresAt"`
+	EndedAt     *time.Time         `bson:"endedAt,omitempty" json:"endedAt,omitempty"` // set when ended before it expired
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,10 +13,14 @@ import (
 
 type DB struct {
 	client            *mongo.Client
+	database          *mongo.Database
 	accounts          *mongo.Collection
 	codes             *mongo.Collection
 	refreshtokens     *mongo.Collection
 	blacklistedtokens *mongo.Collection
+	impersonations    *mongo.Collection
+	relationships     *mongo.Collection
+	revokedtokens     *mongo.Collection
 }
 
 func NewDB(mongoURI string) (*DB, error) {
@@ -26,10 +32,14 @@ func NewDB(mongoURI string) (*DB, error) {
 	db := client.Database("AccountService")
 	return &DB{
 		client:            client,
+		database:          db,
 		accounts:          db.Collection("accounts"),
 		codes:             db.Collection("codes"),
 		refreshtokens:     db.Collection("refreshtokens"),
 		blacklistedtokens: db.Collection("blacklistedtokens"),
+		impersonations:    db.Collection("impersonations"),
+		relationships:     db.Collection("relationships"),
+		revokedtokens:     db.Collection(RevokedTokensCollection),
 	}, nil
 }
 
@@ -120,6 +130,47 @@ func (db *DB) GetAccountCompaniesDataByIDs(ids []primitive.ObjectID) ([]*Account
 	return out, nil
 }
 
+/* ---------- RELATIONSHIPS ---------- */
+
+// GetRelationship returns the stored terms between a seller and a customer.
+func (db *DB) GetRelationship(sellerID, customerID primitive.ObjectID) (*Relationship, error) {
+	var rel Relationship
+	err := db.relationships.FindOne(context.Background(), bson.M{"sellerId": sellerID, "customerId": customerID}).Decode(&rel)
+	return &rel, err
+}
+
+// GetRelationships returns all relationships matching the filter.
+func (db *DB) GetRelationships(filter bson.M) ([]*Relationship, error) {
+	cursor, err := db.relationships.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var out []*Relationship
+	err = cursor.All(context.Background(), &out)
+	return out, err
+}
+
+// SaveRelationship creates or replaces the relationship for its seller/customer pair.
+func (db *DB) SaveRelationship(rel *Relationship) error {
+	now := time.Now()
+	rel.UpdatedAt = now
+	if rel.CreatedAt.IsZero() {
+		rel.CreatedAt = now
+	}
+	if rel.ID.IsZero() {
+		rel.ID = primitive.NewObjectID()
+	}
+	_, err := db.relationships.ReplaceOne(
+		context.Background(),
+		bson.M{"sellerId": rel.SellerID, "customerId": rel.CustomerID},
+		rel,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
 /* ---------- REFRESH TOKENS ---------- */
 
 func (db *DB) CreateRefreshToken(token *RefreshToken) error {
@@ -138,6 +189,82 @@ func (db *DB) DeleteRefreshToken(token string) error {
 	return err
 }
 
+// ErrRefreshTokenReused is returned by RotateRefreshToken when the session no
+// longer holds the token being rotated: another request has already rotated
+// it, so the token has been used twice.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// RotateRefreshToken replaces the token of an existing session and records
+// when and from where it was last used. It only does so while the session
+// still holds oldToken.
+func (db *DB) RotateRefreshToken(id primitive.ObjectID, oldToken, token string, expiresAt primitive.DateTime, userAgent, ip string) error {
+	res, err := db.refreshtokens.UpdateOne(context.Background(), bson.M{"_id": id, "token": oldToken}, bson.M{"$set": bson.M{
+		"token":      token,
+		"expiresAt":  expiresAt,
+		"userAgent":  userAgent,
+		"ip":         ip,
+		"lastUsedAt": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// GetRefreshTokensByUser returns the sessions of an account, most recently used first.
+func (db *DB) GetRefreshTokensByUser(userID primitive.ObjectID) ([]*RefreshToken, error) {
+	opts := options.Find().SetSort(bson.M{"lastUsedAt": -1})
+	cursor, err := db.refreshtokens.Find(context.Background(), bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var out []*RefreshToken
+	err = cursor.All(context.Background(), &out)
+	return out, err
+}
+
+// GetRefreshTokenByID returns a single session belonging to the account.
+func (db *DB) GetRefreshTokenByID(userID, id primitive.ObjectID) (*RefreshToken, error) {
+	var rt RefreshToken
+	err := db.refreshtokens.FindOne(context.Background(), bson.M{"_id": id, "userId": userID}).Decode(&rt)
+	return &rt, err
+}
+
+// EvictLeastRecentlyUsedRefreshTokens deletes the sessions of an account that
+// were used longest ago, by lastUsedAt rather than createdAt, so that at most
+// keep remain. It returns the evicted sessions.
+func (db *DB) EvictLeastRecentlyUsedRefreshTokens(userID primitive.ObjectID, keep int) ([]*RefreshToken, error) {
+	sessions, err := db.GetRefreshTokensByUser(userID)
+	if err != nil || len(sessions) <= keep {
+		return nil, err
+	}
+
+	evicted := sessions[keep:]
+	ids := make([]primitive.ObjectID, 0, len(evicted))
+	for _, s := range evicted {
+		ids = append(ids, s.ID)
+	}
+	_, err = db.refreshtokens.DeleteMany(context.Background(), bson.M{"_id": bson.M{"$in": ids}})
+	return evicted, err
+}
+
+// DeleteRefreshTokenByID removes a session whatever token it holds.
+func (db *DB) DeleteRefreshTokenByID(id primitive.ObjectID) error {
+	_, err := db.refreshtokens.DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
+
+// DeleteRefreshTokensByUser removes every session of an account.
+func (db *DB) DeleteRefreshTokensByUser(userID primitive.ObjectID) error {
+	_, err := db.refreshtokens.DeleteMany(context.Background(), bson.M{"userId": userID})
+	return err
+}
+
 /* ---------- BLACKLIST ---------- */
 
 func (db *DB) BlacklistToken(token *BlacklistedToken) error {
@@ -150,6 +277,75 @@ func (db *DB) IsTokenBlacklisted(token string) (bool, error) {
 	return n > 0, err
 }
 
+/* ---------- REVOKED TOKENS ---------- */
+
+// RevokedTokensCollection holds the IDs of access tokens revoked before they
+// expire. catalog-service and checkout-service read it from this database
+// too, so every service turns a revoked token away.
+const RevokedTokensCollection = "revokedtokens"
+
+// RevokeTokenID records that access tokens carrying id (as their "jti" or
+// "sid" claim) are no longer accepted. The record goes once expiresAt has
+// passed and no such token can be valid anyway.
+func (db *DB) RevokeTokenID(id string, expiresAt time.Time) error {
+	_, err := db.revokedtokens.UpdateOne(context.Background(),
+		bson.M{"_id": id},
+		bson.M{"$max": bson.M{"expiresAt": expiresAt}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// IsRevoked reports whether tokens carrying id have been revoked.
+func (db *DB) IsRevoked(id string) (bool, error) {
+	n, err := db.revokedtokens.CountDocuments(context.Background(), bson.M{"_id": id}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+/* ---------- IMPERSONATIONS ---------- */
+
+func (db *DB) CreateImpersonation(imp *Impersonation) error {
+	_, err := db.impersonations.InsertOne(context.Background(), imp)
+	return err
+}
+
+// GetImpersonation returns a single impersonation record.
+func (db *DB) GetImpersonation(id primitive.ObjectID) (*Impersonation, error) {
+	var imp Impersonation
+	err := db.impersonations.FindOne(context.Background(), bson.M{"_id": id}).Decode(&imp)
+	return &imp, err
+}
+
+// EndImpersonation records when an impersonation was ended early.
+func (db *DB) EndImpersonation(id primitive.ObjectID, at time.Time) error {
+	_, err := db.impersonations.UpdateOne(context.Background(),
+		bson.M{"_id": id, "endedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"endedAt": at}},
+	)
+	return err
+}
+
+// GetImpersonations returns impersonation records matching the filter, newest first.
+func (db *DB) GetImpersonations(filter bson.M) ([]*Impersonation, error) {
+	opts := options.Find().SetSort(bson.M{"createdAt": -1})
+	cursor, err := db.impersonations.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var out []*Impersonation
+	err = cursor.All(context.Background(), &out)
+	return out, err
+}
+
+/* ---------- DATABASE ---------- */
+
+// Database exposes the underlying database for schema migrations.
+func (db *DB) Database() *mongo.Database {
+	return db.database
+}
+
 /* ---------- DISCONNECT ---------- */
 
 func (db *DB) Disconnect() {
@@ -0,0 +1,56 @@
+// Command migrate applies pending ProductService schema migrations.
+//
+//	go run ./cmd/migrate          # apply pending migrations
+//	go run ./cmd/migrate -status  # list applied and pending migrations
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"business-cart/catalog-service/internal/config"
+	"business-cart/catalog-service/internal/migrations"
+	"business-cart/catalog-service/internal/storage"
+)
+
+func main() {
+	status := flag.Bool("status", false, "list applied and pending migrations without applying them")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := storage.NewDB(cfg.MongoURI)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Disconnect()
+
+	ctx := context.Background()
+	if *status {
+		applied, err := migrations.Applied(ctx, db.Database())
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		for _, r := range applied {
+			fmt.Printf("applied  %3d  %s  (%s)\n", r.Version, r.Description, r.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		pending, err := migrations.Pending(ctx, db.Database())
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		for _, m := range pending {
+			fmt.Printf("pending  %3d  %s\n", m.Version, m.Description)
+		}
+		return
+	}
+
+	if err := migrations.Run(ctx, db.Database()); err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	fmt.Println("migrations up to date")
+}
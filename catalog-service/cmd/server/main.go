@@ -1,13 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"log"
+	"mime"
 	"net/http"
 	"strings"
+	"time"
 
 	"business-cart/catalog-service/internal/config"
+	"business-cart/catalog-service/internal/fx"
 	"business-cart/catalog-service/internal/handler"
+	"business-cart/catalog-service/internal/jobs"
+	"business-cart/catalog-service/internal/media"
+	"business-cart/catalog-service/internal/migrations"
 	"business-cart/catalog-service/internal/storage"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -16,7 +25,10 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-var chiRouter *chi.Mux
+var (
+	chiRouter *chi.Mux
+	h         *handler.Handler
+)
 
 func init() {
 	cfg, err := config.LoadConfig()
@@ -29,7 +41,55 @@ func init() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	h := handler.NewHandler(db, cfg.JWTSecret)
+	if cfg.RunMigrations {
+		if err := migrations.Run(context.Background(), db.Database()); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
+	var blobs media.BlobStore
+	switch cfg.BlobStore {
+	case "fs":
+		blobs = media.NewFSStore(cfg.MediaDir, cfg.MediaBaseURL)
+	case "s3":
+		blobs, err = media.NewS3Store(context.Background(), media.S3Config{
+			Bucket:          cfg.S3Bucket,
+			Region:          cfg.S3Region,
+			Endpoint:        cfg.S3Endpoint,
+			PublicURL:       cfg.S3PublicURL,
+			AccessKeyID:     cfg.AWSAccessKeyID,
+			SecretAccessKey: cfg.AWSSecretAccessKey,
+			SessionToken:    cfg.AWSSessionToken,
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up S3: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown BLOB_STORE %q", cfg.BlobStore)
+	}
+
+	var rates fx.Provider
+	switch {
+	case cfg.FXProvider == "static" && cfg.FXRatesFile != "":
+		if rates, err = fx.LoadStatic(cfg.FXRatesFile); err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+	case cfg.FXProvider == "static":
+		rates = fx.NewStatic(storage.DefaultCurrency, nil, time.Time{})
+	default:
+		log.Fatalf("Unknown FX_PROVIDER %q", cfg.FXProvider)
+	}
+
+	var imports jobs.Queue
+	if cfg.ImportFunction != "" {
+		queue, err := jobs.NewLambdaQueue(context.Background(), cfg.ImportFunction)
+		if err != nil {
+			log.Fatalf("Failed to set up the import queue: %v", err)
+		}
+		imports = queue
+	}
+
+	h = handler.NewHandler(db, blobs, rates, imports, cfg.JWTSecret)
 
 	chiRouter = chi.NewRouter()
 	chiRouter.Use(middleware.Logger)
@@ -37,10 +97,24 @@ func init() {
 }
 
 func adapter(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	httpRequest, err := http.NewRequest(req.HTTPMethod, req.Path, strings.NewReader(req.Body))
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return events.APIGatewayProxyResponse{StatusCode: 400}, nil
+		}
+		body = decoded
+	}
+	httpRequest, err := http.NewRequest(req.HTTPMethod, req.Path, bytes.NewReader(body))
 	if err != nil {
 		return events.APIGatewayProxyResponse{StatusCode: 500}, err
 	}
+	// Carry query string parameters over so handlers can read r.URL.Query()
+	query := httpRequest.URL.Query()
+	for key, value := range req.QueryStringParameters {
+		query.Set(key, value)
+	}
+	httpRequest.URL.RawQuery = query.Encode()
 	for key, value := range req.Headers {
 		httpRequest.Header.Set(key, value)
 	}
@@ -48,15 +122,42 @@ func adapter(ctx context.Context, req events.APIGatewayProxyRequest) (events.API
 	w := &responseRecorder{}
 	chiRouter.ServeHTTP(w, httpRequest)
 
-	return events.APIGatewayProxyResponse{
+	response := events.APIGatewayProxyResponse{
 		StatusCode:        w.code,
 		Body:              w.body.String(),
 		MultiValueHeaders: w.Header(),
-	}, nil
+	}
+	// Binary responses such as images must be base64 encoded for API Gateway
+	if !isText(w.Header().Get("Content-Type")) {
+		response.Body = base64.StdEncoding.EncodeToString([]byte(w.body.String()))
+		response.IsBase64Encoded = true
+	}
+	return response, nil
+}
+
+// dispatch runs import jobs the worker is invoked with and serves everything
+// else as an API Gateway request.
+func dispatch(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	var event jobs.Event
+	if err := json.Unmarshal(payload, &event); err == nil && event.ImportJobID != "" {
+		return nil, h.RunImport(event.ImportJobID)
+	}
+
+	var req events.APIGatewayProxyRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	return adapter(ctx, req)
+}
+
+func isText(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "" || strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" || mediaType == "application/x-ndjson"
 }
 
 func main() {
-	lambda.Start(adapter)
+	lambda.Start(dispatch)
 }
 
 type responseRecorder struct {
@@ -0,0 +1,246 @@
+// Package bulk reads product rows from CSV and JSON Lines uploads and writes
+// catalogs out in the same formats, so an export can be imported again.
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"business-cart/catalog-service/internal/storage"
+)
+
+// Formats.
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+// Row is one product of an upload as JSON product fields. Only the fields
+// present in the row are set. Err holds a problem reading the row itself.
+type Row struct {
+	Line   int
+	Fields map[string]interface{}
+	Err    error
+}
+
+// SKU returns the row's product SKU.
+func (r Row) SKU() string {
+	sku, _ := r.Fields["sku"].(string)
+	return strings.TrimSpace(sku)
+}
+
+// JSON returns the row fields as a JSON object.
+func (r Row) JSON() []byte {
+	raw, _ := json.Marshal(r.Fields)
+	return raw
+}
+
+// Column kinds.
+const (
+	text = iota
+	number
+	integer
+	timestamp // RFC 3339
+	object    // a JSON value, for composite fields
+)
+
+// Columns are the CSV columns in export order.
+var Columns = []string{
+	"sku", "name", "description", "price", "currency", "categoryId", "image",
+	"status", "publishAt", "unpublishAt",
+	"gtin", "upc", "unitOfMeasure", "packSize",
+	"weight", "dimensions", "quantityRules", "components", "priceTiers", "attributes", "variantOptions", "variants",
+}
+
+var columnKinds = map[string]int{
+	"sku": text, "name": text, "description": text, "price": number, "currency": text, "categoryId": text, "image": text,
+	"status": text, "publishAt": timestamp, "unpublishAt": timestamp,
+	"gtin": text, "upc": text, "unitOfMeasure": text, "packSize": integer,
+	"weight": object, "dimensions": object, "quantityRules": object, "components": object, "priceTiers": object, "attributes": object,
+	"variantOptions": object, "variants": object,
+}
+
+// readOnly fields are set by the service and ignored in uploads.
+var readOnly = []string{"_id", "sellerID", "images", "createdAt", "updatedAt", "customerPrice", "priceListId", "revision", "exchangeRate"}
+
+// ParseCSV reads a CSV upload with a header row naming the columns. Empty
+// cells leave the field unset. It fails only when the file itself cannot be
+// read; problems with single rows are reported on the row. Reading stops
+// after max+1 rows, enough for the caller to tell the file is too long.
+func ParseCSV(r io.Reader, max int) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if _, ok := columnKinds[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		header[i] = name
+	}
+
+	var rows []Row
+	for len(rows) <= max {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue // blank line
+		}
+		line, _ := reader.FieldPos(0)
+		row := Row{Line: line, Fields: map[string]interface{}{}}
+		if len(record) != len(header) {
+			row.Err = fmt.Errorf("expected %d columns, found %d", len(header), len(record))
+		}
+		for i, name := range header {
+			if i >= len(record) || row.Err != nil {
+				break
+			}
+			value, set, err := parseCell(name, record[i])
+			if err != nil {
+				row.Err = err
+			} else if set {
+				row.Fields[name] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseCell(column, cell string) (interface{}, bool, error) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return nil, false, nil
+	}
+	switch columnKinds[column] {
+	case number:
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s must be a number", column)
+		}
+		return f, true, nil
+	case integer:
+		n, err := strconv.Atoi(cell)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s must be a whole number", column)
+		}
+		return n, true, nil
+	case timestamp:
+		if _, err := time.Parse(time.RFC3339, cell); err != nil {
+			return nil, false, fmt.Errorf("%s must be an RFC 3339 time such as 2025-01-31T09:00:00Z", column)
+		}
+		return cell, true, nil
+	case object:
+		var v interface{}
+		if err := json.Unmarshal([]byte(cell), &v); err != nil {
+			return nil, false, fmt.Errorf("%s must be valid JSON", column)
+		}
+		return v, true, nil
+	}
+	return cell, true, nil
+}
+
+// ParseJSONL reads one product JSON object per line, as written by WriteJSONL.
+// Fields set by the service such as _id are ignored. Blank lines are skipped.
+// Reading stops after max+1 rows, as for ParseCSV.
+func ParseJSONL(r io.Reader, max int) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var rows []Row
+	for line := 1; len(rows) <= max && scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		row := Row{Line: line}
+		if err := json.Unmarshal(raw, &row.Fields); err != nil || row.Fields == nil {
+			row.Err = errors.New("invalid JSON object")
+			row.Fields = map[string]interface{}{}
+		}
+		for _, key := range readOnly {
+			delete(row.Fields, key)
+		}
+		for key := range row.Fields {
+			if _, ok := columnKinds[key]; !ok && row.Err == nil {
+				row.Err = fmt.Errorf("unknown field %q", key)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// WriteCSV writes products with a header row of Columns.
+func WriteCSV(w io.Writer, products []*storage.Product) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Columns); err != nil {
+		return err
+	}
+	for _, p := range products {
+		record := []string{
+			p.SKU, p.Name, p.Description, strconv.FormatFloat(p.Price, 'f', -1, 64), p.Currency, p.CategoryID, p.Image,
+			p.Status, timeCell(p.PublishAt), timeCell(p.UnpublishAt),
+			p.GTIN, p.UPC, p.UnitOfMeasure, "",
+			jsonCell(p.Weight), jsonCell(p.Dimensions), jsonCell(p.QuantityRules), jsonCell(p.Components), jsonCell(p.PriceTiers), jsonCell(p.Attributes),
+			jsonCell(p.VariantOptions), jsonCell(p.Variants),
+		}
+		if p.PackSize != 0 {
+			record[13] = strconv.Itoa(p.PackSize)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func timeCell(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// jsonCell encodes a composite field, leaving empty values blank.
+func jsonCell(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	switch string(raw) {
+	case "null", "[]", "{}":
+		return ""
+	}
+	return string(raw)
+}
+
+// WriteJSONL writes one product JSON object per line.
+func WriteJSONL(w io.Writer, products []*storage.Product) error {
+	encoder := json.NewEncoder(w)
+	for _, p := range products {
+		if err := encoder.Encode(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package bulk
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// wantRow is a parsed row with its error, if any, as text.
+type wantRow struct {
+	line   int
+	fields map[string]interface{}
+	err    string
+}
+
+func checkRows(t *testing.T, got []Row, want []wantRow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows %+v, want %d", len(got), got, len(want))
+	}
+	for i, row := range got {
+		errText := ""
+		if row.Err != nil {
+			errText = row.Err.Error()
+		}
+		if row.Line != want[i].line || errText != want[i].err {
+			t.Errorf("row %d: line %d with error %q, want line %d with error %q", i, row.Line, errText, want[i].line, want[i].err)
+		}
+		if want[i].fields != nil && !reflect.DeepEqual(row.Fields, want[i].fields) {
+			t.Errorf("row %d: fields = %#v, want %#v", i, row.Fields, want[i].fields)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		max     int
+		want    []wantRow
+		wantErr string
+	}{
+		{
+			name:  "typed cells",
+			input: "sku,name,price,packSize,publishAt,priceTiers\nA-1,Widget,9.99,12,2025-01-31T09:00:00Z,\"[{\"\"minQuantity\"\":10,\"\"price\"\":9}]\"\n",
+			max:   10,
+			want: []wantRow{{line: 2, fields: map[string]interface{}{
+				"sku":        "A-1",
+				"name":       "Widget",
+				"price":      9.99,
+				"packSize":   12,
+				"publishAt":  "2025-01-31T09:00:00Z",
+				"priceTiers": []interface{}{map[string]interface{}{"minQuantity": float64(10), "price": float64(9)}},
+			}}},
+		},
+		{
+			name:  "empty cells leave fields unset",
+			input: "sku,name,price\nA-1, ,\n",
+			max:   10,
+			want:  []wantRow{{line: 2, fields: map[string]interface{}{"sku": "A-1"}}},
+		},
+		{
+			name:  "byte order mark and blank lines",
+			input: "\ufeffsku,name\nA-1,Widget\n\nA-2,Gadget\n",
+			max:   10,
+			want: []wantRow{
+				{line: 2, fields: map[string]interface{}{"sku": "A-1", "name": "Widget"}},
+				{line: 4, fields: map[string]interface{}{"sku": "A-2", "name": "Gadget"}},
+			},
+		},
+		{
+			name:  "row errors",
+			input: "sku,price,packSize,publishAt,attributes\nA-1,cheap,,,\nA-2,1,1.5,,\nA-3,1,1,31/01/2025,\nA-4,1,1,,\"{\"\"color\"\":\"\nA-5,1\nA-6,1,1,,\n",
+			max:   10,
+			want: []wantRow{
+				{line: 2, err: "price must be a number"},
+				{line: 3, err: "packSize must be a whole number"},
+				{line: 4, err: "publishAt must be an RFC 3339 time such as 2025-01-31T09:00:00Z"},
+				{line: 5, err: "attributes must be valid JSON"},
+				{line: 6, err: "expected 5 columns, found 2"},
+				{line: 7, fields: map[string]interface{}{"sku": "A-6", "price": float64(1), "packSize": 1}},
+			},
+		},
+		{
+			name:  "stops after max+1 rows",
+			input: "sku\nA-1\nA-2\nA-3\nA-4\n",
+			max:   2,
+			want:  []wantRow{{line: 2}, {line: 3}, {line: 4}},
+		},
+		{name: "unknown column", input: "sku,colour\nA-1,red\n", max: 10, wantErr: `unknown column "colour"`},
+		{name: "empty file", input: "", max: 10, wantErr: "the file is empty"},
+		{name: "read-only column", input: "sku,_id\nA-1,x\n", max: 10, wantErr: `unknown column "_id"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ParseCSV(strings.NewReader(tt.input), tt.max)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseCSV() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRows(t, rows, tt.want)
+		})
+	}
+}
+
+func TestParseJSONL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		max   int
+		want  []wantRow
+	}{
+		{
+			name:  "fields as sent",
+			input: `{"sku":"A-1","price":9.99,"packSize":12,"variants":[{"id":"red"}]}`,
+			max:   10,
+			want: []wantRow{{line: 1, fields: map[string]interface{}{
+				"sku":      "A-1",
+				"price":    9.99,
+				"packSize": float64(12),
+				"variants": []interface{}{map[string]interface{}{"id": "red"}},
+			}}},
+		},
+		{
+			name:  "read-only fields are dropped",
+			input: `{"_id":"66f1a2b3c4d5e6f708192a3b","sellerID":"seller-1","revision":4,"sku":"A-1"}`,
+			max:   10,
+			want:  []wantRow{{line: 1, fields: map[string]interface{}{"sku": "A-1"}}},
+		},
+		{
+			name:  "blank lines keep their numbers",
+			input: "{\"sku\":\"A-1\"}\n\n  \n{\"sku\":\"A-2\"}\n",
+			max:   10,
+			want:  []wantRow{{line: 1}, {line: 4}},
+		},
+		{
+			name:  "row errors",
+			input: "{\"sku\":\n[1,2]\nnull\n{\"sku\":\"A-4\",\"colour\":\"red\"}\n{\"sku\":\"A-5\"}\n",
+			max:   10,
+			want: []wantRow{
+				{line: 1, err: "invalid JSON object"},
+				{line: 2, err: "invalid JSON object"},
+				{line: 3, err: "invalid JSON object"},
+				{line: 4, err: `unknown field "colour"`},
+				{line: 5, fields: map[string]interface{}{"sku": "A-5"}},
+			},
+		},
+		{
+			name:  "stops after max+1 rows",
+			input: "{\"sku\":\"A-1\"}\n{\"sku\":\"A-2\"}\n{\"sku\":\"A-3\"}\n",
+			max:   1,
+			want:  []wantRow{{line: 1}, {line: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ParseJSONL(strings.NewReader(tt.input), tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRows(t, rows, tt.want)
+		})
+	}
+}
+
+func TestExportImportsAgain(t *testing.T) {
+	publishAt := time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)
+	product := &storage.Product{
+		ID:         primitive.NewObjectID(),
+		SKU:        "A-1",
+		Name:       "Widget, large",
+		Price:      9.99,
+		Currency:   "USD",
+		SellerID:   "seller-1",
+		Status:     "active",
+		PublishAt:  &publishAt,
+		PackSize:   12,
+		PriceTiers: []storage.PriceTier{{MinQuantity: 10, Price: 9}},
+	}
+	for format, write := range map[string]func(*bytes.Buffer) error{
+		CSV:   func(b *bytes.Buffer) error { return WriteCSV(b, []*storage.Product{product}) },
+		JSONL: func(b *bytes.Buffer) error { return WriteJSONL(b, []*storage.Product{product}) },
+	} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			parse := ParseCSV
+			if format == JSONL {
+				parse = ParseJSONL
+			}
+			rows, err := parse(&buf, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 || rows[0].Err != nil {
+				t.Fatalf("rows = %+v, want one row without errors", rows)
+			}
+			row := rows[0]
+			if row.SKU() != "A-1" || row.Fields["name"] != "Widget, large" || row.Fields["price"] != 9.99 || row.Fields["publishAt"] != "2025-01-31T09:00:00Z" {
+				t.Errorf("fields = %#v", row.Fields)
+			}
+			if _, ok := row.Fields["sellerID"]; ok {
+				t.Error("sellerID was imported")
+			}
+		})
+	}
+}
@@ -10,6 +10,30 @@ type Config struct {
 	MongoURI         string
 	JWTSecret        string
 	JWTRefreshSecret string
+	RunMigrations    bool
+
+	// Media storage: "fs" (the default) keeps files under MediaDir and
+	// serves them at MediaBaseURL; "s3" uses the S3 bucket settings.
+	BlobStore    string
+	MediaDir     string
+	MediaBaseURL string
+	S3Bucket     string
+	S3Region     string
+	S3Endpoint   string
+	S3PublicURL  string
+
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+
+	// Exchange rates: "static" (the default) reads fixed rates from
+	// FXRatesFile; without one only same-currency prices can be shown.
+	FXProvider  string
+	FXRatesFile string
+
+	// ImportFunction is the Lambda function that runs import jobs, invoked
+	// asynchronously. Without one, imports run before the upload is answered.
+	ImportFunction string
 }
 
 func LoadConfig() (*Config, error) {
@@ -23,5 +47,30 @@ func LoadConfig() (*Config, error) {
 		MongoURI:         os.Getenv("MONGO_URI"),
 		JWTSecret:        os.Getenv("JWT_SECRET"),
 		JWTRefreshSecret: os.Getenv("JWT_REFRESH_SECRET"),
+		RunMigrations:    os.Getenv("RUN_MIGRATIONS") == "true",
+
+		BlobStore:    getEnv("BLOB_STORE", "fs"),
+		MediaDir:     getEnv("MEDIA_DIR", "./media"),
+		MediaBaseURL: getEnv("MEDIA_BASE_URL", "http://localhost:3001/media"),
+		S3Bucket:     os.Getenv("S3_BUCKET"),
+		S3Region:     getEnv("S3_REGION", os.Getenv("AWS_REGION")),
+		S3Endpoint:   os.Getenv("S3_ENDPOINT"),
+		S3PublicURL:  os.Getenv("S3_PUBLIC_URL"),
+
+		AWSAccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		AWSSecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		AWSSessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+
+		FXProvider:  getEnv("FX_PROVIDER", "static"),
+		FXRatesFile: os.Getenv("FX_RATES_FILE"),
+
+		ImportFunction: os.Getenv("IMPORT_FUNCTION"),
 	}, nil
 }
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
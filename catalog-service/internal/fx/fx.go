@@ -0,0 +1,70 @@
+// Package fx converts prices between currencies using exchange rates from a
+// pluggable provider.
+package fx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+var ErrNoRate = errors.New("no exchange rate")
+
+// Provider quotes exchange rates between ISO 4217 currency codes.
+type Provider interface {
+	// Rate returns how many units of to one unit of from buys. It fails
+	// with ErrNoRate when the provider does not quote the pair.
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+// Rate is an exchange rate as quoted at a point in time. Prices converted
+// with it keep a copy, so they can be explained after rates move.
+type Rate struct {
+	From   string    `bson:"from" json:"from"`
+	To     string    `bson:"to" json:"to"`
+	Rate   float64   `bson:"rate" json:"rate"`
+	AsOf   time.Time `bson:"asOf" json:"asOf"`
+	Source string    `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+// Convert converts an amount in From to To, rounded to To's minor unit.
+func (r Rate) Convert(amount float64) float64 {
+	return Round(amount*r.Rate, r.To)
+}
+
+// minorUnits lists the ISO 4217 currencies without two decimal places.
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorUnits returns the number of decimal places of a currency: 2 unless
+// the currency is known to use another.
+func MinorUnits(code string) int {
+	if n, ok := minorUnits[code]; ok {
+		return n
+	}
+	return 2
+}
+
+// Round rounds an amount to the minor unit of its currency.
+func Round(amount float64, code string) float64 {
+	scale := math.Pow10(MinorUnits(code))
+	return math.Round(amount*scale) / scale
+}
+
+// ValidCode reports whether code looks like an ISO 4217 currency code:
+// three upper-case letters.
+func ValidCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		rate   Rate
+		amount float64
+		want   float64
+	}{
+		{"same currency", Rate{From: "USD", To: "USD", Rate: 1}, 19.99, 19.99},
+		{"rounds to cents", Rate{From: "USD", To: "EUR", Rate: 0.92}, 10.99, 10.11},
+		{"rounds half up", Rate{From: "USD", To: "EUR", Rate: 0.5}, 0.05, 0.03},
+		{"zero amount", Rate{From: "USD", To: "GBP", Rate: 0.79}, 0, 0},
+		{"zero-decimal target", Rate{From: "USD", To: "JPY", Rate: 151.37}, 10.99, 1664},
+		{"zero-decimal source", Rate{From: "JPY", To: "USD", Rate: 1 / 151.37}, 1664, 10.99},
+		{"three-decimal target", Rate{From: "USD", To: "KWD", Rate: 0.3071}, 10.99, 3.375},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rate.Convert(tt.amount); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Convert(%v) = %v, want %v", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		code   string
+		amount float64
+		want   float64
+	}{
+		{"USD", 1.006, 1.01},
+		{"EUR", 2.344, 2.34},
+		{"JPY", 1499.5, 1500},
+		{"JPY", 1499.49, 1499},
+		{"KRW", 12345.678, 12346},
+		{"CLP", 0.4, 0},
+		{"BHD", 1.2345, 1.235},
+		{"XXX", 3.456, 3.46}, // unlisted currencies use two decimals
+	}
+	for _, tt := range tests {
+		if got := Round(tt.amount, tt.code); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Round(%v, %s) = %v, want %v", tt.amount, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestStaticRate(t *testing.T) {
+	asOf := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	provider := NewStatic("USD", map[string]float64{"EUR": 0.8, "GBP": 0.5, "JPY": 150}, asOf)
+
+	tests := []struct {
+		from, to string
+		want     float64
+	}{
+		{"USD", "USD", 1},
+		{"EUR", "EUR", 1},
+		{"USD", "EUR", 0.8},
+		{"EUR", "USD", 1.25},
+		{"EUR", "GBP", 0.625},
+		{"GBP", "JPY", 300},
+	}
+	for _, tt := range tests {
+		rate, err := provider.Rate(context.Background(), tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("Rate(%s, %s): %v", tt.from, tt.to, err)
+		}
+		if math.Abs(rate.Rate-tt.want) > 1e-9 {
+			t.Errorf("Rate(%s, %s) = %v, want %v", tt.from, tt.to, rate.Rate, tt.want)
+		}
+		if rate.From != tt.from || rate.To != tt.to || !rate.AsOf.Equal(asOf) || rate.Source != "static" {
+			t.Errorf("Rate(%s, %s) = %+v, want it stamped with the pair, asOf and source", tt.from, tt.to, rate)
+		}
+	}
+}
+
+func TestStaticRateUnknownCurrency(t *testing.T) {
+	provider := NewStatic("USD", map[string]float64{"EUR": 0.8}, time.Now())
+
+	tests := []struct{ from, to string }{
+		{"USD", "CHF"},
+		{"CHF", "USD"},
+		{"CHF", "EUR"},
+		{"CHF", "SEK"},
+	}
+	for _, tt := range tests {
+		if _, err := provider.Rate(context.Background(), tt.from, tt.to); !errors.Is(err, ErrNoRate) {
+			t.Errorf("Rate(%s, %s) error = %v, want ErrNoRate", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestLoadStatic(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"valid", `{"base": "USD", "asOf": "2026-01-02T00:00:00Z", "rates": {"EUR": 0.92, "JPY": 151.37}}`, false},
+		{"invalid base", `{"base": "usd", "rates": {"EUR": 0.92}}`, true},
+		{"invalid code", `{"base": "USD", "rates": {"EURO": 0.92}}`, true},
+		{"non-positive rate", `{"base": "USD", "rates": {"EUR": 0}}`, true},
+		{"not JSON", `USD EUR 0.92`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rates.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadStatic(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadStatic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidCode(t *testing.T) {
+	for code, want := range map[string]bool{"USD": true, "JPY": true, "usd": false, "US": false, "USDX": false, "U$D": false, "": false} {
+		if got := ValidCode(code); got != want {
+			t.Errorf("ValidCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Static quotes fixed rates against a base currency, cross rates included.
+// It suits development, tests and sellers who set their own rates.
+type Static struct {
+	base   string
+	rates  map[string]float64 // units of each currency per unit of base
+	asOf   time.Time
+	source string
+}
+
+// StaticFile is the format LoadStatic reads:
+//
+//	{"base": "USD", "asOf": "2026-01-02T00:00:00Z", "rates": {"EUR": 0.92, "GBP": 0.79}}
+type StaticFile struct {
+	Base  string             `json:"base"`
+	AsOf  time.Time          `json:"asOf"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// NewStatic creates a provider quoting rates, in units per unit of base.
+func NewStatic(base string, rates map[string]float64, asOf time.Time) *Static {
+	return &Static{base: base, rates: rates, asOf: asOf, source: "static"}
+}
+
+// LoadStatic reads a StaticFile from path.
+func LoadStatic(path string) (*Static, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f StaticFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("exchange rates %s: %w", path, err)
+	}
+	if !ValidCode(f.Base) {
+		return nil, fmt.Errorf("exchange rates %s: invalid base currency %q", path, f.Base)
+	}
+	for code, rate := range f.Rates {
+		if !ValidCode(code) || rate <= 0 {
+			return nil, fmt.Errorf("exchange rates %s: invalid rate for %q", path, code)
+		}
+	}
+	return NewStatic(f.Base, f.Rates, f.AsOf), nil
+}
+
+func (s *Static) Rate(ctx context.Context, from, to string) (Rate, error) {
+	if from == to {
+		return Rate{From: from, To: to, Rate: 1, AsOf: s.asOf, Source: s.source}, nil
+	}
+	fromRate, ok := s.perBase(from)
+	if !ok {
+		return Rate{}, fmt.Errorf("%w from %s to %s", ErrNoRate, from, to)
+	}
+	toRate, ok := s.perBase(to)
+	if !ok {
+		return Rate{}, fmt.Errorf("%w from %s to %s", ErrNoRate, from, to)
+	}
+	return Rate{From: from, To: to, Rate: toRate / fromRate, AsOf: s.asOf, Source: s.source}, nil
+}
+
+func (s *Static) perBase(code string) (float64, bool) {
+	if code == s.base {
+		return 1, true
+	}
+	rate, ok := s.rates[code]
+	return rate, ok
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errComponentLookup = errors.New("failed to look up bundle components")
+
+// validateComponents checks that a bundle is made of other products of its
+// seller, that none of them is a bundle, and that a component names a variant
+// exactly when its product has variants. A product already used as a
+// component cannot become a bundle itself.
+func (h *Handler) validateComponents(product *storage.Product) error {
+	if !product.IsBundle() {
+		return nil
+	}
+	var ids []primitive.ObjectID
+	for _, c := range product.Components {
+		id, err := primitive.ObjectIDFromHex(c.ProductID)
+		if err != nil {
+			return fmt.Errorf("component %s is not a product of this seller", c.ProductID)
+		}
+		ids = append(ids, id)
+	}
+	components, err := h.db.GetProducts(bson.M{"_id": bson.M{"$in": ids}, "sellerID": product.SellerID})
+	if err != nil {
+		return errComponentLookup
+	}
+	byID := map[string]*storage.Product{}
+	for _, p := range components {
+		byID[p.ID.Hex()] = p
+	}
+	for _, c := range product.Components {
+		p, ok := byID[c.ProductID]
+		switch {
+		case !ok:
+			return fmt.Errorf("component %s is not a product of this seller", c.ProductID)
+		case p.IsBundle():
+			return fmt.Errorf("component %s is a bundle; bundles cannot be nested", c.ProductID)
+		case c.VariantID != "" && p.FindVariant(c.VariantID) == nil:
+			return fmt.Errorf("variant %s not found on component %s", c.VariantID, c.ProductID)
+		case c.VariantID == "" && len(p.Variants) > 0:
+			return fmt.Errorf("component %s has variants; name one with variantId", c.ProductID)
+		}
+	}
+
+	if !product.ID.IsZero() {
+		containing, err := h.db.GetProducts(bson.M{"components.productId": product.ID.Hex()})
+		if err != nil {
+			return errComponentLookup
+		}
+		if len(containing) > 0 {
+			return errors.New("a component of a bundle cannot be a bundle itself")
+		}
+	}
+	return nil
+}
+
+// checkComponents is validateComponents for handlers: it writes the error
+// and returns false when the components are invalid.
+func (h *Handler) checkComponents(w http.ResponseWriter, product *storage.Product) bool {
+	err := h.validateComponents(product)
+	if errors.Is(err, errComponentLookup) {
+		http.Error(w, "Failed to retrieve bundle components", http.StatusInternalServerError)
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// bundleStock derives a bundle's available stock from its components': at
+// each warehouse, the number of whole bundles the components there make up.
+// Untracked components never limit a bundle, and a bundle made only of
+// untracked components is untracked itself.
+func bundleStock(bundle *storage.Product, levels []*storage.StockLevel, active map[string]bool) []Availability {
+	type key struct{ productID, variantID string }
+	available := map[key]map[string]int{} // component -> warehouse -> available
+	warehouses := map[string]bool{}
+	for _, c := range bundle.Components {
+		available[key{c.ProductID, c.VariantID}] = nil
+	}
+	for _, l := range levels {
+		k := key{l.ProductID, l.VariantID}
+		byWarehouse, ok := available[k]
+		if !ok {
+			continue
+		}
+		if byWarehouse == nil {
+			byWarehouse = map[string]int{}
+			available[k] = byWarehouse
+		}
+		if active[l.WarehouseID] {
+			byWarehouse[l.WarehouseID] = l.Available
+		}
+		warehouses[l.WarehouseID] = true
+	}
+
+	var ids []string
+	for wh := range warehouses {
+		ids = append(ids, wh)
+	}
+	sort.Strings(ids)
+
+	var stock []Availability
+	for _, wh := range ids {
+		n := math.MaxInt
+		for _, c := range bundle.Components {
+			byWarehouse := available[key{c.ProductID, c.VariantID}]
+			if byWarehouse == nil {
+				continue // untracked
+			}
+			if made := byWarehouse[wh] / c.Quantity; made < n {
+				n = made
+			}
+		}
+		stock = append(stock, Availability{ProductID: bundle.ID.Hex(), WarehouseID: wh, Available: max(n, 0)})
+	}
+	return stock
+}
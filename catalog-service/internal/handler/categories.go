@@ -0,0 +1,414 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errCategoryLookup marks a failure to load categories, as opposed to a
+// product that does not fit its category.
+var errCategoryLookup = errors.New("failed to retrieve category")
+
+type categoryRequest struct {
+	Name       string                        `json:"name"`
+	ParentID   string                        `json:"parentId"`
+	Attributes []storage.AttributeDefinition `json:"attributes"`
+}
+
+// CreateCategory adds a category to the caller's tree: the seller's own for
+// companies, the platform-wide tree for admins.
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := categoryOwner(w, r)
+	if !ok {
+		return
+	}
+	var req categoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	category := storage.Category{
+		SellerID:   sellerID,
+		Name:       req.Name,
+		Path:       []string{},
+		Attributes: req.Attributes,
+	}
+	if category.Attributes == nil {
+		category.Attributes = []storage.AttributeDefinition{}
+	}
+	if err := category.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ParentID != "" {
+		parent, ok := h.parentCategory(w, req.ParentID, sellerID)
+		if !ok {
+			return
+		}
+		category.ParentID = req.ParentID
+		category.Path = append(append(category.Path, parent.Path...), req.ParentID)
+	}
+
+	if err := h.db.CreateCategory(&category); err != nil {
+		http.Error(w, "Failed to create category", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// GetCategories lists the platform categories and those of the sellers the
+// caller may see: their own for companies, linked sellers for customers, or
+// the seller in sellerId.
+func (h *Handler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	sellerID := r.URL.Query().Get("sellerId")
+	sellerIDs := []string{""}
+	switch {
+	case sellerID != "":
+		if !canBuyFrom(userClaims, sellerID) {
+			http.Error(w, "Unauthorized access to seller", http.StatusForbidden)
+			return
+		}
+		sellerIDs = append(sellerIDs, sellerID)
+	case userClaims["role"] == "company":
+		sellerIDs = append(sellerIDs, userClaims["id"].(string))
+	case userClaims["role"] == "customer":
+		ids, _ := userClaims["associate_company_ids"].([]interface{})
+		for _, id := range ids {
+			sellerIDs = append(sellerIDs, id.(string))
+		}
+	}
+
+	categories, err := h.db.GetCategories(sellerIDs)
+	if err != nil {
+		http.Error(w, "Failed to retrieve categories", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(categories)
+}
+
+func (h *Handler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.visibleCategory(w, r)
+	if !ok {
+		return
+	}
+	crumbs, err := h.breadcrumbs([]string{category.ID.Hex()})
+	if err != nil {
+		http.Error(w, "Failed to retrieve categories", http.StatusInternalServerError)
+		return
+	}
+	category.Breadcrumbs = crumbs[category.ID.Hex()]
+	json.NewEncoder(w).Encode(category)
+}
+
+// UpdateCategory renames the category or replaces its attribute schema.
+// Products already in the category are checked against the new schema when
+// they are next saved.
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.ownedCategory(w, r)
+	if !ok {
+		return
+	}
+	var req categoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name != "" {
+		category.Name = req.Name
+	}
+	if req.Attributes != nil {
+		category.Attributes = req.Attributes
+	}
+	if err := category.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UpdateCategory(category); err != nil {
+		http.Error(w, "Failed to update category", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(category)
+}
+
+type moveCategoryRequest struct {
+	ParentID string `json:"parentId"`
+	Position int    `json:"position"`
+}
+
+// MoveCategory moves a category, with its subtree, under another parent of
+// the same tree, or to the root when parentId is empty, at position among
+// the new siblings. Moving within the same parent reorders it.
+func (h *Handler) MoveCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.ownedCategory(w, r)
+	if !ok {
+		return
+	}
+	var req moveCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Position < 0 {
+		http.Error(w, "position must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	var parent *storage.Category
+	if req.ParentID != "" {
+		if parent, ok = h.parentCategory(w, req.ParentID, category.SellerID); !ok {
+			return
+		}
+		if parent.ID == category.ID || slices.Contains(parent.Path, category.ID.Hex()) {
+			http.Error(w, "A category cannot be moved below itself", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.db.MoveCategory(category, parent, req.Position); err != nil {
+		http.Error(w, "Failed to move category", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(category)
+}
+
+// DeleteCategory removes a category without subcategories or products.
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.ownedCategory(w, r)
+	if !ok {
+		return
+	}
+	inUse, err := h.db.CategoryInUse(category.ID.Hex())
+	if err != nil {
+		http.Error(w, "Failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	if inUse {
+		http.Error(w, "Category still has subcategories or products", http.StatusConflict)
+		return
+	}
+
+	if err := h.db.DeleteCategory(category); err != nil {
+		http.Error(w, "Failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// categoryOwner returns the tree a caller manages: their own for companies,
+// the platform tree ("") for admins.
+func categoryOwner(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	switch userClaims["role"] {
+	case "company":
+		return userClaims["id"].(string), true
+	case "admin":
+		return "", true
+	}
+	http.Error(w, "Unauthorized: Company or admin role required", http.StatusForbidden)
+	return "", false
+}
+
+func (h *Handler) loadCategory(w http.ResponseWriter, r *http.Request) (*storage.Category, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+	category, err := h.db.GetCategoryByID(id)
+	if err != nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return nil, false
+	}
+	return category, true
+}
+
+// ownedCategory loads a category of the tree the caller manages.
+func (h *Handler) ownedCategory(w http.ResponseWriter, r *http.Request) (*storage.Category, bool) {
+	sellerID, ok := categoryOwner(w, r)
+	if !ok {
+		return nil, false
+	}
+	category, ok := h.loadCategory(w, r)
+	if !ok {
+		return nil, false
+	}
+	if category.SellerID != sellerID {
+		http.Error(w, "Unauthorized access to category", http.StatusForbidden)
+		return nil, false
+	}
+	return category, true
+}
+
+// visibleCategory loads a platform category or one of a seller the caller
+// may see.
+func (h *Handler) visibleCategory(w http.ResponseWriter, r *http.Request) (*storage.Category, bool) {
+	category, ok := h.loadCategory(w, r)
+	if !ok {
+		return nil, false
+	}
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if category.SellerID != "" && !canBuyFrom(userClaims, category.SellerID) {
+		http.Error(w, "Unauthorized access to category", http.StatusForbidden)
+		return nil, false
+	}
+	return category, true
+}
+
+// parentCategory loads the would-be parent of a category in sellerID's tree.
+func (h *Handler) parentCategory(w http.ResponseWriter, parentID, sellerID string) (*storage.Category, bool) {
+	id, err := primitive.ObjectIDFromHex(parentID)
+	if err != nil {
+		http.Error(w, "parentId is not a category", http.StatusBadRequest)
+		return nil, false
+	}
+	parent, err := h.db.GetCategoryByID(id)
+	if err == mongo.ErrNoDocuments || err == nil && parent.SellerID != sellerID {
+		http.Error(w, "parentId is not a category of this tree", http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve category", http.StatusInternalServerError)
+		return nil, false
+	}
+	return parent, true
+}
+
+// validateCategory checks that the product's category is one of the seller's
+// or a platform category, and that its attributes fit the attribute schemas
+// of the category and its ancestors. A deeper category's definition of an
+// attribute overrides its ancestors'. Lookup failures are errCategoryLookup.
+func (h *Handler) validateCategory(product *storage.Product) error {
+	if product.CategoryID == "" {
+		return nil
+	}
+	invalid := errors.New("categoryId is not a category of this seller")
+	id, err := primitive.ObjectIDFromHex(product.CategoryID)
+	if err != nil {
+		return invalid
+	}
+	category, err := h.db.GetCategoryByID(id)
+	if err == mongo.ErrNoDocuments {
+		return invalid
+	}
+	if err != nil {
+		return errCategoryLookup
+	}
+	if category.SellerID != "" && category.SellerID != product.SellerID {
+		return invalid
+	}
+
+	ancestors, err := h.db.GetCategoriesByIDs(category.Path)
+	if err != nil {
+		return errCategoryLookup
+	}
+	byID := map[string]*storage.Category{}
+	for _, a := range ancestors {
+		byID[a.ID.Hex()] = a
+	}
+	definitions := map[string]storage.AttributeDefinition{}
+	var names []string
+	for _, c := range append(pathCategories(category.Path, byID), category) {
+		for _, d := range c.Attributes {
+			if _, ok := definitions[d.Name]; !ok {
+				names = append(names, d.Name)
+			}
+			definitions[d.Name] = d
+		}
+	}
+	schema := make([]storage.AttributeDefinition, len(names))
+	for i, name := range names {
+		schema[i] = definitions[name]
+	}
+	return product.ValidateAttributes(schema)
+}
+
+// checkCategory is validateCategory for handlers, writing the error.
+func (h *Handler) checkCategory(w http.ResponseWriter, product *storage.Product) bool {
+	err := h.validateCategory(product)
+	if errors.Is(err, errCategoryLookup) {
+		http.Error(w, "Failed to retrieve category", http.StatusInternalServerError)
+		return false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// addBreadcrumbs fills in the category path of each product.
+func (h *Handler) addBreadcrumbs(products []*storage.Product) error {
+	var ids []string
+	for _, p := range products {
+		if p.CategoryID != "" {
+			ids = append(ids, p.CategoryID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	crumbs, err := h.breadcrumbs(ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		p.Breadcrumbs = crumbs[p.CategoryID]
+	}
+	return nil
+}
+
+// breadcrumbs returns the path from the root to each of the categories,
+// including the category itself, by category ID.
+func (h *Handler) breadcrumbs(ids []string) (map[string][]storage.Breadcrumb, error) {
+	categories, err := h.db.GetCategoriesByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]*storage.Category{}
+	var ancestorIDs []string
+	for _, c := range categories {
+		byID[c.ID.Hex()] = c
+		ancestorIDs = append(ancestorIDs, c.Path...)
+	}
+	ancestors, err := h.db.GetCategoriesByIDs(ancestorIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range ancestors {
+		byID[c.ID.Hex()] = c
+	}
+
+	crumbs := map[string][]storage.Breadcrumb{}
+	for _, c := range categories {
+		var path []storage.Breadcrumb
+		for _, node := range append(pathCategories(c.Path, byID), c) {
+			path = append(path, storage.Breadcrumb{ID: node.ID.Hex(), Name: node.Name})
+		}
+		crumbs[c.ID.Hex()] = path
+	}
+	return crumbs, nil
+}
+
+// pathCategories resolves the ancestor IDs of a path, skipping missing ones.
+func pathCategories(path []string, byID map[string]*storage.Category) []*storage.Category {
+	var categories []*storage.Category
+	for _, id := range path {
+		if c, ok := byID[id]; ok {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"business-cart/catalog-service/internal/fx"
+	"business-cart/catalog-service/internal/storage"
+)
+
+// displayCurrency returns the currency asked for with ?currency=, or "" to
+// show each product in its own. It writes a 400 and returns false when the
+// code is invalid.
+func displayCurrency(w http.ResponseWriter, r *http.Request) (string, bool) {
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency != "" && !fx.ValidCode(currency) {
+		http.Error(w, "currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+		return "", false
+	}
+	return currency, true
+}
+
+// GetExchangeRate returns the rate between ?from= and ?to= as the provider
+// quotes it now. Checkout uses it to restate amounts in the seller's currency.
+func (h *Handler) GetExchangeRate(w http.ResponseWriter, r *http.Request) {
+	from := strings.ToUpper(r.URL.Query().Get("from"))
+	to := strings.ToUpper(r.URL.Query().Get("to"))
+	if !fx.ValidCode(from) || !fx.ValidCode(to) {
+		http.Error(w, "from and to must be three-letter ISO 4217 codes", http.StatusBadRequest)
+		return
+	}
+	rate, err := h.rates.Rate(r.Context(), from, to)
+	if errors.Is(err, fx.ErrNoRate) {
+		http.Error(w, "No exchange rate from "+from+" to "+to, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get exchange rate", http.StatusBadGateway)
+		return
+	}
+	json.NewEncoder(w).Encode(rate)
+}
+
+// convertProducts restates every price on the products in currency, fetching
+// each rate once. Converted products carry the rate they were converted at.
+func (h *Handler) convertProducts(ctx context.Context, products []*storage.Product, currency string) error {
+	if currency == "" {
+		return nil
+	}
+	rates := map[string]fx.Rate{}
+	for _, p := range products {
+		if p.Currency == currency {
+			continue
+		}
+		rate, ok := rates[p.Currency]
+		if !ok {
+			var err error
+			if rate, err = h.rates.Rate(ctx, p.Currency, currency); err != nil {
+				return err
+			}
+			rates[p.Currency] = rate
+		}
+		convertProduct(p, rate)
+	}
+	return nil
+}
+
+func convertProduct(p *storage.Product, rate fx.Rate) {
+	p.Price = rate.Convert(p.Price)
+	p.CustomerPrice = convertAmount(p.CustomerPrice, rate)
+	p.PriceTiers = convertTiers(p.PriceTiers, rate)
+	for i := range p.Variants {
+		v := &p.Variants[i]
+		v.Price = convertAmount(v.Price, rate)
+		v.CustomerPrice = convertAmount(v.CustomerPrice, rate)
+		v.PriceTiers = convertTiers(v.PriceTiers, rate)
+	}
+	p.Currency = rate.To
+	p.ExchangeRate = &rate
+}
+
+func convertAmount(amount *float64, rate fx.Rate) *float64 {
+	if amount == nil {
+		return nil
+	}
+	converted := rate.Convert(*amount)
+	return &converted
+}
+
+func convertTiers(tiers []storage.PriceTier, rate fx.Rate) []storage.PriceTier {
+	if len(tiers) == 0 {
+		return tiers
+	}
+	converted := make([]storage.PriceTier, len(tiers))
+	for i, t := range tiers {
+		t.Price = rate.Convert(t.Price)
+		converted[i] = t
+	}
+	return converted
+}
+
+// conversionError answers a failed convertProducts call. A currency the rate
+// provider does not quote is the caller's problem.
+func conversionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fx.ErrNoRate) {
+		http.Error(w, "No exchange rate for the requested currency", http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Failed to get exchange rates", http.StatusBadGateway)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetProductHistory lists a product's revisions, newest first, with the
+// fields each one changed. The history outlives the product, so disputes
+// about deleted products can still be settled. (Owner or admin).
+func (h *Handler) GetProductHistory(w http.ResponseWriter, r *http.Request) {
+	productID, ok := productIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.db.GetProductRevisions(productID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve product history", http.StatusInternalServerError)
+		return
+	}
+	if len(revisions) == 0 {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if !canReadHistory(w, r, revisions[0]) {
+		return
+	}
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// GetProductRevision returns one revision with the product as it was after
+// it. (Owner or admin).
+func (h *Handler) GetProductRevision(w http.ResponseWriter, r *http.Request) {
+	productID, ok := productIDParam(w, r)
+	if !ok {
+		return
+	}
+	revision, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil || revision < 1 {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := h.db.GetProductRevision(productID, revision)
+	if err != nil {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	if !canReadHistory(w, r, rev) {
+		return
+	}
+	json.NewEncoder(w).Encode(rev)
+}
+
+// productAsOf writes the product as it was at the time in ?asOf=, for
+// GetProductByID. (Owner or admin).
+func (h *Handler) productAsOf(w http.ResponseWriter, r *http.Request, productID string) {
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("asOf"))
+	if err != nil {
+		http.Error(w, "asOf must be an RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := h.db.GetProductRevisionAt(productID, at)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if !canReadHistory(w, r, rev) {
+		return
+	}
+	if rev.Action == storage.RevisionDelete {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(rev.Product)
+}
+
+func canReadHistory(w http.ResponseWriter, r *http.Request, rev *storage.ProductRevision) bool {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != "admin" && rev.SellerID != userClaims["id"].(string) {
+		http.Error(w, "Unauthorized access to product", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func productIDParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return "", false
+	}
+	return id.Hex(), true
+}
+
+// actor returns who makes the request, for product revisions. Impersonated
+// requests name the acting admin as well.
+func actor(r *http.Request) storage.Actor {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	a := storage.Actor{ID: userClaims["id"].(string)}
+	a.Role, _ = userClaims["role"].(string)
+	if act, ok := r.Context().Value("act").(map[string]interface{}); ok {
+		a.ImpersonatorID, _ = act["sub"].(string)
+	}
+	return a
+}
@@ -2,10 +2,20 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"business-cart/catalog-service/internal/fx"
+	"business-cart/catalog-service/internal/jobs"
+	"business-cart/catalog-service/internal/media"
 	"business-cart/catalog-service/internal/middleware"
+	"business-cart/catalog-service/internal/search"
 	"business-cart/catalog-service/internal/storage"
+	"business-cart/catalog-service/internal/visibility"
 
 	"github.com/go-chi/chi/v5"
 	"go.mongodb.org/mongo-driver/bson"
@@ -14,21 +24,85 @@ import (
 
 type Handler struct {
 	db        *storage.DB
+	searcher  search.Searcher
+	blobs     media.BlobStore
+	rates     fx.Provider
+	imports   jobs.Queue // nil runs imports before responding
 	jwtSecret string
 }
 
-func NewHandler(db *storage.DB, jwtSecret string) *Handler {
-	return &Handler{db: db, jwtSecret: jwtSecret}
+func NewHandler(db *storage.DB, blobs media.BlobStore, rates fx.Provider, imports jobs.Queue, jwtSecret string) *Handler {
+	return &Handler{db: db, searcher: search.NewMongoSearcher(db.Database()), blobs: blobs, rates: rates, imports: imports, jwtSecret: jwtSecret}
 }
 
 func (h *Handler) RegisterRoutes(router *chi.Mux) {
+	router.Get("/media/*", h.ServeMedia)
+
 	router.Group(func(r chi.Router) {
-		r.Use(middleware.AuthMiddleware(h.jwtSecret))
+		r.Use(middleware.AuthMiddleware(h.jwtSecret, h.db))
 		r.Post("/products", h.CreateProduct)
 		r.Get("/products", h.GetProducts)
+		r.Get("/products/export", h.ExportProducts)
+		r.Get("/products/changes", h.GetProductChanges)
+		r.Post("/products/imports", h.CreateImport)
+		r.Get("/products/imports", h.GetImports)
+		r.Get("/products/imports/{id}", h.GetImport)
 		r.Get("/products/{id}", h.GetProductByID)
+		r.Get("/products/{id}/history", h.GetProductHistory)
+		r.Get("/products/{id}/history/{revision}", h.GetProductRevision)
 		r.Put("/products/{id}", h.UpdateProduct)
+		r.Patch("/products/{id}", h.UpdateProduct)
 		r.Delete("/products/{id}", h.DeleteProduct)
+		r.Post("/products/{id}/images", h.UploadProductImage)
+		r.Put("/products/{id}/images", h.UpdateProductImages)
+		r.Delete("/products/{id}/images/{imageId}", h.DeleteProductImage)
+
+		r.Post("/categories", h.CreateCategory)
+		r.Get("/categories", h.GetCategories)
+		r.Get("/categories/{id}", h.GetCategory)
+		r.Put("/categories/{id}", h.UpdateCategory)
+		r.Post("/categories/{id}/move", h.MoveCategory)
+		r.Delete("/categories/{id}", h.DeleteCategory)
+
+		r.Get("/products/{id}/inventory", h.GetInventory)
+		r.Get("/products/{id}/inventory/adjustments", h.GetInventoryAdjustments)
+		r.Post("/products/{id}/inventory/adjustments", h.AdjustInventory)
+		r.Post("/inventory/availability", h.GetAvailability)
+		r.Post("/inventory/reservations", h.CreateReservation)
+		r.Get("/inventory/reservations/{id}", h.GetReservation)
+		r.Post("/inventory/reservations/{id}/commit", h.CommitReservation)
+		r.Post("/inventory/reservations/{id}/release", h.ReleaseReservation)
+
+		r.Post("/warehouses", h.CreateWarehouse)
+		r.Get("/warehouses", h.GetWarehouses)
+		r.Get("/warehouses/{id}", h.GetWarehouseByID)
+		r.Put("/warehouses/{id}", h.UpdateWarehouse)
+		r.Delete("/warehouses/{id}", h.DeleteWarehouse)
+
+		r.Post("/price-lists", h.CreatePriceList)
+		r.Get("/price-lists", h.GetPriceLists)
+		r.Get("/price-lists/{id}", h.GetPriceListByID)
+		r.Put("/price-lists/{id}", h.UpdatePriceList)
+		r.Delete("/price-lists/{id}", h.DeletePriceList)
+		r.Post("/customer-groups", h.CreateCustomerGroup)
+		r.Get("/customer-groups", h.GetCustomerGroups)
+		r.Get("/customer-groups/{id}", h.GetCustomerGroupByID)
+		r.Put("/customer-groups/{id}", h.UpdateCustomerGroup)
+		r.Delete("/customer-groups/{id}", h.DeleteCustomerGroup)
+		r.Post("/pricing/resolve", h.ResolvePrices)
+		r.Get("/exchange-rates", h.GetExchangeRate)
+
+		r.Post("/visibility-rules", h.CreateVisibilityRule)
+		r.Get("/visibility-rules", h.GetVisibilityRules)
+		r.Get("/visibility-rules/{id}", h.GetVisibilityRuleByID)
+		r.Put("/visibility-rules/{id}", h.UpdateVisibilityRule)
+		r.Delete("/visibility-rules/{id}", h.DeleteVisibilityRule)
+
+		r.Post("/part-numbers", h.CreatePartNumber)
+		r.Get("/part-numbers", h.GetPartNumbers)
+		r.Get("/part-numbers/{id}", h.GetPartNumberByID)
+		r.Put("/part-numbers/{id}", h.UpdatePartNumber)
+		r.Delete("/part-numbers/{id}", h.DeletePartNumber)
 	})
 }
 
@@ -46,12 +120,31 @@ func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	product.SellerID = userClaims["id"].(string)
+	product.Images = nil // added through the image endpoints
+	if product.Status == "" {
+		product.Status = storage.ProductActive
+	}
+	product.Normalize()
+	if err := product.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.checkCategory(w, &product) {
+		return
+	}
+	if !h.checkComponents(w, &product) {
+		return
+	}
+	if !h.skusAvailable(w, &product) {
+		return
+	}
 
-	if err := h.db.CreateProduct(&product); err != nil {
+	if err := h.db.CreateProduct(&product, actor(r)); err != nil {
 		http.Error(w, "Failed to create product", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("ETag", etag(&product))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(product)
 }
@@ -83,6 +176,39 @@ func (h *Handler) GetProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Customers only see published products their sellers' visibility rules
+	// allow; sellers see every status and may filter by one
+	status := r.URL.Query().Get("status")
+	var restrictions []*visibility.Restriction
+	switch {
+	case role == "customer":
+		status = ""
+		for key, value := range storage.PublishedFilter(time.Now()) {
+			filter[key] = value
+		}
+		var err error
+		restrictions, err = h.customerRestrictions(accountID, filter["sellerID"].(bson.M)["$in"].([]string))
+		if err != nil {
+			http.Error(w, "Failed to retrieve visibility rules", http.StatusInternalServerError)
+			return
+		}
+		visibility.Exclude(filter, restrictions)
+	case status != "" && !validStatus(status):
+		http.Error(w, "status must be draft, active, discontinued or archived", http.StatusBadRequest)
+		return
+	case status != "":
+		filter["status"] = status
+	}
+
+	if isSearch(r) {
+		h.searchProducts(w, r, filter, restrictions, role, accountID, status)
+		return
+	}
+
+	currency, ok := displayCurrency(w, r)
+	if !ok {
+		return
+	}
 	products, err := h.db.GetProducts(filter)
 	if err != nil {
 		http.Error(w, "Failed to retrieve products", http.StatusInternalServerError)
@@ -94,6 +220,25 @@ func (h *Handler) GetProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.addBreadcrumbs(products); err != nil {
+		http.Error(w, "Failed to retrieve categories", http.StatusInternalServerError)
+		return
+	}
+	if role == "customer" {
+		if err := h.applyCustomerPrices(products, accountID); err != nil {
+			http.Error(w, "Failed to resolve prices", http.StatusInternalServerError)
+			return
+		}
+		if err := h.applyPartNumbers(products, accountID); err != nil {
+			http.Error(w, "Failed to retrieve part numbers", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.convertProducts(r.Context(), products, currency); err != nil {
+		conversionError(w, err)
+		return
+	}
+
 	json.NewEncoder(w).Encode(products)
 }
 
@@ -103,6 +248,14 @@ func (h *Handler) GetProductByID(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid ID", http.StatusBadRequest)
 		return
 	}
+	if r.URL.Query().Has("asOf") {
+		h.productAsOf(w, r, id.Hex())
+		return
+	}
+	currency, ok := displayCurrency(w, r)
+	if !ok {
+		return
+	}
 
 	product, err := h.db.GetProductByID(id)
 	if err != nil {
@@ -111,14 +264,54 @@ func (h *Handler) GetProductByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userClaims := r.Context().Value("user").(map[string]interface{})
-	if userClaims["role"] != "admin" && product.SellerID != userClaims["id"].(string) {
-		http.Error(w, "Unauthorized access to product", http.StatusForbidden)
-		return
+	switch userClaims["role"] {
+	case "admin":
+	case "customer":
+		if !canBuyFrom(userClaims, product.SellerID) {
+			http.Error(w, "Unauthorized access to product", http.StatusForbidden)
+			return
+		}
+		// Discontinued products stay viewable for past orders; hidden ones
+		// look like they do not exist
+		visible, err := h.visibleTo(userClaims["id"].(string), product)
+		if err != nil {
+			http.Error(w, "Failed to retrieve visibility rules", http.StatusInternalServerError)
+			return
+		}
+		if !visible || !product.Published(time.Now()) && product.Status != storage.ProductDiscontinued {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		if err := h.applyCustomerPrices([]*storage.Product{product}, userClaims["id"].(string)); err != nil {
+			http.Error(w, "Failed to resolve prices", http.StatusInternalServerError)
+			return
+		}
+		if err := h.applyPartNumbers([]*storage.Product{product}, userClaims["id"].(string)); err != nil {
+			http.Error(w, "Failed to retrieve part numbers", http.StatusInternalServerError)
+			return
+		}
+	default:
+		if product.SellerID != userClaims["id"].(string) {
+			http.Error(w, "Unauthorized access to product", http.StatusForbidden)
+			return
+		}
 	}
 
+	if err := h.addBreadcrumbs([]*storage.Product{product}); err != nil {
+		http.Error(w, "Failed to retrieve categories", http.StatusInternalServerError)
+		return
+	}
+	if err := h.convertProducts(r.Context(), []*storage.Product{product}, currency); err != nil {
+		conversionError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(product))
 	json.NewEncoder(w).Encode(product)
 }
 
+// UpdateProduct applies a JSON Merge Patch to a product. Only mutableFields
+// can be changed. With If-Match the update only applies to the revision the
+// caller last saw; otherwise it fails with 412.
 func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
@@ -138,18 +331,139 @@ func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updates bson.M
-	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+	contentType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+	if contentType != "" && contentType != "application/json" && contentType != "application/merge-patch+json" {
+		http.Error(w, "Content-Type must be application/merge-patch+json", http.StatusUnsupportedMediaType)
+		return
+	}
+	if !ifMatch(r, product) {
+		http.Error(w, "Product has been modified", http.StatusPreconditionFailed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	patched, fields, err := patchProduct(product, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	patched.Normalize()
+	if err := patched.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.checkCategory(w, &patched) {
+		return
+	}
+	if !h.checkComponents(w, &patched) {
+		return
+	}
+	if !h.skusAvailable(w, &patched) {
+		return
+	}
 
-	if err := h.db.UpdateProduct(id, updates); err != nil {
-		http.Error(w, "Failed to update product", http.StatusInternalServerError)
+	// An empty patch changes nothing and records no revision
+	updated := product
+	if len(fields) > 0 {
+		set, unset, err := patchUpdate(&patched, fields)
+		if err != nil {
+			http.Error(w, "Failed to update product", http.StatusInternalServerError)
+			return
+		}
+		updated, err = h.db.PatchProduct(id, product.Revision, set, unset, actor(r))
+		if errors.Is(err, storage.ErrRevisionConflict) {
+			// Changed between reading and writing; the caller has to look again
+			http.Error(w, "Product has been modified", http.StatusPreconditionFailed)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update product", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := h.addBreadcrumbs([]*storage.Product{updated}); err != nil {
+		http.Error(w, "Failed to retrieve categories", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("ETag", etag(updated))
+	json.NewEncoder(w).Encode(updated)
+}
+
+func validStatus(status string) bool {
+	switch status {
+	case storage.ProductDraft, storage.ProductActive, storage.ProductDiscontinued, storage.ProductArchived:
+		return true
+	}
+	return false
+}
+
+// mergeProduct returns a copy of product with the JSON update in body applied.
+// updates holds the keys of body. Composite fields are replaced, not merged
+// into the stored values.
+func mergeProduct(product *storage.Product, body []byte, updates bson.M) (storage.Product, error) {
+	merged := *product
+	for key := range updates {
+		switch key {
+		case "weight":
+			merged.Weight = nil
+		case "dimensions":
+			merged.Dimensions = nil
+		case "quantityRules":
+			merged.QuantityRules = nil
+		case "components":
+			merged.Components = nil
+		case "priceTiers":
+			merged.PriceTiers = nil
+		case "attributes":
+			merged.Attributes = nil
+		case "variantOptions":
+			merged.VariantOptions = nil
+		case "variants":
+			merged.Variants = nil
+		}
+	}
+	err := json.Unmarshal(body, &merged)
+	return merged, err
+}
+
+// skusAvailable writes a 409 and returns false when another product of the
+// seller already uses one of the product's SKUs.
+func (h *Handler) skusAvailable(w http.ResponseWriter, product *storage.Product) bool {
+	taken, err := h.db.SKUInUse(product.SellerID, product.SKUs(), product.ID)
+	if err != nil {
+		http.Error(w, "Failed to check SKU", http.StatusInternalServerError)
+		return false
+	}
+	if taken {
+		http.Error(w, "SKU already in use", http.StatusConflict)
+		return false
+	}
+	return true
+}
 
-	w.WriteHeader(http.StatusOK)
+// normalizedUpdates replaces the values of known product fields in updates
+// with their typed, normalized form, so e.g. new variants get their IDs.
+func normalizedUpdates(updates bson.M, product *storage.Product) error {
+	raw, err := bson.Marshal(product)
+	if err != nil {
+		return err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	delete(updates, "_id")
+	for key := range updates {
+		if value, ok := doc[key]; ok {
+			updates[key] = value
+		}
+	}
+	return nil
 }
 
 func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
@@ -171,10 +485,28 @@ func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.DeleteProduct(id); err != nil {
+	if !ifMatch(r, product) {
+		http.Error(w, "Product has been modified", http.StatusPreconditionFailed)
+		return
+	}
+	bundles, err := h.db.GetProducts(bson.M{"components.productId": id.Hex()})
+	if err != nil {
+		http.Error(w, "Failed to retrieve bundles", http.StatusInternalServerError)
+		return
+	}
+	if len(bundles) > 0 {
+		http.Error(w, "Product is a component of a bundle; remove it from the bundle first", http.StatusConflict)
+		return
+	}
+
+	if err := h.db.DeleteProduct(id, actor(r)); err != nil {
 		http.Error(w, "Failed to delete product", http.StatusInternalServerError)
 		return
 	}
+	// Customers' codes for a deleted product map to nothing
+	if err := h.db.DeleteProductPartNumbers(id.Hex()); err != nil {
+		log.Printf("Failed to delete part numbers of product %s: %v", id.Hex(), err)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"business-cart/catalog-service/internal/bulk"
+	"business-cart/catalog-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// maxImportRows bounds a single upload.
+	maxImportRows = 50000
+	// maxImportBytes bounds the size of an upload. The whole request must fit
+	// in a synchronous Lambda invocation, which takes at most 6 MB, within
+	// API Gateway's 10 MB.
+	maxImportBytes = 5 << 20
+	// importProgressEvery is how many rows are processed between progress saves.
+	importProgressEvery = 100
+	// importStaleAfter is how long a job may go without progress before it is
+	// considered interrupted, e.g. because the instance running it stopped.
+	importStaleAfter = 5 * time.Minute
+)
+
+// CreateImport accepts a CSV or JSON Lines upload of products and hands it to
+// the import worker. The job is returned with 202 for polling.
+func (h *Handler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != "company" {
+		http.Error(w, "Unauthorized: Company role required", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	job := storage.ImportJob{
+		SellerID: userClaims["id"].(string),
+		Format:   importFormat(r),
+		Mode:     query.Get("mode"),
+		Status:   storage.ImportPending,
+		Errors:   []storage.ImportRowError{},
+	}
+	if job.Mode == "" {
+		job.Mode = storage.ImportUpsert
+	}
+	switch job.Mode {
+	case storage.ImportCreate, storage.ImportUpdate, storage.ImportUpsert:
+	default:
+		http.Error(w, "mode must be create, update or upsert", http.StatusBadRequest)
+		return
+	}
+	if v := query.Get("dryRun"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "dryRun must be true or false", http.StatusBadRequest)
+			return
+		}
+		job.DryRun = dryRun
+	}
+
+	if job.Format != bulk.CSV && job.Format != bulk.JSONL {
+		http.Error(w, "format must be csv or jsonl", http.StatusBadRequest)
+		return
+	}
+	upload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Imports are limited to %d MB", maxImportBytes>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+	rows, err := parseImport(job.Format, upload)
+	if err != nil {
+		http.Error(w, "Invalid file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(rows) == 0 {
+		http.Error(w, "The file has no products", http.StatusBadRequest)
+		return
+	}
+	if len(rows) > maxImportRows {
+		http.Error(w, fmt.Sprintf("Imports are limited to %d products", maxImportRows), http.StatusBadRequest)
+		return
+	}
+	job.Total = len(rows)
+
+	// The worker reads the file back, as it runs in another invocation
+	if err := h.db.CreateImportJob(&job); err != nil {
+		http.Error(w, "Failed to create import", http.StatusInternalServerError)
+		return
+	}
+	if err := h.db.SaveImportUpload(job.ID, upload); err != nil {
+		h.failImport(&job, "failed to save upload")
+		http.Error(w, "Failed to create import", http.StatusInternalServerError)
+		return
+	}
+	if h.imports == nil {
+		if err := h.RunImport(job.ID.Hex()); err != nil {
+			log.Printf("Failed to run import %s: %v", job.ID.Hex(), err)
+		}
+	} else if err := h.imports.EnqueueImport(r.Context(), job.ID.Hex()); err != nil {
+		log.Printf("Failed to enqueue import %s: %v", job.ID.Hex(), err)
+		h.failImport(&job, "failed to start import")
+		_ = h.db.DeleteImportUpload(job.ID)
+		http.Error(w, "Failed to start import", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// parseImport reads the rows of an upload in the given format.
+func parseImport(format string, upload []byte) ([]bulk.Row, error) {
+	if format == bulk.JSONL {
+		return bulk.ParseJSONL(bytes.NewReader(upload), maxImportRows)
+	}
+	return bulk.ParseCSV(bytes.NewReader(upload), maxImportRows)
+}
+
+// importFormat takes the format from the format parameter, or else from the
+// content type of the upload.
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return bulk.CSV
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return bulk.JSONL
+	}
+	return ""
+}
+
+func (h *Handler) GetImports(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := sellerScope(w, r)
+	if !ok {
+		return
+	}
+	jobs, err := h.db.GetImportJobs(sellerID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve imports", http.StatusInternalServerError)
+		return
+	}
+	for _, job := range jobs {
+		h.checkStale(job)
+	}
+	json.NewEncoder(w).Encode(jobs)
+}
+
+func (h *Handler) GetImport(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	job, err := h.db.GetImportJobByID(id)
+	if err != nil {
+		http.Error(w, "Import not found", http.StatusNotFound)
+		return
+	}
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if job.SellerID != userClaims["id"].(string) && userClaims["role"] != "admin" {
+		http.Error(w, "Unauthorized access to import", http.StatusForbidden)
+		return
+	}
+	h.checkStale(job)
+	json.NewEncoder(w).Encode(job)
+}
+
+// RunImport runs a pending import job from its saved upload. The import
+// worker calls it; a job that is no longer pending, e.g. one delivered
+// twice, is left alone.
+func (h *Handler) RunImport(jobID string) error {
+	id, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return err
+	}
+	job, err := h.db.ClaimImportJob(id)
+	if err != nil || job == nil {
+		return err
+	}
+	defer func() {
+		if err := h.db.DeleteImportUpload(id); err != nil {
+			log.Printf("Failed to delete upload of import %s: %v", jobID, err)
+		}
+	}()
+
+	upload, err := h.db.GetImportUpload(id)
+	if err != nil {
+		log.Printf("Failed to load upload of import %s: %v", jobID, err)
+		h.failImport(job, "upload not found")
+		return nil
+	}
+	rows, err := parseImport(job.Format, upload)
+	if err != nil {
+		h.failImport(job, "invalid file: "+err.Error())
+		return nil
+	}
+	h.runImport(*job, rows)
+	return nil
+}
+
+// failImport marks a job that could not run as failed.
+func (h *Handler) failImport(job *storage.ImportJob, reason string) {
+	now := time.Now()
+	job.Status = storage.ImportFailed
+	job.Error = reason
+	job.FinishedAt = &now
+	h.saveImport(job)
+}
+
+// checkStale marks a job that stopped making progress as failed.
+func (h *Handler) checkStale(job *storage.ImportJob) {
+	if job.Status != storage.ImportPending && job.Status != storage.ImportRunning {
+		return
+	}
+	if time.Since(job.UpdatedAt) < importStaleAfter {
+		return
+	}
+	h.failImport(job, "import interrupted")
+}
+
+// runImport processes the rows of a running import job, saving progress as
+// it goes.
+func (h *Handler) runImport(job storage.ImportJob, rows []bulk.Row) {
+	// SKUs seen earlier in the file, by line
+	seen := map[string]int{}
+	for i, row := range rows {
+		created, err := h.importRow(&job, row, seen)
+		switch {
+		case err != nil:
+			job.Failed++
+			if len(job.Errors) < storage.MaxImportErrors {
+				job.Errors = append(job.Errors, storage.ImportRowError{Line: row.Line, SKU: row.SKU(), Error: err.Error()})
+			}
+		case created:
+			job.Created++
+		default:
+			job.Updated++
+		}
+		job.Processed++
+		if (i+1)%importProgressEvery == 0 {
+			h.saveImport(&job)
+		}
+	}
+
+	now := time.Now()
+	job.Status = storage.ImportCompleted
+	job.FinishedAt = &now
+	h.saveImport(&job)
+}
+
+func (h *Handler) saveImport(job *storage.ImportJob) {
+	if err := h.db.UpdateImportJob(job); err != nil {
+		log.Printf("Failed to save import %s: %v", job.ID.Hex(), err)
+	}
+}
+
+// importRow creates or updates the product of one row according to the job's
+// mode and reports whether it was created. Dry runs validate without saving.
+func (h *Handler) importRow(job *storage.ImportJob, row bulk.Row, seen map[string]int) (bool, error) {
+	if row.Err != nil {
+		return false, row.Err
+	}
+	sku := row.SKU()
+	if sku == "" {
+		return false, errors.New("sku is required")
+	}
+	if line, ok := seen[sku]; ok {
+		return false, fmt.Errorf("duplicate SKU, already on line %d", line)
+	}
+	seen[sku] = row.Line
+
+	existing, err := h.db.GetProductBySKU(job.SellerID, sku)
+	if err != nil {
+		return false, errors.New("failed to look up SKU")
+	}
+	body := row.JSON()
+
+	if existing == nil {
+		if job.Mode == storage.ImportUpdate {
+			return false, errors.New("no product with this SKU")
+		}
+		product := storage.Product{}
+		if err := json.Unmarshal(body, &product); err != nil {
+			return false, errors.New("invalid product: " + err.Error())
+		}
+		product.SellerID = job.SellerID
+		if product.Status == "" {
+			product.Status = storage.ProductActive
+		}
+		product.Normalize()
+		if err := product.Validate(); err != nil {
+			return false, err
+		}
+		if err := h.validateCategory(&product); err != nil {
+			return false, err
+		}
+		if err := h.validateComponents(&product); err != nil {
+			return false, err
+		}
+		if err := h.skusFree(&product); err != nil {
+			return false, err
+		}
+		if !job.DryRun {
+			if err := h.db.CreateProduct(&product, importActor(job)); err != nil {
+				return false, errors.New("failed to create product")
+			}
+		}
+		return true, nil
+	}
+
+	if job.Mode == storage.ImportCreate {
+		return false, errors.New("a product with this SKU already exists")
+	}
+	updates := bson.M{}
+	for key, value := range row.Fields {
+		updates[key] = value
+	}
+	merged, err := mergeProduct(existing, body, updates)
+	if err != nil {
+		return false, errors.New("invalid product: " + err.Error())
+	}
+	merged.Normalize()
+	if err := merged.Validate(); err != nil {
+		return false, err
+	}
+	if err := h.validateCategory(&merged); err != nil {
+		return false, err
+	}
+	if err := h.validateComponents(&merged); err != nil {
+		return false, err
+	}
+	if err := h.skusFree(&merged); err != nil {
+		return false, err
+	}
+	if !job.DryRun {
+		if err := normalizedUpdates(updates, &merged); err != nil {
+			return false, errors.New("failed to update product")
+		}
+		if err := h.db.UpdateProduct(existing.ID, updates, importActor(job)); err != nil {
+			return false, errors.New("failed to update product")
+		}
+	}
+	return false, nil
+}
+
+// importActor attributes the changes of an import job to its seller.
+func importActor(job *storage.ImportJob) storage.Actor {
+	return storage.Actor{ID: job.SellerID, Role: "company", Source: "import"}
+}
+
+// skusFree is skusAvailable for import rows.
+func (h *Handler) skusFree(product *storage.Product) error {
+	taken, err := h.db.SKUInUse(product.SellerID, product.SKUs(), product.ID)
+	if err != nil {
+		return errors.New("failed to check SKU")
+	}
+	if taken {
+		return errors.New("SKU already in use")
+	}
+	return nil
+}
+
+// ExportProducts writes the seller's full catalog as CSV (the default) or
+// JSON Lines, in the formats imports accept.
+func (h *Handler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := sellerScope(w, r)
+	if !ok {
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = bulk.CSV
+	}
+	if format != bulk.CSV && format != bulk.JSONL {
+		http.Error(w, "format must be csv or jsonl", http.StatusBadRequest)
+		return
+	}
+
+	products, err := h.db.GetProducts(bson.M{"sellerID": sellerID})
+	if err != nil {
+		http.Error(w, "Failed to retrieve products", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+	if format == bulk.CSV {
+		w.Header().Set("Content-Type", "text/csv")
+		err = bulk.WriteCSV(w, products)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		err = bulk.WriteJSONL(w, products)
+	}
+	if err != nil {
+		log.Printf("Failed to export products of %s: %v", sellerID, err)
+	}
+}
@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultReservationTTL matches the lifetime of a checkout quote.
+const defaultReservationTTL = 24 * time.Hour
+
+// roleService is the role of the tokens checkout-service signs for its own
+// calls. Only such tokens hold stock for a buyer.
+const roleService = "service"
+
+var adjustmentReasons = map[string]bool{
+	storage.ReasonReceived:   true,
+	storage.ReasonReturn:     true,
+	storage.ReasonDamaged:    true,
+	storage.ReasonCount:      true,
+	storage.ReasonCorrection: true,
+}
+
+type AdjustStockRequest struct {
+	VariantID   string `json:"variantId"`
+	WarehouseID string `json:"warehouseId"` // empty for stock without a location
+	Delta       int    `json:"delta"`
+	Reason      string `json:"reason"`
+	Note        string `json:"note"`
+}
+
+type ReserveRequest struct {
+	Reference string                    `json:"reference"`
+	SellerID  string                    `json:"sellerId"`
+	AccountID string                    `json:"accountId"` // the buyer
+	ExpiresAt time.Time                 `json:"expiresAt"`
+	Lines     []storage.ReservationLine `json:"lines"`
+}
+
+// GetInventory returns the stock levels of a product. (Owner or admin)
+func (h *Handler) GetInventory(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.ownedProduct(w, r, true)
+	if !ok {
+		return
+	}
+
+	levels, err := h.db.GetStockLevels(product.ID.Hex())
+	if err != nil {
+		http.Error(w, "Failed to retrieve inventory", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(levels)
+}
+
+// AdjustInventory records a stock movement such as a receipt or a count
+// correction. (Owner only)
+func (h *Handler) AdjustInventory(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.ownedProduct(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req AdjustStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Delta == 0 {
+		http.Error(w, "delta must not be zero", http.StatusBadRequest)
+		return
+	}
+	if product.IsBundle() {
+		http.Error(w, "Bundles take their stock from their components", http.StatusConflict)
+		return
+	}
+	if !adjustmentReasons[req.Reason] {
+		http.Error(w, "reason must be one of received, return, damaged, count, correction", http.StatusBadRequest)
+		return
+	}
+	if msg := checkVariant(product, req.VariantID); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.WarehouseID != "" {
+		whID, err := primitive.ObjectIDFromHex(req.WarehouseID)
+		if err != nil {
+			http.Error(w, "Invalid warehouse ID", http.StatusBadRequest)
+			return
+		}
+		warehouse, err := h.db.GetWarehouseByID(whID)
+		if err != nil || warehouse.SellerID != product.SellerID {
+			http.Error(w, "warehouse not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	level, err := h.db.AdjustStock(&storage.InventoryAdjustment{
+		SellerID:    product.SellerID,
+		ProductID:   product.ID.Hex(),
+		VariantID:   req.VariantID,
+		WarehouseID: req.WarehouseID,
+		Delta:       req.Delta,
+		Reason:      req.Reason,
+		Note:        req.Note,
+		ActorID:     userClaims["id"].(string),
+	})
+	if err == storage.ErrNegativeStock {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to adjust inventory", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(level)
+}
+
+// GetInventoryAdjustments returns the stock ledger of a product. (Owner or admin)
+func (h *Handler) GetInventoryAdjustments(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.ownedProduct(w, r, true)
+	if !ok {
+		return
+	}
+
+	adjustments, err := h.db.GetAdjustments(product.ID.Hex())
+	if err != nil {
+		http.Error(w, "Failed to retrieve adjustments", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(adjustments)
+}
+
+// CreateReservation holds stock for a checkout quote. (Checkout only, with
+// its service token, once it has checked the buyer may buy from the seller)
+func (h *Handler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != roleService {
+		http.Error(w, "Reservations are made by checkout", http.StatusForbidden)
+		return
+	}
+
+	var req ReserveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Reference == "" || req.SellerID == "" || req.AccountID == "" || len(req.Lines) == 0 {
+		http.Error(w, "reference, sellerId, accountId and lines are required", http.StatusBadRequest)
+		return
+	}
+	for _, line := range req.Lines {
+		if line.ProductID == "" || line.Quantity <= 0 {
+			http.Error(w, "every line needs a productId and a positive quantity", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// A hold lasts at most as long as a quote, so a client cannot keep stock
+	// away from other buyers indefinitely.
+	now := time.Now()
+	if latest := now.Add(defaultReservationTTL); req.ExpiresAt.IsZero() || req.ExpiresAt.After(latest) {
+		req.ExpiresAt = latest
+	} else if !req.ExpiresAt.After(now) {
+		http.Error(w, "expiresAt must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	res := &storage.Reservation{
+		Reference: req.Reference,
+		SellerID:  req.SellerID,
+		AccountID: req.AccountID,
+		Lines:     req.Lines,
+		ExpiresAt: req.ExpiresAt,
+	}
+	err := h.db.Reserve(res)
+	var short *storage.InsufficientStockError
+	if errors.As(err, &short) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "Insufficient stock",
+			"lines": short.Lines,
+		})
+		return
+	}
+	if err == storage.ErrReferenceInUse {
+		http.Error(w, "Reservation reference is already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reserve stock", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.ownedReservation(w, r, true)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// CommitReservation converts a reservation into a sale once the order is
+// placed. (Seller, admin or checkout)
+func (h *Handler) CommitReservation(w http.ResponseWriter, r *http.Request) {
+	h.closeReservation(w, r, h.db.CommitReservation)
+}
+
+// ReleaseReservation returns reserved stock when a quote is abandoned.
+// (Seller, admin or checkout)
+func (h *Handler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	h.closeReservation(w, r, h.db.ReleaseReservation)
+}
+
+func (h *Handler) closeReservation(w http.ResponseWriter, r *http.Request, close func(primitive.ObjectID) (*storage.Reservation, error)) {
+	res, ok := h.ownedReservation(w, r, false)
+	if !ok {
+		return
+	}
+
+	closed, err := close(res.ID)
+	if err == storage.ErrReservationNotOpen {
+		http.Error(w, "Reservation is already "+res.Status, http.StatusConflict)
+		return
+	}
+	if err == storage.ErrReservationExpired {
+		http.Error(w, "Reservation has expired", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update reservation", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(closed)
+}
+
+// ownedProduct loads the product in the URL and checks the caller is its
+// seller, or an admin when allowAdmin is set.
+func (h *Handler) ownedProduct(w http.ResponseWriter, r *http.Request, allowAdmin bool) (*storage.Product, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	product, err := h.db.GetProductByID(id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if product.SellerID != userClaims["id"].(string) && !(allowAdmin && userClaims["role"] == "admin") {
+		http.Error(w, "Unauthorized access to product", http.StatusForbidden)
+		return nil, false
+	}
+	return product, true
+}
+
+// ownedReservation loads the reservation in the URL for its seller, an admin
+// or checkout, and for its buyer when allowBuyer is set.
+func (h *Handler) ownedReservation(w http.ResponseWriter, r *http.Request, allowBuyer bool) (*storage.Reservation, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	res, err := h.db.GetReservation(id)
+	if err != nil {
+		http.Error(w, "Reservation not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	callerID := userClaims["id"].(string)
+	role := userClaims["role"]
+	if role != "admin" && role != roleService && res.SellerID != callerID && !(allowBuyer && res.AccountID == callerID) {
+		http.Error(w, "Unauthorized access to reservation", http.StatusForbidden)
+		return nil, false
+	}
+	return res, true
+}
+
+// checkVariant validates a variant reference against the product. Products
+// with variants keep stock per variant only.
+func checkVariant(product *storage.Product, variantID string) string {
+	if len(product.Variants) == 0 {
+		if variantID != "" {
+			return "product has no variants"
+		}
+		return ""
+	}
+	if variantID == "" {
+		return "variantId is required for products with variants"
+	}
+	if product.FindVariant(variantID) == nil {
+		return "variant not found"
+	}
+	return ""
+}
+
+// canBuyFrom reports whether the caller may buy from a seller.
+func canBuyFrom(userClaims map[string]interface{}, sellerID string) bool {
+	switch userClaims["role"] {
+	case "admin":
+		return true
+	case "company":
+		return userClaims["id"] == sellerID
+	case "customer":
+		ids, _ := userClaims["associate_company_ids"].([]interface{})
+		for _, id := range ids {
+			if id == sellerID {
+				return true
+			}
+		}
+	}
+	return false
+}
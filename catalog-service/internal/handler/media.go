@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"business-cart/catalog-service/internal/media"
+	"business-cart/catalog-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UploadProductImage stores an image sent as multipart form field "file" or as
+// the raw request body, generates its thumbnails and appends it to the
+// product. The first image becomes the primary one.
+func (h *Handler) UploadProductImage(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.ownedProduct(w, r, false)
+	if !ok {
+		return
+	}
+	if len(product.Images) >= storage.MaxProductImages {
+		http.Error(w, fmt.Sprintf("Products are limited to %d images", storage.MaxProductImages), http.StatusConflict)
+		return
+	}
+
+	data, err := readUpload(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	img, err := media.DecodeImage(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	thumbnails, err := img.Thumbnails()
+	if err != nil {
+		http.Error(w, "Failed to generate thumbnails", http.StatusInternalServerError)
+		return
+	}
+
+	image := storage.ProductImage{
+		ID:          primitive.NewObjectID().Hex(),
+		ContentType: img.ContentType,
+		Width:       img.Width,
+		Height:      img.Height,
+		Size:        len(data),
+		Primary:     len(product.Images) == 0,
+		Thumbnails:  []storage.ImageThumbnail{},
+		CreatedAt:   time.Now(),
+	}
+	prefix := imagePrefix(product.ID, image.ID)
+	key := prefix + "original." + media.ImageTypes[img.ContentType]
+	if err := h.blobs.Put(r.Context(), key, img.ContentType, data); err != nil {
+		http.Error(w, "Failed to store image", http.StatusInternalServerError)
+		return
+	}
+	keys := []string{key}
+	image.Key, image.URL = key, h.blobs.URL(key)
+	for _, thumbnail := range thumbnails {
+		key := prefix + thumbnail.Name + "." + media.ImageTypes[thumbnail.ContentType]
+		if err := h.blobs.Put(r.Context(), key, thumbnail.ContentType, thumbnail.Data); err != nil {
+			h.deleteBlobs(keys)
+			http.Error(w, "Failed to store image", http.StatusInternalServerError)
+			return
+		}
+		keys = append(keys, key)
+		image.Thumbnails = append(image.Thumbnails, storage.ImageThumbnail{
+			Name: thumbnail.Name, Key: key, URL: h.blobs.URL(key), Width: thumbnail.Width, Height: thumbnail.Height,
+		})
+	}
+
+	added, err := h.db.AddProductImage(product.ID, image, actor(r))
+	if err != nil || !added {
+		h.deleteBlobs(keys)
+	}
+	if err != nil {
+		http.Error(w, "Failed to add image", http.StatusInternalServerError)
+		return
+	}
+	if !added {
+		http.Error(w, fmt.Sprintf("Products are limited to %d images", storage.MaxProductImages), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(image)
+}
+
+// readUpload reads the uploaded file, at most media.MaxImageBytes of it.
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxImageBytes+1<<20)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	body := io.Reader(r.Body)
+	if mediaType == "multipart/form-data" {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			return nil, errors.New("invalid multipart body")
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil, errors.New("the file field is required")
+			}
+			if err != nil {
+				return nil, errors.New("invalid multipart body")
+			}
+			if part.FormName() == "file" {
+				body = part
+				break
+			}
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(body, media.MaxImageBytes+1))
+	if err != nil {
+		return nil, errors.New("invalid request body")
+	}
+	return data, nil
+}
+
+type imageOrderRequest struct {
+	ImageIDs  []string `json:"imageIds"`
+	PrimaryID string   `json:"primaryId"`
+}
+
+// UpdateProductImages reorders the product's images and optionally picks a
+// new primary image. imageIds must list every image of the product.
+func (h *Handler) UpdateProductImages(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.ownedProduct(w, r, false)
+	if !ok {
+		return
+	}
+	var req imageOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	images := product.Images
+	if req.ImageIDs != nil {
+		byID := map[string]storage.ProductImage{}
+		for _, image := range product.Images {
+			byID[image.ID] = image
+		}
+		if len(req.ImageIDs) != len(product.Images) {
+			http.Error(w, "imageIds must list every image of the product", http.StatusBadRequest)
+			return
+		}
+		images = make([]storage.ProductImage, 0, len(req.ImageIDs))
+		for _, id := range req.ImageIDs {
+			image, ok := byID[id]
+			if !ok {
+				http.Error(w, "imageIds must list every image of the product", http.StatusBadRequest)
+				return
+			}
+			delete(byID, id)
+			images = append(images, image)
+		}
+	}
+	if req.PrimaryID != "" {
+		found := false
+		for i := range images {
+			images[i].Primary = images[i].ID == req.PrimaryID
+			found = found || images[i].Primary
+		}
+		if !found {
+			http.Error(w, "Image not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.db.SetProductImages(product.ID, nonNilImages(images), actor(r)); err != nil {
+		http.Error(w, "Failed to update images", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(nonNilImages(images))
+}
+
+// DeleteProductImage removes an image and its files. When the primary image
+// is removed the next image becomes primary.
+func (h *Handler) DeleteProductImage(w http.ResponseWriter, r *http.Request) {
+	product, ok := h.ownedProduct(w, r, false)
+	if !ok {
+		return
+	}
+	imageID := chi.URLParam(r, "imageId")
+
+	var removed *storage.ProductImage
+	images := []storage.ProductImage{}
+	for _, image := range product.Images {
+		if image.ID == imageID {
+			removed = &image
+			continue
+		}
+		images = append(images, image)
+	}
+	if removed == nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	if removed.Primary && len(images) > 0 {
+		images[0].Primary = true
+	}
+
+	if err := h.db.SetProductImages(product.ID, images, actor(r)); err != nil {
+		http.Error(w, "Failed to delete image", http.StatusInternalServerError)
+		return
+	}
+	h.deleteBlobs(imageKeys(*removed))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServeMedia serves stored files, for blob stores that clients cannot reach
+// themselves: the local filesystem store and the private S3 bucket.
+func (h *Handler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	blob, err := h.blobs.Get(r.Context(), chi.URLParam(r, "*"))
+	if errors.Is(err, media.ErrNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", blob.ContentType)
+	// Keys are never reused, so files can be cached indefinitely
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Write(blob.Data)
+}
+
+func imagePrefix(productID primitive.ObjectID, imageID string) string {
+	return "products/" + productID.Hex() + "/" + imageID + "/"
+}
+
+// imageKeys returns the blob keys of an image and its thumbnails.
+func imageKeys(image storage.ProductImage) []string {
+	keys := []string{image.Key}
+	for _, thumbnail := range image.Thumbnails {
+		keys = append(keys, thumbnail.Key)
+	}
+	return keys
+}
+
+// deleteBlobs removes files best effort; leftovers are unreferenced.
+func (h *Handler) deleteBlobs(keys []string) {
+	for _, key := range keys {
+		h.blobs.Delete(context.Background(), key)
+	}
+}
+
+func nonNilImages(images []storage.ProductImage) []storage.ProductImage {
+	if images == nil {
+		return []storage.ProductImage{}
+	}
+	return images
+}
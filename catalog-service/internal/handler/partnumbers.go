@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PartNumberRequest struct {
+	SellerID   string `json:"sellerId"`   // customers: the seller the code is for
+	CustomerID string `json:"customerId"` // sellers: the customer whose code it is
+	PartNumber string `json:"partNumber"`
+	ProductID  string `json:"productId"`
+	VariantID  string `json:"variantId"`
+}
+
+// CreatePartNumber maps a customer's part number to a product. Customers map
+// their own codes for sellers they buy from; sellers map codes on behalf of
+// a customer.
+func (h *Handler) CreatePartNumber(w http.ResponseWriter, r *http.Request) {
+	var req PartNumberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	pn := &storage.PartNumber{}
+	switch userClaims["role"] {
+	case "customer":
+		if !canBuyFrom(userClaims, req.SellerID) {
+			http.Error(w, "Unauthorized access to seller", http.StatusForbidden)
+			return
+		}
+		pn.SellerID = req.SellerID
+		pn.CustomerID = userClaims["id"].(string)
+	case "company":
+		if req.CustomerID == "" {
+			http.Error(w, "customerId is required", http.StatusBadRequest)
+			return
+		}
+		pn.SellerID = userClaims["id"].(string)
+		pn.CustomerID = req.CustomerID
+	default:
+		http.Error(w, "Unauthorized: Company or customer role required", http.StatusForbidden)
+		return
+	}
+
+	if !h.applyPartNumberRequest(w, pn, &req) {
+		return
+	}
+	if err := h.db.CreatePartNumber(pn); err != nil {
+		partNumberSaveError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pn)
+}
+
+// GetPartNumbers lists part numbers: customers see their own (?sellerId=
+// narrows to one seller), sellers those of their products (?customerId=
+// narrows to one customer) and admins any. Repeating ?partNumber= looks up
+// those codes only.
+func (h *Handler) GetPartNumbers(w http.ResponseWriter, r *http.Request) {
+	filter, ok := partNumberScope(w, r)
+	if !ok {
+		return
+	}
+	if codes := r.URL.Query()["partNumber"]; len(codes) > 0 {
+		filter["partNumber"] = bson.M{"$in": codes}
+	}
+	if productID := r.URL.Query().Get("productId"); productID != "" {
+		filter["productId"] = productID
+	}
+
+	partNumbers, err := h.db.GetPartNumbers(filter)
+	if err != nil {
+		http.Error(w, "Failed to retrieve part numbers", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(partNumbers)
+}
+
+func (h *Handler) GetPartNumberByID(w http.ResponseWriter, r *http.Request) {
+	pn, ok := h.ownedPartNumber(w, r, true)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(pn)
+}
+
+// UpdatePartNumber changes the code or the item it maps to. The seller and
+// customer of a part number never change.
+func (h *Handler) UpdatePartNumber(w http.ResponseWriter, r *http.Request) {
+	pn, ok := h.ownedPartNumber(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req PartNumberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !h.applyPartNumberRequest(w, pn, &req) {
+		return
+	}
+	if err := h.db.UpdatePartNumber(pn); err != nil {
+		partNumberSaveError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(pn)
+}
+
+func (h *Handler) DeletePartNumber(w http.ResponseWriter, r *http.Request) {
+	pn, ok := h.ownedPartNumber(w, r, false)
+	if !ok {
+		return
+	}
+	if err := h.db.DeletePartNumber(pn.ID); err != nil {
+		http.Error(w, "Failed to delete part number", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyPartNumberRequest validates req and copies it onto pn, writing an error
+// and returning false when it is invalid. The item must be a product of the
+// seller the customer may see, naming a variant exactly when the product has
+// variants.
+func (h *Handler) applyPartNumberRequest(w http.ResponseWriter, pn *storage.PartNumber, req *PartNumberRequest) bool {
+	fail := func(msg string) bool {
+		http.Error(w, msg, http.StatusBadRequest)
+		return false
+	}
+
+	code := strings.TrimSpace(req.PartNumber)
+	if code == "" {
+		return fail("partNumber is required")
+	}
+	id, err := primitive.ObjectIDFromHex(req.ProductID)
+	if err != nil {
+		return fail("Invalid product ID")
+	}
+	product, err := h.db.GetProductByID(id)
+	if err != nil || product.SellerID != pn.SellerID {
+		return fail("product not found: " + req.ProductID)
+	}
+	visible, err := h.visibleTo(pn.CustomerID, product)
+	if err != nil {
+		http.Error(w, "Failed to retrieve visibility rules", http.StatusInternalServerError)
+		return false
+	}
+	if !visible {
+		return fail("product not found: " + req.ProductID)
+	}
+	switch {
+	case req.VariantID != "" && product.FindVariant(req.VariantID) == nil:
+		return fail("variant not found: " + req.VariantID)
+	case req.VariantID == "" && len(product.Variants) > 0:
+		return fail("product has variants; name one with variantId")
+	}
+
+	pn.PartNumber = code
+	pn.ProductID = req.ProductID
+	pn.VariantID = req.VariantID
+	return true
+}
+
+func partNumberSaveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrPartNumberTaken) {
+		http.Error(w, "The customer already uses this part number, or has one for this item", http.StatusConflict)
+		return
+	}
+	http.Error(w, "Failed to save part number", http.StatusInternalServerError)
+}
+
+// partNumberScope returns the filter selecting the part numbers the caller may
+// see, narrowed by ?sellerId= or ?customerId= where the role allows.
+func partNumberScope(w http.ResponseWriter, r *http.Request) (bson.M, bool) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	query := r.URL.Query()
+	filter := bson.M{}
+	switch userClaims["role"] {
+	case "customer":
+		filter["customerId"] = userClaims["id"].(string)
+		if sellerID := query.Get("sellerId"); sellerID != "" {
+			filter["sellerID"] = sellerID
+		}
+	case "company":
+		filter["sellerID"] = userClaims["id"].(string)
+		if customerID := query.Get("customerId"); customerID != "" {
+			filter["customerId"] = customerID
+		}
+	case "admin":
+		if sellerID := query.Get("sellerId"); sellerID != "" {
+			filter["sellerID"] = sellerID
+		}
+		if customerID := query.Get("customerId"); customerID != "" {
+			filter["customerId"] = customerID
+		}
+	default:
+		http.Error(w, "Unauthorized: Invalid role", http.StatusForbidden)
+		return nil, false
+	}
+	return filter, true
+}
+
+// ownedPartNumber loads the part number in the URL if the caller is its
+// customer or seller, or an admin when allowAdmin is set.
+func (h *Handler) ownedPartNumber(w http.ResponseWriter, r *http.Request, allowAdmin bool) (*storage.PartNumber, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	pn, err := h.db.GetPartNumberByID(id)
+	if err != nil {
+		http.Error(w, "Part number not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	accountID := userClaims["id"].(string)
+	switch {
+	case userClaims["role"] == "customer" && pn.CustomerID == accountID:
+	case userClaims["role"] == "company" && pn.SellerID == accountID:
+	case allowAdmin && userClaims["role"] == "admin":
+	default:
+		http.Error(w, "Unauthorized access to part number", http.StatusForbidden)
+		return nil, false
+	}
+	return pn, true
+}
+
+// applyPartNumbers fills in the customer's part numbers on the products and
+// their variants.
+func (h *Handler) applyPartNumbers(products []*storage.Product, customerID string) error {
+	var ids []string
+	for _, p := range products {
+		ids = append(ids, p.ID.Hex())
+	}
+	codes, err := h.partNumbersFor(customerID, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		p.CustomerPartNumber = codes[itemKey{p.ID.Hex(), ""}]
+		for i := range p.Variants {
+			v := &p.Variants[i]
+			v.CustomerPartNumber = codes[itemKey{p.ID.Hex(), v.ID}]
+		}
+	}
+	return nil
+}
+
+type itemKey struct{ productID, variantID string }
+
+// partNumbersFor returns a customer's codes for the given products, by item.
+func (h *Handler) partNumbersFor(customerID string, productIDs []string) (map[itemKey]string, error) {
+	codes := map[itemKey]string{}
+	if len(productIDs) == 0 {
+		return codes, nil
+	}
+	partNumbers, err := h.db.GetPartNumbers(bson.M{"customerId": customerID, "productId": bson.M{"$in": productIDs}})
+	if err != nil {
+		return nil, err
+	}
+	for _, pn := range partNumbers {
+		codes[itemKey{pn.ProductID, pn.VariantID}] = pn.PartNumber
+	}
+	return codes, nil
+}
+
+// partNumberProducts returns the IDs of the products the given codes map to
+// within filter, which scopes the part numbers to the caller.
+func (h *Handler) partNumberProducts(filter bson.M, codes []string) ([]string, error) {
+	filter["partNumber"] = bson.M{"$in": codes}
+	partNumbers, err := h.db.GetPartNumbers(filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, pn := range partNumbers {
+		if !slices.Contains(ids, pn.ProductID) {
+			ids = append(ids, pn.ProductID)
+		}
+	}
+	return ids, nil
+}
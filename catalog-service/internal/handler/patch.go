@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// mutableFields are the product fields a seller may change through
+// UpdateProduct. Everything else is set by the service or through its own
+// endpoints.
+var mutableFields = map[string]bool{
+	"name":           true,
+	"description":    true,
+	"price":          true,
+	"currency":       true,
+	"categoryId":     true,
+	"status":         true,
+	"publishAt":      true,
+	"unpublishAt":    true,
+	"sku":            true,
+	"gtin":           true,
+	"upc":            true,
+	"unitOfMeasure":  true,
+	"packSize":       true,
+	"weight":         true,
+	"dimensions":     true,
+	"quantityRules":  true,
+	"components":     true,
+	"priceTiers":     true,
+	"attributes":     true,
+	"variantOptions": true,
+	"variants":       true,
+}
+
+// requiredFields cannot be removed with null.
+var requiredFields = map[string]bool{"name": true, "price": true, "status": true}
+
+// patchProduct applies a JSON Merge Patch (RFC 7386) to product. It returns
+// the patched product and the top-level fields the patch touched, or an
+// error suitable for showing to the seller.
+func patchProduct(product *storage.Product, body []byte) (storage.Product, []string, error) {
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return storage.Product{}, nil, fmt.Errorf("body must be a JSON object")
+	}
+	var fields []string
+	for field, value := range patch {
+		if !mutableFields[field] {
+			return storage.Product{}, nil, fmt.Errorf("field %q cannot be changed", field)
+		}
+		if value == nil && requiredFields[field] {
+			return storage.Product{}, nil, fmt.Errorf("field %q cannot be removed", field)
+		}
+		fields = append(fields, field)
+	}
+
+	current, err := json.Marshal(product)
+	if err != nil {
+		return storage.Product{}, nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return storage.Product{}, nil, err
+	}
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return storage.Product{}, nil, err
+	}
+
+	var patched storage.Product
+	if err := json.Unmarshal(merged, &patched); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok && typeErr.Field != "" {
+			return storage.Product{}, nil, fmt.Errorf("field %q has an invalid value", typeErr.Field)
+		}
+		return storage.Product{}, nil, fmt.Errorf("invalid product: %v", err)
+	}
+	return patched, fields, nil
+}
+
+// mergePatch applies a merge patch to a decoded JSON document: objects are
+// merged recursively, null removes a member and anything else replaces it.
+func mergePatch(target, patch interface{}) interface{} {
+	fields, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]interface{})
+	if !ok {
+		doc = map[string]interface{}{}
+	}
+	for key, value := range fields {
+		if value == nil {
+			delete(doc, key)
+		} else {
+			doc[key] = mergePatch(doc[key], value)
+		}
+	}
+	return doc
+}
+
+// patchUpdate splits the patched fields into the stored values to set and
+// the fields to remove.
+func patchUpdate(product *storage.Product, fields []string) (bson.M, []string, error) {
+	raw, err := bson.Marshal(product)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, err
+	}
+	set := bson.M{}
+	var unset []string
+	for _, field := range fields {
+		if value, ok := doc[field]; ok {
+			set[field] = value
+		} else {
+			unset = append(unset, field)
+		}
+	}
+	return set, unset, nil
+}
+
+// etag is the entity tag of a product's current revision.
+func etag(product *storage.Product) string {
+	return `"` + strconv.Itoa(product.Revision) + `"`
+}
+
+// ifMatch reports whether the request's If-Match header, if any, matches the
+// product's current revision. Only strong tags match.
+func ifMatch(r *http.Request, product *storage.Product) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag(product) {
+			return true
+		}
+	}
+	return false
+}
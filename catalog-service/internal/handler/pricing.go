@@ -0,0 +1,556 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"business-cart/catalog-service/internal/fx"
+	"business-cart/catalog-service/internal/pricing"
+	"business-cart/catalog-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PriceListRequest struct {
+	Name        string                   `json:"name"`
+	Type        string                   `json:"type"` // fixed | percent
+	PercentOff  float64                  `json:"percentOff"`
+	Entries     []storage.PriceListEntry `json:"entries"`
+	CustomerIDs []string                 `json:"customerIds"`
+	GroupIDs    []string                 `json:"groupIds"`
+	ValidFrom   *time.Time               `json:"validFrom"`
+	ValidTo     *time.Time               `json:"validTo"`
+	Active      *bool                    `json:"active"` // defaults to true on create
+}
+
+type CustomerGroupRequest struct {
+	Name        string   `json:"name"`
+	CustomerIDs []string `json:"customerIds"`
+}
+
+type ResolvePricesRequest struct {
+	SellerID   string     `json:"sellerId"`
+	CustomerID string     `json:"customerId"` // set by companies and admins; customers price for themselves
+	At         *time.Time `json:"at"`         // defaults to now; ignored for customers
+	Currency   string     `json:"currency"`   // defaults to the first line's product currency
+	Lines      []struct {
+		ProductID string `json:"productId"`
+		VariantID string `json:"variantId"`
+		Quantity  int    `json:"quantity"` // defaults to 1
+	} `json:"lines"`
+}
+
+/* ---------- price lists ---------- */
+
+func (h *Handler) CreatePriceList(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != "company" {
+		http.Error(w, "Unauthorized: Company role required", http.StatusForbidden)
+		return
+	}
+
+	var req PriceListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	list := &storage.PriceList{SellerID: userClaims["id"].(string), Active: true}
+	if !h.applyPriceListRequest(w, list, &req) {
+		return
+	}
+	if err := h.db.CreatePriceList(list); err != nil {
+		http.Error(w, "Failed to create price list", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetPriceLists lists the caller's price lists; admins pass ?sellerId=.
+func (h *Handler) GetPriceLists(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := sellerScope(w, r)
+	if !ok {
+		return
+	}
+
+	lists, err := h.db.GetPriceLists(bson.M{"sellerID": sellerID})
+	if err != nil {
+		http.Error(w, "Failed to retrieve price lists", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(lists)
+}
+
+func (h *Handler) GetPriceListByID(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.ownedPriceList(w, r, true)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *Handler) UpdatePriceList(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.ownedPriceList(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req PriceListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !h.applyPriceListRequest(w, list, &req) {
+		return
+	}
+	if err := h.db.UpdatePriceList(list); err != nil {
+		http.Error(w, "Failed to update price list", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *Handler) DeletePriceList(w http.ResponseWriter, r *http.Request) {
+	list, ok := h.ownedPriceList(w, r, false)
+	if !ok {
+		return
+	}
+	if err := h.db.DeletePriceList(list.ID); err != nil {
+		http.Error(w, "Failed to delete price list", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/* ---------- customer groups ---------- */
+
+func (h *Handler) CreateCustomerGroup(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != "company" {
+		http.Error(w, "Unauthorized: Company role required", http.StatusForbidden)
+		return
+	}
+
+	var req CustomerGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	group := &storage.CustomerGroup{
+		SellerID:    userClaims["id"].(string),
+		Name:        req.Name,
+		CustomerIDs: nonNil(req.CustomerIDs),
+	}
+	if err := h.db.CreateCustomerGroup(group); err != nil {
+		http.Error(w, "Failed to create customer group", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+// GetCustomerGroups lists the caller's customer groups; admins pass ?sellerId=.
+func (h *Handler) GetCustomerGroups(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := sellerScope(w, r)
+	if !ok {
+		return
+	}
+
+	groups, err := h.db.GetCustomerGroups(sellerID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve customer groups", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(groups)
+}
+
+func (h *Handler) GetCustomerGroupByID(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.ownedCustomerGroup(w, r, true)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(group)
+}
+
+func (h *Handler) UpdateCustomerGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.ownedCustomerGroup(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req CustomerGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	group.Name = req.Name
+	group.CustomerIDs = nonNil(req.CustomerIDs)
+	if err := h.db.UpdateCustomerGroup(group); err != nil {
+		http.Error(w, "Failed to update customer group", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(group)
+}
+
+func (h *Handler) DeleteCustomerGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.ownedCustomerGroup(w, r, false)
+	if !ok {
+		return
+	}
+	if err := h.db.DeleteCustomerGroup(group.ID); err != nil {
+		http.Error(w, "Failed to delete customer group", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/* ---------- price resolution ---------- */
+
+// ResolvePrices returns the price a customer pays for each line. Checkout
+// calls it with the buyer's token to price carts and quotes.
+func (h *Handler) ResolvePrices(w http.ResponseWriter, r *http.Request) {
+	var req ResolvePricesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	switch userClaims["role"] {
+	case "customer":
+		// Customers price for themselves and only as of now, so an old or
+		// future price list cannot be picked.
+		req.CustomerID = userClaims["id"].(string)
+		req.At = nil
+	case "company":
+		req.SellerID = userClaims["id"].(string)
+	case "admin":
+	default:
+		http.Error(w, "Unauthorized: Invalid role", http.StatusForbidden)
+		return
+	}
+	if req.SellerID == "" || req.CustomerID == "" || len(req.Lines) == 0 {
+		http.Error(w, "sellerId, customerId and lines are required", http.StatusBadRequest)
+		return
+	}
+	if !canBuyFrom(userClaims, req.SellerID) {
+		http.Error(w, "Unauthorized access to seller", http.StatusForbidden)
+		return
+	}
+	req.Currency = strings.ToUpper(req.Currency)
+	if req.Currency != "" && !fx.ValidCode(req.Currency) {
+		http.Error(w, "currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+	at := time.Now()
+	if req.At != nil {
+		at = *req.At
+	}
+
+	var ids []primitive.ObjectID
+	for _, line := range req.Lines {
+		id, err := primitive.ObjectIDFromHex(line.ProductID)
+		if err != nil {
+			http.Error(w, "Invalid product ID", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	products, err := h.db.GetProducts(bson.M{"_id": bson.M{"$in": ids}, "sellerID": req.SellerID})
+	if err != nil {
+		http.Error(w, "Failed to retrieve products", http.StatusInternalServerError)
+		return
+	}
+	byID := map[string]*storage.Product{}
+	for _, p := range products {
+		byID[p.ID.Hex()] = p
+	}
+
+	lists, err := h.db.GetApplicablePriceLists(req.SellerID, req.CustomerID, at)
+	if err != nil {
+		http.Error(w, "Failed to retrieve price lists", http.StatusInternalServerError)
+		return
+	}
+	restrictions, err := h.customerRestrictions(req.CustomerID, []string{req.SellerID})
+	if err != nil {
+		http.Error(w, "Failed to retrieve visibility rules", http.StatusInternalServerError)
+		return
+	}
+
+	prices := []pricing.Price{}
+	unavailable := []string{}
+	for _, line := range req.Lines {
+		product, ok := byID[line.ProductID]
+		if !ok {
+			http.Error(w, "Product not found: "+line.ProductID, http.StatusNotFound)
+			return
+		}
+		if line.VariantID != "" && product.FindVariant(line.VariantID) == nil {
+			http.Error(w, "Variant not found: "+line.VariantID, http.StatusNotFound)
+			return
+		}
+		if !product.Published(at) || !visibleUnder(restrictions, product) {
+			unavailable = append(unavailable, line.ProductID)
+			continue
+		}
+		prices = append(prices, pricing.Resolve(product, line.VariantID, line.Quantity, lists))
+	}
+	if len(unavailable) > 0 {
+		unavailableResponse(w, unavailable)
+		return
+	}
+	var productIDs []string
+	for id := range byID {
+		productIDs = append(productIDs, id)
+	}
+	codes, err := h.partNumbersFor(req.CustomerID, productIDs)
+	if err != nil {
+		http.Error(w, "Failed to retrieve part numbers", http.StatusInternalServerError)
+		return
+	}
+	for i := range prices {
+		prices[i].CustomerPartNumber = codes[itemKey{prices[i].ProductID, prices[i].VariantID}]
+	}
+
+	// Every line is priced in one currency, so the lines add up
+	if req.Currency == "" {
+		req.Currency = prices[0].Currency
+	}
+	rates := map[string]fx.Rate{}
+	for i := range prices {
+		from := prices[i].Currency
+		rate, ok := rates[from]
+		if !ok {
+			var err error
+			if rate, err = h.rates.Rate(r.Context(), from, req.Currency); err != nil {
+				conversionError(w, err)
+				return
+			}
+			rates[from] = rate
+		}
+		prices[i].Convert(rate)
+	}
+	json.NewEncoder(w).Encode(prices)
+}
+
+// unavailableResponse writes a 422 naming the products that cannot be ordered.
+func unavailableResponse(w http.ResponseWriter, productIDs []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      "Products are not available",
+		"productIds": productIDs,
+	})
+}
+
+// applyCustomerPrices fills in the customer's price on each product, loading
+// the applicable price lists once per seller.
+func (h *Handler) applyCustomerPrices(products []*storage.Product, customerID string) error {
+	now := time.Now()
+	listsBySeller := map[string][]*storage.PriceList{}
+	for _, product := range products {
+		lists, ok := listsBySeller[product.SellerID]
+		if !ok {
+			var err error
+			lists, err = h.db.GetApplicablePriceLists(product.SellerID, customerID, now)
+			if err != nil {
+				return err
+			}
+			listsBySeller[product.SellerID] = lists
+		}
+
+		price := pricing.Resolve(product, "", 1, lists)
+		product.CustomerPrice = &price.Price
+		product.PriceListID = price.PriceListID
+		for i := range product.Variants {
+			v := &product.Variants[i]
+			vp := pricing.Resolve(product, v.ID, 1, lists)
+			v.CustomerPrice = &vp.Price
+		}
+	}
+	return nil
+}
+
+// applyPriceListRequest validates req and copies it onto list, writing a 400
+// and returning false when it is invalid.
+func (h *Handler) applyPriceListRequest(w http.ResponseWriter, list *storage.PriceList, req *PriceListRequest) bool {
+	fail := func(msg string) bool {
+		http.Error(w, msg, http.StatusBadRequest)
+		return false
+	}
+
+	if req.Name == "" {
+		return fail("name is required")
+	}
+	switch req.Type {
+	case storage.PriceListFixed:
+		if req.PercentOff != 0 {
+			return fail("percentOff only applies to percent price lists")
+		}
+	case storage.PriceListPercent:
+		if req.PercentOff < 0 || req.PercentOff > 100 {
+			return fail("percentOff must be between 0 and 100")
+		}
+	default:
+		return fail("type must be fixed or percent")
+	}
+	if req.ValidFrom != nil && req.ValidTo != nil && !req.ValidTo.After(*req.ValidFrom) {
+		return fail("validTo must be after validFrom")
+	}
+
+	var ids []primitive.ObjectID
+	for _, e := range req.Entries {
+		id, err := primitive.ObjectIDFromHex(e.ProductID)
+		if err != nil {
+			return fail("Invalid product ID in entries")
+		}
+		ids = append(ids, id)
+		if req.Type == storage.PriceListFixed {
+			if e.Price == nil || *e.Price < 0 || e.PercentOff != nil {
+				return fail("fixed price list entries need a non-negative price")
+			}
+			if err := storage.ValidatePriceTiers(e.PriceTiers); err != nil {
+				return fail(err.Error())
+			}
+		}
+		if req.Type == storage.PriceListPercent && (e.Price != nil || len(e.PriceTiers) > 0 || e.PercentOff == nil || *e.PercentOff < 0 || *e.PercentOff > 100) {
+			return fail("percent price list entries need a percentOff between 0 and 100")
+		}
+	}
+	if len(ids) > 0 {
+		products, err := h.db.GetProducts(bson.M{"_id": bson.M{"$in": ids}, "sellerID": list.SellerID})
+		if err != nil {
+			http.Error(w, "Failed to retrieve products", http.StatusInternalServerError)
+			return false
+		}
+		byID := map[string]*storage.Product{}
+		for _, p := range products {
+			byID[p.ID.Hex()] = p
+		}
+		for _, e := range req.Entries {
+			product, ok := byID[e.ProductID]
+			if !ok {
+				return fail("entry product not found: " + e.ProductID)
+			}
+			if e.VariantID != "" && product.FindVariant(e.VariantID) == nil {
+				return fail("entry variant not found: " + e.VariantID)
+			}
+		}
+	}
+
+	if len(req.GroupIDs) > 0 {
+		groups, err := h.db.GetCustomerGroups(list.SellerID)
+		if err != nil {
+			http.Error(w, "Failed to retrieve customer groups", http.StatusInternalServerError)
+			return false
+		}
+		own := map[string]bool{}
+		for _, g := range groups {
+			own[g.ID.Hex()] = true
+		}
+		for _, id := range req.GroupIDs {
+			if !own[id] {
+				return fail("customer group not found: " + id)
+			}
+		}
+	}
+
+	list.Name = req.Name
+	list.Type = req.Type
+	list.PercentOff = req.PercentOff
+	list.Entries = req.Entries
+	if list.Entries == nil {
+		list.Entries = []storage.PriceListEntry{}
+	}
+	list.CustomerIDs = nonNil(req.CustomerIDs)
+	list.GroupIDs = nonNil(req.GroupIDs)
+	list.ValidFrom = req.ValidFrom
+	list.ValidTo = req.ValidTo
+	if req.Active != nil {
+		list.Active = *req.Active
+	}
+	return true
+}
+
+func (h *Handler) ownedPriceList(w http.ResponseWriter, r *http.Request, allowAdmin bool) (*storage.PriceList, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	list, err := h.db.GetPriceListByID(id)
+	if err != nil {
+		http.Error(w, "Price list not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if list.SellerID != userClaims["id"].(string) && !(allowAdmin && userClaims["role"] == "admin") {
+		http.Error(w, "Unauthorized access to price list", http.StatusForbidden)
+		return nil, false
+	}
+	return list, true
+}
+
+func (h *Handler) ownedCustomerGroup(w http.ResponseWriter, r *http.Request, allowAdmin bool) (*storage.CustomerGroup, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	group, err := h.db.GetCustomerGroupByID(id)
+	if err != nil {
+		http.Error(w, "Customer group not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if group.SellerID != userClaims["id"].(string) && !(allowAdmin && userClaims["role"] == "admin") {
+		http.Error(w, "Unauthorized access to customer group", http.StatusForbidden)
+		return nil, false
+	}
+	return group, true
+}
+
+// sellerScope returns the seller whose records a company or admin lists.
+func sellerScope(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	switch userClaims["role"] {
+	case "company":
+		return userClaims["id"].(string), true
+	case "admin":
+		if sellerID := r.URL.Query().Get("sellerId"); sellerID != "" {
+			return sellerID, true
+		}
+		http.Error(w, "sellerId is required", http.StatusBadRequest)
+	default:
+		http.Error(w, "Unauthorized: Company role required", http.StatusForbidden)
+	}
+	return "", false
+}
+
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"business-cart/catalog-service/internal/search"
+	"business-cart/catalog-service/internal/visibility"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// searchParams are the query parameters that turn GET /products into a search
+// returning a result envelope. attr.<name> parameters count as well.
+var searchParams = []string{"q", "partNumber", "categoryId", "minPrice", "maxPrice", "inStock", "sort", "limit", "cursor"}
+
+func isSearch(r *http.Request) bool {
+	query := r.URL.Query()
+	for _, param := range searchParams {
+		if query.Has(param) {
+			return true
+		}
+	}
+	for key := range query {
+		if strings.HasPrefix(key, "attr.") {
+			return true
+		}
+	}
+	return false
+}
+
+// searchProducts runs a product search restricted to the sellers in the role
+// filter, and for customers to published products their visibility
+// restrictions allow, and writes {products, nextCursor, total, facets}.
+func (h *Handler) searchProducts(w http.ResponseWriter, r *http.Request, filter bson.M, restrictions []*visibility.Restriction, role, accountID, status string) {
+	q, err := parseSearchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	currency, ok := displayCurrency(w, r)
+	if !ok {
+		return
+	}
+	if categoryID := r.URL.Query().Get("categoryId"); categoryID != "" {
+		// A category includes its subcategories
+		descendants, err := h.db.GetDescendantCategoryIDs(categoryID)
+		if err != nil {
+			http.Error(w, "Failed to search products", http.StatusInternalServerError)
+			return
+		}
+		q.CategoryIDs = append([]string{categoryID}, descendants...)
+	}
+	if codes := r.URL.Query()["partNumber"]; len(codes) > 0 {
+		// Customer part numbers match the products they are mapped to
+		scope, ok := partNumberScope(w, r)
+		if !ok {
+			return
+		}
+		if q.ProductIDs, err = h.partNumberProducts(scope, codes); err != nil {
+			http.Error(w, "Failed to retrieve part numbers", http.StatusInternalServerError)
+			return
+		}
+	}
+	if role == "customer" {
+		now := time.Now()
+		q.PublishedAt = &now
+		q.Restrictions = restrictions
+	} else if status != "" {
+		q.Statuses = []string{status}
+	}
+	switch sellerID := filter["sellerID"].(type) {
+	case string:
+		q.SellerIDs = []string{sellerID}
+	case bson.M:
+		q.SellerIDs = nonNil(sellerID["$in"].([]string))
+	}
+
+	result, err := h.searcher.Search(r.Context(), q)
+	if errors.Is(err, search.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to search products", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.addBreadcrumbs(result.Products); err != nil {
+		http.Error(w, "Failed to retrieve categories", http.StatusInternalServerError)
+		return
+	}
+	if role == "customer" && len(result.Products) > 0 {
+		if err := h.applyCustomerPrices(result.Products, accountID); err != nil {
+			http.Error(w, "Failed to resolve prices", http.StatusInternalServerError)
+			return
+		}
+		if err := h.applyPartNumbers(result.Products, accountID); err != nil {
+			http.Error(w, "Failed to retrieve part numbers", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.convertProducts(r.Context(), result.Products, currency); err != nil {
+		conversionError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+func parseSearchQuery(r *http.Request) (search.Query, error) {
+	query := r.URL.Query()
+	q := search.Query{
+		Text:   strings.TrimSpace(query.Get("q")),
+		Sort:   query.Get("sort"),
+		Cursor: query.Get("cursor"),
+	}
+	if !search.ValidSort(q.Sort) {
+		return q, errors.New("sort must be one of relevance, newest, name, -name, price, -price")
+	}
+
+	for key, values := range query {
+		if name, ok := strings.CutPrefix(key, "attr."); ok && name != "" && len(values) > 0 {
+			if q.Attributes == nil {
+				q.Attributes = map[string]string{}
+			}
+			q.Attributes[name] = values[0]
+		}
+	}
+
+	var err error
+	if q.MinPrice, err = parsePrice(query.Get("minPrice")); err != nil {
+		return q, errors.New("minPrice must be a non-negative number")
+	}
+	if q.MaxPrice, err = parsePrice(query.Get("maxPrice")); err != nil {
+		return q, errors.New("maxPrice must be a non-negative number")
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return q, errors.New("minPrice cannot exceed maxPrice")
+	}
+	if v := query.Get("inStock"); v != "" {
+		if q.InStock, err = strconv.ParseBool(v); err != nil {
+			return q, errors.New("inStock must be true or false")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > search.MaxLimit {
+			return q, errors.New("limit must be between 1 and 200")
+		}
+	}
+	return q, nil
+}
+
+func parsePrice(v string) (*float64, error) {
+	if v == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(v, 64)
+	if err != nil || price < 0 {
+		return nil, errors.New("invalid price")
+	}
+	return &price, nil
+}
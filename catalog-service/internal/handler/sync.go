@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"business-cart/catalog-service/internal/storage"
+	"business-cart/catalog-service/internal/visibility"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// syncLag holds the change feed back from the present. A revision is stamped
+// just before it is written, so the newest ones may not all be readable yet.
+const syncLag = 5 * time.Second
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+)
+
+// ProductChanges is a page of the product change feed. When Reset is set,
+// Products starts the caller's whole catalog, which replaces what they hold;
+// the rest of it follows while HasMore is set.
+type ProductChanges struct {
+	Reset     bool               `json:"reset"`
+	Products  []*storage.Product `json:"products"`
+	Removed   []Tombstone        `json:"removed"`
+	SyncToken string             `json:"syncToken"`
+	HasMore   bool               `json:"hasMore"` // ask again with SyncToken right away
+}
+
+// Tombstone is a product the caller can no longer see: deleted,
+// unpublished or hidden by the seller's visibility rules. It may name a
+// product they never had.
+type Tombstone struct {
+	ID       string `json:"id"`
+	SellerID string `json:"sellerID"`
+}
+
+// syncToken is where a client is in the change feed. It is handed out
+// base64-encoded and opaque.
+type syncToken struct {
+	At     time.Time `json:"t"`           // last revision read
+	ID     string    `json:"i,omitempty"` // and its ID, within a page
+	Window time.Time `json:"w"`           // publish windows are checked from here
+	Scope  string    `json:"s"`           // fingerprint of what the caller sees
+	After  string    `json:"a,omitempty"` // last product sent while a reset is paged
+}
+
+func (t syncToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSyncToken(s string) (syncToken, error) {
+	var t syncToken
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, err
+	}
+	if t.At.IsZero() || t.Scope == "" {
+		return t, errors.New("incomplete sync token")
+	}
+	return t, nil
+}
+
+// GetProductChanges is the change feed offline catalogs sync from. Without
+// a syncToken it returns the caller's whole catalog, as GET /products would,
+// a page at a time, with a token to pass next time. With one it returns the
+// products created or changed since, as they are now, and tombstones for
+// those the caller can no longer see. When what the caller sees changed
+// without any product changing (their sellers, the visibility rules, price
+// lists or part numbers that apply to them, or the category tree), the feed
+// starts over with a reset. For customers, products whose publish window
+// opened or closed are only picked up on the last page, once hasMore is
+// false: the windows are checked from the token's Window up to now, and
+// Window only moves forward then. (Company or customer).
+func (h *Handler) GetProductChanges(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	role := userClaims["role"].(string)
+	accountID := userClaims["id"].(string)
+
+	var sellerIDs []string
+	switch role {
+	case "company":
+		sellerIDs = []string{accountID}
+	case "customer":
+		ids, _ := userClaims["associate_company_ids"].([]interface{})
+		for _, id := range ids {
+			if s, ok := id.(string); ok {
+				sellerIDs = append(sellerIDs, s)
+			}
+		}
+	default:
+		http.Error(w, "Unauthorized: Company or customer role required", http.StatusForbidden)
+		return
+	}
+	sellerIDs = nonNil(sellerIDs)
+
+	limit := defaultSyncLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxSyncLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxSyncLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var token syncToken
+	raw := r.URL.Query().Get("syncToken")
+	if raw != "" {
+		var err error
+		if token, err = decodeSyncToken(raw); err != nil {
+			http.Error(w, "Invalid sync token", http.StatusBadRequest)
+			return
+		}
+	}
+
+	scope, restrictions, err := h.syncScope(role, accountID, sellerIDs)
+	if err != nil {
+		http.Error(w, "Failed to resolve catalog scope", http.StatusInternalServerError)
+		return
+	}
+	filter := syncFilter(role, sellerIDs, restrictions)
+	until := time.Now().Add(-syncLag)
+
+	// A reset pages through the catalog in _id order. Changes made meanwhile
+	// are picked up afterwards from the revisions since it started.
+	reset := raw == "" || token.Scope != scope
+	if reset || token.After != "" {
+		next := syncToken{At: until, Window: until, Scope: scope}
+		if !reset {
+			after, err := primitive.ObjectIDFromHex(token.After)
+			if err != nil {
+				http.Error(w, "Invalid sync token", http.StatusBadRequest)
+				return
+			}
+			filter["_id"] = bson.M{"$gt": after}
+			next = token
+		}
+		products, err := h.db.GetProductPage(filter, limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve products", http.StatusInternalServerError)
+			return
+		}
+		if !h.prepareSyncProducts(w, products, role, accountID) {
+			return
+		}
+		hasMore := len(products) == limit
+		next.After = ""
+		if hasMore {
+			next.After = products[len(products)-1].ID.Hex()
+		}
+		json.NewEncoder(w).Encode(ProductChanges{Reset: reset, Products: products, Removed: []Tombstone{}, SyncToken: next.encode(), HasMore: hasMore})
+		return
+	}
+	if until.Before(token.At) {
+		until = token.At
+	}
+
+	after := storage.ChangeCursor{At: token.At}
+	if token.ID != "" {
+		if after.ID, err = primitive.ObjectIDFromHex(token.ID); err != nil {
+			http.Error(w, "Invalid sync token", http.StatusBadRequest)
+			return
+		}
+	}
+	revisions, err := h.db.GetProductChanges(sellerIDs, after, until, limit)
+	if err != nil {
+		http.Error(w, "Failed to retrieve product changes", http.StatusInternalServerError)
+		return
+	}
+
+	sellerOf := map[string]string{} // changed product -> seller
+	var changed []string
+	mark := func(productID, sellerID string) {
+		if _, ok := sellerOf[productID]; !ok {
+			sellerOf[productID] = sellerID
+			changed = append(changed, productID)
+		}
+	}
+	for _, rev := range revisions {
+		mark(rev.ProductID, rev.SellerID)
+	}
+
+	next := token
+	hasMore := len(revisions) == limit
+	if hasMore {
+		last := revisions[len(revisions)-1]
+		next.At, next.ID = last.CreatedAt, last.ID.Hex()
+	} else {
+		// Products also come and go for customers as their publish
+		// windows open and close, which changes no revision.
+		if role == "customer" {
+			timed, err := h.db.GetProducts(bson.M{
+				"sellerID": bson.M{"$in": sellerIDs},
+				"$or": bson.A{
+					bson.M{"publishAt": bson.M{"$gt": token.Window, "$lte": until}},
+					bson.M{"unpublishAt": bson.M{"$gt": token.Window, "$lte": until}},
+				},
+			})
+			if err != nil {
+				http.Error(w, "Failed to retrieve product changes", http.StatusInternalServerError)
+				return
+			}
+			for _, p := range timed {
+				mark(p.ID.Hex(), p.SellerID)
+			}
+		}
+		next.At, next.ID, next.Window = until, "", until
+	}
+
+	changes := ProductChanges{Products: []*storage.Product{}, Removed: []Tombstone{}, HasMore: hasMore}
+	if len(changed) > 0 {
+		var ids []primitive.ObjectID
+		for _, id := range changed {
+			if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+				ids = append(ids, oid)
+			}
+		}
+		filter["_id"] = bson.M{"$in": ids}
+		products, err := h.db.GetProducts(filter)
+		if err != nil {
+			http.Error(w, "Failed to retrieve products", http.StatusInternalServerError)
+			return
+		}
+		if !h.prepareSyncProducts(w, products, role, accountID) {
+			return
+		}
+		visible := map[string]bool{}
+		for _, p := range products {
+			visible[p.ID.Hex()] = true
+		}
+		for _, id := range changed {
+			if !visible[id] {
+				changes.Removed = append(changes.Removed, Tombstone{ID: id, SellerID: sellerOf[id]})
+			}
+		}
+		changes.Products = products
+	}
+	changes.SyncToken = next.encode()
+	json.NewEncoder(w).Encode(changes)
+}
+
+// syncFilter matches the products the caller sees in the feed: all of a
+// seller's own, and for customers those GET /products lists.
+func syncFilter(role string, sellerIDs []string, restrictions []*visibility.Restriction) bson.M {
+	filter := bson.M{"sellerID": bson.M{"$in": sellerIDs}}
+	if role == "customer" {
+		for key, value := range storage.PublishedFilter(time.Now()) {
+			filter[key] = value
+		}
+		visibility.Exclude(filter, restrictions)
+	}
+	return filter
+}
+
+// prepareSyncProducts adds to synced products what GET /products shows the
+// caller beyond the stored product. It writes the error and returns false
+// when that fails.
+func (h *Handler) prepareSyncProducts(w http.ResponseWriter, products []*storage.Product, role, accountID string) bool {
+	if len(products) == 0 {
+		return true
+	}
+	if err := h.addBreadcrumbs(products); err != nil {
+		http.Error(w, "Failed to retrieve categories", http.StatusInternalServerError)
+		return false
+	}
+	if role != "customer" {
+		return true
+	}
+	if err := h.applyCustomerPrices(products, accountID); err != nil {
+		http.Error(w, "Failed to resolve prices", http.StatusInternalServerError)
+		return false
+	}
+	if err := h.applyPartNumbers(products, accountID); err != nil {
+		http.Error(w, "Failed to retrieve part numbers", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// syncScope fingerprints everything besides the products themselves that
+// decides what the caller's catalog holds, and returns the customer's
+// visibility restrictions on the way. A client whose token carries another
+// fingerprint must start over. Categories, price lists and part numbers are
+// summarised by storage stamps rather than loaded on every poll.
+func (h *Handler) syncScope(role, accountID string, sellerIDs []string) (string, []*visibility.Restriction, error) {
+	sellerIDs = append([]string(nil), sellerIDs...)
+	sort.Strings(sellerIDs)
+	parts := []string{role, accountID, strings.Join(sellerIDs, ",")}
+
+	// Breadcrumbs name a product's categories, platform ones included
+	categories, err := h.db.CategoriesStamp(append(sellerIDs, ""))
+	if err != nil {
+		return "", nil, err
+	}
+	parts = append(parts, "c:"+categories)
+
+	var restrictions []*visibility.Restriction
+	if role == "customer" {
+		if restrictions, err = h.customerRestrictions(accountID, sellerIDs); err != nil {
+			return "", nil, err
+		}
+		var fingerprints []string
+		for _, r := range restrictions {
+			fingerprints = append(fingerprints, r.Fingerprint())
+		}
+		sort.Strings(fingerprints)
+		parts = append(parts, fingerprints...)
+
+		lists, err := h.db.PriceListsStamp(sellerIDs, accountID, time.Now())
+		if err != nil {
+			return "", nil, err
+		}
+		partNumbers, err := h.db.PartNumbersStamp(accountID, sellerIDs)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, "l:"+lists, "n:"+partNumbers)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:16]), restrictions, nil
+}
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"business-cart/catalog-service/internal/storage"
+	"business-cart/catalog-service/internal/visibility"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type VisibilityRuleRequest struct {
+	Name        string   `json:"name"`
+	ProductIDs  []string `json:"productIds"`
+	CategoryIDs []string `json:"categoryIds"`
+	CustomerIDs []string `json:"customerIds"`
+	GroupIDs    []string `json:"groupIds"`
+	Active      *bool    `json:"active"` // defaults to true on create
+}
+
+func (h *Handler) CreateVisibilityRule(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != "company" {
+		http.Error(w, "Unauthorized: Company role required", http.StatusForbidden)
+		return
+	}
+
+	var req VisibilityRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule := &storage.VisibilityRule{SellerID: userClaims["id"].(string), Active: true}
+	if !h.applyVisibilityRuleRequest(w, rule, &req) {
+		return
+	}
+	if err := h.db.CreateVisibilityRule(rule); err != nil {
+		http.Error(w, "Failed to create visibility rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// GetVisibilityRules lists the caller's visibility rules; admins pass ?sellerId=.
+func (h *Handler) GetVisibilityRules(w http.ResponseWriter, r *http.Request) {
+	sellerID, ok := sellerScope(w, r)
+	if !ok {
+		return
+	}
+
+	rules, err := h.db.GetVisibilityRules(bson.M{"sellerID": sellerID})
+	if err != nil {
+		http.Error(w, "Failed to retrieve visibility rules", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(rules)
+}
+
+func (h *Handler) GetVisibilityRuleByID(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedVisibilityRule(w, r, true)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *Handler) UpdateVisibilityRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedVisibilityRule(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req VisibilityRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !h.applyVisibilityRuleRequest(w, rule, &req) {
+		return
+	}
+	if err := h.db.UpdateVisibilityRule(rule); err != nil {
+		http.Error(w, "Failed to update visibility rule", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *Handler) DeleteVisibilityRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedVisibilityRule(w, r, false)
+	if !ok {
+		return
+	}
+	if err := h.db.DeleteVisibilityRule(rule.ID); err != nil {
+		http.Error(w, "Failed to delete visibility rule", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyVisibilityRuleRequest validates req and copies it onto rule, writing a
+// 400 and returning false when it is invalid.
+func (h *Handler) applyVisibilityRuleRequest(w http.ResponseWriter, rule *storage.VisibilityRule, req *VisibilityRuleRequest) bool {
+	fail := func(msg string) bool {
+		http.Error(w, msg, http.StatusBadRequest)
+		return false
+	}
+
+	if req.Name == "" {
+		return fail("name is required")
+	}
+	if len(req.ProductIDs) == 0 && len(req.CategoryIDs) == 0 {
+		return fail("productIds or categoryIds are required")
+	}
+
+	if len(req.ProductIDs) > 0 {
+		var ids []primitive.ObjectID
+		for _, id := range req.ProductIDs {
+			oid, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return fail("Invalid product ID in productIds")
+			}
+			ids = append(ids, oid)
+		}
+		products, err := h.db.GetProducts(bson.M{"_id": bson.M{"$in": ids}, "sellerID": rule.SellerID})
+		if err != nil {
+			http.Error(w, "Failed to retrieve products", http.StatusInternalServerError)
+			return false
+		}
+		own := map[string]bool{}
+		for _, p := range products {
+			own[p.ID.Hex()] = true
+		}
+		for _, id := range req.ProductIDs {
+			if !own[id] {
+				return fail("product not found: " + id)
+			}
+		}
+	}
+
+	if len(req.CategoryIDs) > 0 {
+		categories, err := h.db.GetCategoriesByIDs(req.CategoryIDs)
+		if err != nil {
+			http.Error(w, "Failed to retrieve categories", http.StatusInternalServerError)
+			return false
+		}
+		usable := map[string]bool{}
+		for _, c := range categories {
+			// Platform categories can be restricted too; the rule only
+			// affects the seller's own products in them
+			if c.SellerID == "" || c.SellerID == rule.SellerID {
+				usable[c.ID.Hex()] = true
+			}
+		}
+		for _, id := range req.CategoryIDs {
+			if !usable[id] {
+				return fail("category not found: " + id)
+			}
+		}
+	}
+
+	if len(req.GroupIDs) > 0 {
+		groups, err := h.db.GetCustomerGroups(rule.SellerID)
+		if err != nil {
+			http.Error(w, "Failed to retrieve customer groups", http.StatusInternalServerError)
+			return false
+		}
+		own := map[string]bool{}
+		for _, g := range groups {
+			own[g.ID.Hex()] = true
+		}
+		for _, id := range req.GroupIDs {
+			if !own[id] {
+				return fail("customer group not found: " + id)
+			}
+		}
+	}
+
+	rule.Name = req.Name
+	rule.ProductIDs = nonNil(req.ProductIDs)
+	rule.CategoryIDs = nonNil(req.CategoryIDs)
+	rule.CustomerIDs = nonNil(req.CustomerIDs)
+	rule.GroupIDs = nonNil(req.GroupIDs)
+	if req.Active != nil {
+		rule.Active = *req.Active
+	}
+	return true
+}
+
+func (h *Handler) ownedVisibilityRule(w http.ResponseWriter, r *http.Request, allowAdmin bool) (*storage.VisibilityRule, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	rule, err := h.db.GetVisibilityRuleByID(id)
+	if err != nil {
+		http.Error(w, "Visibility rule not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if rule.SellerID != userClaims["id"].(string) && !(allowAdmin && userClaims["role"] == "admin") {
+		http.Error(w, "Unauthorized access to visibility rule", http.StatusForbidden)
+		return nil, false
+	}
+	return rule, true
+}
+
+// customerRestrictions loads the restrictions the active visibility rules of
+// the given sellers place on a customer. Sellers without rules are left out.
+func (h *Handler) customerRestrictions(customerID string, sellerIDs []string) ([]*visibility.Restriction, error) {
+	if len(sellerIDs) == 0 {
+		return nil, nil
+	}
+	rules, err := h.db.GetVisibilityRules(bson.M{"sellerID": bson.M{"$in": sellerIDs}, "active": true})
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	bySeller := map[string][]*storage.VisibilityRule{}
+	var categoryIDs []string
+	for _, rule := range rules {
+		bySeller[rule.SellerID] = append(bySeller[rule.SellerID], rule)
+		categoryIDs = append(categoryIDs, rule.CategoryIDs...)
+	}
+	subcategories, err := h.db.GetSubcategoryIDs(categoryIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var restrictions []*visibility.Restriction
+	for sellerID, sellerRules := range bySeller {
+		groupIDs, err := h.db.GetCustomerGroupIDs(sellerID, customerID)
+		if err != nil {
+			return nil, err
+		}
+		if r := visibility.New(sellerID, customerID, groupIDs, sellerRules, subcategories); r != nil {
+			restrictions = append(restrictions, r)
+		}
+	}
+	return restrictions, nil
+}
+
+// visibleTo reports whether a customer may see a product under its seller's
+// visibility rules.
+func (h *Handler) visibleTo(customerID string, product *storage.Product) (bool, error) {
+	restrictions, err := h.customerRestrictions(customerID, []string{product.SellerID})
+	if err != nil {
+		return false, err
+	}
+	return visibleUnder(restrictions, product), nil
+}
+
+func visibleUnder(restrictions []*visibility.Restriction, product *storage.Product) bool {
+	for _, r := range restrictions {
+		if !r.Visible(product) {
+			return false
+		}
+	}
+	return true
+}
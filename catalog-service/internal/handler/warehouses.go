@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WarehouseRequest struct {
+	Name    string          `json:"name"`
+	Code    string          `json:"code"`
+	Address storage.Address `json:"address"`
+	Active  *bool           `json:"active"` // defaults to true on create
+}
+
+// Availability is the sellable stock of a product or variant at one warehouse.
+type Availability struct {
+	ProductID   string `json:"productId"`
+	VariantID   string `json:"variantId,omitempty"`
+	WarehouseID string `json:"warehouseId,omitempty"`
+	Available   int    `json:"available"`
+}
+
+type AvailabilityRequest struct {
+	SellerID   string   `json:"sellerId"`
+	ProductIDs []string `json:"productIds"`
+}
+
+type AvailabilityResponse struct {
+	Warehouses []*storage.Warehouse `json:"warehouses"`
+	Stock      []Availability       `json:"stock"`
+}
+
+func (h *Handler) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if userClaims["role"] != "company" {
+		http.Error(w, "Unauthorized: Company role required", http.StatusForbidden)
+		return
+	}
+
+	var req WarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateWarehouse(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	warehouse := &storage.Warehouse{
+		SellerID: userClaims["id"].(string),
+		Name:     req.Name,
+		Code:     req.Code,
+		Address:  req.Address,
+		Active:   req.Active == nil || *req.Active,
+	}
+	if err := h.db.CreateWarehouse(warehouse); err != nil {
+		http.Error(w, "Failed to create warehouse", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(warehouse)
+}
+
+// GetWarehouses lists a seller's warehouses. Companies see their own; admins
+// and linked customers pass ?sellerId= and customers only see active ones.
+func (h *Handler) GetWarehouses(w http.ResponseWriter, r *http.Request) {
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	sellerID := r.URL.Query().Get("sellerId")
+	activeOnly := false
+	switch userClaims["role"] {
+	case "company":
+		sellerID = userClaims["id"].(string)
+	case "admin":
+	case "customer":
+		activeOnly = true
+	default:
+		http.Error(w, "Unauthorized: Invalid role", http.StatusForbidden)
+		return
+	}
+	if sellerID == "" {
+		http.Error(w, "sellerId is required", http.StatusBadRequest)
+		return
+	}
+	if !canBuyFrom(userClaims, sellerID) {
+		http.Error(w, "Unauthorized access to seller", http.StatusForbidden)
+		return
+	}
+
+	warehouses, err := h.db.GetWarehouses(sellerID, activeOnly)
+	if err != nil {
+		http.Error(w, "Failed to retrieve warehouses", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(warehouses)
+}
+
+func (h *Handler) GetWarehouseByID(w http.ResponseWriter, r *http.Request) {
+	warehouse, ok := h.ownedWarehouse(w, r, true)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(warehouse)
+}
+
+func (h *Handler) UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+	warehouse, ok := h.ownedWarehouse(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req WarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateWarehouse(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	warehouse.Name = req.Name
+	warehouse.Code = req.Code
+	warehouse.Address = req.Address
+	if req.Active != nil {
+		warehouse.Active = *req.Active
+	}
+	if err := h.db.UpdateWarehouse(warehouse); err != nil {
+		http.Error(w, "Failed to update warehouse", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(warehouse)
+}
+
+// DeleteWarehouse removes an empty warehouse. Warehouses still holding stock
+// must be deactivated instead.
+func (h *Handler) DeleteWarehouse(w http.ResponseWriter, r *http.Request) {
+	warehouse, ok := h.ownedWarehouse(w, r, false)
+	if !ok {
+		return
+	}
+
+	hasStock, err := h.db.WarehouseHasStock(warehouse.ID.Hex())
+	if err != nil {
+		http.Error(w, "Failed to check warehouse stock", http.StatusInternalServerError)
+		return
+	}
+	if hasStock {
+		http.Error(w, "Warehouse still holds stock; deactivate it instead", http.StatusConflict)
+		return
+	}
+
+	if err := h.db.DeleteWarehouse(warehouse.ID); err != nil {
+		http.Error(w, "Failed to delete warehouse", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAvailability returns a seller's active warehouses and the available
+// stock of the requested products at each of them, for checkout to source
+// orders from. Products missing from the stock list are not inventory tracked.
+func (h *Handler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	var req AvailabilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.SellerID == "" || len(req.ProductIDs) == 0 {
+		http.Error(w, "sellerId and productIds are required", http.StatusBadRequest)
+		return
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if !canBuyFrom(userClaims, req.SellerID) {
+		http.Error(w, "Unauthorized access to seller", http.StatusForbidden)
+		return
+	}
+
+	// Holds past their expiry would otherwise still count against Available
+	if err := h.db.ReleaseExpiredReservations(); err != nil {
+		http.Error(w, "Failed to release expired reservations", http.StatusInternalServerError)
+		return
+	}
+	warehouses, err := h.db.GetWarehouses(req.SellerID, true)
+	if err != nil {
+		http.Error(w, "Failed to retrieve warehouses", http.StatusInternalServerError)
+		return
+	}
+	active := map[string]bool{"": true}
+	for _, wh := range warehouses {
+		active[wh.ID.Hex()] = true
+	}
+
+	// Bundles are sold from their components' stock
+	var ids []primitive.ObjectID
+	for _, id := range req.ProductIDs {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			ids = append(ids, oid)
+		}
+	}
+	bundles, err := h.db.GetProducts(bson.M{"_id": bson.M{"$in": ids}, "sellerID": req.SellerID, "components.0": bson.M{"$exists": true}})
+	if err != nil {
+		http.Error(w, "Failed to retrieve bundles", http.StatusInternalServerError)
+		return
+	}
+	requested := map[string]bool{}
+	stockIDs := req.ProductIDs
+	for _, id := range req.ProductIDs {
+		requested[id] = true
+	}
+	for _, b := range bundles {
+		for _, c := range b.Components {
+			stockIDs = append(stockIDs, c.ProductID)
+		}
+	}
+
+	levels, err := h.db.GetSellerStock(req.SellerID, stockIDs)
+	if err != nil {
+		http.Error(w, "Failed to retrieve stock", http.StatusInternalServerError)
+		return
+	}
+	resp := AvailabilityResponse{Warehouses: warehouses, Stock: []Availability{}}
+	for _, l := range levels {
+		if !requested[l.ProductID] {
+			continue // a component only asked for through its bundle
+		}
+		available := l.Available
+		if !active[l.WarehouseID] {
+			available = 0 // tracked, but not sellable from an inactive warehouse
+		}
+		resp.Stock = append(resp.Stock, Availability{
+			ProductID:   l.ProductID,
+			VariantID:   l.VariantID,
+			WarehouseID: l.WarehouseID,
+			Available:   available,
+		})
+	}
+	for _, b := range bundles {
+		resp.Stock = append(resp.Stock, bundleStock(b, levels, active)...)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ownedWarehouse loads the warehouse in the URL and checks the caller is its
+// seller, or an admin when allowAdmin is set.
+func (h *Handler) ownedWarehouse(w http.ResponseWriter, r *http.Request, allowAdmin bool) (*storage.Warehouse, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	warehouse, err := h.db.GetWarehouseByID(id)
+	if err != nil {
+		http.Error(w, "Warehouse not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	userClaims := r.Context().Value("user").(map[string]interface{})
+	if warehouse.SellerID != userClaims["id"].(string) && !(allowAdmin && userClaims["role"] == "admin") {
+		http.Error(w, "Unauthorized access to warehouse", http.StatusForbidden)
+		return nil, false
+	}
+	return warehouse, true
+}
+
+func validateWarehouse(req *WarehouseRequest) string {
+	if req.Name == "" {
+		return "name is required"
+	}
+	c := req.Address.Coords
+	if c.Lat < -90 || c.Lat > 90 || c.Lng < -180 || c.Lng > 180 {
+		return "address coordinates are out of range"
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+// Package jobs hands work that outlives a request, such as product imports,
+// to a worker. A Lambda function returns as soon as it has responded, so
+// background goroutines cannot be relied on; the worker is an asynchronous
+// invocation of a Lambda function instead.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Event is the payload a worker is invoked with.
+type Event struct {
+	ImportJobID string `json:"importJobId"`
+}
+
+// Queue hands jobs to a worker.
+type Queue interface {
+	// EnqueueImport asks the worker to run the import job with the given ID.
+	EnqueueImport(ctx context.Context, jobID string) error
+}
+
+// LambdaQueue invokes a Lambda function asynchronously with an Event per job.
+// Lambda retries failed invocations, so the worker must tolerate a job
+// delivered twice.
+type LambdaQueue struct {
+	client   *lambda.Client
+	function string
+}
+
+// NewLambdaQueue creates a queue for the named function, with credentials
+// and region from the environment.
+func NewLambdaQueue(ctx context.Context, function string) (*LambdaQueue, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &LambdaQueue{client: lambda.NewFromConfig(cfg), function: function}, nil
+}
+
+func (q *LambdaQueue) EnqueueImport(ctx context.Context, jobID string) error {
+	payload, err := json.Marshal(Event{ImportJobID: jobID})
+	if err != nil {
+		return err
+	}
+	_, err = q.client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName:   aws.String(q.function),
+		InvocationType: types.InvocationTypeEvent,
+		Payload:        payload,
+	})
+	return err
+}
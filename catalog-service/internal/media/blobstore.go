@@ -0,0 +1,23 @@
+// Package media stores product images and their thumbnails.
+package media
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps binary objects under slash-separated keys.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) (*Blob, error)
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the object.
+	URL(key string) string
+}
+
+type Blob struct {
+	ContentType string
+	Data        []byte
+}
@@ -0,0 +1,77 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FSStore keeps blobs as files under a directory, for local development.
+// Files are served by the service itself under baseURL.
+type FSStore struct {
+	dir     string
+	baseURL string
+}
+
+func NewFSStore(dir, baseURL string) *FSStore {
+	return &FSStore{dir: dir, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// path maps a key to a file under the store directory, refusing keys that
+// would escape it.
+func (s *FSStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean[1:] != key {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *FSStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o644)
+}
+
+func (s *FSStore) Get(ctx context.Context, key string) (*Blob, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &Blob{ContentType: contentType, Data: data}, nil
+}
+
+func (s *FSStore) Delete(ctx context.Context, key string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FSStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
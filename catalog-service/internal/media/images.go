@@ -0,0 +1,159 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxImageBytes bounds an uploaded image.
+	MaxImageBytes = 5 << 20
+	// maxImagePixels guards against small files that decode to huge images.
+	maxImagePixels = 40_000_000
+)
+
+// ImageTypes are the accepted image content types and their file extensions.
+var ImageTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// ThumbnailSize is a named thumbnail bounded by Edge pixels on its longest side.
+type ThumbnailSize struct {
+	Name string
+	Edge int
+}
+
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "small", Edge: 150},
+	{Name: "medium", Edge: 400},
+	{Name: "large", Edge: 800},
+}
+
+// Image is a decoded, validated upload.
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	img         image.Image
+}
+
+// Thumbnail is an encoded thumbnail of an Image.
+type Thumbnail struct {
+	Name        string
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// DecodeImage checks that data is a JPEG, PNG or GIF image within the size
+// limits, judging the type by the content rather than the declared type.
+func DecodeImage(data []byte) (*Image, error) {
+	if len(data) == 0 {
+		return nil, errors.New("the image is empty")
+	}
+	if len(data) > MaxImageBytes {
+		return nil, fmt.Errorf("images are limited to %d MB", MaxImageBytes>>20)
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := ImageTypes[contentType]; !ok {
+		return nil, errors.New("images must be JPEG, PNG or GIF")
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("the image cannot be read")
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, errors.New("the image dimensions are too large")
+	}
+	var img image.Image
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, errors.New("the image cannot be read")
+	}
+	bounds := img.Bounds()
+	return &Image{ContentType: contentType, Width: bounds.Dx(), Height: bounds.Dy(), img: img}, nil
+}
+
+// Thumbnails scales the image down to each of ThumbnailSizes. Images are never
+// scaled up. JPEGs stay JPEGs; other images become PNGs to keep transparency.
+func (i *Image) Thumbnails() ([]Thumbnail, error) {
+	src := image.NewRGBA(image.Rect(0, 0, i.Width, i.Height))
+	draw.Draw(src, src.Bounds(), i.img, i.img.Bounds().Min, draw.Src)
+
+	thumbnails := make([]Thumbnail, 0, len(ThumbnailSizes))
+	for _, size := range ThumbnailSizes {
+		width, height := fit(i.Width, i.Height, size.Edge)
+		scaled := scale(src, width, height)
+
+		var buf bytes.Buffer
+		thumbnail := Thumbnail{Name: size.Name, Width: width, Height: height}
+		if i.ContentType == "image/jpeg" {
+			thumbnail.ContentType = "image/jpeg"
+			if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85}); err != nil {
+				return nil, err
+			}
+		} else {
+			thumbnail.ContentType = "image/png"
+			if err := png.Encode(&buf, scaled); err != nil {
+				return nil, err
+			}
+		}
+		thumbnail.Data = buf.Bytes()
+		thumbnails = append(thumbnails, thumbnail)
+	}
+	return thumbnails, nil
+}
+
+// fit returns the dimensions of a width x height image scaled down so its
+// longest side is at most edge, keeping the aspect ratio.
+func fit(width, height, edge int) (int, int) {
+	if width <= edge && height <= edge {
+		return width, height
+	}
+	if width >= height {
+		return edge, max(1, height*edge/width)
+	}
+	return max(1, width*edge/height), edge
+}
+
+// scale resizes src with a box filter, averaging the source pixels each
+// destination pixel covers.
+func scale(src *image.RGBA, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+uint64(p[0]), g+uint64(p[1]), b+uint64(p[2]), a+uint64(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
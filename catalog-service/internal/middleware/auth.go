@@ -2,13 +2,22 @@ package middleware
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// Revocations tells whether a token ID has been revoked: that of the token
+// itself, its "jti", or that of the session it was issued for, its "sid".
+type Revocations interface {
+	IsRevoked(id string) (bool, error)
+}
+
+// AuthMiddleware accepts requests bearing a valid access token that has not
+// been revoked.
+func AuthMiddleware(jwtSecret string, revocations Revocations) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -39,7 +48,31 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
+			for _, claim := range []string{"jti", "sid"} {
+				id, _ := claims[claim].(string)
+				if id == "" {
+					continue
+				}
+				revoked, err := revocations.IsRevoked(id)
+				if err != nil {
+					log.Printf("Failed to check %s %s: %v", claim, id, err)
+					http.Error(w, "Failed to check token", http.StatusInternalServerError)
+					return
+				}
+				if revoked {
+					http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+					return
+				}
+			}
+
 			ctx := context.WithValue(r.Context(), "user", userClaims)
+
+			// Attribute impersonated requests to the acting admin.
+			if act, ok := claims["act"].(map[string]interface{}); ok {
+				log.Printf("Impersonated request: admin %v (%v) acting as %v: %s %s", act["sub"], act["email"], userClaims["id"], r.Method, r.URL.Path)
+				ctx = context.WithValue(ctx, "act", act)
+			}
+
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
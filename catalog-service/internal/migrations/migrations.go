@@ -0,0 +1,121 @@
+// Package migrations applies ordered, versioned changes to the ProductService
+// database. Every migration must be idempotent so that a partially applied
+// run can simply be repeated.
+//
+// The runner in this file is copied, on purpose, into each service. Every
+// service is its own Go module with its own build and deploy (checkout-service
+// vendors its dependencies), and there is no shared module they could import
+// without replace directives that break those builds. Keep the copies in step
+// by hand.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collection records which versions have been applied.
+const collection = "migrations"
+
+// Migration is a single versioned schema or data change.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record is the document stored for each applied migration.
+type Record struct {
+	Version     int       `bson:"_id" json:"version"`
+	Description string    `bson:"description" json:"description"`
+	AppliedAt   time.Time `bson:"appliedAt" json:"appliedAt"`
+}
+
+// Applied returns the migrations already recorded in the database.
+func Applied(ctx context.Context, db *mongo.Database) ([]Record, error) {
+	cursor, err := db.Collection(collection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var out []Record
+	err = cursor.All(ctx, &out)
+	return out, err
+}
+
+// Pending returns the migrations that have not been applied yet, in order.
+func Pending(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	applied, err := Applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, r := range applied {
+		done[r.Version] = true
+	}
+
+	all := append([]Migration(nil), Migrations...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+
+	var pending []Migration
+	for _, m := range all {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Run applies every pending migration in version order and records each one.
+// It stops at the first failure.
+func Run(ctx context.Context, db *mongo.Database) error {
+	pending, err := Pending(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		log.Printf("migrations: applying %d %s", m.Version, m.Description)
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		_, err := db.Collection(collection).UpdateOne(ctx,
+			bson.M{"_id": m.Version},
+			bson.M{"$set": bson.M{"description": m.Description, "appliedAt": time.Now()}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return fmt.Errorf("recording migration %d: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+// createIndexes is a helper for migrations that only add indexes. Creating an
+// index that already exists with the same definition is a no-op.
+func createIndexes(name string, models ...mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(name).Indexes().CreateMany(ctx, models)
+		return err
+	}
+}
+
+// dropIndex removes an index by name. A missing index is not an error, so the
+// migration stays idempotent.
+func dropIndex(ctx context.Context, coll *mongo.Collection, name string) error {
+	_, err := coll.Indexes().DropOne(ctx, name)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Code == 26) { // IndexNotFound, NamespaceNotFound
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,315 @@
+package migrations
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrations lists every migration of the service. Append new entries with
+// the next version number; never renumber or edit an applied one.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "reshape legacy products: companyId -> sellerID, drop userId",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			products := db.Collection("products")
+			_, err := products.UpdateMany(ctx,
+				bson.M{"sellerID": bson.M{"$exists": false}, "companyId": bson.M{"$exists": true}},
+				bson.M{"$rename": bson.M{"companyId": "sellerID"}},
+			)
+			if err != nil {
+				return err
+			}
+			// userId only goes once the product has a seller; without one
+			// it is the last hint of who owns the product.
+			_, err = products.UpdateMany(ctx,
+				bson.M{"userId": bson.M{"$exists": true}, "sellerID": bson.M{"$exists": true}},
+				bson.M{"$unset": bson.M{"userId": "", "companyId": ""}},
+			)
+			if err != nil {
+				return err
+			}
+			cursor, err := products.Find(ctx,
+				bson.M{"sellerID": bson.M{"$exists": false}},
+				options.Find().SetProjection(bson.M{"_id": 1, "userId": 1}),
+			)
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+			for cursor.Next(ctx) {
+				var orphan struct {
+					ID     primitive.ObjectID `bson:"_id"`
+					UserID interface{}        `bson:"userId"`
+				}
+				if err := cursor.Decode(&orphan); err != nil {
+					return err
+				}
+				log.Printf("migrations: product %s has no seller (userId %v); left as is", orphan.ID.Hex(), orphan.UserID)
+			}
+			return cursor.Err()
+		},
+	},
+	{
+		Version:     2,
+		Description: "products by seller",
+		Up: createIndexes("products",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "createdAt", Value: -1}}},
+		),
+	},
+	{
+		Version:     3,
+		Description: "unique product and variant SKUs per seller",
+		Up: createIndexes("products",
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "sku", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"sku": bson.M{"$gt": ""}}),
+			},
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "variants.sku", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"variants.sku": bson.M{"$gt": ""}}),
+			},
+		),
+	},
+	{
+		Version:     4,
+		Description: "one stock level per product and variant",
+		Up: createIndexes("stock",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "productId", Value: 1}, {Key: "variantId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		),
+	},
+	{
+		Version:     5,
+		Description: "inventory ledger by product",
+		Up: createIndexes("inventoryadjustments",
+			mongo.IndexModel{Keys: bson.D{{Key: "productId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		),
+	},
+	{
+		Version:     6,
+		Description: "one open reservation per reference, expiry sweep",
+		Up: createIndexes("reservations",
+			mongo.IndexModel{
+				Keys: bson.D{{Key: "reference", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"status": "reserved"}),
+			},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+		),
+	},
+	{
+		Version:     7,
+		Description: "stock per warehouse: backfill warehouseId, key stock by product, variant and warehouse",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			stock := db.Collection("stock")
+			_, err := stock.UpdateMany(ctx,
+				bson.M{"warehouseId": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"warehouseId": ""}},
+			)
+			if err != nil {
+				return err
+			}
+			if err := dropIndex(ctx, stock, "productId_1_variantId_1"); err != nil {
+				return err
+			}
+			return createIndexes("stock",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "productId", Value: 1}, {Key: "variantId", Value: 1}, {Key: "warehouseId", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "productId", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "warehouseId", Value: 1}}},
+			)(ctx, db)
+		},
+	},
+	{
+		Version:     8,
+		Description: "warehouses by seller",
+		Up: createIndexes("warehouses",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "name", Value: 1}}},
+		),
+	},
+	{
+		Version:     9,
+		Description: "price lists by seller and by assigned customer or group",
+		Up: createIndexes("pricelists",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "customerIds", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "groupIds", Value: 1}}},
+		),
+	},
+	{
+		Version:     10,
+		Description: "customer groups by seller and member",
+		Up: createIndexes("customergroups",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "customerIds", Value: 1}}},
+		),
+	},
+	{
+		Version:     11,
+		Description: "product search: text index on name, description and SKUs, category filter",
+		Up: createIndexes("products",
+			mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "name", Value: "text"},
+					{Key: "description", Value: "text"},
+					{Key: "sku", Value: "text"},
+					{Key: "variants.sku", Value: "text"},
+				},
+				Options: options.Index().SetName("products_text").
+					SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "sku", Value: 10}, {Key: "variants.sku", Value: 10}, {Key: "description", Value: 1}}),
+			},
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "categoryId", Value: 1}}},
+		),
+	},
+	{
+		Version:     12,
+		Description: "product import jobs by seller",
+		Up: createIndexes("importjobs",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "createdAt", Value: -1}}},
+		),
+	},
+	{
+		Version:     13,
+		Description: "category trees by seller and parent, and by ancestor",
+		Up: createIndexes("categories",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "parentId", Value: 1}, {Key: "position", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "path", Value: 1}}},
+		),
+	},
+	{
+		Version:     14,
+		Description: "product lifecycle: existing products are active, products by seller and status",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			products := db.Collection("products")
+			if _, err := products.UpdateMany(ctx,
+				bson.M{"status": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"status": "active"}},
+			); err != nil {
+				return err
+			}
+			return createIndexes("products",
+				mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "status", Value: 1}}},
+			)(ctx, db)
+		},
+	},
+	{
+		Version:     15,
+		Description: "visibility rules by seller",
+		Up: createIndexes("visibilityrules",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "active", Value: 1}}},
+		),
+	},
+	{
+		Version:     16,
+		Description: "product history: one revision per change, baseline revision for existing products",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createIndexes("productrevisions",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "productId", Value: 1}, {Key: "revision", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{Keys: bson.D{{Key: "productId", Value: 1}, {Key: "createdAt", Value: -1}}},
+			)(ctx, db); err != nil {
+				return err
+			}
+
+			// Products from before history record their current state as
+			// revision 1, attributed to nobody.
+			products := db.Collection("products")
+			revisions := db.Collection("productrevisions")
+			cursor, err := products.Find(ctx, bson.M{"revision": bson.M{"$exists": false}})
+			if err != nil {
+				return err
+			}
+			defer cursor.Close(ctx)
+			for cursor.Next(ctx) {
+				var product bson.M
+				if err := cursor.Decode(&product); err != nil {
+					return err
+				}
+				product["revision"] = 1
+				id := product["_id"].(primitive.ObjectID)
+				createdAt := product["updatedAt"]
+				if createdAt == nil {
+					createdAt = id.Timestamp()
+				}
+				_, err := revisions.UpdateOne(ctx,
+					bson.M{"productId": id.Hex(), "revision": 1},
+					bson.M{"$setOnInsert": bson.M{
+						"productId": id.Hex(),
+						"sellerID":  product["sellerID"],
+						"revision":  1,
+						"action":    "create",
+						"actorId":   "",
+						"actorRole": "",
+						"changes":   bson.A{},
+						"product":   product,
+						"createdAt": createdAt,
+					}},
+					options.Update().SetUpsert(true),
+				)
+				if err != nil {
+					return err
+				}
+				if _, err := products.UpdateOne(ctx,
+					bson.M{"_id": id, "revision": bson.M{"$exists": false}},
+					bson.M{"$set": bson.M{"revision": 1}},
+				); err != nil {
+					return err
+				}
+			}
+			return cursor.Err()
+		},
+	},
+	{
+		Version:     17,
+		Description: "price existing products in the default currency",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("products").UpdateMany(ctx,
+				bson.M{"currency": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"currency": "USD"}},
+			)
+			return err
+		},
+	},
+	{
+		Version:     18,
+		Description: "bundles by component",
+		Up: createIndexes("products",
+			mongo.IndexModel{Keys: bson.D{{Key: "components.productId", Value: 1}}},
+		),
+	},
+	{
+		Version:     19,
+		Description: "customer part numbers: one code per item and one item per code",
+		Up: createIndexes("partnumbers",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "customerId", Value: 1}, {Key: "partNumber", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "customerId", Value: 1}, {Key: "productId", Value: 1}, {Key: "variantId", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "productId", Value: 1}}},
+		),
+	},
+	{
+		Version:     20,
+		Description: "product change feed: revisions by seller in log order",
+		Up: createIndexes("productrevisions",
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerID", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+		),
+	},
+	{
+		Version:     21,
+		Description: "import uploads TTL on createdAt, for jobs the worker never ran",
+		Up: createIndexes("importuploads",
+			mongo.IndexModel{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(24 * 60 * 60)},
+		),
+	},
+}
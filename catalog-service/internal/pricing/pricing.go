@@ -0,0 +1,162 @@
+// Package pricing resolves the price a customer pays for a product.
+package pricing
+
+import (
+	"business-cart/catalog-service/internal/fx"
+	"business-cart/catalog-service/internal/storage"
+)
+
+// Price is the resolved unit price of a product or variant for one customer
+// at a given quantity.
+type Price struct {
+	ProductID   string  `json:"productId"`
+	VariantID   string  `json:"variantId,omitempty"`
+	Quantity    int     `json:"quantity"`
+	ListPrice   float64 `json:"listPrice"`
+	Price       float64 `json:"price"`
+	Currency    string  `json:"currency"`
+	PriceListID string  `json:"priceListId,omitempty"` // empty when the list price applies
+	Tier        *Tier   `json:"tier,omitempty"`        // the quantity break applied, if any
+	Revision    int     `json:"revision"`              // the product revision priced
+
+	// CustomerPartNumber is the customer's own code for the item, if any
+	CustomerPartNumber string `json:"customerPartNumber,omitempty"`
+
+	// ExchangeRate converted the prices from the product's currency, if any
+	ExchangeRate *fx.Rate `json:"exchangeRate,omitempty"`
+
+	// QuantityRules the line's quantity must meet, for checkout to enforce
+	QuantityRules *storage.QuantityRules `json:"quantityRules,omitempty"`
+
+	// Components of a bundle, for checkout to reserve and ship in its place
+	Components []storage.BundleComponent `json:"components,omitempty"`
+}
+
+// Tier describes the quantity break a price came from. MaxQuantity is zero
+// for the open-ended top tier.
+type Tier struct {
+	MinQuantity int     `json:"minQuantity"`
+	MaxQuantity int     `json:"maxQuantity,omitempty"`
+	Price       float64 `json:"price"`
+}
+
+// ListPrice is the seller's standard unit price of a product or variant at a
+// quantity. A variant with its own price uses its own tiers; otherwise the
+// product's price and tiers apply.
+func ListPrice(product *storage.Product, variantID string, quantity int) (float64, *Tier) {
+	if v := product.FindVariant(variantID); v != nil && v.Price != nil {
+		return tierPrice(*v.Price, v.PriceTiers, quantity)
+	}
+	return tierPrice(product.Price, product.PriceTiers, quantity)
+}
+
+// Resolve returns the unit price of a product or variant at a quantity under
+// the given price lists, which must already be filtered to those assigned to
+// the customer and valid at the time of pricing. When several lists price
+// the item, the lowest price wins; without any the list price applies.
+func Resolve(product *storage.Product, variantID string, quantity int, lists []*storage.PriceList) Price {
+	if quantity < 1 {
+		quantity = 1
+	}
+	listPrice, listTier := ListPrice(product, variantID, quantity)
+	best := Price{
+		ProductID: product.ID.Hex(),
+		VariantID: variantID,
+		Quantity:  quantity,
+		ListPrice: listPrice,
+		Price:     listPrice,
+		Currency:  product.Currency,
+		Tier:      listTier,
+		Revision:  product.Revision,
+
+		QuantityRules: product.QuantityRules,
+		Components:    product.Components,
+	}
+	for _, list := range lists {
+		price, tier, ok := listPriceFor(list, product.ID.Hex(), variantID, product.Currency, quantity, listPrice, listTier)
+		if ok && price < best.Price {
+			best.Price = price
+			best.PriceListID = list.ID.Hex()
+			best.Tier = tier
+		}
+	}
+	return best
+}
+
+// Convert restates the price in rate.To. A rate between the same currency
+// leaves it as it is.
+func (p *Price) Convert(rate fx.Rate) {
+	if rate.From == rate.To {
+		return
+	}
+	p.ListPrice = rate.Convert(p.ListPrice)
+	p.Price = rate.Convert(p.Price)
+	if p.Tier != nil {
+		t := *p.Tier
+		t.Price = rate.Convert(t.Price)
+		p.Tier = &t
+	}
+	p.Currency = rate.To
+	p.ExchangeRate = &rate
+}
+
+// listPriceFor prices an item under one list, in the product's currency. A
+// variant entry is more specific than a product entry.
+func listPriceFor(list *storage.PriceList, productID, variantID, currency string, quantity int, listPrice float64, listTier *Tier) (float64, *Tier, bool) {
+	var entry *storage.PriceListEntry
+	for i := range list.Entries {
+		e := &list.Entries[i]
+		if e.ProductID != productID {
+			continue
+		}
+		if variantID != "" && e.VariantID == variantID {
+			entry = e
+			break
+		}
+		if e.VariantID == "" {
+			entry = e
+		}
+	}
+
+	switch list.Type {
+	case storage.PriceListFixed:
+		if entry == nil || entry.Price == nil {
+			return 0, nil, false
+		}
+		price, tier := tierPrice(*entry.Price, entry.PriceTiers, quantity)
+		return price, tier, true
+	case storage.PriceListPercent:
+		percent := list.PercentOff
+		if entry != nil && entry.PercentOff != nil {
+			percent = *entry.PercentOff
+		}
+		if percent == 0 {
+			return 0, nil, false
+		}
+		// The discount applies on top of the list price's own quantity break
+		var tier *Tier
+		if listTier != nil {
+			t := *listTier
+			t.Price = fx.Round(t.Price*(1-percent/100), currency)
+			tier = &t
+		}
+		return fx.Round(listPrice*(1-percent/100), currency), tier, true
+	}
+	return 0, nil, false
+}
+
+// tierPrice picks the highest tier the quantity reaches, or the base price
+// below the first tier.
+func tierPrice(base float64, tiers []storage.PriceTier, quantity int) (float64, *Tier) {
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if quantity < tiers[i].MinQuantity {
+			continue
+		}
+		tier := &Tier{MinQuantity: tiers[i].MinQuantity, Price: tiers[i].Price}
+		if i+1 < len(tiers) {
+			tier.MaxQuantity = tiers[i+1].MinQuantity - 1
+		}
+		return tiers[i].Price, tier
+	}
+	return base, nil
+}
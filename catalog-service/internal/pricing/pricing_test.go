@@ -0,0 +1,144 @@
+package pricing
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+// testProduct costs 10.00, 9.00 from 10 and 8.00 from 50. Its red variant
+// has a price of its own; the blue one inherits the product's.
+func testProduct() *storage.Product {
+	return &storage.Product{
+		ID:         primitive.NewObjectID(),
+		Price:      10,
+		Currency:   "USD",
+		PriceTiers: []storage.PriceTier{{MinQuantity: 10, Price: 9}, {MinQuantity: 50, Price: 8}},
+		Variants: []storage.Variant{
+			{ID: "red", Price: ptr(12), PriceTiers: []storage.PriceTier{{MinQuantity: 10, Price: 11}}},
+			{ID: "blue"},
+		},
+	}
+}
+
+func TestListPrice(t *testing.T) {
+	product := testProduct()
+	tests := []struct {
+		name      string
+		variantID string
+		quantity  int
+		want      float64
+		wantTier  *Tier
+	}{
+		{"below the first tier", "", 1, 10, nil},
+		{"first tier", "", 10, 9, &Tier{MinQuantity: 10, MaxQuantity: 49, Price: 9}},
+		{"last tier of the first", "", 49, 9, &Tier{MinQuantity: 10, MaxQuantity: 49, Price: 9}},
+		{"open-ended top tier", "", 60, 8, &Tier{MinQuantity: 50, Price: 8}},
+		{"variant price", "red", 5, 12, nil},
+		{"variant tier", "red", 10, 11, &Tier{MinQuantity: 10, Price: 11}},
+		{"variant without a price", "blue", 10, 9, &Tier{MinQuantity: 10, MaxQuantity: 49, Price: 9}},
+		{"unknown variant", "green", 1, 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, tier := ListPrice(product, tt.variantID, tt.quantity)
+			if got != tt.want || !reflect.DeepEqual(tier, tt.wantTier) {
+				t.Errorf("ListPrice(%q, %d) = %v, %+v, want %v, %+v", tt.variantID, tt.quantity, got, tier, tt.want, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	product := testProduct()
+	productID := product.ID.Hex()
+	contract := &storage.PriceList{
+		ID:   primitive.NewObjectID(),
+		Type: storage.PriceListFixed,
+		Entries: []storage.PriceListEntry{
+			{ProductID: productID, Price: ptr(8.5), PriceTiers: []storage.PriceTier{{MinQuantity: 20, Price: 7}}},
+			{ProductID: productID, VariantID: "red", Price: ptr(10)},
+		},
+	}
+	tenOff := &storage.PriceList{ID: primitive.NewObjectID(), Type: storage.PriceListPercent, PercentOff: 10}
+	quarterOff := &storage.PriceList{
+		ID:         primitive.NewObjectID(),
+		Type:       storage.PriceListPercent,
+		PercentOff: 10,
+		Entries:    []storage.PriceListEntry{{ProductID: productID, PercentOff: ptr(25)}},
+	}
+	premium := &storage.PriceList{
+		ID:      primitive.NewObjectID(),
+		Type:    storage.PriceListFixed,
+		Entries: []storage.PriceListEntry{{ProductID: productID, Price: ptr(9.5)}},
+	}
+	otherProduct := &storage.PriceList{
+		ID:      primitive.NewObjectID(),
+		Type:    storage.PriceListFixed,
+		Entries: []storage.PriceListEntry{{ProductID: primitive.NewObjectID().Hex(), Price: ptr(1)}},
+	}
+
+	tests := []struct {
+		name      string
+		variantID string
+		quantity  int
+		lists     []*storage.PriceList
+		want      float64
+		wantList  *storage.PriceList // nil when the list price applies
+		wantTier  *Tier
+	}{
+		{"no lists", "", 1, nil, 10, nil, nil},
+		{"quantity below one prices one", "", 0, nil, 10, nil, nil},
+		{"fixed entry", "", 1, []*storage.PriceList{contract}, 8.5, contract, nil},
+		{"fixed entry tier", "", 20, []*storage.PriceList{contract}, 7, contract, &Tier{MinQuantity: 20, Price: 7}},
+		{"list price tier below the fixed entry", "", 60, []*storage.PriceList{premium}, 8, nil, &Tier{MinQuantity: 50, Price: 8}},
+		{"variant entry over the product entry", "red", 1, []*storage.PriceList{contract}, 10, contract, nil},
+		{"product entry for an inheriting variant", "blue", 1, []*storage.PriceList{contract}, 8.5, contract, nil},
+		{"percent on the list price tier", "", 10, []*storage.PriceList{tenOff}, 8.1, tenOff, &Tier{MinQuantity: 10, MaxQuantity: 49, Price: 8.1}},
+		{"percent entry overrides the list's", "", 1, []*storage.PriceList{quarterOff}, 7.5, quarterOff, nil},
+		{"lowest list wins", "", 1, []*storage.PriceList{tenOff, contract}, 8.5, contract, nil},
+		{"no entry for the product", "", 1, []*storage.PriceList{otherProduct}, 10, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(product, tt.variantID, tt.quantity, tt.lists)
+			wantListID := ""
+			if tt.wantList != nil {
+				wantListID = tt.wantList.ID.Hex()
+			}
+			if math.Abs(got.Price-tt.want) > 1e-9 || got.PriceListID != wantListID || !reflect.DeepEqual(got.Tier, tt.wantTier) {
+				t.Errorf("Resolve() = %v from %q with tier %+v, want %v from %q with tier %+v", got.Price, got.PriceListID, got.Tier, tt.want, wantListID, tt.wantTier)
+			}
+			if got.Quantity < 1 || got.ProductID != productID || got.Currency != "USD" {
+				t.Errorf("Resolve() = %+v, want the product's ID and currency and a quantity of at least one", got)
+			}
+		})
+	}
+}
+
+func TestResolveRoundsToCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		price    float64
+		want     float64
+	}{
+		{"USD", 19.99, 16.99}, // 16.9915
+		{"JPY", 1499, 1274},   // 1274.15
+		{"KWD", 4.999, 4.249}, // 4.24915
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			product := &storage.Product{ID: primitive.NewObjectID(), Price: tt.price, Currency: tt.currency}
+			list := &storage.PriceList{ID: primitive.NewObjectID(), Type: storage.PriceListPercent, PercentOff: 15}
+			if got := Resolve(product, "", 1, []*storage.PriceList{list}).Price; math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("15%% off %v %s = %v, want %v", tt.price, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"business-cart/catalog-service/internal/storage"
+	"business-cart/catalog-service/internal/visibility"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemorySearcher searches a fixed set of products held in memory. It follows
+// the same semantics as MongoSearcher and is meant for tests and local runs.
+type MemorySearcher struct {
+	mu       sync.RWMutex
+	products []*storage.Product
+	stock    []storage.StockLevel
+}
+
+func NewMemorySearcher(products []*storage.Product, stock []storage.StockLevel) *MemorySearcher {
+	return &MemorySearcher{products: products, stock: stock}
+}
+
+// Set replaces the searchable products and stock levels.
+func (s *MemorySearcher) Set(products []*storage.Product, stock []storage.StockLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products, s.stock = products, stock
+}
+
+func (s *MemorySearcher) Search(ctx context.Context, q Query) (*Result, error) {
+	q.Normalize()
+	after, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	available := map[string]int{}
+	for _, level := range s.stock {
+		available[level.ProductID] += level.OnHand - level.Reserved
+	}
+
+	type hit struct {
+		product *storage.Product
+		score   int
+	}
+	var hits []hit
+	for _, p := range s.products {
+		if q.SellerIDs != nil && !contains(q.SellerIDs, p.SellerID) {
+			continue
+		}
+		if len(q.CategoryIDs) > 0 && !contains(q.CategoryIDs, p.CategoryID) {
+			continue
+		}
+		if q.ProductIDs != nil && !contains(q.ProductIDs, p.ID.Hex()) {
+			continue
+		}
+		if len(q.Statuses) > 0 && !contains(q.Statuses, p.Status) {
+			continue
+		}
+		if q.PublishedAt != nil && !p.Published(*q.PublishedAt) {
+			continue
+		}
+		if !visible(p, q.Restrictions) {
+			continue
+		}
+		if !matchesAttributes(p, q.Attributes) {
+			continue
+		}
+		if q.MinPrice != nil && p.Price < *q.MinPrice || q.MaxPrice != nil && p.Price > *q.MaxPrice {
+			continue
+		}
+		if q.InStock && !inStock(p, available) {
+			continue
+		}
+		score := 0
+		if q.Text != "" {
+			if score = textScore(p, q.Text); score == 0 {
+				continue
+			}
+		}
+		hits = append(hits, hit{p, score})
+	}
+
+	result := &Result{
+		Products: []*storage.Product{},
+		Total:    int64(len(hits)),
+		Facets:   Facets{Categories: []FacetValue{}, Attributes: map[string][]FacetValue{}},
+	}
+	matched := make([]*storage.Product, len(hits))
+	for i, h := range hits {
+		matched[i] = h.product
+	}
+	countFacets(matched, &result.Facets)
+
+	// Order
+	field, dir := sortField(q.Sort)
+	less := func(a, b *storage.Product) int {
+		c := compareField(a, b, field)
+		if c == 0 {
+			c = strings.Compare(a.ID.Hex(), b.ID.Hex())
+		}
+		return c * dir
+	}
+	if q.Sort == SortRelevance {
+		sort.SliceStable(hits, func(i, j int) bool {
+			if hits[i].score != hits[j].score {
+				return hits[i].score > hits[j].score
+			}
+			return hits[i].product.ID.Hex() < hits[j].product.ID.Hex()
+		})
+	} else {
+		sort.SliceStable(hits, func(i, j int) bool { return less(hits[i].product, hits[j].product) < 0 })
+	}
+
+	// Page
+	start := 0
+	if after != nil {
+		if q.Sort == SortRelevance {
+			start = after.Offset
+		} else {
+			pivot := &storage.Product{ID: after.ID}
+			setField(pivot, field, after.Value)
+			start = sort.Search(len(hits), func(i int) bool { return less(hits[i].product, pivot) > 0 })
+		}
+	}
+	if start > len(hits) {
+		start = len(hits)
+	}
+	end := start + q.Limit
+	if end >= len(hits) {
+		end = len(hits)
+	} else {
+		last := hits[end-1].product
+		next := cursor{Sort: q.Sort, ID: last.ID}
+		switch q.Sort {
+		case SortRelevance:
+			next = cursor{Sort: q.Sort, Offset: end}
+		case SortNameAsc, SortNameDesc:
+			next.Value = last.Name
+		case SortPriceAsc, SortPriceDesc:
+			next.Value = last.Price
+		default:
+			next.Value = last.CreatedAt
+		}
+		result.NextCursor = encodeCursor(next)
+	}
+	for _, h := range hits[start:end] {
+		result.Products = append(result.Products, h.product)
+	}
+	return result, nil
+}
+
+// textScore weighs each query term found in the name or SKUs above one found
+// only in the description, mirroring the text index weights.
+func textScore(p *storage.Product, text string) int {
+	name := strings.ToLower(p.Name)
+	description := strings.ToLower(p.Description)
+	skus := strings.ToLower(strings.Join(p.SKUs(), " "))
+	score := 0
+	for _, term := range strings.Fields(strings.ToLower(text)) {
+		if strings.Contains(name, term) || strings.Contains(skus, term) {
+			score += 10
+		} else if strings.Contains(description, term) {
+			score++
+		}
+	}
+	return score
+}
+
+func matchesAttributes(p *storage.Product, want map[string]string) bool {
+	for name, value := range want {
+		found := false
+		for _, attr := range p.Attributes {
+			if attr.Name == name && fmt.Sprint(attr.Value) == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func countFacets(products []*storage.Product, facets *Facets) {
+	categories := map[string]int64{}
+	attributes := map[string]map[string]int64{}
+	for i, p := range products {
+		if p.CategoryID != "" {
+			categories[p.CategoryID]++
+		}
+		for _, attr := range p.Attributes {
+			if attributes[attr.Name] == nil {
+				attributes[attr.Name] = map[string]int64{}
+			}
+			attributes[attr.Name][fmt.Sprint(attr.Value)]++
+		}
+		if i == 0 {
+			facets.Price = &PriceRange{Min: p.Price, Max: p.Price}
+		} else {
+			facets.Price.Min = min(facets.Price.Min, p.Price)
+			facets.Price.Max = max(facets.Price.Max, p.Price)
+		}
+	}
+	facets.Categories = facetValues(categories)
+	for name, values := range attributes {
+		facets.Attributes[name] = facetValues(values)
+	}
+}
+
+// facetValues orders counts by count descending, then value.
+func facetValues(counts map[string]int64) []FacetValue {
+	values := make([]FacetValue, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, FacetValue{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values
+}
+
+func compareField(a, b *storage.Product, field string) int {
+	switch field {
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "price":
+		switch {
+		case a.Price < b.Price:
+			return -1
+		case a.Price > b.Price:
+			return 1
+		}
+		return 0
+	default:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}
+}
+
+// setField puts a cursor value back on a product so it can be compared.
+func setField(p *storage.Product, field string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if field == "name" {
+			p.Name = v
+		}
+	case float64:
+		if field == "price" {
+			p.Price = v
+		}
+	case primitive.DateTime:
+		p.CreatedAt = v.Time()
+	case time.Time:
+		p.CreatedAt = v
+	}
+}
+
+func visible(p *storage.Product, restrictions []*visibility.Restriction) bool {
+	for _, r := range restrictions {
+		if !r.Visible(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// inStock reports whether an untracked product, or a tracked one with stock,
+// is available. A bundle is out of stock when any of its components is.
+func inStock(p *storage.Product, available map[string]int) bool {
+	ids := []string{p.ID.Hex()}
+	for _, c := range p.Components {
+		ids = append(ids, c.ProductID)
+	}
+	for _, id := range ids {
+		if n, tracked := available[id]; tracked && n <= 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testCatalog is five products of seller s1, in creation order, and one of s2.
+func testCatalog() []*storage.Product {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	product := func(sellerID, name, description string, price float64, categoryID, color string) *storage.Product {
+		created = created.Add(time.Hour)
+		p := &storage.Product{
+			ID:          primitive.NewObjectID(),
+			SellerID:    sellerID,
+			Name:        name,
+			Description: description,
+			Price:       price,
+			CategoryID:  categoryID,
+			Status:      "active",
+			CreatedAt:   created,
+		}
+		if color != "" {
+			p.Attributes = []storage.Attribute{{Name: "color", Type: "text", Value: color}}
+		}
+		return p
+	}
+	return []*storage.Product{
+		product("s1", "Blue Widget", "", 10, "c1", "blue"),
+		product("s1", "Red Widget", "", 20, "c1", "red"),
+		product("s1", "Green Gadget", "Pairs with any widget", 15, "c2", "green"),
+		product("s1", "Yellow Gizmo", "", 10, "c2", "blue"),
+		product("s1", "Amber Lamp", "", 30, "", ""),
+		product("s2", "Widget Pro", "", 99, "c9", "black"),
+	}
+}
+
+func names(products []*storage.Product) []string {
+	out := []string{}
+	for _, p := range products {
+		out = append(out, p.Name)
+	}
+	return out
+}
+
+func TestSearchFacets(t *testing.T) {
+	searcher := NewMemorySearcher(testCatalog(), nil)
+
+	result, err := searcher.Search(context.Background(), Query{SellerIDs: []string{"s1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 5 {
+		t.Errorf("Total = %d, want 5", result.Total)
+	}
+	wantCategories := []FacetValue{{Value: "c1", Count: 2}, {Value: "c2", Count: 2}}
+	if !reflect.DeepEqual(result.Facets.Categories, wantCategories) {
+		t.Errorf("category facets = %+v, want %+v", result.Facets.Categories, wantCategories)
+	}
+	wantColors := []FacetValue{{Value: "blue", Count: 2}, {Value: "green", Count: 1}, {Value: "red", Count: 1}}
+	if !reflect.DeepEqual(result.Facets.Attributes["color"], wantColors) {
+		t.Errorf("color facets = %+v, want %+v", result.Facets.Attributes["color"], wantColors)
+	}
+	if p := result.Facets.Price; p == nil || p.Min != 10 || p.Max != 30 {
+		t.Errorf("price facet = %+v, want 10 to 30", p)
+	}
+
+	// Facets count every match, not just the page, and follow the filters
+	min := 12.0
+	result, err = searcher.Search(context.Background(), Query{
+		SellerIDs:  []string{"s1"},
+		Attributes: map[string]string{"color": "blue"},
+		MinPrice:   &min,
+		Limit:      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 0 || len(result.Products) != 0 || result.Facets.Price != nil {
+		t.Errorf("blue products from 12 = %+v, want none", result)
+	}
+
+	result, err = searcher.Search(context.Background(), Query{SellerIDs: []string{"s1"}, CategoryIDs: []string{"c2"}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 2 || len(result.Products) != 1 {
+		t.Errorf("c2 search = %d of %d products, want 1 of 2", len(result.Products), result.Total)
+	}
+	wantColors = []FacetValue{{Value: "blue", Count: 1}, {Value: "green", Count: 1}}
+	if !reflect.DeepEqual(result.Facets.Attributes["color"], wantColors) {
+		t.Errorf("c2 color facets = %+v, want %+v", result.Facets.Attributes["color"], wantColors)
+	}
+}
+
+func TestSearchSort(t *testing.T) {
+	searcher := NewMemorySearcher(testCatalog(), nil)
+
+	tests := []struct {
+		sort string
+		text string
+		want []string
+	}{
+		{"", "", []string{"Amber Lamp", "Yellow Gizmo", "Green Gadget", "Red Widget", "Blue Widget"}},
+		{SortNewest, "", []string{"Amber Lamp", "Yellow Gizmo", "Green Gadget", "Red Widget", "Blue Widget"}},
+		{SortNameAsc, "", []string{"Amber Lamp", "Blue Widget", "Green Gadget", "Red Widget", "Yellow Gizmo"}},
+		{SortNameDesc, "", []string{"Yellow Gizmo", "Red Widget", "Green Gadget", "Blue Widget", "Amber Lamp"}},
+		// Equal prices fall back to product ID, reversed with the sort
+		{SortPriceAsc, "", []string{"Blue Widget", "Yellow Gizmo", "Green Gadget", "Red Widget", "Amber Lamp"}},
+		{SortPriceDesc, "", []string{"Amber Lamp", "Red Widget", "Green Gadget", "Yellow Gizmo", "Blue Widget"}},
+		// Name matches outrank description matches
+		{"", "widget", []string{"Blue Widget", "Red Widget", "Green Gadget"}},
+		{SortRelevance, "widget", []string{"Blue Widget", "Red Widget", "Green Gadget"}},
+		{SortPriceDesc, "widget", []string{"Red Widget", "Green Gadget", "Blue Widget"}},
+		// Relevance needs text to rank by
+		{SortRelevance, "", []string{"Amber Lamp", "Yellow Gizmo", "Green Gadget", "Red Widget", "Blue Widget"}},
+	}
+	for _, tt := range tests {
+		result, err := searcher.Search(context.Background(), Query{SellerIDs: []string{"s1"}, Text: tt.text, Sort: tt.sort})
+		if err != nil {
+			t.Fatalf("sort %q text %q: %v", tt.sort, tt.text, err)
+		}
+		if got := names(result.Products); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sort %q text %q = %v, want %v", tt.sort, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	searcher := NewMemorySearcher(testCatalog(), nil)
+
+	for _, sort := range []string{SortNewest, SortNameAsc, SortNameDesc, SortPriceAsc, SortPriceDesc, SortRelevance} {
+		text := ""
+		if sort == SortRelevance {
+			text = "widget gizmo lamp gadget"
+		}
+		whole, err := searcher.Search(context.Background(), Query{SellerIDs: []string{"s1"}, Text: text, Sort: sort})
+		if err != nil {
+			t.Fatalf("%s: %v", sort, err)
+		}
+		if whole.NextCursor != "" {
+			t.Errorf("%s: a single page has a next cursor", sort)
+		}
+
+		var paged []*storage.Product
+		q := Query{SellerIDs: []string{"s1"}, Text: text, Sort: sort, Limit: 2}
+		for pages := 0; ; pages++ {
+			if pages == len(whole.Products) {
+				t.Fatalf("%s: paging does not end", sort)
+			}
+			page, err := searcher.Search(context.Background(), q)
+			if err != nil {
+				t.Fatalf("%s page %d: %v", sort, pages+1, err)
+			}
+			if page.Total != whole.Total {
+				t.Errorf("%s page %d: Total = %d, want %d", sort, pages+1, page.Total, whole.Total)
+			}
+			paged = append(paged, page.Products...)
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if got, want := names(paged), names(whole.Products); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: pages = %v, want %v", sort, got, want)
+		}
+	}
+}
+
+func TestSearchCursorFromAnotherSort(t *testing.T) {
+	searcher := NewMemorySearcher(testCatalog(), nil)
+
+	page, err := searcher.Search(context.Background(), Query{SellerIDs: []string{"s1"}, Sort: SortNameAsc, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("no next cursor")
+	}
+
+	for _, sort := range []string{SortNameDesc, SortPriceAsc, SortNewest} {
+		_, err := searcher.Search(context.Background(), Query{SellerIDs: []string{"s1"}, Sort: sort, Cursor: page.NextCursor})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("name cursor under %s: error = %v, want ErrInvalidCursor", sort, err)
+		}
+	}
+	for _, cursor := range []string{"not base64!", "bm90IGJzb24"} {
+		_, err := searcher.Search(context.Background(), Query{SellerIDs: []string{"s1"}, Sort: SortNameAsc, Cursor: cursor})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: error = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package search
+
+import (
+	"context"
+	"strconv"
+
+	"business-cart/catalog-service/internal/storage"
+	"business-cart/catalog-service/internal/visibility"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoSearcher searches the products collection using its text index on
+// name, description and SKUs.
+type MongoSearcher struct {
+	products *mongo.Collection
+	stock    *mongo.Collection
+}
+
+func NewMongoSearcher(db *mongo.Database) *MongoSearcher {
+	return &MongoSearcher{
+		products: db.Collection("products"),
+		stock:    db.Collection("stock"),
+	}
+}
+
+func (s *MongoSearcher) Search(ctx context.Context, q Query) (*Result, error) {
+	q.Normalize()
+	after, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := s.filter(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Products: []*storage.Product{}}
+	if err := s.facets(ctx, filter, result); err != nil {
+		return nil, err
+	}
+
+	// Page
+	findOpts := options.Find().SetLimit(int64(q.Limit + 1))
+	pageFilter := filter
+	offset := 0
+	if q.Sort == SortRelevance {
+		findOpts.SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}})
+		findOpts.SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}})
+		if after != nil {
+			offset = after.Offset
+			findOpts.SetSkip(int64(offset))
+		}
+	} else {
+		field, dir := sortField(q.Sort)
+		findOpts.SetSort(bson.D{{Key: field, Value: dir}, {Key: "_id", Value: dir}})
+		if after != nil {
+			op := "$gt"
+			if dir < 0 {
+				op = "$lt"
+			}
+			pageFilter = bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+				bson.M{field: bson.M{op: after.Value}},
+				bson.M{field: after.Value, "_id": bson.M{op: after.ID}},
+			}}}}
+		}
+	}
+
+	cur, err := s.products.Find(ctx, pageFilter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &result.Products); err != nil {
+		return nil, err
+	}
+
+	if len(result.Products) > q.Limit {
+		result.Products = result.Products[:q.Limit]
+		last := result.Products[len(result.Products)-1]
+		next := cursor{Sort: q.Sort}
+		switch q.Sort {
+		case SortRelevance:
+			next.Offset = offset + q.Limit
+		case SortNameAsc, SortNameDesc:
+			next.Value, next.ID = last.Name, last.ID
+		case SortPriceAsc, SortPriceDesc:
+			next.Value, next.ID = last.Price, last.ID
+		default:
+			next.Value, next.ID = last.CreatedAt, last.ID
+		}
+		result.NextCursor = encodeCursor(next)
+	}
+	return result, nil
+}
+
+func (s *MongoSearcher) filter(ctx context.Context, q Query) (bson.M, error) {
+	filter := bson.M{}
+	if q.Text != "" {
+		filter["$text"] = bson.M{"$search": q.Text}
+	}
+	if q.SellerIDs != nil {
+		filter["sellerID"] = bson.M{"$in": q.SellerIDs}
+	}
+	if len(q.CategoryIDs) > 0 {
+		filter["categoryId"] = bson.M{"$in": q.CategoryIDs}
+	}
+
+	var and bson.A
+	for name, value := range q.Attributes {
+		and = append(and, bson.M{"attributes": bson.M{"$elemMatch": bson.M{
+			"name":  name,
+			"value": bson.M{"$in": attributeValues(value)},
+		}}})
+	}
+	if len(q.Statuses) > 0 {
+		filter["status"] = bson.M{"$in": q.Statuses}
+	}
+	if q.PublishedAt != nil {
+		and = append(and, storage.PublishedFilter(*q.PublishedAt))
+	}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	visibility.Exclude(filter, q.Restrictions)
+
+	price := bson.M{}
+	if q.MinPrice != nil {
+		price["$gte"] = *q.MinPrice
+	}
+	if q.MaxPrice != nil {
+		price["$lte"] = *q.MaxPrice
+	}
+	if len(price) > 0 {
+		filter["price"] = price
+	}
+
+	ids := bson.M{}
+	if q.ProductIDs != nil {
+		in := []primitive.ObjectID{}
+		for _, id := range q.ProductIDs {
+			if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+				in = append(in, oid)
+			}
+		}
+		ids["$in"] = in
+	}
+	if q.InStock {
+		out, err := s.outOfStock(ctx, q.SellerIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > 0 {
+			ids["$nin"] = out
+		}
+	}
+	if len(ids) > 0 {
+		filter["_id"] = ids
+	}
+	return filter, nil
+}
+
+// outOfStock returns the inventory tracked products with nothing available
+// in any warehouse, and the bundles containing them. Untracked products are
+// always in stock.
+func (s *MongoSearcher) outOfStock(ctx context.Context, sellerIDs []string) ([]primitive.ObjectID, error) {
+	match := bson.M{}
+	if sellerIDs != nil {
+		match["sellerID"] = bson.M{"$in": sellerIDs}
+	}
+	cur, err := s.stock.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$productId",
+			"available": bson.M{"$sum": bson.M{"$subtract": bson.A{"$onHand", "$reserved"}}},
+		}}},
+		{{Key: "$match", Value: bson.M{"available": bson.M{"$lte": 0}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ProductID string `bson:"_id"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(rows))
+	out := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if id, err := primitive.ObjectIDFromHex(row.ProductID); err == nil {
+			ids = append(ids, id)
+			out = append(out, row.ProductID)
+		}
+	}
+	if len(out) == 0 {
+		return ids, nil
+	}
+
+	bundles, err := s.products.Distinct(ctx, "_id", bson.M{"components.productId": bson.M{"$in": out}})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range bundles {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			ids = append(ids, oid)
+		}
+	}
+	return ids, nil
+}
+
+// facets counts categories, attribute values and the price range over every
+// product matching the filter, along with the total.
+func (s *MongoSearcher) facets(ctx context.Context, filter bson.M, result *Result) error {
+	cur, err := s.products.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "n"}},
+			"categories": bson.A{
+				bson.M{"$match": bson.M{"categoryId": bson.M{"$gt": ""}}},
+				bson.M{"$group": bson.M{"_id": "$categoryId", "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			},
+			"attributes": bson.A{
+				bson.M{"$unwind": "$attributes"},
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"name": "$attributes.name", "value": bson.M{"$toString": "$attributes.value"}},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id.value", Value: 1}}},
+			},
+			"price": bson.A{
+				bson.M{"$group": bson.M{"_id": nil, "min": bson.M{"$min": "$price"}, "max": bson.M{"$max": "$price"}}},
+			},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var rows []struct {
+		Total []struct {
+			N int64 `bson:"n"`
+		} `bson:"total"`
+		Categories []struct {
+			ID    string `bson:"_id"`
+			Count int64  `bson:"count"`
+		} `bson:"categories"`
+		Attributes []struct {
+			ID struct {
+				Name  string `bson:"name"`
+				Value string `bson:"value"`
+			} `bson:"_id"`
+			Count int64 `bson:"count"`
+		} `bson:"attributes"`
+		Price []PriceRange `bson:"price"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return err
+	}
+
+	result.Facets = Facets{Categories: []FacetValue{}, Attributes: map[string][]FacetValue{}}
+	if len(rows) == 0 {
+		return nil
+	}
+	row := rows[0]
+	if len(row.Total) > 0 {
+		result.Total = row.Total[0].N
+	}
+	for _, c := range row.Categories {
+		result.Facets.Categories = append(result.Facets.Categories, FacetValue{Value: c.ID, Count: c.Count})
+	}
+	for _, a := range row.Attributes {
+		result.Facets.Attributes[a.ID.Name] = append(result.Facets.Attributes[a.ID.Name], FacetValue{Value: a.ID.Value, Count: a.Count})
+	}
+	if len(row.Price) > 0 {
+		result.Facets.Price = &row.Price[0]
+	}
+	return nil
+}
+
+// attributeValues returns the typed values a query string can stand for, as
+// attribute values are stored as text, numbers or booleans.
+func attributeValues(value string) bson.A {
+	values := bson.A{value}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		values = append(values, f)
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		values = append(values, b)
+	}
+	return values
+}
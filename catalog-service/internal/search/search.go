@@ -0,0 +1,165 @@
+// Package search finds products by text, facet filters and sort order, with
+// cursor pagination and facet counts.
+package search
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"business-cart/catalog-service/internal/storage"
+	"business-cart/catalog-service/internal/visibility"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sort orders.
+const (
+	SortRelevance = "relevance" // text matches first; the default with a text query
+	SortNewest    = "newest"    // the default without a text query
+	SortNameAsc   = "name"
+	SortNameDesc  = "-name"
+	SortPriceAsc  = "price"
+	SortPriceDesc = "-price"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Query describes a product search. A nil SellerIDs searches every seller.
+type Query struct {
+	Text        string
+	SellerIDs   []string
+	CategoryIDs []string          // any of the categories
+	ProductIDs  []string          // only these products, when not nil
+	Attributes  map[string]string // attribute name -> value
+	MinPrice    *float64
+	MaxPrice    *float64
+	InStock     bool
+	// Statuses restricts the lifecycle statuses, and PublishedAt to products
+	// published at that time
+	Statuses    []string
+	PublishedAt *time.Time
+	// Restrictions hide products by the sellers' visibility rules
+	Restrictions []*visibility.Restriction
+	Sort         string
+	Limit        int
+	Cursor       string
+}
+
+// Result is one page of products plus facet counts over every match.
+type Result struct {
+	Products   []*storage.Product `json:"products"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+	Total      int64              `json:"total"`
+	Facets     Facets             `json:"facets"`
+}
+
+type Facets struct {
+	Categories []FacetValue            `json:"categories"`
+	Attributes map[string][]FacetValue `json:"attributes"`
+	Price      *PriceRange             `json:"price,omitempty"`
+}
+
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type PriceRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Searcher runs product searches.
+type Searcher interface {
+	Search(ctx context.Context, q Query) (*Result, error)
+}
+
+// Normalize applies defaults and bounds to the query.
+func (q *Query) Normalize() {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	switch q.Sort {
+	case SortNameAsc, SortNameDesc, SortPriceAsc, SortPriceDesc, SortNewest:
+	case SortRelevance:
+		if q.Text == "" {
+			q.Sort = SortNewest
+		}
+	default:
+		if q.Text != "" {
+			q.Sort = SortRelevance
+		} else {
+			q.Sort = SortNewest
+		}
+	}
+}
+
+// ValidSort reports whether s is a supported sort order.
+func ValidSort(s string) bool {
+	switch s {
+	case "", SortRelevance, SortNewest, SortNameAsc, SortNameDesc, SortPriceAsc, SortPriceDesc:
+		return true
+	}
+	return false
+}
+
+// cursor is the position after the last product of a page. Keyset sorts
+// carry the sort value and ID of that product; relevance carries an offset.
+type cursor struct {
+	Sort   string             `bson:"s"`
+	Value  interface{}        `bson:"v,omitempty"`
+	ID     primitive.ObjectID `bson:"id,omitempty"`
+	Offset int                `bson:"o,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	raw, err := bson.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor reads a cursor for the given sort; a cursor issued for another
+// sort order is rejected.
+func decodeCursor(s, sort string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := bson.Unmarshal(raw, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// sortField returns the product field and direction of a keyset sort.
+func sortField(sort string) (string, int) {
+	switch sort {
+	case SortNameAsc:
+		return "name", 1
+	case SortNameDesc:
+		return "name", -1
+	case SortPriceAsc:
+		return "price", 1
+	case SortPriceDesc:
+		return "price", -1
+	default:
+		return "createdAt", -1
+	}
+}
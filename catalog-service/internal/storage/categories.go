@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateCategory adds the category after its last sibling.
+func (db *DB) CreateCategory(category *Category) error {
+	siblings, err := db.GetChildCategories(category.SellerID, category.ParentID)
+	if err != nil {
+		return err
+	}
+	category.Position = len(siblings)
+	category.CreatedAt = time.Now()
+	category.UpdatedAt = category.CreatedAt
+	result, err := db.categories.InsertOne(context.Background(), category)
+	if err != nil {
+		return err
+	}
+	category.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (db *DB) GetCategoryByID(id primitive.ObjectID) (*Category, error) {
+	var category Category
+	err := db.categories.FindOne(context.Background(), bson.M{"_id": id}).Decode(&category)
+	return &category, err
+}
+
+// GetCategories returns the categories of the given sellers, "" being the
+// platform, with siblings ordered by position.
+func (db *DB) GetCategories(sellerIDs []string) ([]*Category, error) {
+	return db.findCategories(bson.M{"sellerID": bson.M{"$in": sellerIDs}},
+		bson.D{{Key: "sellerID", Value: 1}, {Key: "parentId", Value: 1}, {Key: "position", Value: 1}})
+}
+
+// GetChildCategories returns the children of a category, or the root
+// categories of a seller when parentID is empty, by position.
+func (db *DB) GetChildCategories(sellerID, parentID string) ([]*Category, error) {
+	return db.findCategories(bson.M{"sellerID": sellerID, "parentId": parentID}, bson.M{"position": 1})
+}
+
+// GetCategoriesByIDs returns the categories with the given hex IDs.
+func (db *DB) GetCategoriesByIDs(ids []string) ([]*Category, error) {
+	oids := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			oids = append(oids, oid)
+		}
+	}
+	return db.findCategories(bson.M{"_id": bson.M{"$in": oids}}, nil)
+}
+
+// GetDescendantCategoryIDs returns the hex IDs of every category below id.
+func (db *DB) GetDescendantCategoryIDs(id string) ([]string, error) {
+	categories, err := db.findCategories(bson.M{"path": id}, nil)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(categories))
+	for i, c := range categories {
+		ids[i] = c.ID.Hex()
+	}
+	return ids, nil
+}
+
+func (db *DB) findCategories(filter bson.M, sort interface{}) ([]*Category, error) {
+	ctx := context.Background()
+	opts := options.Find()
+	if sort != nil {
+		opts.SetSort(sort)
+	}
+	cursor, err := db.categories.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	categories := []*Category{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// CategoriesStamp changes whenever a category of the sellers is created,
+// renamed, moved or deleted.
+func (db *DB) CategoriesStamp(sellerIDs []string) (string, error) {
+	return stamp(db.categories, bson.M{"sellerID": bson.M{"$in": sellerIDs}}, nil)
+}
+
+// UpdateCategory saves the category's name and attribute schema.
+func (db *DB) UpdateCategory(category *Category) error {
+	category.UpdatedAt = time.Now()
+	_, err := db.categories.UpdateOne(context.Background(), bson.M{"_id": category.ID}, bson.M{"$set": bson.M{
+		"name":       category.Name,
+		"attributes": category.Attributes,
+		"updatedAt":  category.UpdatedAt,
+	}})
+	return err
+}
+
+// MoveCategory moves a category under a new parent (nil for the root) at the
+// given position among its new siblings, rewriting the paths of its subtree
+// and renumbering the siblings it leaves and joins.
+func (db *DB) MoveCategory(category *Category, parent *Category, position int) error {
+	ctx := context.Background()
+	oldParentID, oldPath := category.ParentID, category.Path
+	newParentID, newPath := "", []string{}
+	if parent != nil {
+		newParentID = parent.ID.Hex()
+		newPath = append(append(newPath, parent.Path...), newParentID)
+	}
+
+	siblings, err := db.GetChildCategories(category.SellerID, newParentID)
+	if err != nil {
+		return err
+	}
+	order := make([]*Category, 0, len(siblings)+1)
+	for _, s := range siblings {
+		if s.ID != category.ID {
+			order = append(order, s)
+		}
+	}
+	position = max(0, min(position, len(order)))
+	order = append(order[:position], append([]*Category{category}, order[position:]...)...)
+
+	now := time.Now()
+	if _, err := db.categories.UpdateOne(ctx, bson.M{"_id": category.ID}, bson.M{"$set": bson.M{
+		"parentId": newParentID, "path": newPath, "updatedAt": now,
+	}}); err != nil {
+		return err
+	}
+	if err := db.renumberCategories(order); err != nil {
+		return err
+	}
+	if oldParentID != newParentID {
+		left, err := db.GetChildCategories(category.SellerID, oldParentID)
+		if err != nil {
+			return err
+		}
+		if err := db.renumberCategories(left); err != nil {
+			return err
+		}
+	}
+
+	// Descendants keep their path below the moved category
+	descendants, err := db.findCategories(bson.M{"path": category.ID.Hex()}, nil)
+	if err != nil {
+		return err
+	}
+	for _, d := range descendants {
+		path := append(append([]string{}, newPath...), d.Path[len(oldPath):]...)
+		if _, err := db.categories.UpdateOne(ctx, bson.M{"_id": d.ID}, bson.M{"$set": bson.M{"path": path, "updatedAt": now}}); err != nil {
+			return err
+		}
+	}
+	category.ParentID, category.Path = newParentID, newPath
+	return nil
+}
+
+// renumberCategories stores each category's index as its position.
+func (db *DB) renumberCategories(categories []*Category) error {
+	for i, c := range categories {
+		if c.Position == i {
+			continue
+		}
+		c.Position = i
+		if _, err := db.categories.UpdateOne(context.Background(), bson.M{"_id": c.ID}, bson.M{"$set": bson.M{"position": i}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCategory removes the category, also from visibility rules.
+func (db *DB) DeleteCategory(category *Category) error {
+	ctx := context.Background()
+	if _, err := db.categories.DeleteOne(ctx, bson.M{"_id": category.ID}); err != nil {
+		return err
+	}
+	if _, err := db.visibilityRules.UpdateMany(ctx,
+		bson.M{"categoryIds": category.ID.Hex()},
+		bson.M{"$pull": bson.M{"categoryIds": category.ID.Hex()}},
+	); err != nil {
+		return err
+	}
+	siblings, err := db.GetChildCategories(category.SellerID, category.ParentID)
+	if err != nil {
+		return err
+	}
+	return db.renumberCategories(siblings)
+}
+
+// CategoryInUse reports whether the category has subcategories or products.
+func (db *DB) CategoryInUse(id string) (bool, error) {
+	ctx := context.Background()
+	n, err := db.categories.CountDocuments(ctx, bson.M{"parentId": id}, options.Count().SetLimit(1))
+	if err != nil || n > 0 {
+		return n > 0, err
+	}
+	n, err = db.products.CountDocuments(ctx, bson.M{"categoryId": id}, options.Count().SetLimit(1))
+	return n > 0, err
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxProductImages caps the images of a product.
+const MaxProductImages = 10
+
+// AddProductImage appends an image to the product unless it already holds
+// MaxProductImages, reporting whether it was added. A primary image also
+// becomes the product's Image.
+func (db *DB) AddProductImage(productID primitive.ObjectID, image ProductImage, actor Actor) (bool, error) {
+	set := bson.M{"updatedAt": time.Now()}
+	if image.Primary {
+		set["image"] = image.URL
+	}
+	_, added, err := db.updateProduct(
+		bson.M{"_id": productID, fmt.Sprintf("images.%d", MaxProductImages-1): bson.M{"$exists": false}},
+		bson.M{"$push": bson.M{"images": image}, "$set": set},
+		actor,
+	)
+	return added, err
+}
+
+// SetProductImages replaces the product's images, e.g. after reordering, and
+// points Image at the primary one.
+func (db *DB) SetProductImages(productID primitive.ObjectID, images []ProductImage, actor Actor) error {
+	product := Product{Images: images}
+	set := bson.M{"images": images, "updatedAt": time.Now()}
+	if primary := product.PrimaryImage(); primary != nil {
+		set["image"] = primary.URL
+	} else {
+		set["image"] = ""
+	}
+	return db.UpdateProduct(productID, set, actor)
+}
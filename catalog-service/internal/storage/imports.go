@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetProductBySKU returns the seller's product with the given product-level
+// SKU, or nil when there is none.
+func (db *DB) GetProductBySKU(sellerID, sku string) (*Product, error) {
+	var product Product
+	err := db.products.FindOne(context.Background(), bson.M{"sellerID": sellerID, "sku": sku}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (db *DB) CreateImportJob(job *ImportJob) error {
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	result, err := db.importJobs.InsertOne(context.Background(), job)
+	if err != nil {
+		return err
+	}
+	job.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (db *DB) GetImportJobByID(id primitive.ObjectID) (*ImportJob, error) {
+	var job ImportJob
+	err := db.importJobs.FindOne(context.Background(), bson.M{"_id": id}).Decode(&job)
+	return &job, err
+}
+
+// GetImportJobs returns the seller's import jobs, newest first.
+func (db *DB) GetImportJobs(sellerID string) ([]*ImportJob, error) {
+	ctx := context.Background()
+	cursor, err := db.importJobs.Find(ctx, bson.M{"sellerID": sellerID}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []*ImportJob{}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ClaimImportJob moves a pending job to running and returns it, or returns nil
+// when the job is no longer pending, so that a job delivered twice to the
+// worker runs once.
+func (db *DB) ClaimImportJob(id primitive.ObjectID) (*ImportJob, error) {
+	var job ImportJob
+	err := db.importJobs.FindOneAndUpdate(context.Background(),
+		bson.M{"_id": id, "status": ImportPending},
+		bson.M{"$set": bson.M{"status": ImportRunning, "updatedAt": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// importUpload is the file of an import job, kept until the job has run.
+type importUpload struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Data      []byte             `bson:"data"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// SaveImportUpload keeps the file of an import job for the worker.
+func (db *DB) SaveImportUpload(jobID primitive.ObjectID, data []byte) error {
+	_, err := db.importUploads.InsertOne(context.Background(), importUpload{ID: jobID, Data: data, CreatedAt: time.Now()})
+	return err
+}
+
+func (db *DB) GetImportUpload(jobID primitive.ObjectID) ([]byte, error) {
+	var upload importUpload
+	err := db.importUploads.FindOne(context.Background(), bson.M{"_id": jobID}).Decode(&upload)
+	return upload.Data, err
+}
+
+func (db *DB) DeleteImportUpload(jobID primitive.ObjectID) error {
+	_, err := db.importUploads.DeleteOne(context.Background(), bson.M{"_id": jobID})
+	return err
+}
+
+func (db *DB) UpdateImportJob(job *ImportJob) error {
+	job.UpdatedAt = time.Now()
+	_, err := db.importJobs.ReplaceOne(context.Background(), bson.M{"_id": job.ID}, job)
+	return err
+}
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNegativeStock      = errors.New("adjustment would take on-hand stock below zero")
+	ErrReservationNotOpen = errors.New("reservation is not open")
+	ErrReservationExpired = errors.New("reservation has expired")
+	ErrReferenceInUse     = errors.New("reference is held by another open reservation")
+)
+
+// InsufficientStockError lists the lines a reservation could not hold.
+type InsufficientStockError struct {
+	Lines []ReservationLine
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for %d line(s)", len(e.Lines))
+}
+
+func stockKey(productID, variantID, warehouseID string) bson.M {
+	return bson.M{"productId": productID, "variantId": variantID, "warehouseId": warehouseID}
+}
+
+// GetStockLevels returns the stock levels of a product and its variants.
+func (db *DB) GetStockLevels(productID string) ([]*StockLevel, error) {
+	ctx := context.Background()
+	cursor, err := db.stock.Find(ctx, bson.M{"productId": productID}, options.Find().SetSort(bson.D{{Key: "variantId", Value: 1}, {Key: "warehouseId", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	levels := []*StockLevel{}
+	if err := cursor.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	for _, l := range levels {
+		l.Available = l.OnHand - l.Reserved
+	}
+	return levels, nil
+}
+
+// AdjustStock applies adj.Delta to the on-hand quantity and records the
+// adjustment in the ledger. The first adjustment of a product or variant
+// starts tracking its inventory.
+func (db *DB) AdjustStock(adj *InventoryAdjustment) (*StockLevel, error) {
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := db.stock.UpdateOne(ctx, stockKey(adj.ProductID, adj.VariantID, adj.WarehouseID),
+		bson.M{"$setOnInsert": bson.M{"sellerID": adj.SellerID, "onHand": 0, "reserved": 0, "updatedAt": now}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	filter := stockKey(adj.ProductID, adj.VariantID, adj.WarehouseID)
+	filter["$expr"] = bson.M{"$gte": bson.A{bson.M{"$add": bson.A{"$onHand", adj.Delta}}, 0}}
+	var level StockLevel
+	err = db.stock.FindOneAndUpdate(ctx, filter,
+		bson.M{"$inc": bson.M{"onHand": adj.Delta}, "$set": bson.M{"updatedAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&level)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNegativeStock
+	}
+	if err != nil {
+		return nil, err
+	}
+	level.Available = level.OnHand - level.Reserved
+
+	adj.OnHandAfter = level.OnHand
+	adj.CreatedAt = now
+	if _, err := db.adjustments.InsertOne(ctx, adj); err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+// GetAdjustments returns the ledger of a product, newest first.
+func (db *DB) GetAdjustments(productID string) ([]*InventoryAdjustment, error) {
+	ctx := context.Background()
+	cursor, err := db.adjustments.Find(ctx, bson.M{"productId": productID}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	adjustments := []*InventoryAdjustment{}
+	if err := cursor.All(ctx, &adjustments); err != nil {
+		return nil, err
+	}
+	return adjustments, nil
+}
+
+// Reserve holds stock for every line of the reservation. Each line is
+// reserved with a conditional increment, so concurrent checkouts cannot
+// reserve more than is available. Either all lines are held or none are.
+// Reserving an already open reference returns the existing reservation when
+// it belongs to the same account and seller, and ErrReferenceInUse otherwise.
+func (db *DB) Reserve(res *Reservation) error {
+	ctx := context.Background()
+	if err := db.ReleaseExpiredReservations(); err != nil {
+		return err
+	}
+
+	var existing Reservation
+	err := db.reservations.FindOne(ctx, bson.M{
+		"reference": res.Reference,
+		"accountId": res.AccountID,
+		"sellerID":  res.SellerID,
+		"status":    ReservationReserved,
+	}).Decode(&existing)
+	if err == nil {
+		*res = existing
+		return nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	var held []ReservationLine
+	var short []ReservationLine
+	for i := range res.Lines {
+		line := &res.Lines[i]
+		filter := stockKey(line.ProductID, line.VariantID, line.WarehouseID)
+		filter["sellerID"] = res.SellerID
+		filter["$expr"] = bson.M{"$gte": bson.A{bson.M{"$subtract": bson.A{"$onHand", "$reserved"}}, line.Quantity}}
+		result, err := db.stock.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"reserved": line.Quantity}})
+		if err != nil {
+			db.unreserve(held)
+			return err
+		}
+		if result.MatchedCount == 1 {
+			line.Tracked = true
+			held = append(held, *line)
+			continue
+		}
+
+		tracked, err := db.stock.CountDocuments(ctx, bson.M{"productId": line.ProductID, "variantId": line.VariantID})
+		if err != nil {
+			db.unreserve(held)
+			return err
+		}
+		if tracked > 0 {
+			short = append(short, *line)
+		}
+	}
+	if len(short) > 0 {
+		db.unreserve(held)
+		return &InsufficientStockError{Lines: short}
+	}
+
+	now := time.Now()
+	res.ID = primitive.NewObjectID()
+	res.Status = ReservationReserved
+	res.CreatedAt = now
+	res.UpdatedAt = now
+	if _, err := db.reservations.InsertOne(ctx, res); err != nil {
+		db.unreserve(held)
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrReferenceInUse
+		}
+		return err
+	}
+	return nil
+}
+
+func (db *DB) GetReservation(id primitive.ObjectID) (*Reservation, error) {
+	var res Reservation
+	err := db.reservations.FindOne(context.Background(), bson.M{"_id": id}).Decode(&res)
+	return &res, err
+}
+
+// CommitReservation turns held stock into a sale: on-hand and reserved
+// quantities both drop and each line is recorded in the ledger. It runs in a
+// transaction, so the reservation is either committed with every line or
+// left as it was. A reservation past its expiry is not committed; its stock
+// is released with the other expired reservations.
+func (db *DB) CommitReservation(id primitive.ObjectID) (*Reservation, error) {
+	var res *Reservation
+	err := db.inTransaction(func(ctx mongo.SessionContext) error {
+		var err error
+		res, err = db.commitReservation(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (db *DB) commitReservation(ctx mongo.SessionContext, id primitive.ObjectID) (*Reservation, error) {
+	now := time.Now()
+	var res Reservation
+	err := db.reservations.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": ReservationReserved, "expiresAt": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"status": ReservationCommitted, "updatedAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&res)
+	if err == mongo.ErrNoDocuments {
+		// Tell an expired reservation from one already closed
+		err = db.reservations.FindOne(ctx, bson.M{"_id": id, "status": ReservationReserved}).Err()
+		if err == nil {
+			return nil, ErrReservationExpired
+		}
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrReservationNotOpen
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range res.Lines {
+		if !line.Tracked {
+			continue
+		}
+		var level StockLevel
+		err := db.stock.FindOneAndUpdate(ctx, stockKey(line.ProductID, line.VariantID, line.WarehouseID),
+			bson.M{"$inc": bson.M{"onHand": -line.Quantity, "reserved": -line.Quantity}, "$set": bson.M{"updatedAt": res.UpdatedAt}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&level)
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.adjustments.InsertOne(ctx, &InventoryAdjustment{
+			SellerID:    res.SellerID,
+			ProductID:   line.ProductID,
+			VariantID:   line.VariantID,
+			WarehouseID: line.WarehouseID,
+			Delta:       -line.Quantity,
+			OnHandAfter: level.OnHand,
+			Reason:      ReasonSale,
+			Reference:   res.ID.Hex(),
+			ActorID:     res.AccountID,
+			CreatedAt:   res.UpdatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+// ReleaseReservation returns the held stock to available.
+func (db *DB) ReleaseReservation(id primitive.ObjectID) (*Reservation, error) {
+	res, err := db.closeReservation(id, ReservationReleased)
+	if err != nil {
+		return nil, err
+	}
+	db.unreserve(res.Lines)
+	return res, nil
+}
+
+// ReleaseExpiredReservations releases open reservations past their expiry,
+// covering quotes that were abandoned without an explicit release.
+func (db *DB) ReleaseExpiredReservations() error {
+	ctx := context.Background()
+	cursor, err := db.reservations.Find(ctx,
+		bson.M{"status": ReservationReserved, "expiresAt": bson.M{"$lt": time.Now()}},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var expired []Reservation
+	if err := cursor.All(ctx, &expired); err != nil {
+		return err
+	}
+	for _, res := range expired {
+		if _, err := db.ReleaseReservation(res.ID); err != nil && err != ErrReservationNotOpen {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSellerStock returns the stock levels of a seller's products and variants
+// across all warehouses.
+func (db *DB) GetSellerStock(sellerID string, productIDs []string) ([]*StockLevel, error) {
+	ctx := context.Background()
+	cursor, err := db.stock.Find(ctx, bson.M{"sellerID": sellerID, "productId": bson.M{"$in": productIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	levels := []*StockLevel{}
+	if err := cursor.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	for _, l := range levels {
+		l.Available = l.OnHand - l.Reserved
+	}
+	return levels, nil
+}
+
+// closeReservation moves an open reservation to its final status. Only one
+// caller can win the transition, so stock is never committed or released twice.
+func (db *DB) closeReservation(id primitive.ObjectID, status string) (*Reservation, error) {
+	var res Reservation
+	err := db.reservations.FindOneAndUpdate(context.Background(),
+		bson.M{"_id": id, "status": ReservationReserved},
+		bson.M{"$set": bson.M{"status": status, "updatedAt": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&res)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrReservationNotOpen
+	}
+	return &res, err
+}
+
+// unreserve gives back the reserved quantity of tracked lines.
+func (db *DB) unreserve(lines []ReservationLine) {
+	for _, line := range lines {
+		if !line.Tracked {
+			continue
+		}
+		_, err := db.stock.UpdateOne(context.Background(), stockKey(line.ProductID, line.VariantID, line.WarehouseID),
+			bson.M{"$inc": bson.M{"reserved": -line.Quantity}, "$set": bson.M{"updatedAt": time.Now()}},
+		)
+		if err != nil {
+			log.Printf("Failed to unreserve %d of product %s variant %q at warehouse %q: %v", line.Quantity, line.ProductID, line.VariantID, line.WarehouseID, err)
+		}
+	}
+}
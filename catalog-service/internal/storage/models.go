@@ -3,16 +3,485 @@ package storage
 import (
 	"time"
 
+	"business-cart/catalog-service/internal/fx"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Attribute types a seller can use for custom product attributes.
+const (
+	AttributeText    = "text"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeDate    = "date" // YYYY-MM-DD
+)
+
+// Product lifecycle statuses.
+const (
+	ProductDraft        = "draft"        // being prepared; only the seller sees it
+	ProductActive       = "active"       // sold to linked customers while published
+	ProductDiscontinued = "discontinued" // no longer sold, kept for past orders
+	ProductArchived     = "archived"     // retired
+)
+
+// DefaultCurrency prices products that do not name a currency.
+const DefaultCurrency = "USD"
+
 type Product struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
 	Name        string             `bson:"name" json:"name"`
 	Description string             `bson:"description,omitempty" json:"description,omitempty"`
 	Price       float64            `bson:"price" json:"price"`
+	Currency    string             `bson:"currency" json:"currency"` // ISO 4217 code of every price on the product
+	SellerID    string             `bson:"sellerID" json:"sellerID"`
+	Image       string             `bson:"image,omitempty" json:"image,omitempty"` // URL of the primary image
+	CategoryID  string             `bson:"categoryId,omitempty" json:"categoryId,omitempty"`
+
+	// Lifecycle. Active products are only published between PublishAt and
+	// UnpublishAt; either may be left open.
+	Status      string     `bson:"status" json:"status"`
+	PublishAt   *time.Time `bson:"publishAt,omitempty" json:"publishAt,omitempty"`
+	UnpublishAt *time.Time `bson:"unpublishAt,omitempty" json:"unpublishAt,omitempty"`
+
+	// Identification and packaging
+	SKU           string      `bson:"sku,omitempty" json:"sku,omitempty"` // unique per seller
+	GTIN          string      `bson:"gtin,omitempty" json:"gtin,omitempty"`
+	UPC           string      `bson:"upc,omitempty" json:"upc,omitempty"`
+	UnitOfMeasure string      `bson:"unitOfMeasure,omitempty" json:"unitOfMeasure,omitempty"` // e.g. each, case, kg
+	PackSize      int         `bson:"packSize,omitempty" json:"packSize,omitempty"`           // units per unit of measure
+	Weight        *Weight     `bson:"weight,omitempty" json:"weight,omitempty"`
+	Dimensions    *Dimensions `bson:"dimensions,omitempty" json:"dimensions,omitempty"`
+
+	// QuantityRules limit how much of the product a single order line may hold
+	QuantityRules *QuantityRules `bson:"quantityRules,omitempty" json:"quantityRules,omitempty"`
+
+	// Components make the product a bundle, sold at its own price from the
+	// stock of its components
+	Components []BundleComponent `bson:"components,omitempty" json:"components,omitempty"`
+
+	// PriceTiers are quantity breaks on the list price
+	PriceTiers []PriceTier `bson:"priceTiers,omitempty" json:"priceTiers,omitempty"`
+
+	// Images in display order, managed through the image endpoints
+	Images []ProductImage `bson:"images,omitempty" json:"images,omitempty"`
+
+	Attributes     []Attribute     `bson:"attributes,omitempty" json:"attributes,omitempty"`
+	VariantOptions []VariantOption `bson:"variantOptions,omitempty" json:"variantOptions,omitempty"`
+	Variants       []Variant       `bson:"variants,omitempty" json:"variants,omitempty"`
+
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+
+	// Revision counts the product's changes; see ProductRevision
+	Revision int `bson:"revision" json:"revision"`
+
+	// The requesting customer's price, resolved from price lists on reads
+	CustomerPrice *float64 `bson:"-" json:"customerPrice,omitempty"`
+	PriceListID   string   `bson:"-" json:"priceListId,omitempty"`
+
+	// The rate prices were converted at when another currency was asked for
+	ExchangeRate *fx.Rate `bson:"-" json:"exchangeRate,omitempty"`
+
+	// The requesting customer's own code for the product, on reads
+	CustomerPartNumber string `bson:"-" json:"customerPartNumber,omitempty"`
+
+	// Path from the root category down to CategoryID, filled in on reads
+	Breadcrumbs []Breadcrumb `bson:"-" json:"breadcrumbs,omitempty"`
+}
+
+// ProductImage is an uploaded product image with its generated thumbnails.
+type ProductImage struct {
+	ID          string           `bson:"id" json:"id"`
+	Key         string           `bson:"key" json:"-"` // blob store key
+	URL         string           `bson:"url" json:"url"`
+	ContentType string           `bson:"contentType" json:"contentType"`
+	Width       int              `bson:"width" json:"width"`
+	Height      int              `bson:"height" json:"height"`
+	Size        int              `bson:"size" json:"size"` // bytes
+	Primary     bool             `bson:"primary" json:"primary"`
+	Thumbnails  []ImageThumbnail `bson:"thumbnails" json:"thumbnails"`
+	CreatedAt   time.Time        `bson:"createdAt" json:"createdAt"`
+}
+
+type ImageThumbnail struct {
+	Name   string `bson:"name" json:"name"` // small | medium | large
+	Key    string `bson:"key" json:"-"`
+	URL    string `bson:"url" json:"url"`
+	Width  int    `bson:"width" json:"width"`
+	Height int    `bson:"height" json:"height"`
+}
+
+// PrimaryImage returns the product's primary image, or nil without images.
+func (p *Product) PrimaryImage() *ProductImage {
+	for i := range p.Images {
+		if p.Images[i].Primary {
+			return &p.Images[i]
+		}
+	}
+	return nil
+}
+
+type Weight struct {
+	Value float64 `bson:"value" json:"value"`
+	Unit  string  `bson:"unit" json:"unit"` // g | kg | oz | lb
+}
+
+type Dimensions struct {
+	Length float64 `bson:"length" json:"length"`
+	Width  float64 `bson:"width" json:"width"`
+	Height float64 `bson:"height" json:"height"`
+	Unit   string  `bson:"unit" json:"unit"` // mm | cm | m | in | ft
+}
+
+// QuantityRules constrain the quantity of an order line: at least
+// MinQuantity, a multiple of Multiple (e.g. cases of 12) and at most
+// MaxQuantity. Zero leaves a rule unset.
+type QuantityRules struct {
+	MinQuantity int `bson:"minQuantity,omitempty" json:"minQuantity,omitempty"`
+	Multiple    int `bson:"multiple,omitempty" json:"multiple,omitempty"`
+	MaxQuantity int `bson:"maxQuantity,omitempty" json:"maxQuantity,omitempty"`
+}
+
+// Attribute is a typed, seller-defined product property.
+type Attribute struct {
+	Name  string      `bson:"name" json:"name"`
+	Type  string      `bson:"type" json:"type"` // text | number | boolean | date
+	Value interface{} `bson:"value" json:"value"`
+}
+
+// PriceTier is the unit price from MinQuantity units upwards, up to the next
+// tier. Below the first tier the base price applies.
+type PriceTier struct {
+	MinQuantity int     `bson:"minQuantity" json:"minQuantity"`
+	Price       float64 `bson:"price" json:"price"`
+}
+
+// BundleComponent is the quantity of a product, or of one of its variants,
+// in each unit of a bundle.
+type BundleComponent struct {
+	ProductID string `bson:"productId" json:"productId"`
+	VariantID string `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	Quantity  int    `bson:"quantity" json:"quantity"`
+}
+
+// VariantOption is a variant dimension such as size or colour and the values
+// it can take.
+type VariantOption struct {
+	Name   string   `bson:"name" json:"name"`
+	Values []string `bson:"values" json:"values"`
+}
+
+// Variant is a purchasable combination of option values with its own SKU.
+type Variant struct {
+	ID      string            `bson:"id" json:"id"`
+	SKU     string            `bson:"sku" json:"sku"`
+	GTIN    string            `bson:"gtin,omitempty" json:"gtin,omitempty"`
+	Price   *float64          `bson:"price,omitempty" json:"price,omitempty"` // nil inherits the product price and tiers
+	Options map[string]string `bson:"options" json:"options"`                 // option name -> value
+
+	PriceTiers []PriceTier `bson:"priceTiers,omitempty" json:"priceTiers,omitempty"`
+
+	CustomerPrice      *float64 `bson:"-" json:"customerPrice,omitempty"`
+	CustomerPartNumber string   `bson:"-" json:"customerPartNumber,omitempty"`
+}
+
+// FindVariant returns the variant with the given ID, or nil.
+func (p *Product) FindVariant(id string) *Variant {
+	for i := range p.Variants {
+		if p.Variants[i].ID == id {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
+
+// SKUs returns every SKU used by the product and its variants.
+func (p *Product) SKUs() []string {
+	var skus []string
+	if p.SKU != "" {
+		skus = append(skus, p.SKU)
+	}
+	for _, v := range p.Variants {
+		if v.SKU != "" {
+			skus = append(skus, v.SKU)
+		}
+	}
+	return skus
+}
+
+// Inventory adjustment reasons.
+const (
+	ReasonReceived   = "received"
+	ReasonSale       = "sale"
+	ReasonReturn     = "return"
+	ReasonDamaged    = "damaged"
+	ReasonCount      = "count" // stock count correction
+	ReasonCorrection = "correction"
+)
+
+// Reservation statuses.
+const (
+	ReservationReserved  = "reserved"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+)
+
+// StockLevel is the stock of one product or variant at one warehouse. A
+// product without any stock level is not inventory tracked and can always be
+// ordered.
+type StockLevel struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	SellerID    string             `bson:"sellerID" json:"sellerID"`
-	Image       string             `bson:"image,omitempty" json:"image,omitempty"`
+	ProductID   string             `bson:"productId" json:"productId"`
+	VariantID   string             `bson:"variantId" json:"variantId,omitempty"`     // empty for the base product
+	WarehouseID string             `bson:"warehouseId" json:"warehouseId,omitempty"` // empty for stock without a location
+	OnHand      int                `bson:"onHand" json:"onHand"`
+	Reserved    int                `bson:"reserved" json:"reserved"`
+	Available   int                `bson:"-" json:"available"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// InventoryAdjustment is an entry in the append-only stock ledger.
+type InventoryAdjustment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID    string             `bson:"sellerID" json:"sellerID"`
+	ProductID   string             `bson:"productId" json:"productId"`
+	VariantID   string             `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	WarehouseID string             `bson:"warehouseId,omitempty" json:"warehouseId,omitempty"`
+	Delta       int                `bson:"delta" json:"delta"`
+	OnHandAfter int                `bson:"onHandAfter" json:"onHandAfter"`
+	Reason      string             `bson:"reason" json:"reason"`
+	Note        string             `bson:"note,omitempty" json:"note,omitempty"`
+	Reference   string             `bson:"reference,omitempty" json:"reference,omitempty"` // e.g. the reservation of a sale
+	ActorID     string             `bson:"actorId" json:"actorId"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Reservation holds stock for a checkout quote until the order is placed
+// (committed) or the quote is abandoned (released).
+type Reservation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Reference string             `bson:"reference" json:"reference"` // the checkout quote ID
+	SellerID  string             `bson:"sellerID" json:"sellerID"`
+	AccountID string             `bson:"accountId" json:"accountId"`
+	Lines     []ReservationLine  `bson:"lines" json:"lines"`
+	Status    string             `bson:"status" json:"status"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+type ReservationLine struct {
+	ProductID   string `bson:"productId" json:"productId"`
+	VariantID   string `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	WarehouseID string `bson:"warehouseId,omitempty" json:"warehouseId,omitempty"`
+	Quantity    int    `bson:"quantity" json:"quantity"`
+	Tracked     bool   `bson:"tracked" json:"tracked"` // false when the product has no stock level
+}
+
+type Coords struct {
+	Lat float64 `bson:"lat" json:"lat"`
+	Lng float64 `bson:"lng" json:"lng"`
+}
+
+type Address struct {
+	Street  string `bson:"street" json:"street"`
+	City    string `bson:"city" json:"city"`
+	State   string `bson:"state" json:"state"`
+	Zip     string `bson:"zip" json:"zip"`
+	Country string `bson:"country,omitempty" json:"country,omitempty"`
+	Coords  Coords `bson:"coordinates" json:"coordinates"`
+}
+
+// Warehouse is a stock location of a seller.
+type Warehouse struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID  string             `bson:"sellerID" json:"sellerID"`
+	Name      string             `bson:"name" json:"name"`
+	Code      string             `bson:"code,omitempty" json:"code,omitempty"`
+	Address   Address            `bson:"address" json:"address"`
+	Active    bool               `bson:"active" json:"active"` // inactive warehouses are not used for sourcing
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Price list types.
+const (
+	PriceListFixed   = "fixed"   // entries set the price of each product
+	PriceListPercent = "percent" // a percentage off the list price
+)
+
+// PriceList is a set of negotiated prices a seller assigns to customers
+// directly or through customer groups. It applies between ValidFrom and
+// ValidTo; a nil bound leaves that side open.
+type PriceList struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID    string             `bson:"sellerID" json:"sellerID"`
+	Name        string             `bson:"name" json:"name"`
+	Type        string             `bson:"type" json:"type"`                                 // fixed | percent
+	PercentOff  float64            `bson:"percentOff,omitempty" json:"percentOff,omitempty"` // percent lists: off every product
+	Entries     []PriceListEntry   `bson:"entries" json:"entries"`
+	CustomerIDs []string           `bson:"customerIds" json:"customerIds"`
+	GroupIDs    []string           `bson:"groupIds" json:"groupIds"`
+	ValidFrom   *time.Time         `bson:"validFrom,omitempty" json:"validFrom,omitempty"`
+	ValidTo     *time.Time         `bson:"validTo,omitempty" json:"validTo,omitempty"`
+	Active      bool               `bson:"active" json:"active"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// PriceListEntry prices one product, or one variant when VariantID is set.
+// Fixed lists set Price and optionally PriceTiers; percent lists may override
+// the list's PercentOff.
+type PriceListEntry struct {
+	ProductID  string      `bson:"productId" json:"productId"`
+	VariantID  string      `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	Price      *float64    `bson:"price,omitempty" json:"price,omitempty"`
+	PriceTiers []PriceTier `bson:"priceTiers,omitempty" json:"priceTiers,omitempty"`
+	PercentOff *float64    `bson:"percentOff,omitempty" json:"percentOff,omitempty"`
+}
+
+// CustomerGroup lets a seller assign price lists to many customers at once.
+type CustomerGroup struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID    string             `bson:"sellerID" json:"sellerID"`
+	Name        string             `bson:"name" json:"name"`
+	CustomerIDs []string           `bson:"customerIds" json:"customerIds"`
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
+
+// Import modes decide what happens to rows whose SKU the seller already uses.
+const (
+	ImportCreate = "create" // only new SKUs; existing ones are row errors
+	ImportUpdate = "update" // only existing SKUs; new ones are row errors
+	ImportUpsert = "upsert" // create new SKUs and update existing ones
+)
+
+// Import job statuses.
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportJob is a bulk product upload processed in the background.
+type ImportJob struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID   string             `bson:"sellerID" json:"sellerID"`
+	Format     string             `bson:"format" json:"format"` // csv | jsonl
+	Mode       string             `bson:"mode" json:"mode"`
+	DryRun     bool               `bson:"dryRun" json:"dryRun"` // validate only, nothing is saved
+	Status     string             `bson:"status" json:"status"`
+	Total      int                `bson:"total" json:"total"`
+	Processed  int                `bson:"processed" json:"processed"`
+	Created    int                `bson:"created" json:"created"`
+	Updated    int                `bson:"updated" json:"updated"`
+	Failed     int                `bson:"failed" json:"failed"`
+	Errors     []ImportRowError   `bson:"errors" json:"errors"` // the first MaxImportErrors failed rows
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updatedAt" json:"updatedAt"`
+	FinishedAt *time.Time         `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+}
+
+// ImportRowError reports why a row of an upload was rejected. Line is the
+// line of the row in the uploaded file.
+type ImportRowError struct {
+	Line  int    `bson:"line" json:"line"`
+	SKU   string `bson:"sku,omitempty" json:"sku,omitempty"`
+	Error string `bson:"error" json:"error"`
+}
+
+// MaxImportErrors caps the row errors kept on an import job.
+const MaxImportErrors = 1000
+
+// Category is a node of a category tree. Sellers keep their own trees;
+// platform-wide categories, managed by admins, have no SellerID.
+type Category struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID string             `bson:"sellerID" json:"sellerID,omitempty"`
+	Name     string             `bson:"name" json:"name"`
+	ParentID string             `bson:"parentId" json:"parentId,omitempty"` // empty for root categories
+	Position int                `bson:"position" json:"position"`           // order among siblings, from 0
+	// Path holds the IDs of the ancestors, root first
+	Path []string `bson:"path" json:"path"`
+	// Attributes are required or constrained for products in the category
+	// and its subcategories
+	Attributes []AttributeDefinition `bson:"attributes" json:"attributes"`
+	CreatedAt  time.Time             `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time             `bson:"updatedAt" json:"updatedAt"`
+
+	Breadcrumbs []Breadcrumb `bson:"-" json:"breadcrumbs,omitempty"`
+}
+
+// AttributeDefinition is an entry of a category's attribute schema.
+type AttributeDefinition struct {
+	Name     string   `bson:"name" json:"name"`
+	Type     string   `bson:"type" json:"type"` // text | number | boolean | date
+	Required bool     `bson:"required" json:"required"`
+	Values   []string `bson:"values,omitempty" json:"values,omitempty"` // allowed values of text attributes
+}
+
+type Breadcrumb struct {
+	ID   string `bson:"id" json:"id"`
+	Name string `bson:"name" json:"name"`
+}
+
+// VisibilityRule restricts products of a seller, listed directly or through
+// their categories (including subcategories), to the customers it names
+// directly or through customer groups. Products no active rule covers are
+// visible to every linked customer; a covered product is visible to the
+// customers of any rule covering it.
+type VisibilityRule struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID    string             `bson:"sellerID" json:"sellerID"`
+	Name        string             `bson:"name" json:"name"`
+	ProductIDs  []string           `bson:"productIds" json:"productIds"`
+	CategoryIDs []string           `bson:"categoryIds" json:"categoryIds"`
+	CustomerIDs []string           `bson:"customerIds" json:"customerIds"`
+	GroupIDs    []string           `bson:"groupIds" json:"groupIds"`
+	Active      bool               `bson:"active" json:"active"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ProductRevision is an immutable entry in a product's history: who changed
+// it when, which stored fields changed, and the product as it was afterwards.
+type ProductRevision struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ProductID      string             `bson:"productId" json:"productId"`
+	SellerID       string             `bson:"sellerID" json:"sellerID"`
+	Revision       int                `bson:"revision" json:"revision"`
+	Action         string             `bson:"action" json:"action"` // create | update | delete
+	ActorID        string             `bson:"actorId" json:"actorId"`
+	ActorRole      string             `bson:"actorRole" json:"actorRole"`
+	ImpersonatorID string             `bson:"impersonatorId,omitempty" json:"impersonatorId,omitempty"`
+	Source         string             `bson:"source,omitempty" json:"source,omitempty"` // import for import jobs
+	Changes        []FieldChange      `bson:"changes" json:"changes"`
+	Product        *Product           `bson:"product,omitempty" json:"product,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// FieldChange is the old and new value of a product field. From is nil for
+// fields that were set and To for fields that were cleared.
+type FieldChange struct {
+	Field string      `bson:"field" json:"field"`
+	From  interface{} `bson:"from" json:"from"`
+	To    interface{} `bson:"to" json:"to"`
+}
+
+// PartNumber maps a customer's own item code to a product, or a variant, of a
+// seller. Customers keep their own; sellers may keep them on a customer's
+// behalf. A code maps to one item, and an item has one code, per customer.
+type PartNumber struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID   string             `bson:"sellerID" json:"sellerID"`
+	CustomerID string             `bson:"customerId" json:"customerId"`
+	PartNumber string             `bson:"partNumber" json:"partNumber"`
+	ProductID  string             `bson:"productId" json:"productId"`
+	VariantID  string             `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
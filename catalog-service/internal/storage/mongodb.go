@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"sort"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,8 +13,27 @@ import (
 )
 
 type DB struct {
-	client   *mongo.Client
-	products *mongo.Collection
+	client       *mongo.Client
+	database     *mongo.Database
+	products     *mongo.Collection
+	stock        *mongo.Collection
+	adjustments  *mongo.Collection
+	reservations *mongo.Collection
+	warehouses   *mongo.Collection
+
+	priceLists     *mongo.Collection
+	customerGroups *mongo.Collection
+
+	importJobs    *mongo.Collection
+	importUploads *mongo.Collection
+	categories    *mongo.Collection
+
+	visibilityRules  *mongo.Collection
+	productRevisions *mongo.Collection
+
+	partNumbers *mongo.Collection
+
+	revokedTokens *mongo.Collection
 }
 
 func NewDB(uri string) (*DB, error) {
@@ -30,18 +51,53 @@ func NewDB(uri string) (*DB, error) {
 
 	db := client.Database("ProductService")
 	return &DB{
-		client:   client,
-		products: db.Collection("products"),
+		client:       client,
+		database:     db,
+		products:     db.Collection("products"),
+		stock:        db.Collection("stock"),
+		adjustments:  db.Collection("inventoryadjustments"),
+		reservations: db.Collection("reservations"),
+		warehouses:   db.Collection("warehouses"),
+
+		priceLists:     db.Collection("pricelists"),
+		customerGroups: db.Collection("customergroups"),
+
+		importJobs:    db.Collection("importjobs"),
+		importUploads: db.Collection("importuploads"),
+		categories:    db.Collection("categories"),
+
+		visibilityRules:  db.Collection("visibilityrules"),
+		productRevisions: db.Collection("productrevisions"),
+
+		partNumbers: db.Collection("partnumbers"),
+
+		// account-service records revoked access tokens in its own database
+		revokedTokens: client.Database("AccountService").Collection("revokedtokens"),
 	}, nil
 }
 
-func (db *DB) CreateProduct(product *Product) error {
-	product.CreatedAt = time.Now()
-	product.UpdatedAt = time.Now()
-	_, err := db.products.InsertOne(context.Background(), product)
+// inTransaction runs fn in a MongoDB transaction. The driver retries fn on
+// transient errors such as write conflicts, so fn must be safe to run again.
+func (db *DB) inTransaction(fn func(ctx mongo.SessionContext) error) error {
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(context.Background(), func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
 	return err
 }
 
+// IsRevoked reports whether access tokens carrying id, as their "jti" or
+// "sid" claim, have been revoked by account-service.
+func (db *DB) IsRevoked(id string) (bool, error) {
+	n, err := db.revokedTokens.CountDocuments(context.Background(), bson.M{"_id": id}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
 func (db *DB) GetProductByID(id primitive.ObjectID) (*Product, error) {
 	var product Product
 	err := db.products.FindOne(context.Background(), bson.M{"_id": id}).Decode(&product)
@@ -62,17 +118,82 @@ func (db *DB) GetProducts(filter bson.M) ([]*Product, error) {
 	return products, nil
 }
 
-func (db *DB) UpdateProduct(id primitive.ObjectID, update bson.M) error {
-	update["updatedAt"] = time.Now()
-	_, err := db.products.UpdateOne(
-		context.Background(),
-		bson.M{"_id": id},
-		bson.M{"$set": update},
-	)
-	return err
+// GetProductPage returns up to limit products matching filter in _id order,
+// for callers that page by the last ID they read.
+func (db *DB) GetProductPage(filter bson.M, limit int) ([]*Product, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(limit))
+	cursor, err := db.products.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []*Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
 }
 
-func (db *DB) DeleteProduct(id primitive.ObjectID) error {
-	_, err := db.products.DeleteOne(context.Background(), bson.M{"_id": id})
-	return err
+// stamp summarises the documents matching filter by their number and newest
+// updatedAt, plus any extra $group accumulators. It changes whenever one of
+// them is created, changed or deleted, so callers can tell a set changed
+// without loading it. Every write to the collection must set updatedAt.
+func stamp(coll *mongo.Collection, filter bson.M, extra bson.M) (string, error) {
+	ctx := context.Background()
+	group := bson.M{"_id": nil, "n": bson.M{"$sum": 1}, "updatedAt": bson.M{"$max": "$updatedAt"}}
+	for key, acc := range extra {
+		group[key] = acc
+	}
+	cursor, err := coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: group}},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer cursor.Close(ctx)
+
+	var results []bson.M
+	if err := cursor.All(ctx, &results); err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "n=0", nil
+	}
+	delete(results[0], "_id")
+	var parts []string
+	for key, value := range results[0] {
+		parts = append(parts, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ","), nil
+}
+
+// SKUInUse reports whether another product of the seller already uses any of
+// the given SKUs, either at product or at variant level.
+func (db *DB) SKUInUse(sellerID string, skus []string, exclude primitive.ObjectID) (bool, error) {
+	if len(skus) == 0 {
+		return false, nil
+	}
+	filter := bson.M{
+		"sellerID": sellerID,
+		"_id":      bson.M{"$ne": exclude},
+		"$or": bson.A{
+			bson.M{"sku": bson.M{"$in": skus}},
+			bson.M{"variants.sku": bson.M{"$in": skus}},
+		},
+	}
+	n, err := db.products.CountDocuments(context.Background(), filter)
+	return n > 0, err
+}
+
+// Database exposes the underlying database for schema migrations.
+func (db *DB) Database() *mongo.Database {
+	return db.database
+}
+
+func (db *DB) Disconnect() {
+	_ = db.client.Disconnect(context.Background())
 }
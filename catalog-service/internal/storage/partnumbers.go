@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrPartNumberTaken = errors.New("part number or item already mapped for this customer")
+
+func (db *DB) CreatePartNumber(pn *PartNumber) error {
+	pn.CreatedAt = time.Now()
+	pn.UpdatedAt = pn.CreatedAt
+	result, err := db.partNumbers.InsertOne(context.Background(), pn)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPartNumberTaken
+	}
+	if err != nil {
+		return err
+	}
+	pn.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (db *DB) GetPartNumberByID(id primitive.ObjectID) (*PartNumber, error) {
+	var pn PartNumber
+	err := db.partNumbers.FindOne(context.Background(), bson.M{"_id": id}).Decode(&pn)
+	return &pn, err
+}
+
+func (db *DB) GetPartNumbers(filter bson.M) ([]*PartNumber, error) {
+	ctx := context.Background()
+	cursor, err := db.partNumbers.Find(ctx, filter, options.Find().SetSort(bson.M{"partNumber": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	partNumbers := []*PartNumber{}
+	if err := cursor.All(ctx, &partNumbers); err != nil {
+		return nil, err
+	}
+	return partNumbers, nil
+}
+
+// PartNumbersStamp changes whenever one of the customer's part numbers with
+// the sellers is created, changed or deleted.
+func (db *DB) PartNumbersStamp(customerID string, sellerIDs []string) (string, error) {
+	return stamp(db.partNumbers, bson.M{"customerId": customerID, "sellerID": bson.M{"$in": sellerIDs}}, nil)
+}
+
+func (db *DB) UpdatePartNumber(pn *PartNumber) error {
+	pn.UpdatedAt = time.Now()
+	_, err := db.partNumbers.ReplaceOne(context.Background(), bson.M{"_id": pn.ID}, pn)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPartNumberTaken
+	}
+	return err
+}
+
+func (db *DB) DeletePartNumber(id primitive.ObjectID) error {
+	_, err := db.partNumbers.DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
+
+// DeleteProductPartNumbers removes every customer's code for a product.
+func (db *DB) DeleteProductPartNumbers(productID string) error {
+	_, err := db.partNumbers.DeleteMany(context.Background(), bson.M{"productId": productID})
+	return err
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/* ---------- price lists ---------- */
+
+func (db *DB) CreatePriceList(list *PriceList) error {
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	result, err := db.priceLists.InsertOne(context.Background(), list)
+	if err != nil {
+		return err
+	}
+	list.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (db *DB) GetPriceListByID(id primitive.ObjectID) (*PriceList, error) {
+	var list PriceList
+	err := db.priceLists.FindOne(context.Background(), bson.M{"_id": id}).Decode(&list)
+	return &list, err
+}
+
+func (db *DB) GetPriceLists(filter bson.M) ([]*PriceList, error) {
+	ctx := context.Background()
+	cursor, err := db.priceLists.Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	lists := []*PriceList{}
+	if err := cursor.All(ctx, &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// GetApplicablePriceLists returns the active price lists of a seller that are
+// assigned to the customer, directly or through one of its groups, and valid at.
+func (db *DB) GetApplicablePriceLists(sellerID, customerID string, at time.Time) ([]*PriceList, error) {
+	groupIDs, err := db.GetCustomerGroupIDs(sellerID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPriceLists(bson.M{
+		"sellerID": sellerID,
+		"active":   true,
+		"$or": bson.A{
+			bson.M{"customerIds": customerID},
+			bson.M{"groupIds": bson.M{"$in": groupIDs}},
+		},
+		// open bounds are stored as missing fields, which $not matches
+		"validFrom": bson.M{"$not": bson.M{"$gt": at}},
+		"validTo":   bson.M{"$not": bson.M{"$lte": at}},
+	})
+}
+
+// PriceListsStamp changes whenever the sellers' price lists assigned to the
+// customer, directly or through a group, change or are deleted, or one of
+// their validity windows opens or closes before at.
+func (db *DB) PriceListsStamp(sellerIDs []string, customerID string, at time.Time) (string, error) {
+	ctx := context.Background()
+	cursor, err := db.customerGroups.Find(ctx,
+		bson.M{"sellerID": bson.M{"$in": sellerIDs}, "customerIds": customerID},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return "", err
+	}
+	var groups []CustomerGroup
+	if err := cursor.All(ctx, &groups); err != nil {
+		return "", err
+	}
+	groupIDs := []string{}
+	for _, g := range groups {
+		groupIDs = append(groupIDs, g.ID.Hex())
+	}
+	return stamp(db.priceLists, bson.M{
+		"sellerID": bson.M{"$in": sellerIDs},
+		"$or": bson.A{
+			bson.M{"customerIds": customerID},
+			bson.M{"groupIds": bson.M{"$in": groupIDs}},
+		},
+	}, bson.M{
+		// Windows only ever open and close once, so these counts only grow
+		"started": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$and": bson.A{"$validFrom", bson.M{"$lte": bson.A{"$validFrom", at}}}}, 1, 0}}},
+		"ended":   bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$and": bson.A{"$validTo", bson.M{"$lte": bson.A{"$validTo", at}}}}, 1, 0}}},
+	})
+}
+
+func (db *DB) UpdatePriceList(list *PriceList) error {
+	list.UpdatedAt = time.Now()
+	_, err := db.priceLists.ReplaceOne(context.Background(), bson.M{"_id": list.ID}, list)
+	return err
+}
+
+func (db *DB) DeletePriceList(id primitive.ObjectID) error {
+	_, err := db.priceLists.DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
+
+/* ---------- customer groups ---------- */
+
+func (db *DB) CreateCustomerGroup(group *CustomerGroup) error {
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = group.CreatedAt
+	result, err := db.customerGroups.InsertOne(context.Background(), group)
+	if err != nil {
+		return err
+	}
+	group.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (db *DB) GetCustomerGroupByID(id primitive.ObjectID) (*CustomerGroup, error) {
+	var group CustomerGroup
+	err := db.customerGroups.FindOne(context.Background(), bson.M{"_id": id}).Decode(&group)
+	return &group, err
+}
+
+func (db *DB) GetCustomerGroups(sellerID string) ([]*CustomerGroup, error) {
+	ctx := context.Background()
+	cursor, err := db.customerGroups.Find(ctx, bson.M{"sellerID": sellerID}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []*CustomerGroup{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetCustomerGroupIDs returns the IDs of the seller's groups the customer belongs to.
+func (db *DB) GetCustomerGroupIDs(sellerID, customerID string) ([]string, error) {
+	ctx := context.Background()
+	cursor, err := db.customerGroups.Find(ctx,
+		bson.M{"sellerID": sellerID, "customerIds": customerID},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var groups []CustomerGroup
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, g := range groups {
+		ids = append(ids, g.ID.Hex())
+	}
+	return ids, nil
+}
+
+func (db *DB) UpdateCustomerGroup(group *CustomerGroup) error {
+	group.UpdatedAt = time.Now()
+	_, err := db.customerGroups.ReplaceOne(context.Background(), bson.M{"_id": group.ID}, group)
+	return err
+}
+
+// DeleteCustomerGroup removes the group and unassigns it from price lists.
+func (db *DB) DeleteCustomerGroup(id primitive.ObjectID) error {
+	ctx := context.Background()
+	if _, err := db.customerGroups.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	if _, err := db.priceLists.UpdateMany(ctx,
+		bson.M{"groupIds": id.Hex()},
+		bson.M{"$pull": bson.M{"groupIds": id.Hex()}},
+	); err != nil {
+		return err
+	}
+	_, err := db.visibilityRules.UpdateMany(ctx,
+		bson.M{"groupIds": id.Hex()},
+		bson.M{"$pull": bson.M{"groupIds": id.Hex()}},
+	)
+	return err
+}
@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Revision actions.
+const (
+	RevisionCreate = "create"
+	RevisionUpdate = "update"
+	RevisionDelete = "delete"
+)
+
+// Actor is who changes a product, recorded on its revisions.
+type Actor struct {
+	ID             string
+	Role           string
+	ImpersonatorID string // the admin acting as ID, if any
+	Source         string // e.g. "import"; empty for direct API calls
+}
+
+// unrecordedFields change on every write and are left out of diffs.
+var unrecordedFields = map[string]bool{"_id": true, "revision": true, "createdAt": true, "updatedAt": true}
+
+// CreateProduct stores a new product as revision 1.
+func (db *DB) CreateProduct(product *Product, actor Actor) error {
+	product.CreatedAt = time.Now()
+	product.UpdatedAt = product.CreatedAt
+	product.Revision = 1
+	return db.inTransaction(func(ctx mongo.SessionContext) error {
+		product.ID = primitive.NewObjectID()
+		if _, err := db.products.InsertOne(ctx, product); err != nil {
+			return err
+		}
+		return db.recordRevision(ctx, RevisionCreate, &Product{}, product, actor)
+	})
+}
+
+// UpdateProduct sets the given top-level fields of a product and records the
+// change as a new revision.
+func (db *DB) UpdateProduct(id primitive.ObjectID, update bson.M, actor Actor) error {
+	update["updatedAt"] = time.Now()
+	_, _, err := db.updateProduct(bson.M{"_id": id}, bson.M{"$set": update}, actor)
+	return err
+}
+
+// ErrRevisionConflict reports that a product changed since the revision an
+// update was based on.
+var ErrRevisionConflict = errors.New("product was changed concurrently")
+
+// PatchProduct sets and removes top-level fields of a product, provided it is
+// still at the given revision, and returns the product as updated. It fails
+// with ErrRevisionConflict when the product has moved on.
+func (db *DB) PatchProduct(id primitive.ObjectID, revision int, set bson.M, unset []string, actor Actor) (*Product, error) {
+	set["updatedAt"] = time.Now()
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		fields := bson.M{}
+		for _, field := range unset {
+			fields[field] = ""
+		}
+		update["$unset"] = fields
+	}
+	product, matched, err := db.updateProduct(bson.M{"_id": id, "revision": revision}, update, actor)
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		return nil, ErrRevisionConflict
+	}
+	return product, nil
+}
+
+// DeleteProduct removes a product. Its history is kept, ending in a delete
+// revision holding the product's last state.
+func (db *DB) DeleteProduct(id primitive.ObjectID, actor Actor) error {
+	return db.inTransaction(func(ctx mongo.SessionContext) error {
+		var product Product
+		err := db.products.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&product)
+		if err != nil {
+			return err
+		}
+		deleted := product
+		deleted.Revision++
+		deleted.UpdatedAt = time.Now()
+		return db.recordRevision(ctx, RevisionDelete, &product, &deleted, actor)
+	})
+}
+
+// updateProduct applies a MongoDB update to the product matching filter,
+// bumping its revision, and records the change. The product is read before
+// and after the update and the revision inserted in one transaction, so the
+// revision holds exactly the state the update produced and is never missing.
+// It reports false when no product matched.
+func (db *DB) updateProduct(filter, update bson.M, actor Actor) (*Product, bool, error) {
+	update["$inc"] = bson.M{"revision": 1}
+	var after *Product
+	err := db.inTransaction(func(ctx mongo.SessionContext) error {
+		after = nil
+		var before Product
+		err := db.products.FindOne(ctx, filter).Decode(&before)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var updated Product
+		err = db.products.FindOneAndUpdate(ctx, bson.M{"_id": before.ID}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err != nil {
+			return err
+		}
+		if err := db.recordRevision(ctx, RevisionUpdate, &before, &updated, actor); err != nil {
+			return err
+		}
+		after = &updated
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return after, after != nil, nil
+}
+
+func (db *DB) recordRevision(ctx context.Context, action string, before, after *Product, actor Actor) error {
+	changes, err := diffProducts(before, after)
+	if err != nil {
+		return err
+	}
+	rev := &ProductRevision{
+		ProductID:      after.ID.Hex(),
+		SellerID:       after.SellerID,
+		Revision:       after.Revision,
+		Action:         action,
+		ActorID:        actor.ID,
+		ActorRole:      actor.Role,
+		ImpersonatorID: actor.ImpersonatorID,
+		Source:         actor.Source,
+		Changes:        changes,
+		Product:        after,
+		CreatedAt:      after.UpdatedAt,
+	}
+	_, err = db.productRevisions.InsertOne(ctx, rev)
+	return err
+}
+
+// diffProducts lists the stored fields that differ between two states of a
+// product, by field name.
+func diffProducts(before, after *Product) ([]FieldChange, error) {
+	from, err := productDoc(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := productDoc(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	for field, value := range to {
+		if !unrecordedFields[field] && !reflect.DeepEqual(from[field], value) {
+			changes = append(changes, FieldChange{Field: field, From: from[field], To: value})
+		}
+	}
+	for field, value := range from {
+		if _, ok := to[field]; !ok && !unrecordedFields[field] {
+			changes = append(changes, FieldChange{Field: field, From: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func productDoc(p *Product) (bson.M, error) {
+	raw, err := bson.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(raw, &doc)
+	return doc, err
+}
+
+// GetProductRevisions returns a product's history, newest first, without the
+// product snapshots.
+func (db *DB) GetProductRevisions(productID string) ([]*ProductRevision, error) {
+	ctx := context.Background()
+	opts := options.Find().
+		SetSort(bson.M{"revision": -1}).
+		SetProjection(bson.M{"product": 0})
+	cursor, err := db.productRevisions.Find(ctx, bson.M{"productId": productID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []*ProductRevision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (db *DB) GetProductRevision(productID string, revision int) (*ProductRevision, error) {
+	var rev ProductRevision
+	err := db.productRevisions.FindOne(context.Background(),
+		bson.M{"productId": productID, "revision": revision},
+	).Decode(&rev)
+	return &rev, err
+}
+
+// GetProductRevisionAt returns the revision of a product in force at the
+// given time: the last one recorded at or before it.
+func (db *DB) GetProductRevisionAt(productID string, at time.Time) (*ProductRevision, error) {
+	var rev ProductRevision
+	err := db.productRevisions.FindOne(context.Background(),
+		bson.M{"productId": productID, "createdAt": bson.M{"$lte": at}},
+		options.FindOne().SetSort(bson.D{{Key: "revision", Value: -1}}),
+	).Decode(&rev)
+	return &rev, err
+}
+
+// ChangeCursor is a position in the log of product revisions: the time and
+// ID of the last revision read. A zero ID stands for every revision at At.
+type ChangeCursor struct {
+	At time.Time
+	ID primitive.ObjectID
+}
+
+// GetProductChanges returns the revisions of the given sellers' products
+// recorded after the cursor and no later than until, oldest first and
+// without the product snapshots. It returns at most limit revisions.
+func (db *DB) GetProductChanges(sellerIDs []string, after ChangeCursor, until time.Time, limit int) ([]*ProductRevision, error) {
+	ctx := context.Background()
+	since := bson.A{bson.M{"createdAt": bson.M{"$gt": after.At}}}
+	if !after.ID.IsZero() {
+		since = append(since, bson.M{"createdAt": after.At, "_id": bson.M{"$gt": after.ID}})
+	}
+	filter := bson.M{
+		"sellerID":  bson.M{"$in": sellerIDs},
+		"createdAt": bson.M{"$lte": until},
+		"$or":       since,
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"product": 0, "changes": 0}).
+		SetLimit(int64(limit))
+	cursor, err := db.productRevisions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []*ProductRevision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"business-cart/catalog-service/internal/fx"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	weightUnits    = map[string]bool{"g": true, "kg": true, "oz": true, "lb": true}
+	dimensionUnits = map[string]bool{"mm": true, "cm": true, "m": true, "in": true, "ft": true}
+)
+
+// Normalize trims identifiers, defaults the currency and assigns IDs to new
+// variants.
+func (p *Product) Normalize() {
+	p.Name = strings.TrimSpace(p.Name)
+	p.SKU = strings.TrimSpace(p.SKU)
+	p.Currency = strings.ToUpper(strings.TrimSpace(p.Currency))
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
+	}
+	for i := range p.Variants {
+		v := &p.Variants[i]
+		v.SKU = strings.TrimSpace(v.SKU)
+		if v.ID == "" {
+			v.ID = primitive.NewObjectID().Hex()
+		}
+	}
+}
+
+// Published reports whether customers see and can order the product at the
+// given time.
+func (p *Product) Published(at time.Time) bool {
+	return p.Status == ProductActive &&
+		(p.PublishAt == nil || !p.PublishAt.After(at)) &&
+		(p.UnpublishAt == nil || p.UnpublishAt.After(at))
+}
+
+// PublishedFilter matches the products Published at the given time.
+func PublishedFilter(at time.Time) bson.M {
+	return bson.M{
+		"status": ProductActive,
+		"$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"publishAt": nil}, bson.M{"publishAt": bson.M{"$lte": at}}}},
+			bson.M{"$or": bson.A{bson.M{"unpublishAt": nil}, bson.M{"unpublishAt": bson.M{"$gt": at}}}},
+		},
+	}
+}
+
+// Validate checks the product for consistency. The returned error is suitable
+// for showing to the seller.
+func (p *Product) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.Price < 0 {
+		return errors.New("price must not be negative")
+	}
+	if !fx.ValidCode(p.Currency) {
+		return errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	if p.GTIN != "" && !validCheckDigit(p.GTIN, 8, 12, 13, 14) {
+		return errors.New("gtin must be a valid GTIN-8, -12, -13 or -14")
+	}
+	if p.UPC != "" && !validCheckDigit(p.UPC, 12) {
+		return errors.New("upc must be a valid 12-digit UPC-A")
+	}
+	if err := ValidatePriceTiers(p.PriceTiers); err != nil {
+		return err
+	}
+	switch p.Status {
+	case ProductDraft, ProductActive, ProductDiscontinued, ProductArchived:
+	default:
+		return errors.New("status must be draft, active, discontinued or archived")
+	}
+	if p.PublishAt != nil && p.UnpublishAt != nil && !p.UnpublishAt.After(*p.PublishAt) {
+		return errors.New("unpublishAt must be after publishAt")
+	}
+	if p.PackSize < 0 {
+		return errors.New("packSize must not be negative")
+	}
+	if q := p.QuantityRules; q != nil {
+		if err := q.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := p.validateComponents(); err != nil {
+		return err
+	}
+	if w := p.Weight; w != nil {
+		if w.Value < 0 || !weightUnits[w.Unit] {
+			return errors.New("weight needs a non-negative value and a unit of g, kg, oz or lb")
+		}
+	}
+	if d := p.Dimensions; d != nil {
+		if d.Length < 0 || d.Width < 0 || d.Height < 0 || !dimensionUnits[d.Unit] {
+			return errors.New("dimensions need non-negative sizes and a unit of mm, cm, m, in or ft")
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, a := range p.Attributes {
+		if a.Name == "" {
+			return errors.New("attribute name is required")
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("attribute %q is defined twice", a.Name)
+		}
+		seen[a.Name] = true
+		if err := validateAttributeValue(a); err != nil {
+			return err
+		}
+	}
+
+	return p.validateVariants()
+}
+
+func (p *Product) validateVariants() error {
+	options := map[string]map[string]bool{}
+	for _, o := range p.VariantOptions {
+		if o.Name == "" || len(o.Values) == 0 {
+			return errors.New("variant options need a name and at least one value")
+		}
+		if options[o.Name] != nil {
+			return fmt.Errorf("variant option %q is defined twice", o.Name)
+		}
+		options[o.Name] = map[string]bool{}
+		for _, v := range o.Values {
+			options[o.Name][v] = true
+		}
+	}
+	if len(p.Variants) > 0 && len(options) == 0 {
+		return errors.New("variants require variantOptions")
+	}
+
+	skus := map[string]bool{}
+	if p.SKU != "" {
+		skus[p.SKU] = true
+	}
+	ids := map[string]bool{}
+	combos := map[string]bool{}
+	for _, v := range p.Variants {
+		if ids[v.ID] {
+			return fmt.Errorf("variant id %q is used twice", v.ID)
+		}
+		ids[v.ID] = true
+		if v.SKU == "" {
+			return errors.New("every variant needs a sku")
+		}
+		if skus[v.SKU] {
+			return fmt.Errorf("sku %q is used twice in this product", v.SKU)
+		}
+		skus[v.SKU] = true
+		if v.Price != nil && *v.Price < 0 {
+			return fmt.Errorf("variant %s: price must not be negative", v.SKU)
+		}
+		if len(v.PriceTiers) > 0 && v.Price == nil {
+			return fmt.Errorf("variant %s: priceTiers require a variant price", v.SKU)
+		}
+		if err := ValidatePriceTiers(v.PriceTiers); err != nil {
+			return fmt.Errorf("variant %s: %v", v.SKU, err)
+		}
+		if v.GTIN != "" && !validCheckDigit(v.GTIN, 8, 12, 13, 14) {
+			return fmt.Errorf("variant %s: gtin is not valid", v.SKU)
+		}
+		if len(v.Options) != len(options) {
+			return fmt.Errorf("variant %s must set exactly one value for each variant option", v.SKU)
+		}
+		var combo []string
+		for _, o := range p.VariantOptions {
+			value, ok := v.Options[o.Name]
+			if !ok || !options[o.Name][value] {
+				return fmt.Errorf("variant %s has no valid value for option %q", v.SKU, o.Name)
+			}
+			combo = append(combo, o.Name+"="+value)
+		}
+		key := strings.Join(combo, ";")
+		if combos[key] {
+			return fmt.Errorf("variant %s duplicates option combination %s", v.SKU, key)
+		}
+		combos[key] = true
+	}
+	return nil
+}
+
+// Validate checks that the rules are non-negative and that some quantity
+// satisfies all of them.
+func (q *QuantityRules) Validate() error {
+	if q.MinQuantity < 0 || q.Multiple < 0 || q.MaxQuantity < 0 {
+		return errors.New("quantityRules must not be negative")
+	}
+	if q.MaxQuantity > 0 && q.Smallest() > q.MaxQuantity {
+		return errors.New("quantityRules allow no quantity: maxQuantity is below the smallest allowed quantity")
+	}
+	return nil
+}
+
+// Smallest returns the smallest quantity the rules allow, ignoring
+// MaxQuantity.
+func (q *QuantityRules) Smallest() int {
+	smallest := q.MinQuantity
+	if smallest < 1 {
+		smallest = 1
+	}
+	if m := q.Multiple; m > 1 && smallest%m != 0 {
+		smallest += m - smallest%m
+	}
+	return smallest
+}
+
+// ValidatePriceTiers checks that tiers start above one unit, ascend strictly
+// by quantity and have non-negative prices.
+func ValidatePriceTiers(tiers []PriceTier) error {
+	last := 1
+	for _, t := range tiers {
+		if t.MinQuantity <= last {
+			return errors.New("priceTiers must start above 1 and ascend by minQuantity")
+		}
+		if t.Price < 0 {
+			return errors.New("priceTiers prices must not be negative")
+		}
+		last = t.MinQuantity
+	}
+	return nil
+}
+
+// Validate checks the category's name and attribute schema.
+func (c *Category) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	seen := map[string]bool{}
+	for _, d := range c.Attributes {
+		if d.Name == "" {
+			return errors.New("attribute name is required")
+		}
+		if seen[d.Name] {
+			return fmt.Errorf("attribute %q is defined twice", d.Name)
+		}
+		seen[d.Name] = true
+		switch d.Type {
+		case AttributeText:
+		case AttributeNumber, AttributeBoolean, AttributeDate:
+			if len(d.Values) > 0 {
+				return fmt.Errorf("attribute %q: values can only restrict text attributes", d.Name)
+			}
+		default:
+			return fmt.Errorf("attribute %q: type must be text, number, boolean or date", d.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateAttributes checks the product's attributes against an attribute
+// schema: required attributes must be present, and defined ones must have
+// the defined type and one of the allowed values. Attributes outside the
+// schema are left alone.
+func (p *Product) ValidateAttributes(schema []AttributeDefinition) error {
+	attributes := map[string]Attribute{}
+	for _, a := range p.Attributes {
+		attributes[a.Name] = a
+	}
+	for _, d := range schema {
+		a, ok := attributes[d.Name]
+		if !ok {
+			if d.Required {
+				return fmt.Errorf("attribute %q is required in this category", d.Name)
+			}
+			continue
+		}
+		if a.Type != d.Type {
+			return fmt.Errorf("attribute %q must be of type %s in this category", d.Name, d.Type)
+		}
+		if len(d.Values) > 0 {
+			allowed := false
+			for _, v := range d.Values {
+				allowed = allowed || a.Value == v
+			}
+			if !allowed {
+				return fmt.Errorf("attribute %q must be one of %s", d.Name, strings.Join(d.Values, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func validateAttributeValue(a Attribute) error {
+	switch a.Type {
+	case AttributeText:
+		if _, ok := a.Value.(string); ok {
+			return nil
+		}
+	case AttributeNumber:
+		switch a.Value.(type) {
+		case float64, int32, int64, int:
+			return nil
+		}
+	case AttributeBoolean:
+		if _, ok := a.Value.(bool); ok {
+			return nil
+		}
+	case AttributeDate:
+		if s, ok := a.Value.(string); ok {
+			if _, err := time.Parse("2006-01-02", s); err == nil {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("attribute %q: type must be text, number, boolean or date", a.Name)
+	}
+	return fmt.Errorf("attribute %q: value does not match type %s", a.Name, a.Type)
+}
+
+// validCheckDigit verifies a GS1 barcode number of one of the given lengths.
+func validCheckDigit(code string, lengths ...int) bool {
+	ok := false
+	for _, l := range lengths {
+		ok = ok || len(code) == l
+	}
+	if !ok {
+		return false
+	}
+
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		c := code[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		// weights alternate 3,1,3,... starting next to the check digit
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	last := code[len(code)-1]
+	if last < '0' || last > '9' {
+		return false
+	}
+	return (10-sum%10)%10 == int(last-'0')
+}
+
+// validateComponents checks a bundle's own composition. That the components
+// exist and belong to the seller is checked against the catalog by callers.
+func (p *Product) validateComponents() error {
+	if len(p.Components) == 0 {
+		return nil
+	}
+	if len(p.Variants) > 0 || len(p.VariantOptions) > 0 {
+		return errors.New("bundles cannot have variants")
+	}
+	seen := map[BundleComponent]bool{}
+	for _, c := range p.Components {
+		if c.ProductID == "" || c.Quantity < 1 {
+			return errors.New("bundle components need a productId and a quantity of at least 1")
+		}
+		if !p.ID.IsZero() && c.ProductID == p.ID.Hex() {
+			return errors.New("a bundle cannot contain itself")
+		}
+		key := BundleComponent{ProductID: c.ProductID, VariantID: c.VariantID}
+		if seen[key] {
+			return errors.New("bundle components must not repeat a product or variant")
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// IsBundle reports whether the product is made of other products.
+func (p *Product) IsBundle() bool {
+	return len(p.Components) > 0
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) CreateVisibilityRule(rule *VisibilityRule) error {
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = rule.CreatedAt
+	result, err := db.visibilityRules.InsertOne(context.Background(), rule)
+	if err != nil {
+		return err
+	}
+	rule.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (db *DB) GetVisibilityRuleByID(id primitive.ObjectID) (*VisibilityRule, error) {
+	var rule VisibilityRule
+	err := db.visibilityRules.FindOne(context.Background(), bson.M{"_id": id}).Decode(&rule)
+	return &rule, err
+}
+
+func (db *DB) GetVisibilityRules(filter bson.M) ([]*VisibilityRule, error) {
+	ctx := context.Background()
+	cursor, err := db.visibilityRules.Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rules := []*VisibilityRule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (db *DB) UpdateVisibilityRule(rule *VisibilityRule) error {
+	rule.UpdatedAt = time.Now()
+	_, err := db.visibilityRules.ReplaceOne(context.Background(), bson.M{"_id": rule.ID}, rule)
+	return err
+}
+
+func (db *DB) DeleteVisibilityRule(id primitive.ObjectID) error {
+	_, err := db.visibilityRules.DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
+
+// GetSubcategoryIDs returns, for each of the given categories, the IDs of
+// every category below it.
+func (db *DB) GetSubcategoryIDs(ids []string) (map[string][]string, error) {
+	subcategories := map[string][]string{}
+	if len(ids) == 0 {
+		return subcategories, nil
+	}
+	wanted := map[string]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	categories, err := db.findCategories(bson.M{"path": bson.M{"$in": ids}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		for _, ancestor := range c.Path {
+			if wanted[ancestor] {
+				subcategories[ancestor] = append(subcategories[ancestor], c.ID.Hex())
+			}
+		}
+	}
+	return subcategories, nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (db *DB) CreateWarehouse(warehouse *Warehouse) error {
+	warehouse.CreatedAt = time.Now()
+	warehouse.UpdatedAt = warehouse.CreatedAt
+	result, err := db.warehouses.InsertOne(context.Background(), warehouse)
+	if err != nil {
+		return err
+	}
+	warehouse.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (db *DB) GetWarehouseByID(id primitive.ObjectID) (*Warehouse, error) {
+	var warehouse Warehouse
+	err := db.warehouses.FindOne(context.Background(), bson.M{"_id": id}).Decode(&warehouse)
+	return &warehouse, err
+}
+
+// GetWarehouses returns the warehouses of a seller, optionally only active ones.
+func (db *DB) GetWarehouses(sellerID string, activeOnly bool) ([]*Warehouse, error) {
+	ctx := context.Background()
+	filter := bson.M{"sellerID": sellerID}
+	if activeOnly {
+		filter["active"] = true
+	}
+	cursor, err := db.warehouses.Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	warehouses := []*Warehouse{}
+	if err := cursor.All(ctx, &warehouses); err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+func (db *DB) UpdateWarehouse(warehouse *Warehouse) error {
+	warehouse.UpdatedAt = time.Now()
+	_, err := db.warehouses.ReplaceOne(context.Background(), bson.M{"_id": warehouse.ID}, warehouse)
+	return err
+}
+
+func (db *DB) DeleteWarehouse(id primitive.ObjectID) error {
+	_, err := db.warehouses.DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
+
+// WarehouseHasStock reports whether any stock is on hand or reserved at the warehouse.
+func (db *DB) WarehouseHasStock(warehouseID string) (bool, error) {
+	n, err := db.stock.CountDocuments(context.Background(), bson.M{
+		"warehouseId": warehouseID,
+		"$or":         bson.A{bson.M{"onHand": bson.M{"$ne": 0}}, bson.M{"reserved": bson.M{"$ne": 0}}},
+	})
+	return n > 0, err
+}
@@ -0,0 +1,171 @@
+// Package visibility applies a seller's visibility rules to one customer.
+//
+// A product is restricted when an active rule covers it, by product or by a
+// category at or above the product's category. A restricted product is
+// visible only to the customers of a rule covering it, named directly or
+// through one of their customer groups. The same Restriction serves product
+// lists and search (as a query filter), product detail and price resolution
+// (per product), so every path hides the same products.
+package visibility
+
+import (
+	"sort"
+	"strings"
+
+	"business-cart/catalog-service/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Restriction is what a seller's rules mean for one customer: the products
+// and categories the rules cover, and those of them the customer may see.
+type Restriction struct {
+	SellerID string
+	covered  scope
+	allowed  scope
+}
+
+type scope struct {
+	products   map[string]bool
+	categories map[string]bool
+}
+
+// New builds the restriction the given rules of a seller place on a
+// customer in the given groups. subcategories maps each category named by a
+// rule to the categories below it. It returns nil when no active rule
+// covers anything.
+func New(sellerID, customerID string, groupIDs []string, rules []*storage.VisibilityRule, subcategories map[string][]string) *Restriction {
+	r := &Restriction{SellerID: sellerID, covered: newScope(), allowed: newScope()}
+	groups := map[string]bool{}
+	for _, id := range groupIDs {
+		groups[id] = true
+	}
+	for _, rule := range rules {
+		if rule.SellerID != sellerID || !rule.Active {
+			continue
+		}
+		r.covered.add(rule, subcategories)
+		if names(rule, customerID, groups) {
+			r.allowed.add(rule, subcategories)
+		}
+	}
+	if len(r.covered.products) == 0 && len(r.covered.categories) == 0 {
+		return nil
+	}
+	return r
+}
+
+// Visible reports whether the customer may see the product. Products of
+// other sellers are not affected.
+func (r *Restriction) Visible(p *storage.Product) bool {
+	if r == nil || p.SellerID != r.SellerID || !r.covered.has(p) {
+		return true
+	}
+	return r.allowed.has(p)
+}
+
+// Fingerprint summarises what the restriction covers and allows, so that
+// callers can tell when a customer's view of the seller's catalog changed.
+func (r *Restriction) Fingerprint() string {
+	if r == nil {
+		return ""
+	}
+	return r.SellerID + "|" + r.covered.key() + "|" + r.allowed.key()
+}
+
+// HiddenFilter matches the seller's products hidden from the customer.
+func (r *Restriction) HiddenFilter() bson.M {
+	filter := bson.M{
+		"sellerID": r.SellerID,
+		"$or":      r.covered.filter(),
+	}
+	if allowed := r.allowed.filter(); len(allowed) > 0 {
+		filter["$nor"] = allowed
+	}
+	return filter
+}
+
+// Exclude adds a condition to a product filter that drops every product
+// hidden by one of the restrictions.
+func Exclude(filter bson.M, restrictions []*Restriction) {
+	var hidden bson.A
+	for _, r := range restrictions {
+		if r != nil {
+			hidden = append(hidden, r.HiddenFilter())
+		}
+	}
+	if len(hidden) > 0 {
+		filter["$nor"] = hidden
+	}
+}
+
+func names(rule *storage.VisibilityRule, customerID string, groups map[string]bool) bool {
+	for _, id := range rule.CustomerIDs {
+		if id == customerID {
+			return true
+		}
+	}
+	for _, id := range rule.GroupIDs {
+		if groups[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func newScope() scope {
+	return scope{products: map[string]bool{}, categories: map[string]bool{}}
+}
+
+func (s scope) add(rule *storage.VisibilityRule, subcategories map[string][]string) {
+	for _, id := range rule.ProductIDs {
+		s.products[id] = true
+	}
+	for _, id := range rule.CategoryIDs {
+		s.categories[id] = true
+		for _, sub := range subcategories[id] {
+			s.categories[sub] = true
+		}
+	}
+}
+
+// key lists the scope's products and categories in a stable order.
+func (s scope) key() string {
+	var ids []string
+	for id := range s.products {
+		ids = append(ids, "p:"+id)
+	}
+	for id := range s.categories {
+		ids = append(ids, "c:"+id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func (s scope) has(p *storage.Product) bool {
+	return s.products[p.ID.Hex()] || p.CategoryID != "" && s.categories[p.CategoryID]
+}
+
+// filter returns the conditions matching the scope's products, for use in
+// $or or $nor.
+func (s scope) filter() bson.A {
+	var conditions bson.A
+	if len(s.products) > 0 {
+		ids := []primitive.ObjectID{}
+		for id := range s.products {
+			if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+				ids = append(ids, oid)
+			}
+		}
+		conditions = append(conditions, bson.M{"_id": bson.M{"$in": ids}})
+	}
+	if len(s.categories) > 0 {
+		ids := []string{}
+		for id := range s.categories {
+			ids = append(ids, id)
+		}
+		conditions = append(conditions, bson.M{"categoryId": bson.M{"$in": ids}})
+	}
+	return conditions
+}
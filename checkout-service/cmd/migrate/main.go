@@ -0,0 +1,55 @@
+// Command migrate applies pending CheckoutService schema migrations.
+//
+//	go run ./cmd/migrate          # apply pending migrations
+//	go run ./cmd/migrate -status  # list applied and pending migrations
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/syed/businesscart/checkout-service/internal/config"
+	"github.com/syed/businesscart/checkout-service/internal/migrations"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	status := flag.Bool("status", false, "list applied and pending migrations without applying them")
+	flag.Parse()
+
+	cfg := config.NewConfig()
+
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(cfg.MongoURI))
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer client.Disconnect(context.TODO())
+
+	db := client.Database(cfg.MongoDatabase)
+	ctx := context.Background()
+	if *status {
+		applied, err := migrations.Applied(ctx, db)
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		for _, r := range applied {
+			fmt.Printf("applied  %3d  %s  (%s)\n", r.Version, r.Description, r.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		pending, err := migrations.Pending(ctx, db)
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		for _, m := range pending {
+			fmt.Printf("pending  %3d  %s\n", m.Version, m.Description)
+		}
+		return
+	}
+
+	if err := migrations.Run(ctx, db); err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	fmt.Println("migrations up to date")
+}
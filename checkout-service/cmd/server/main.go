@@ -6,12 +6,19 @@ import (
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/syed/businesscart/checkout-service/internal/account"
 	"github.com/syed/businesscart/checkout-service/internal/cart"
+	"github.com/syed/businesscart/checkout-service/internal/catalog"
 	"github.com/syed/businesscart/checkout-service/internal/config"
+	"github.com/syed/businesscart/checkout-service/internal/credit"
 	"github.com/syed/businesscart/checkout-service/internal/handler"
+	"github.com/syed/businesscart/checkout-service/internal/list"
+	"github.com/syed/businesscart/checkout-service/internal/migrations"
 	"github.com/syed/businesscart/checkout-service/internal/order"
 	"github.com/syed/businesscart/checkout-service/internal/payment"
+	"github.com/syed/businesscart/checkout-service/internal/punchout"
 	"github.com/syed/businesscart/checkout-service/internal/quote"
+	"github.com/syed/businesscart/checkout-service/internal/revocation"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -27,12 +34,25 @@ func main() {
 	log.Println("Successfully connected to MongoDB.")
 
 	db := client.Database(cfg.MongoDatabase)
+
+	if cfg.RunMigrations {
+		if err := migrations.Run(context.TODO(), db); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
 	cartService := cart.NewService(db)
 	quoteService := quote.NewService(db)
 	orderService := order.NewService(db)
+	listService := list.NewService(db)
+	punchoutService := punchout.NewService(db)
 	paymentService := payment.NewPaymentService()
+	accountClient := account.NewClient(cfg.AccountServiceUrl)
+	catalogClient := catalog.NewClient(cfg.CatalogServiceUrl)
+	creditService := credit.NewService(accountClient, orderService)
+	revocationService := revocation.NewService(client.Database(cfg.AccountDatabase))
 
-	lambdaHandler := handler.NewLambdaHandler(cartService, quoteService, orderService, paymentService, cfg.JWTSecret)
+	lambdaHandler := handler.NewLambdaHandler(cartService, quoteService, orderService, paymentService, creditService, listService, punchoutService, revocationService, accountClient, catalogClient, cfg.JWTSecret, cfg.PunchOutStartURL)
 
 	log.Println("Starting Lambda handler...")
 	lambda.Start(func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
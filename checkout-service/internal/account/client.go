@@ -0,0 +1,160 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CreditTerms mirrors the credit terms returned by account-service.
+type CreditTerms struct {
+	SellerID         string  `json:"sellerId"`
+	CustomerID       string  `json:"customerId"`
+	CreditLimit      float64 `json:"creditLimit"`
+	PaymentTermsDays int     `json:"paymentTermsDays"`
+	OverLimitAction  string  `json:"overLimitAction"`
+	IsDefault        bool    `json:"isDefault"`
+}
+
+// TradingTerms mirrors the seller/customer relationship returned by account-service.
+type TradingTerms struct {
+	SellerID              string   `json:"sellerId"`
+	CustomerID            string   `json:"customerId"`
+	Status                string   `json:"status"`
+	AllowedPaymentMethods []string `json:"allowedPaymentMethods"`
+	NetTermsDays          int      `json:"netTermsDays"`
+	DefaultShippingMethod string   `json:"defaultShippingMethod"`
+	DiscountTier          string   `json:"discountTier"`
+	DiscountPercent       float64  `json:"discountPercent"`
+	TaxExempt             bool     `json:"taxExempt"`
+	SalesRep              string   `json:"salesRep"`
+	Currency              string   `json:"currency"` // the seller's default currency
+}
+
+type Coords struct {
+	Lat float64 `bson:"lat" json:"lat"`
+	Lng float64 `bson:"lng" json:"lng"`
+}
+
+// Address mirrors the address of an account in account-service.
+type Address struct {
+	Street string `bson:"street" json:"street"`
+	City   string `bson:"city" json:"city"`
+	State  string `bson:"state" json:"state"`
+	Zip    string `bson:"zip" json:"zip"`
+	Coords Coords `bson:"coordinates" json:"coordinates"`
+}
+
+// RelationshipActive is the only status under which a customer may buy.
+const RelationshipActive = "active"
+
+// ErrNotFound is returned when account-service has no such account, or the
+// customer is not linked to the seller.
+var ErrNotFound = errors.New("not found")
+
+// Account mirrors the parts of an account in account-service checkout needs.
+type Account struct {
+	ID       string `json:"_id"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Customer *struct {
+		CustomerCodes []struct {
+			CodeID string `json:"codeId"` // the seller's account ID
+		} `json:"customerCodes"`
+	} `json:"customer,omitempty"`
+}
+
+// LinkedTo reports whether the account is a customer of the seller.
+func (a *Account) LinkedTo(sellerID string) bool {
+	if a.Customer == nil {
+		return false
+	}
+	for _, code := range a.Customer.CustomerCodes {
+		if code.CodeID == sellerID {
+			return true
+		}
+	}
+	return false
+}
+
+// Client calls account-service on behalf of the current caller.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a new account-service client.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetCreditTerms fetches the credit a seller extends to a customer. The
+// caller's Authorization header is forwarded so account-service applies its
+// own access rules.
+func (c *Client) GetCreditTerms(authHeader, customerID, sellerID string) (*CreditTerms, error) {
+	path := fmt.Sprintf("/accounts/%s/credit?sellerId=%s", url.PathEscape(customerID), url.QueryEscape(sellerID))
+	var terms CreditTerms
+	if err := c.get(authHeader, path, &terms); err != nil {
+		return nil, err
+	}
+	return &terms, nil
+}
+
+// GetTradingTerms fetches the terms a seller has negotiated with a customer.
+func (c *Client) GetTradingTerms(authHeader, customerID, sellerID string) (*TradingTerms, error) {
+	path := fmt.Sprintf("/accounts/%s/relationships/%s", url.PathEscape(customerID), url.PathEscape(sellerID))
+	var terms TradingTerms
+	if err := c.get(authHeader, path, &terms); err != nil {
+		return nil, err
+	}
+	return &terms, nil
+}
+
+// GetAccount fetches an account by ID.
+func (c *Client) GetAccount(authHeader, accountID string) (*Account, error) {
+	var acc Account
+	if err := c.get(authHeader, "/accounts/"+url.PathEscape(accountID), &acc); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// GetAddress fetches the address on file for an account, or nil when it has none.
+func (c *Client) GetAddress(authHeader, accountID string) (*Address, error) {
+	var acc struct {
+		Address *Address `json:"address"`
+	}
+	if err := c.get(authHeader, "/accounts/"+url.PathEscape(accountID), &acc); err != nil {
+		return nil, err
+	}
+	return acc.Address, nil
+}
+
+func (c *Client) get(authHeader, path string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("account-service %s: %w", path, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("account-service %s: status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
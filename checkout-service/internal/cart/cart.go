@@ -20,10 +20,21 @@ func NewService(db *mongo.Database) *Service {
 	return &Service{collection: db.Collection("carts")}
 }
 
-// GetCart retrieves a user's cart for a specific company.
-func (s *Service) GetCart(accountID, sellerID string) (*Cart, error) {
+// cartFilter selects the account's cart with a seller: the cart of a PunchOut
+// session, or with no sessionID the account's own cart.
+func cartFilter(accountID, sellerID, sessionID string) bson.M {
+	filter := bson.M{"accountId": accountID, "sellerId": sellerID, "sessionId": bson.M{"$exists": false}}
+	if sessionID != "" {
+		filter["sessionId"] = sessionID
+	}
+	return filter
+}
+
+// GetCart retrieves a user's cart for a specific company, or the cart of
+// their PunchOut session with it.
+func (s *Service) GetCart(accountID, sellerID, sessionID string) (*Cart, error) {
 	var cart Cart
-	err := s.collection.FindOne(context.TODO(), bson.M{"accountId": accountID, "sellerId": sellerID}).Decode(&cart)
+	err := s.collection.FindOne(context.TODO(), cartFilter(accountID, sellerID, sessionID)).Decode(&cart)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, errors.New("cart not found")
@@ -35,10 +46,10 @@ func (s *Service) GetCart(accountID, sellerID string) (*Cart, error) {
 }
 
 // ClearCart removes all items from a user's cart for a specific company.
-func (s *Service) ClearCart(accountID, sellerID string) error {
+func (s *Service) ClearCart(accountID, sellerID, sessionID string) error {
 	_, err := s.collection.UpdateOne(
 		context.TODO(),
-		bson.M{"accountId": accountID, "sellerId": sellerID},
+		cartFilter(accountID, sellerID, sessionID),
 		bson.M{"$set": bson.M{"items": []CartItem{}}},
 	)
 	return err
@@ -50,7 +61,7 @@ func (s *Service) SaveCart(cart *Cart) error {
 	cart.TotalPrice = s.calculateTotalPrice(*cart)
 	result, err := s.collection.UpdateOne(
 		context.TODO(),
-		bson.M{"accountId": cart.AccountID, "sellerId": cart.SellerID},
+		cartFilter(cart.AccountID, cart.SellerID, cart.SessionID),
 		bson.M{"$set": cart},
 		options.Update().SetUpsert(true),
 	)
@@ -62,10 +73,51 @@ func (s *Service) SaveCart(cart *Cart) error {
 	return nil
 }
 
+// DeleteCart removes the cart of a PunchOut session once it is returned.
+func (s *Service) DeleteCart(accountID, sellerID, sessionID string) error {
+	_, err := s.collection.DeleteOne(context.TODO(), cartFilter(accountID, sellerID, sessionID))
+	return err
+}
+
 func (s *Service) calculateTotalPrice(cart Cart) float64 {
+	return Subtotal(cart.Items)
+}
+
+// Subtotal sums the price of the given items.
+func Subtotal(items []CartItem) float64 {
 	var total float64
-	for _, item := range cart.Items {
+	for _, item := range items {
 		total += item.Price * float64(item.Quantity)
 	}
 	return total
 }
+
+// ExchangeRates returns the distinct rates the items were converted at, one
+// per currency converted from.
+func ExchangeRates(items []CartItem) []ExchangeRate {
+	var rates []ExchangeRate
+	seen := map[string]bool{}
+	for _, item := range items {
+		if r := item.ExchangeRate; r != nil && !seen[r.From] {
+			seen[r.From] = true
+			rates = append(rates, *r)
+		}
+	}
+	return rates
+}
+
+// Fulfilment lists what has to be picked to ship the items: each item as it
+// is, except that bundles are expanded into their components.
+func Fulfilment(items []CartItem) []FulfilmentLine {
+	var lines []FulfilmentLine
+	for _, item := range items {
+		if len(item.Components) == 0 {
+			lines = append(lines, FulfilmentLine{ItemID: item.ID.Hex(), ProductID: item.ProductID, VariantID: item.VariantID, WarehouseID: item.WarehouseID, Quantity: item.Quantity})
+			continue
+		}
+		for _, c := range item.Components {
+			lines = append(lines, FulfilmentLine{ItemID: item.ID.Hex(), ProductID: c.ProductID, VariantID: c.VariantID, WarehouseID: item.WarehouseID, Quantity: c.Quantity * item.Quantity})
+		}
+	}
+	return lines
+}
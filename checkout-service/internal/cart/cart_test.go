@@ -0,0 +1,81 @@
+package cart
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExchangeRates(t *testing.T) {
+	asOf := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	eur := &ExchangeRate{From: "EUR", To: "USD", Rate: 1.08, AsOf: asOf, Source: "static"}
+	gbp := &ExchangeRate{From: "GBP", To: "USD", Rate: 1.27, AsOf: asOf, Source: "static"}
+
+	tests := []struct {
+		name  string
+		items []CartItem
+		want  []ExchangeRate
+	}{
+		{"no items", nil, nil},
+		{"nothing converted", []CartItem{{ProductID: "a"}, {ProductID: "b"}}, nil},
+		{"one rate per source currency", []CartItem{
+			{ProductID: "a", ExchangeRate: eur},
+			{ProductID: "b"},
+			{ProductID: "c", ExchangeRate: gbp},
+			{ProductID: "d", ExchangeRate: eur},
+		}, []ExchangeRate{*eur, *gbp}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExchangeRates(tt.items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExchangeRates() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeRatesIsASnapshot(t *testing.T) {
+	rate := &ExchangeRate{From: "EUR", To: "USD", Rate: 1.08}
+	rates := ExchangeRates([]CartItem{{ProductID: "a", ExchangeRate: rate}})
+
+	rate.Rate = 1.20 // the line is repriced after the quote was taken
+	if rates[0].Rate != 1.08 {
+		t.Errorf("stored rate = %v, want the 1.08 quoted", rates[0].Rate)
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     float64
+	}{
+		{19.999, "USD", 20},
+		{0.125, "EUR", 0.13},
+		{1234.5, "JPY", 1235},
+		{82.49, "JPY", 82},
+		{1.2345, "KWD", 1.235},
+		{10.005, "XXX", 10.01}, // unknown currencies have two decimals
+	}
+	for _, tt := range tests {
+		if got := Round(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("Round(%v, %s) = %v, want %v", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestSubtotalJPY(t *testing.T) {
+	// Yen prices converted from USD carry fractions until rounded
+	items := []CartItem{{ProductID: "a", Price: 1530.4, Quantity: 3}, {ProductID: "b", Price: 98.6, Quantity: 1}}
+	subtotal := Round(Subtotal(items), "JPY")
+	if subtotal != 4690 {
+		t.Errorf("subtotal = %v, want 4690", subtotal)
+	}
+	tax := Round(subtotal*0.0825, "JPY")
+	if tax != 387 {
+		t.Errorf("tax = %v, want 387", tax)
+	}
+	if MinorUnits("JPY") != 0 {
+		t.Errorf("MinorUnits(JPY) = %d, want 0", MinorUnits("JPY"))
+	}
+}
@@ -1,15 +1,69 @@
 package cart
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // CartItem represents an item in a shopping cart.
 type CartItem struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	ProductID string             `bson:"productId" json:"productId"`
-	Quantity  int                `bson:"quantity" json:"quantity"`
-	SellerID  string             `bson:"sellerId" json:"sellerId"`
-	Name      string             `bson:"name" json:"name"`
-	Price     float64            `bson:"price" json:"price"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	ProductID       string             `bson:"productId" json:"productId"`
+	VariantID       string             `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	WarehouseID     string             `bson:"warehouseId,omitempty" json:"warehouseId,omitempty"` // stock location chosen at quote time
+	Quantity        int                `bson:"quantity" json:"quantity"`
+	SellerID        string             `bson:"sellerId" json:"sellerId"`
+	Name            string             `bson:"name" json:"name"`
+	Price           float64            `bson:"price" json:"price"`
+	ListPrice       float64            `bson:"listPrice,omitempty" json:"listPrice,omitempty"` // seller's standard price when a price list applies
+	PriceListID     string             `bson:"priceListId,omitempty" json:"priceListId,omitempty"`
+	PriceTier       *PriceTier         `bson:"priceTier,omitempty" json:"priceTier,omitempty"`             // quantity break applied to Price
+	ProductRevision int                `bson:"productRevision,omitempty" json:"productRevision,omitempty"` // catalog revision of the product as priced
+	QuantityRules   *QuantityRules     `bson:"quantityRules,omitempty" json:"quantityRules,omitempty"`     // the product's order quantity rules
+	ExchangeRate    *ExchangeRate      `bson:"exchangeRate,omitempty" json:"exchangeRate,omitempty"`       // converted the prices from the product's currency
+
+	// CustomerPartNumber is the customer's own code for the item
+	CustomerPartNumber string `bson:"customerPartNumber,omitempty" json:"customerPartNumber,omitempty"`
+
+	// Components of a bundle line, per bundle. They are reserved and shipped
+	// in its place.
+	Components []BundleComponent `bson:"components,omitempty" json:"components,omitempty"`
+}
+
+// BundleComponent is the quantity of a product or variant in one bundle.
+type BundleComponent struct {
+	ProductID string `bson:"productId" json:"productId"`
+	VariantID string `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	Quantity  int    `bson:"quantity" json:"quantity"`
+}
+
+// FulfilmentLine is a quantity of a product or variant to pick from a
+// warehouse for a cart line.
+type FulfilmentLine struct {
+	ItemID      string `bson:"itemId" json:"itemId"`
+	ProductID   string `bson:"productId" json:"productId"`
+	VariantID   string `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	WarehouseID string `bson:"warehouseId,omitempty" json:"warehouseId,omitempty"`
+	Quantity    int    `bson:"quantity" json:"quantity"`
+}
+
+// ExchangeRate is the rate catalog-service converted a line's prices at,
+// kept so the price can be explained after rates move.
+type ExchangeRate struct {
+	From   string    `bson:"from" json:"from"`
+	To     string    `bson:"to" json:"to"`
+	Rate   float64   `bson:"rate" json:"rate"`
+	AsOf   time.Time `bson:"asOf" json:"asOf"`
+	Source string    `bson:"source,omitempty" json:"source,omitempty"`
+}
+
+// PriceTier is the quantity break a line's unit price came from. MaxQuantity
+// is zero for the open-ended top tier.
+type PriceTier struct {
+	MinQuantity int     `bson:"minQuantity" json:"minQuantity"`
+	MaxQuantity int     `bson:"maxQuantity,omitempty" json:"maxQuantity,omitempty"`
+	Price       float64 `bson:"price" json:"price"`
 }
 
 // Cart represents a shopping cart.
@@ -19,4 +73,14 @@ type Cart struct {
 	SellerID   string             `bson:"sellerId" json:"sellerId"`
 	Items      []CartItem         `bson:"items" json:"items"`
 	TotalPrice float64            `bson:"totalPrice" json:"totalPrice"`
+	Currency   string             `bson:"currency,omitempty" json:"currency,omitempty"` // ISO 4217 code every line is priced in
+
+	// SessionID is set on the cart of a PunchOut session, kept apart from
+	// the customer's own cart with the seller until the session expires.
+	SessionID string     `bson:"sessionId,omitempty" json:"sessionId,omitempty"`
+	ExpiresAt *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+
+	// Adjustments lists quantities changed by the last request to meet
+	// quantity rules
+	Adjustments []QuantityAdjustment `bson:"-" json:"adjustments,omitempty"`
 }
@@ -0,0 +1,26 @@
+package cart
+
+import "math"
+
+// minorUnits lists the ISO 4217 currencies without two decimal places, as
+// catalog-service's fx package does.
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorUnits returns the number of decimal places of a currency: 2 unless
+// the currency is known to use another.
+func MinorUnits(code string) int {
+	if n, ok := minorUnits[code]; ok {
+		return n
+	}
+	return 2
+}
+
+// Round rounds an amount to the minor unit of its currency.
+func Round(amount float64, code string) float64 {
+	scale := math.Pow10(MinorUnits(code))
+	return math.Round(amount*scale) / scale
+}
@@ -0,0 +1,112 @@
+package cart
+
+// Reasons a line's quantity breaks its product's quantity rules.
+const (
+	BelowMinimum = "below_minimum"
+	NotMultiple  = "not_a_multiple"
+	AboveMaximum = "above_maximum"
+)
+
+// QuantityRules mirror a product's order quantity rules in catalog-service:
+// at least MinQuantity, a multiple of Multiple and at most MaxQuantity. Zero
+// leaves a rule unset.
+type QuantityRules struct {
+	MinQuantity int `bson:"minQuantity,omitempty" json:"minQuantity,omitempty"`
+	Multiple    int `bson:"multiple,omitempty" json:"multiple,omitempty"`
+	MaxQuantity int `bson:"maxQuantity,omitempty" json:"maxQuantity,omitempty"`
+}
+
+// QuantityViolation reports a line whose quantity breaks the rules, with the
+// nearest quantity they allow (zero if none).
+type QuantityViolation struct {
+	ItemID          string        `json:"itemId,omitempty"`
+	ProductID       string        `json:"productId"`
+	VariantID       string        `json:"variantId,omitempty"`
+	Quantity        int           `json:"quantity"`
+	Reason          string        `json:"reason"`
+	Rules           QuantityRules `json:"quantityRules"`
+	AllowedQuantity int           `json:"allowedQuantity,omitempty"`
+}
+
+// QuantityAdjustment records a line quantity changed to meet the rules.
+type QuantityAdjustment struct {
+	ItemID    string `json:"itemId"`
+	ProductID string `json:"productId"`
+	VariantID string `json:"variantId,omitempty"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Reason    string `json:"reason"`
+}
+
+// Check returns why quantity breaks the rules, or "" if it meets them.
+func (r QuantityRules) Check(quantity int) string {
+	switch {
+	case r.MinQuantity > 0 && quantity < r.MinQuantity:
+		return BelowMinimum
+	case r.Multiple > 1 && quantity%r.Multiple != 0:
+		return NotMultiple
+	case r.MaxQuantity > 0 && quantity > r.MaxQuantity:
+		return AboveMaximum
+	}
+	return ""
+}
+
+// Adjust returns the allowed quantity nearest to quantity: raised to the
+// minimum and up to the next multiple, or lowered to the largest allowed
+// quantity when above the maximum. It returns 0 if the rules allow none.
+func (r QuantityRules) Adjust(quantity int) int {
+	q := quantity
+	if q < r.MinQuantity {
+		q = r.MinQuantity
+	}
+	if q < 1 {
+		q = 1
+	}
+	if m := r.Multiple; m > 1 && q%m != 0 {
+		q += m - q%m
+	}
+	if r.MaxQuantity > 0 && q > r.MaxQuantity {
+		q = r.MaxQuantity
+		if m := r.Multiple; m > 1 {
+			q -= q % m
+		}
+	}
+	if r.Check(q) != "" {
+		return 0
+	}
+	return q
+}
+
+// CheckQuantity returns the violation of the item's quantity rules, or nil.
+func CheckQuantity(item CartItem) *QuantityViolation {
+	if item.QuantityRules == nil {
+		return nil
+	}
+	reason := item.QuantityRules.Check(item.Quantity)
+	if reason == "" {
+		return nil
+	}
+	v := &QuantityViolation{
+		ProductID:       item.ProductID,
+		VariantID:       item.VariantID,
+		Quantity:        item.Quantity,
+		Reason:          reason,
+		Rules:           *item.QuantityRules,
+		AllowedQuantity: item.QuantityRules.Adjust(item.Quantity),
+	}
+	if !item.ID.IsZero() {
+		v.ItemID = item.ID.Hex()
+	}
+	return v
+}
+
+// CheckQuantities returns the violations of every item's quantity rules.
+func CheckQuantities(items []CartItem) []QuantityViolation {
+	var violations []QuantityViolation
+	for _, item := range items {
+		if v := CheckQuantity(item); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+	return violations
+}
@@ -0,0 +1,300 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrReservationNotOpen is returned when a reservation was already committed
+// or released.
+var ErrReservationNotOpen = errors.New("reservation is not open")
+
+// ReservationLine is one product or variant held by a reservation.
+type ReservationLine struct {
+	ProductID   string `json:"productId"`
+	VariantID   string `json:"variantId,omitempty"`
+	WarehouseID string `json:"warehouseId,omitempty"`
+	Quantity    int    `json:"quantity"`
+}
+
+type Coords struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Warehouse mirrors a seller's stock location in catalog-service.
+type Warehouse struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Address struct {
+		Coords Coords `json:"coordinates"`
+	} `json:"address"`
+}
+
+// StockAvailability is the available stock of a product or variant at one warehouse.
+type StockAvailability struct {
+	ProductID   string `json:"productId"`
+	VariantID   string `json:"variantId,omitempty"`
+	WarehouseID string `json:"warehouseId,omitempty"`
+	Available   int    `json:"available"`
+}
+
+// PriceLine identifies a product or variant and the quantity to price it at.
+type PriceLine struct {
+	ProductID string `json:"productId"`
+	VariantID string `json:"variantId,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Tier is the quantity break a price came from.
+type Tier struct {
+	MinQuantity int     `json:"minQuantity"`
+	MaxQuantity int     `json:"maxQuantity,omitempty"`
+	Price       float64 `json:"price"`
+}
+
+// Price mirrors a price resolved by catalog-service for the caller.
+type Price struct {
+	ProductID   string  `json:"productId"`
+	VariantID   string  `json:"variantId,omitempty"`
+	ListPrice   float64 `json:"listPrice"`
+	Price       float64 `json:"price"`
+	Currency    string  `json:"currency"`
+	PriceListID string  `json:"priceListId,omitempty"`
+	Tier        *Tier   `json:"tier,omitempty"`
+	Revision    int     `json:"revision"`
+
+	CustomerPartNumber string `json:"customerPartNumber,omitempty"`
+
+	QuantityRules *QuantityRules    `json:"quantityRules,omitempty"`
+	ExchangeRate  *ExchangeRate     `json:"exchangeRate,omitempty"`
+	Components    []BundleComponent `json:"components,omitempty"`
+}
+
+// BundleComponent is the quantity of a product or variant in one bundle.
+type BundleComponent struct {
+	ProductID string `json:"productId"`
+	VariantID string `json:"variantId,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// ExchangeRate is the rate a price was converted from the product's currency at.
+type ExchangeRate struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Rate   float64   `json:"rate"`
+	AsOf   time.Time `json:"asOf"`
+	Source string    `json:"source,omitempty"`
+}
+
+// QuantityRules are a product's order quantity rules.
+type QuantityRules struct {
+	MinQuantity int `json:"minQuantity,omitempty"`
+	Multiple    int `json:"multiple,omitempty"`
+	MaxQuantity int `json:"maxQuantity,omitempty"`
+}
+
+// Availability is a seller's active warehouses and their stock of some products.
+type Availability struct {
+	Warehouses []Warehouse         `json:"warehouses"`
+	Stock      []StockAvailability `json:"stock"`
+}
+
+// Reservation mirrors the stock reservation returned by catalog-service.
+type Reservation struct {
+	ID        string            `json:"id"`
+	Reference string            `json:"reference"`
+	SellerID  string            `json:"sellerID"`
+	Lines     []ReservationLine `json:"lines"`
+	Status    string            `json:"status"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// InsufficientStockError lists the lines catalog-service could not reserve.
+type InsufficientStockError struct {
+	Lines []ReservationLine `json:"lines"`
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for %d line(s)", len(e.Lines))
+}
+
+// UnavailableProductsError is returned when products cannot be ordered,
+// e.g. because they are not published.
+type UnavailableProductsError struct {
+	ProductIDs []string `json:"productIds"`
+}
+
+func (e *UnavailableProductsError) Error() string {
+	return fmt.Sprintf("%d product(s) not available", len(e.ProductIDs))
+}
+
+// Client calls catalog-service on behalf of the current caller.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a new catalog-service client.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ResolvePrices fetches the caller's price for each line, in order, in
+// currency. An empty currency prices in that of the first line's product.
+func (c *Client) ResolvePrices(authHeader, sellerID, customerID, currency string, lines []PriceLine) ([]Price, error) {
+	body := map[string]interface{}{
+		"sellerId":   sellerID,
+		"customerId": customerID,
+		"currency":   currency,
+		"lines":      lines,
+	}
+	var prices []Price
+	if err := c.post(authHeader, "/pricing/resolve", body, &prices); err != nil {
+		return nil, err
+	}
+	if len(prices) != len(lines) {
+		return nil, fmt.Errorf("catalog-service returned %d prices for %d lines", len(prices), len(lines))
+	}
+	return prices, nil
+}
+
+// GetAvailability fetches where a seller holds stock of the given products.
+func (c *Client) GetAvailability(authHeader, sellerID string, productIDs []string) (*Availability, error) {
+	body := map[string]interface{}{
+		"sellerId":   sellerID,
+		"productIds": productIDs,
+	}
+	var availability Availability
+	if err := c.post(authHeader, "/inventory/availability", body, &availability); err != nil {
+		return nil, err
+	}
+	return &availability, nil
+}
+
+// Reserve holds stock for a quote of accountID. Reserving the same reference
+// twice returns the open reservation. It needs a service token.
+func (c *Client) Reserve(authHeader, reference, sellerID, accountID string, expiresAt time.Time, lines []ReservationLine) (*Reservation, error) {
+	body := map[string]interface{}{
+		"reference": reference,
+		"sellerId":  sellerID,
+		"accountId": accountID,
+		"expiresAt": expiresAt,
+		"lines":     lines,
+	}
+	var res Reservation
+	if err := c.post(authHeader, "/inventory/reservations", body, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Commit turns a reservation into a sale. It needs a service token.
+func (c *Client) Commit(authHeader, reservationID string) error {
+	return c.post(authHeader, "/inventory/reservations/"+url.PathEscape(reservationID)+"/commit", nil, nil)
+}
+
+// PartNumber mirrors a customer's part number mapped to a product or variant.
+type PartNumber struct {
+	PartNumber string `json:"partNumber"`
+	ProductID  string `json:"productId"`
+	VariantID  string `json:"variantId,omitempty"`
+}
+
+// GetPartNumbers looks up the given codes among the part numbers the caller
+// keeps with a seller. Codes that map to nothing are left out.
+func (c *Client) GetPartNumbers(authHeader, sellerID string, codes []string) ([]PartNumber, error) {
+	query := url.Values{"sellerId": {sellerID}, "partNumber": codes}
+	var partNumbers []PartNumber
+	if err := c.get(authHeader, "/part-numbers?"+query.Encode(), &partNumbers); err != nil {
+		return nil, err
+	}
+	return partNumbers, nil
+}
+
+// GetExchangeRate fetches the current rate from one currency to another.
+func (c *Client) GetExchangeRate(authHeader, from, to string) (*ExchangeRate, error) {
+	query := url.Values{"from": {from}, "to": {to}}
+	var rate ExchangeRate
+	if err := c.get(authHeader, "/exchange-rates?"+query.Encode(), &rate); err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// Release returns reserved stock. It needs a service token.
+func (c *Client) Release(authHeader, reservationID string) error {
+	return c.post(authHeader, "/inventory/reservations/"+url.PathEscape(reservationID)+"/release", nil, nil)
+}
+
+func (c *Client) get(authHeader, path string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("catalog-service %s: status %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) post(authHeader, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authHeader)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json"):
+		var short InsufficientStockError
+		if err := json.NewDecoder(resp.Body).Decode(&short); err != nil {
+			return err
+		}
+		return &short
+	case resp.StatusCode == http.StatusConflict:
+		return ErrReservationNotOpen
+	case resp.StatusCode == http.StatusUnprocessableEntity && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json"):
+		var unavailable UnavailableProductsError
+		if err := json.NewDecoder(resp.Body).Decode(&unavailable); err != nil {
+			return err
+		}
+		return &unavailable
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated:
+		return fmt.Errorf("catalog-service %s: status %d", path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
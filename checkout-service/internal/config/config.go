@@ -6,9 +6,18 @@ import "os"
 type Config struct {
 	PaymentServiceUrl string
 	OrderServiceUrl   string
+	AccountServiceUrl string
+	CatalogServiceUrl string
 	JWTSecret         string
 	MongoURI          string
 	MongoDatabase     string
+	// AccountDatabase is account-service's database on the same cluster,
+	// where it records revoked access tokens
+	AccountDatabase string
+	RunMigrations   bool
+	// PunchOutStartURL is the storefront page a PunchOut buyer's browser
+	// opens to start shopping; it redeems the token it is given
+	PunchOutStartURL string
 }
 
 // NewConfig creates a new Config struct and populates it with values from environment variables.
@@ -16,9 +25,14 @@ func NewConfig() *Config {
 	return &Config{
 		PaymentServiceUrl: getEnv("PAYMENT_SERVICE_URL", "http://localhost:3005"),
 		OrderServiceUrl:   getEnv("ORDER_SERVICE_URL", "http://order-service:3003"),
+		AccountServiceUrl: getEnv("ACCOUNT_SERVICE_URL", "http://localhost:3000"),
+		CatalogServiceUrl: getEnv("CATALOG_SERVICE_URL", "http://localhost:3001"),
 		JWTSecret:         getEnv("JWT_SECRET", "your-secret-key"),
 		MongoURI:          getEnv("MONGO_URI", "mongodb://localhost:27017"),
 		MongoDatabase:     getEnv("MONGO_DB_NAME", "CheckoutService"),
+		AccountDatabase:   getEnv("ACCOUNT_DB_NAME", "AccountService"),
+		RunMigrations:     getEnv("RUN_MIGRATIONS", "false") == "true",
+		PunchOutStartURL:  getEnv("PUNCHOUT_START_URL", "http://localhost:5173/punchout"),
 	}
 }
 
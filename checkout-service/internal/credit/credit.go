@@ -0,0 +1,79 @@
+package credit
+
+import (
+	"github.com/syed/businesscart/checkout-service/internal/account"
+	"github.com/syed/businesscart/checkout-service/internal/order"
+)
+
+// OverLimitFlag lets an order through on credit hold instead of rejecting it.
+const OverLimitFlag = "flag"
+
+// Utilization is a customer's credit position with one seller.
+type Utilization struct {
+	SellerID         string  `json:"sellerId"`
+	CustomerID       string  `json:"customerId"`
+	CreditLimit      float64 `json:"creditLimit"`
+	Used             float64 `json:"used"`
+	Available        float64 `json:"available"`
+	PaymentTermsDays int     `json:"paymentTermsDays"`
+	OverLimitAction  string  `json:"overLimitAction"`
+}
+
+// Service combines seller credit terms from account-service with the
+// outstanding on-account orders held by checkout.
+type Service struct {
+	accountClient *account.Client
+	orderService  balanceStore
+}
+
+// balanceStore is what the service needs of the order service. Tests stand
+// in one kept in memory.
+type balanceStore interface {
+	OutstandingBalance(accountID, sellerID string) (float64, error)
+}
+
+// NewService creates a new credit service.
+func NewService(accountClient *account.Client, orderService *order.Service) *Service {
+	return &Service{accountClient: accountClient, orderService: orderService}
+}
+
+// GetUtilization returns how much of its credit limit a customer has used with a seller.
+func (s *Service) GetUtilization(authHeader, customerID, sellerID string) (*Utilization, error) {
+	terms, err := s.accountClient.GetCreditTerms(authHeader, customerID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.orderService.OutstandingBalance(customerID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return &Utilization{
+		SellerID:         sellerID,
+		CustomerID:       customerID,
+		CreditLimit:      terms.CreditLimit,
+		Used:             used,
+		Available:        terms.CreditLimit - used,
+		PaymentTermsDays: terms.PaymentTermsDays,
+		OverLimitAction:  terms.OverLimitAction,
+	}, nil
+}
+
+// Recheck refreshes u from the customer's outstanding balance, which by now
+// includes any order just placed, and reports whether the limit is exceeded.
+// Orders placed concurrently each pass the check made before they were
+// created; rechecking after the insert catches the ones that together go
+// over the limit.
+func (s *Service) Recheck(u *Utilization) (bool, error) {
+	used, err := s.orderService.OutstandingBalance(u.CustomerID, u.SellerID)
+	if err != nil {
+		return false, err
+	}
+	u.Used = used
+	u.Available = u.CreditLimit - used
+	return u.Available < 0, nil
+}
+
+// Exceeds reports whether charging amount would take the customer over the limit.
+func (u *Utilization) Exceeds(amount float64) bool {
+	return amount > u.Available
+}
@@ -0,0 +1,93 @@
+package credit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/syed/businesscart/checkout-service/internal/account"
+)
+
+// fixedBalance is the outstanding balance of every customer, or err.
+type fixedBalance struct {
+	used float64
+	err  error
+}
+
+func (b *fixedBalance) OutstandingBalance(accountID, sellerID string) (float64, error) {
+	return b.used, b.err
+}
+
+func TestGetUtilization(t *testing.T) {
+	accountSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/customer-1/credit" || r.URL.Query().Get("sellerId") != "seller-1" {
+			t.Errorf("unexpected call to account-service: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(account.CreditTerms{SellerID: "seller-1", CustomerID: "customer-1", CreditLimit: 5000, PaymentTermsDays: 30, OverLimitAction: OverLimitFlag})
+	}))
+	defer accountSrv.Close()
+	s := &Service{accountClient: account.NewClient(accountSrv.URL), orderService: &fixedBalance{used: 1250.50}}
+
+	u, err := s.GetUtilization("Bearer token", "customer-1", "seller-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Utilization{SellerID: "seller-1", CustomerID: "customer-1", CreditLimit: 5000, Used: 1250.50, Available: 3749.50, PaymentTermsDays: 30, OverLimitAction: OverLimitFlag}
+	if *u != want {
+		t.Errorf("GetUtilization() = %+v, want %+v", *u, want)
+	}
+}
+
+func TestRecheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		used          float64
+		wantOver      bool
+		wantAvailable float64
+	}{
+		{"well within the limit", 400, false, 600},
+		{"exactly at the limit", 1000, false, 0},
+		{"two orders together over the limit", 1150, true, -150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Both orders saw 300 available before either was created
+			u := &Utilization{SellerID: "seller-1", CustomerID: "customer-1", CreditLimit: 1000, Used: 700, Available: 300}
+			s := &Service{orderService: &fixedBalance{used: tt.used}}
+
+			over, err := s.Recheck(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if over != tt.wantOver || u.Used != tt.used || u.Available != tt.wantAvailable {
+				t.Errorf("Recheck() = %v with used %v and available %v, want %v with used %v and available %v", over, u.Used, u.Available, tt.wantOver, tt.used, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestRecheckError(t *testing.T) {
+	failure := errors.New("connection reset")
+	u := &Utilization{CreditLimit: 1000, Used: 700, Available: 300}
+	s := &Service{orderService: &fixedBalance{err: failure}}
+
+	if _, err := s.Recheck(u); err != failure {
+		t.Errorf("Recheck() error = %v, want %v", err, failure)
+	}
+	if u.Used != 700 || u.Available != 300 {
+		t.Errorf("utilization = %+v, want it left as it was", u)
+	}
+}
+
+func TestExceeds(t *testing.T) {
+	u := &Utilization{CreditLimit: 1000, Used: 700, Available: 300}
+	for amount, want := range map[float64]bool{0: false, 299.99: false, 300: false, 300.01: true, 5000: true} {
+		if got := u.Exceeds(amount); got != want {
+			t.Errorf("Exceeds(%v) = %v, want %v", amount, got, want)
+		}
+	}
+}
@@ -2,19 +2,32 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/syed/businesscart/checkout-service/internal/account"
 	"github.com/syed/businesscart/checkout-service/internal/cart"
+	"github.com/syed/businesscart/checkout-service/internal/catalog"
+	"github.com/syed/businesscart/checkout-service/internal/credit"
+	"github.com/syed/businesscart/checkout-service/internal/list"
 	"github.com/syed/businesscart/checkout-service/internal/order"
 	"github.com/syed/businesscart/checkout-service/internal/payment"
+	"github.com/syed/businesscart/checkout-service/internal/punchout"
 	"github.com/syed/businesscart/checkout-service/internal/quote"
+	"github.com/syed/businesscart/checkout-service/internal/revocation"
+	"github.com/syed/businesscart/checkout-service/internal/sourcing"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// flatShippingCost is charged on every quote, in the seller's currency.
+const flatShippingCost = 10.00
+
 // CheckoutRequest represents the request body for a checkout.
 type CheckoutRequest struct {
 	CompanyID    string          `json:"companyId"`
@@ -26,25 +39,75 @@ type CheckoutRequest struct {
 // CartItemRequest represents the request body for adding/updating a cart item.
 type CartItemRequest struct {
 	Entity cart.CartItem `json:"entity"`
+	// AdjustQuantity moves a quantity that breaks the product's quantity
+	// rules to the nearest allowed one instead of refusing it
+	AdjustQuantity bool `json:"adjustQuantity"`
+	// Currency reprices the whole cart in another ISO 4217 currency. New
+	// carts default to the seller's currency.
+	Currency string `json:"currency"`
+}
+
+// orderStore is what the handler needs of the order service. Tests stand in
+// one kept in memory.
+type orderStore interface {
+	CreateOrder(o *order.Order) (*order.Order, error)
+	GetOrders(userID, role, sellerID string) ([]*order.Order, error)
+	GetOrder(orderID primitive.ObjectID) (*order.Order, error)
+	GetOrderByPurchaseOrder(accountID, sellerID, purchaseOrder string) (*order.Order, error)
+	MarkPaid(orderID primitive.ObjectID, transactionID string) error
+	HoldCredit(orderID primitive.ObjectID) error
+	DeleteOrder(orderID primitive.ObjectID) error
+}
+
+// punchoutStore is what the handler needs of the PunchOut service. Tests
+// stand in one kept in memory.
+type punchoutStore interface {
+	CreateBuyer(b *punchout.Buyer) error
+	GetBuyers(sellerID string) ([]*punchout.Buyer, error)
+	GetBuyer(id primitive.ObjectID) (*punchout.Buyer, error)
+	DeleteBuyer(id primitive.ObjectID) error
+	Authenticate(h *punchout.Header) (*punchout.Buyer, error)
+	CreateSession(session *punchout.Session) error
+	GetSession(id primitive.ObjectID) (*punchout.Session, error)
+	StartSession(token string) (*punchout.Session, error)
+	ReturnSession(id primitive.ObjectID) error
 }
 
 // LambdaHandler handles AWS Lambda requests.
 type LambdaHandler struct {
 	cartService    *cart.Service
 	quoteService   *quote.Service
-	orderService   *order.Service
+	orderService   orderStore
 	paymentService *payment.PaymentService
+	creditService  *credit.Service
+	listService    *list.Service
+	accountClient  *account.Client
+	catalogClient  *catalog.Client
 	jwtSecret      string
+
+	punchoutService  punchoutStore
+	punchOutStartURL string
+
+	revocationService *revocation.Service
 }
 
 // NewLambdaHandler creates a new LambdaHandler.
-func NewLambdaHandler(cartService *cart.Service, quoteService *quote.Service, orderService *order.Service, paymentService *payment.PaymentService, jwtSecret string) *LambdaHandler {
+func NewLambdaHandler(cartService *cart.Service, quoteService *quote.Service, orderService *order.Service, paymentService *payment.PaymentService, creditService *credit.Service, listService *list.Service, punchoutService *punchout.Service, revocationService *revocation.Service, accountClient *account.Client, catalogClient *catalog.Client, jwtSecret string, punchOutStartURL string) *LambdaHandler {
 	return &LambdaHandler{
 		cartService:    cartService,
 		quoteService:   quoteService,
 		orderService:   orderService,
 		paymentService: paymentService,
+		creditService:  creditService,
+		listService:    listService,
+		accountClient:  accountClient,
+		catalogClient:  catalogClient,
 		jwtSecret:      jwtSecret,
+
+		punchoutService:  punchoutService,
+		punchOutStartURL: punchOutStartURL,
+
+		revocationService: revocationService,
 	}
 }
 
@@ -65,6 +128,11 @@ func (h *LambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (ev
 
 	log.Printf("Received event: %+v", request)
 
+	// Procurement systems authenticate with the cXML shared secret instead
+	if isPunchOutDocumentRoute(request) {
+		return h.handlePunchOutDocumentRequest(request)
+	}
+
 	// Validate JWT token
 	authHeader, ok := request.Headers["Authorization"]
 	if !ok {
@@ -90,6 +158,23 @@ func (h *LambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (ev
 		return h.errorResponse(http.StatusUnauthorized, "Unauthorized: User claim is not a map"), nil
 	}
 
+	// Tokens account-service revoked early, by their own ID or that of their
+	// session, are turned away
+	for _, claim := range []string{"jti", "sid"} {
+		id, _ := claims[claim].(string)
+		if id == "" {
+			continue
+		}
+		revoked, err := h.revocationService.IsRevoked(id)
+		if err != nil {
+			log.Printf("Failed to check %s %s: %v", claim, id, err)
+			return h.errorResponse(http.StatusInternalServerError, "Failed to check token"), nil
+		}
+		if revoked {
+			return h.errorResponse(http.StatusUnauthorized, "Unauthorized: Token has been revoked"), nil
+		}
+	}
+
 	accountID, ok := userClaim["id"].(string)
 	if !ok {
 		return h.errorResponse(http.StatusUnauthorized, "Unauthorized: User ID missing"), nil
@@ -112,18 +197,44 @@ func (h *LambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (ev
 
 	log.Printf("Account ID: %s, Role: %s, Associate Company IDs: %v", accountID, role, associateCompanyIDs)
 
+	// Impersonation tokens carry the acting admin in the "act" claim. Such
+	// requests are attributed to the admin and may only look.
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		log.Printf("Impersonated request: admin %v (%v) acting as %s: %s %s", act["sub"], act["email"], accountID, request.HTTPMethod, request.Path)
+		if !impersonationAllowed(request) {
+			return h.errorResponse(http.StatusForbidden, "Forbidden: not permitted while impersonating"), nil
+		}
+	}
+
+	// PunchOut shoppers return their cart to the procurement system, which
+	// sends the order itself.
+	punchoutSessionID, _ := claims["punchout"].(string)
+	if punchoutSessionID != "" && (strings.HasPrefix(request.Path, "/orders") || strings.HasPrefix(request.Path, "/quotes")) && request.HTTPMethod == "POST" {
+		return h.errorResponse(http.StatusForbidden, "Forbidden: orders from a PunchOut session are placed by the procurement system"), nil
+	}
+
 	if strings.HasPrefix(request.Path, "/cart") {
-		return h.handleCartRequest(request, accountID, role, associateCompanyIDs)
+		return h.handleCartRequest(request, accountID, role, associateCompanyIDs, punchoutSessionID)
 	} else if strings.HasPrefix(request.Path, "/quotes") {
 		return h.handleQuoteRequest(request, accountID)
 	} else if strings.HasPrefix(request.Path, "/orders") {
 		return h.handleOrderRequest(request, accountID, role)
+	} else if strings.HasPrefix(request.Path, "/credit") {
+		return h.handleCreditRequest(request, accountID, role, associateCompanyIDs)
+	} else if strings.HasPrefix(request.Path, "/lists") {
+		return h.handleListRequest(request, accountID, role, associateCompanyIDs, punchoutSessionID)
+	} else if strings.HasPrefix(request.Path, "/punchout") {
+		return h.handlePunchOutRequest(request, accountID, role, punchoutSessionID)
 	}
 
 	return h.errorResponse(http.StatusNotFound, "Route not found"), nil
 }
 
 func (h *LambdaHandler) handleOrderRequest(request events.APIGatewayProxyRequest, accountID string, role string) (events.APIGatewayProxyResponse, error) {
+	parts := strings.Split(strings.Trim(request.Path, "/"), "/")
+	if request.HTTPMethod == "POST" && len(parts) == 3 && parts[2] == "payments" {
+		return h.handleRecordPaymentRequest(request, accountID, role, parts[1])
+	}
 	if request.HTTPMethod == "POST" {
 		return h.handlePlaceOrderRequest(request, accountID)
 	}
@@ -194,37 +305,246 @@ func (h *LambdaHandler) handlePlaceOrderRequest(request events.APIGatewayProxyRe
 	if err != nil {
 		return h.errorResponse(http.StatusNotFound, "Quote not found"), nil
 	}
+	if quote.AccountID != accountID {
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+	if time.Now().After(quote.ExpiresAt) {
+		h.releaseReservation(quote.ReservationID)
+		_ = h.quoteService.DeleteQuote(req.QuoteID)
+		return h.errorResponse(http.StatusGone, "Quote has expired"), nil
+	}
 
-	// Process payment
-	transactionID, ok := h.paymentService.ProcessPayment(quote.GrandTotal, req.PaymentMethod, req.PaymentToken)
+	createdOrder, resp, ok := h.placeOrder(request.Headers["Authorization"], quote, req.PaymentMethod, req.PaymentToken, "")
 	if !ok {
-		return h.errorResponse(http.StatusPaymentRequired, "Payment failed"), nil
+		return resp, nil
+	}
+
+	// Clean up cart and quote
+	_ = h.cartService.ClearCart(accountID, quote.SellerID, "")
+	_ = h.quoteService.DeleteQuote(req.QuoteID)
+
+	respBody, _ := json.Marshal(createdOrder)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
+			"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		},
+		Body: string(respBody),
+	}, nil
+}
+
+// placeOrder charges a quote to the chosen payment method, or to the
+// customer's credit for on-account orders, creates the order and commits the
+// quote's reservation. It returns false with the response to send when the
+// order cannot be placed.
+func (h *LambdaHandler) placeOrder(authHeader string, q *quote.Quote, paymentMethod, paymentToken, purchaseOrder string) (*order.Order, events.APIGatewayProxyResponse, bool) {
+	if len(q.AllowedPaymentMethods) > 0 && !containsString(q.AllowedPaymentMethods, paymentMethod) {
+		return nil, h.errorResponse(http.StatusBadRequest, "Payment method not allowed for this customer"), false
+	}
+
+	var transactionID, paymentStatus string
+	var dueAt *time.Time
+	var creditHold bool
+	var utilization *credit.Utilization
+	if paymentMethod == order.PaymentMethodOnAccount {
+		// Charge the order against the customer's credit with the seller
+		var err error
+		utilization, err = h.creditService.GetUtilization(authHeader, q.AccountID, q.SellerID)
+		if err != nil {
+			log.Printf("Failed to get credit utilization: %v", err)
+			return nil, h.errorResponse(http.StatusBadGateway, "Failed to check credit"), false
+		}
+		if utilization.Exceeds(creditAmount(q)) {
+			if utilization.OverLimitAction != credit.OverLimitFlag {
+				return nil, h.errorResponse(http.StatusPaymentRequired, "Credit limit exceeded"), false
+			}
+			creditHold = true
+		}
+		due := time.Now().AddDate(0, 0, utilization.PaymentTermsDays)
+		dueAt = &due
+		paymentStatus = order.PaymentStatusUnpaid
+	} else {
+		// Process payment
+		var ok bool
+		transactionID, ok = h.paymentService.ProcessPayment(q.GrandTotal, paymentMethod, paymentToken)
+		if !ok {
+			return nil, h.errorResponse(http.StatusPaymentRequired, "Payment failed"), false
+		}
+		paymentStatus = order.PaymentStatusPaid
 	}
 
 	newOrder := &order.Order{
-		ID:            primitive.NewObjectID(),
-		QuoteID:       quote.ID,
-		AccountID:     accountID,
-		SellerID:      quote.SellerID,
-		Items:         quote.Items,
-		Subtotal:      quote.Subtotal,
-		ShippingCost:  quote.ShippingCost,
-		TaxAmount:     quote.TaxAmount,
-		GrandTotal:    quote.GrandTotal,
-		PaymentMethod: req.PaymentMethod,
-		TransactionID: transactionID,
+		ID:             primitive.NewObjectID(),
+		QuoteID:        q.ID,
+		AccountID:      q.AccountID,
+		SellerID:       q.SellerID,
+		Items:          q.Items,
+		Subtotal:       q.Subtotal,
+		DiscountAmount: q.DiscountAmount,
+		ShippingCost:   q.ShippingCost,
+		TaxAmount:      q.TaxAmount,
+		GrandTotal:     q.GrandTotal,
+		Currency:       q.Currency,
+		SellerTotal:    creditAmount(q),
+		SellerCurrency: q.SellerCurrency,
+		ExchangeRates:  q.ExchangeRates,
+		Fulfilment:     cart.Fulfilment(q.Items),
+		ShippingMethod: q.ShippingMethod,
+		ShipTo:         q.ShipTo,
+		SalesRep:       q.SalesRep,
+		PaymentMethod:  paymentMethod,
+		TransactionID:  transactionID,
+		PaymentStatus:  paymentStatus,
+		DueAt:          dueAt,
+		CreditHold:     creditHold,
+		PurchaseOrder:  purchaseOrder,
 	}
 
 	createdOrder, err := h.orderService.CreateOrder(newOrder)
+	if errors.Is(err, order.ErrPurchaseOrderExists) {
+		return nil, h.errorResponse(http.StatusConflict, "An order was already placed for this purchase order"), false
+	}
 	if err != nil {
-		return h.errorResponse(http.StatusInternalServerError, "Failed to create order"), nil
+		return nil, h.errorResponse(http.StatusInternalServerError, "Failed to create order"), false
 	}
 
-	// Clean up cart and quote
-	_ = h.cartService.ClearCart(accountID, quote.SellerID)
-	_ = h.quoteService.DeleteQuote(req.QuoteID)
+	// Another order may have used the same credit since the check above.
+	// Once this order counts towards the balance, check again and take it
+	// back if the two together are over the limit.
+	if utilization != nil {
+		over, err := h.creditService.Recheck(utilization)
+		if err != nil || (over && utilization.OverLimitAction != credit.OverLimitFlag) {
+			if delErr := h.orderService.DeleteOrder(createdOrder.ID); delErr != nil {
+				log.Printf("Failed to roll back order %s: %v", createdOrder.ID.Hex(), delErr)
+			}
+			if err != nil {
+				log.Printf("Failed to recheck credit utilization: %v", err)
+				return nil, h.errorResponse(http.StatusInternalServerError, "Failed to check credit"), false
+			}
+			return nil, h.errorResponse(http.StatusPaymentRequired, "Credit limit exceeded"), false
+		}
+		if over && !createdOrder.CreditHold {
+			if err := h.orderService.HoldCredit(createdOrder.ID); err != nil {
+				log.Printf("Failed to put order %s on credit hold: %v", createdOrder.ID.Hex(), err)
+			}
+			createdOrder.CreditHold = true
+		}
+	}
 
-	respBody, _ := json.Marshal(createdOrder)
+	// The reserved stock is now sold. The order stands even if this fails,
+	// so the failure is only logged for the seller to reconcile.
+	if q.ReservationID != "" {
+		if err := h.catalogClient.Commit(h.serviceAuthHeader(), q.ReservationID); err != nil {
+			log.Printf("Failed to commit reservation %s for order %s: %v", q.ReservationID, createdOrder.ID.Hex(), err)
+		}
+	}
+
+	return createdOrder, events.APIGatewayProxyResponse{}, true
+}
+
+// creditAmount is what an order placed from the quote holds against the
+// customer's credit: its total in the seller's currency. Quotes from before
+// SellerTotal was kept hold their GrandTotal.
+func creditAmount(q *quote.Quote) float64 {
+	if q.SellerCurrency == "" {
+		return q.GrandTotal
+	}
+	return q.SellerTotal
+}
+
+// handleRecordPaymentRequest lets the seller record payment of an on-account
+// order, which releases the credit it was holding.
+func (h *LambdaHandler) handleRecordPaymentRequest(request events.APIGatewayProxyRequest, accountID string, role string, orderIdStr string) (events.APIGatewayProxyResponse, error) {
+	orderID, err := primitive.ObjectIDFromHex(orderIdStr)
+	if err != nil {
+		return h.errorResponse(http.StatusBadRequest, "Invalid order ID"), nil
+	}
+
+	var req struct {
+		TransactionID string `json:"transactionId"`
+	}
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return h.errorResponse(http.StatusBadRequest, "Invalid request body"), nil
+		}
+	}
+
+	existing, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		return h.errorResponse(http.StatusNotFound, "Order not found"), nil
+	}
+	if role != "admin" && !(role == "company" && existing.SellerID == accountID) {
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+	if existing.PaymentStatus != order.PaymentStatusUnpaid {
+		return h.errorResponse(http.StatusConflict, "Order is not awaiting payment"), nil
+	}
+
+	if err := h.orderService.MarkPaid(orderID, req.TransactionID); err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to record payment"), nil
+	}
+
+	updated, err := h.orderService.GetOrder(orderID)
+	if err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to get order"), nil
+	}
+	respBody, _ := json.Marshal(updated)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
+			"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		},
+		Body: string(respBody),
+	}, nil
+}
+
+// handleCreditRequest returns credit utilization per seller. Customers see
+// every seller they are linked to (or ?sellerId=), companies pass ?customerId=,
+// admins pass both.
+func (h *LambdaHandler) handleCreditRequest(request events.APIGatewayProxyRequest, accountID string, role string, associateCompanyIDs []string) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod != "GET" {
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed"), nil
+	}
+
+	customerID := request.QueryStringParameters["customerId"]
+	sellerID := request.QueryStringParameters["sellerId"]
+	var sellerIDs []string
+	switch role {
+	case "customer":
+		customerID = accountID
+		if sellerID != "" {
+			sellerIDs = []string{sellerID}
+		} else {
+			sellerIDs = associateCompanyIDs
+		}
+	case "company":
+		sellerIDs = []string{accountID}
+	case "admin":
+		sellerIDs = []string{sellerID}
+	default:
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+	if customerID == "" || len(sellerIDs) == 0 || sellerIDs[0] == "" {
+		return h.errorResponse(http.StatusBadRequest, "Customer ID and Seller ID are required"), nil
+	}
+
+	utilizations := []*credit.Utilization{}
+	for _, id := range sellerIDs {
+		u, err := h.creditService.GetUtilization(request.Headers["Authorization"], customerID, id)
+		if err != nil {
+			log.Printf("Failed to get credit utilization for seller %s: %v", id, err)
+			return h.errorResponse(http.StatusBadGateway, "Failed to get credit utilization"), nil
+		}
+		utilizations = append(utilizations, u)
+	}
+
+	respBody, _ := json.Marshal(utilizations)
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
 		Headers: map[string]string{
@@ -262,38 +582,46 @@ func (h *LambdaHandler) handleGetOrdersRequest(request events.APIGatewayProxyReq
 
 func (h *LambdaHandler) handleCreateQuoteRequest(request events.APIGatewayProxyRequest, accountID string) (events.APIGatewayProxyResponse, error) {
 	var req struct {
-		CartID   string `json:"cartId"`
-		SellerID string `json:"sellerId"`
+		CartID   string           `json:"cartId"`
+		SellerID string           `json:"sellerId"`
+		ShipTo   *account.Address `json:"shipTo"` // defaults to the customer's address
 	}
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
 		return h.errorResponse(http.StatusBadRequest, "Invalid request body"), nil
 	}
 
-	cart, err := h.cartService.GetCart(accountID, req.SellerID)
+	currentCart, err := h.cartService.GetCart(accountID, req.SellerID, "")
 	if err != nil {
 		return h.errorResponse(http.StatusNotFound, "Cart not found"), nil
 	}
 
-	if len(cart.Items) == 0 {
+	if len(currentCart.Items) == 0 {
 		return h.errorResponse(http.StatusBadRequest, "Cart is empty"), nil
 	}
 
-	// Simple tax and shipping calculation (placeholders)
-	taxAmount := cart.TotalPrice * 0.0825 // 8.25% tax
-	shippingCost := 10.00                 // Flat rate shipping
+	authHeader := request.Headers["Authorization"]
+	newQuote, resp, ok := h.priceQuote(authHeader, currentCart)
+	if !ok {
+		return resp, nil
+	}
 
-	newQuote := &quote.Quote{
-		CartID:       cart.ID,
-		AccountID:    accountID,
-		SellerID:     req.SellerID,
-		Items:        cart.Items,
-		Subtotal:     cart.TotalPrice,
-		ShippingCost: shippingCost,
-		TaxAmount:    taxAmount,
-		GrandTotal:   cart.TotalPrice + shippingCost + taxAmount,
+	// A new quote replaces earlier ones for the same cart, so their stock is
+	// released before sourcing again.
+	previous, err := h.quoteService.GetQuotesForCart(accountID, req.SellerID)
+	if err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to get quotes"), nil
+	}
+	for _, q := range previous {
+		h.releaseReservation(q.ReservationID)
+		_ = h.quoteService.DeleteQuote(q.ID.Hex())
+	}
+
+	if resp, ok := h.reserveQuote(authHeader, newQuote, req.ShipTo); !ok {
+		return resp, nil
 	}
 
 	if err := h.quoteService.CreateQuote(newQuote); err != nil {
+		h.releaseReservation(newQuote.ReservationID)
 		return h.errorResponse(http.StatusInternalServerError, "Failed to create quote"), nil
 	}
 
@@ -310,42 +638,198 @@ func (h *LambdaHandler) handleCreateQuoteRequest(request events.APIGatewayProxyR
 	}, nil
 }
 
-func (h *LambdaHandler) handleCartRequest(request events.APIGatewayProxyRequest, accountID string, role string, associateCompanyIDs []string) (events.APIGatewayProxyResponse, error) {
+// priceQuote prices a cart under the trading terms the seller negotiated with
+// its customer and returns the quote for it, not yet sourced. It returns
+// false with the response to send when the cart cannot be quoted.
+func (h *LambdaHandler) priceQuote(authHeader string, c *cart.Cart) (*quote.Quote, events.APIGatewayProxyResponse, bool) {
+	// Terms the seller negotiated with this customer
+	terms, err := h.accountClient.GetTradingTerms(authHeader, c.AccountID, c.SellerID)
+	if err != nil {
+		log.Printf("Failed to get trading terms: %v", err)
+		return nil, h.errorResponse(http.StatusBadGateway, "Failed to get trading terms"), false
+	}
+	if terms.Status != account.RelationshipActive {
+		return nil, h.errorResponse(http.StatusForbidden, "Trading relationship is not active"), false
+	}
+
+	// Charge the customer's price as of now, which may differ from when the
+	// items were added to the cart.
+	if err := h.priceItems(authHeader, c.AccountID, c); err != nil {
+		return nil, h.pricingErrorResponse(err), false
+	}
+	// Rules may have changed since the items were added
+	if violations := cart.CheckQuantities(c.Items); len(violations) > 0 {
+		return nil, h.quantityRulesResponse(violations), false
+	}
+	// Every amount is rounded to the minor unit of its currency, e.g. whole
+	// yen, and the totals are sums of rounded amounts
+	subtotal := cart.Round(cart.Subtotal(c.Items), c.Currency)
+	discountAmount := cart.Round(subtotal*terms.DiscountPercent/100, c.Currency)
+
+	// Simple tax and shipping calculation (placeholders)
+	taxAmount := cart.Round((subtotal-discountAmount)*0.0825, c.Currency) // 8.25% tax
+	if terms.TaxExempt {
+		taxAmount = 0
+	}
+	// Shipping is a flat rate in the seller's currency, and credit limits
+	// are kept in it too
+	sellerCurrency := terms.Currency
+	if sellerCurrency == "" {
+		sellerCurrency = c.Currency
+	}
+	exchangeRates := cart.ExchangeRates(c.Items)
+	sellerRate := 1.0 // quote currency per unit of the seller's
+	if sellerCurrency != c.Currency {
+		rate, fetched, err := h.sellerExchangeRate(authHeader, exchangeRates, sellerCurrency, c.Currency)
+		if err != nil {
+			log.Printf("Failed to get exchange rate from %s to %s: %v", sellerCurrency, c.Currency, err)
+			return nil, h.errorResponse(http.StatusBadGateway, "Failed to get exchange rate"), false
+		}
+		if fetched {
+			exchangeRates = append(exchangeRates, rate)
+		}
+		sellerRate = rate.Rate
+	}
+	shippingCost := cart.Round(flatShippingCost*sellerRate, c.Currency)
+	grandTotal := cart.Round(subtotal-discountAmount+shippingCost+taxAmount, c.Currency)
+
+	return &quote.Quote{
+		ID:                    primitive.NewObjectID(),
+		CartID:                c.ID,
+		AccountID:             c.AccountID,
+		SellerID:              c.SellerID,
+		Items:                 c.Items,
+		Subtotal:              subtotal,
+		ShippingCost:          shippingCost,
+		TaxAmount:             taxAmount,
+		GrandTotal:            grandTotal,
+		Currency:              c.Currency,
+		SellerTotal:           cart.Round(grandTotal/sellerRate, sellerCurrency),
+		SellerCurrency:        sellerCurrency,
+		ExchangeRates:         exchangeRates,
+		DiscountTier:          terms.DiscountTier,
+		DiscountAmount:        discountAmount,
+		TaxExempt:             terms.TaxExempt,
+		ShippingMethod:        terms.DefaultShippingMethod,
+		AllowedPaymentMethods: terms.AllowedPaymentMethods,
+		NetTermsDays:          terms.NetTermsDays,
+		SalesRep:              terms.SalesRep,
+		ExpiresAt:             time.Now().Add(quote.TTL),
+	}, events.APIGatewayProxyResponse{}, true
+}
+
+// sellerExchangeRate returns the rate from the seller's currency to the
+// quote's. A line already converted from the seller's currency lends its
+// rate, so the quote uses one rate per currency; otherwise the current rate
+// is fetched from catalog-service and fetched is true.
+func (h *LambdaHandler) sellerExchangeRate(authHeader string, rates []cart.ExchangeRate, from, to string) (rate cart.ExchangeRate, fetched bool, err error) {
+	for _, r := range rates {
+		if r.From == from && r.To == to {
+			return r, false, nil
+		}
+	}
+	r, err := h.catalogClient.GetExchangeRate(authHeader, from, to)
+	if err != nil {
+		return cart.ExchangeRate{}, false, err
+	}
+	if r.Rate <= 0 {
+		return cart.ExchangeRate{}, false, fmt.Errorf("invalid rate %v", r.Rate)
+	}
+	return cart.ExchangeRate{From: r.From, To: r.To, Rate: r.Rate, AsOf: r.AsOf, Source: r.Source}, true, nil
+}
+
+// reserveQuote sources the quote's lines from the seller's warehouses nearest
+// to shipTo, or to the customer's address, and reserves their stock for as
+// long as the quote is valid. It returns false with the response to send
+// when the stock cannot be held.
+func (h *LambdaHandler) reserveQuote(authHeader string, q *quote.Quote, shipTo *account.Address) (events.APIGatewayProxyResponse, bool) {
+	// Pick the warehouses that ship each line
+	if shipTo == nil {
+		var err error
+		if shipTo, err = h.accountClient.GetAddress(authHeader, q.AccountID); err != nil {
+			log.Printf("Failed to get ship-to address, sourcing without it: %v", err)
+		}
+	}
+	items, shortItems, err := h.sourceItems(authHeader, q.SellerID, q.Items, shipTo)
+	if err != nil {
+		log.Printf("Failed to source order: %v", err)
+		return h.errorResponse(http.StatusBadGateway, "Failed to check stock"), false
+	}
+	if len(shortItems) > 0 {
+		return h.insufficientStockResponse(shortItems), false
+	}
+	q.Items = items
+	q.ShipTo = shipTo
+
+	// Hold the stock for as long as the quote is valid, bundles through
+	// their components. Stock can still run out between sourcing and
+	// reserving, which is reported the same way.
+	var lines []catalog.ReservationLine
+	for _, l := range cart.Fulfilment(items) {
+		lines = append(lines, catalog.ReservationLine{ProductID: l.ProductID, VariantID: l.VariantID, WarehouseID: l.WarehouseID, Quantity: l.Quantity})
+	}
+	reservation, err := h.catalogClient.Reserve(h.serviceAuthHeader(), q.ID.Hex(), q.SellerID, q.AccountID, q.ExpiresAt, lines)
+	var short *catalog.InsufficientStockError
+	if errors.As(err, &short) {
+		return h.insufficientStockResponse(short.Lines), false
+	}
+	if err != nil {
+		log.Printf("Failed to reserve stock: %v", err)
+		return h.errorResponse(http.StatusBadGateway, "Failed to reserve stock"), false
+	}
+	q.ReservationID = reservation.ID
+	return events.APIGatewayProxyResponse{}, true
+}
+
+// handleCartRequest serves the cart routes. A PunchOut shopper (sessionID
+// set) works on the cart of their session rather than their own.
+// impersonationReadRoutes are the routes an admin acting as another account
+// may read. Nothing that changes a cart, list, quote, order, credit or
+// PunchOut buyer is on it.
+var impersonationReadRoutes = []string{"/cart", "/quotes", "/orders", "/credit", "/lists", "/punchout/buyers"}
+
+// impersonationAllowed reports whether an impersonation token may make a
+// request: only GETs of impersonationReadRoutes.
+func impersonationAllowed(request events.APIGatewayProxyRequest) bool {
+	if request.HTTPMethod != "GET" {
+		return false
+	}
+	path := strings.TrimSuffix(request.Path, "/")
+	for _, route := range impersonationReadRoutes {
+		if path == route || strings.HasPrefix(path, route+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *LambdaHandler) handleCartRequest(request events.APIGatewayProxyRequest, accountID string, role string, associateCompanyIDs []string, sessionID string) (events.APIGatewayProxyResponse, error) {
 	headers := map[string]string{
 		"Content-Type":                 "application/json",
 		"Access-Control-Allow-Origin":  "*",
 		"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
 		"Access-Control-Allow-Headers": "Content-Type, Authorization",
 	}
+	if request.Path == "/cart/quick-order" && request.HTTPMethod == "POST" {
+		return h.handleQuickOrderRequest(request, accountID, associateCompanyIDs, sessionID)
+	}
 	switch request.HTTPMethod {
 	case "POST": // Add item to cart
 		var req CartItemRequest
 		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
 			return h.errorResponse(http.StatusBadRequest, "Invalid request body"), nil
 		}
-		currentCart, err := h.cartService.GetCart(accountID, req.Entity.SellerID)
-		if err != nil && err.Error() != "cart not found" {
-			return h.errorResponse(http.StatusInternalServerError, "Failed to get cart"), nil
-		}
-		if currentCart == nil {
-			currentCart = &cart.Cart{
-				AccountID: accountID,
-				SellerID:  req.Entity.SellerID,
-				Items:     []cart.CartItem{},
-			}
+		currentCart, resp, ok := h.openCart(request.Headers["Authorization"], accountID, req.Entity.SellerID, sessionID, req.Currency)
+		if !ok {
+			return resp, nil
 		}
+		line := addItem(currentCart, req.Entity)
 
-		found := false
-		for i, item := range currentCart.Items {
-			if item.ProductID == req.Entity.ProductID && item.SellerID == req.Entity.SellerID {
-				currentCart.Items[i].Quantity += req.Entity.Quantity
-				found = true
-				break
-			}
+		if err := h.priceItems(request.Headers["Authorization"], accountID, currentCart); err != nil {
+			return h.pricingErrorResponse(err), nil
 		}
-		if !found {
-			req.Entity.ID = primitive.NewObjectID() // Assign a new ObjectID for the new item
-			currentCart.Items = append(currentCart.Items, req.Entity)
+		if resp, ok := h.applyQuantityRules(request.Headers["Authorization"], accountID, currentCart, line, req.AdjustQuantity); !ok {
+			return resp, nil
 		}
 
 		if err := h.cartService.SaveCart(currentCart); err != nil {
@@ -378,11 +862,11 @@ func (h *LambdaHandler) handleCartRequest(request events.APIGatewayProxyRequest,
 			}
 		}
 
-		fetchedCart, err := h.cartService.GetCart(accountID, sellerID)
+		fetchedCart, err := h.cartService.GetCart(accountID, sellerID, sessionID)
 		if err != nil {
 			if err.Error() == "cart not found" {
 				// Return an empty cart if not found, as per previous cart-service behavior
-				emptyCart := cart.Cart{AccountID: accountID, SellerID: sellerID, Items: []cart.CartItem{}, TotalPrice: 0}
+				emptyCart := cart.Cart{AccountID: accountID, SellerID: sellerID, SessionID: sessionID, Items: []cart.CartItem{}, TotalPrice: 0}
 				respBody, _ := json.Marshal(emptyCart)
 				return events.APIGatewayProxyResponse{
 					StatusCode: http.StatusOK,
@@ -415,23 +899,30 @@ func (h *LambdaHandler) handleCartRequest(request events.APIGatewayProxyRequest,
 			return h.errorResponse(http.StatusBadRequest, "Invalid item ID format"), nil
 		}
 
-		currentCart, err := h.cartService.GetCart(accountID, sellerID)
+		currentCart, err := h.cartService.GetCart(accountID, sellerID, sessionID)
 		if err != nil {
 			return h.errorResponse(http.StatusNotFound, "Cart not found"), nil
 		}
 
-		found := false
+		line := -1
 		for i, item := range currentCart.Items {
 			if item.ID == objID {
 				currentCart.Items[i].Quantity = req.Entity.Quantity
-				found = true
+				line = i
 				break
 			}
 		}
-		if !found {
+		if line < 0 {
 			return h.errorResponse(http.StatusNotFound, "Item not found in cart"), nil
 		}
 
+		if err := h.priceItems(request.Headers["Authorization"], accountID, currentCart); err != nil {
+			return h.pricingErrorResponse(err), nil
+		}
+		if resp, ok := h.applyQuantityRules(request.Headers["Authorization"], accountID, currentCart, line, req.AdjustQuantity); !ok {
+			return resp, nil
+		}
+
 		if err := h.cartService.SaveCart(currentCart); err != nil {
 			return h.errorResponse(http.StatusInternalServerError, "Failed to update cart item"), nil
 		}
@@ -456,7 +947,7 @@ func (h *LambdaHandler) handleCartRequest(request events.APIGatewayProxyRequest,
 				return h.errorResponse(http.StatusBadRequest, "Invalid item ID format"), nil
 			}
 
-			currentCart, err := h.cartService.GetCart(accountID, sellerID)
+			currentCart, err := h.cartService.GetCart(accountID, sellerID, sessionID)
 			if err != nil {
 				return h.errorResponse(http.StatusNotFound, "Cart not found"), nil
 			}
@@ -490,10 +981,10 @@ func (h *LambdaHandler) handleCartRequest(request events.APIGatewayProxyRequest,
 			if sellerID == "" {
 				return h.errorResponse(http.StatusBadRequest, "Seller ID is required"), nil
 			}
-			if err := h.cartService.ClearCart(accountID, sellerID); err != nil {
+			if err := h.cartService.ClearCart(accountID, sellerID, sessionID); err != nil {
 				return h.errorResponse(http.StatusInternalServerError, "Failed to clear cart"), nil
 			}
-			emptyCart := cart.Cart{AccountID: accountID, SellerID: sellerID, Items: []cart.CartItem{}, TotalPrice: 0}
+			emptyCart := cart.Cart{AccountID: accountID, SellerID: sellerID, SessionID: sessionID, Items: []cart.CartItem{}, TotalPrice: 0}
 			respBody, _ := json.Marshal(emptyCart)
 			return events.APIGatewayProxyResponse{
 				StatusCode: http.StatusOK,
@@ -508,6 +999,99 @@ func (h *LambdaHandler) handleCartRequest(request events.APIGatewayProxyRequest,
 	}
 }
 
+// openCart returns the account's cart with a seller, or the cart of its
+// PunchOut session with sessionID, or a new one. New carts
+// are priced in the seller's currency unless currency asks for another,
+// which also reprices an existing cart. It returns false with the response
+// to send when the cart cannot be opened.
+func (h *LambdaHandler) openCart(authHeader, accountID, sellerID, sessionID, currency string) (*cart.Cart, events.APIGatewayProxyResponse, bool) {
+	currentCart, err := h.cartService.GetCart(accountID, sellerID, sessionID)
+	if err != nil && err.Error() != "cart not found" {
+		return nil, h.errorResponse(http.StatusInternalServerError, "Failed to get cart"), false
+	}
+	if currentCart == nil {
+		currentCart = &cart.Cart{
+			AccountID: accountID,
+			SellerID:  sellerID,
+			SessionID: sessionID,
+			Items:     []cart.CartItem{},
+		}
+		// Without the seller's currency the first product's applies
+		if currency == "" {
+			terms, err := h.accountClient.GetTradingTerms(authHeader, accountID, sellerID)
+			if err != nil {
+				log.Printf("Failed to get seller currency, pricing in the product's: %v", err)
+			} else {
+				currentCart.Currency = terms.Currency
+			}
+		}
+	}
+	if currency != "" {
+		currency = strings.ToUpper(currency)
+		if !validCurrency(currency) {
+			return nil, h.errorResponse(http.StatusBadRequest, "currency must be a three-letter ISO 4217 code"), false
+		}
+		currentCart.Currency = currency
+	}
+	return currentCart, events.APIGatewayProxyResponse{}, true
+}
+
+// addItem adds item to the cart, merging it into the line for the same
+// product and variant if there is one. It returns the index of the line.
+func addItem(c *cart.Cart, item cart.CartItem) int {
+	for i, existing := range c.Items {
+		if existing.ProductID == item.ProductID && existing.VariantID == item.VariantID && existing.SellerID == item.SellerID {
+			c.Items[i].Quantity += item.Quantity
+			return i
+		}
+	}
+	item.ID = primitive.NewObjectID()
+	c.Items = append(c.Items, item)
+	return len(c.Items) - 1
+}
+
+// addItemsToCart adds several items to the account's cart with a seller, or
+// to the cart of its PunchOut session, at once. They are priced and checked against quantity rules as if added one
+// by one, and nothing is saved unless all of them can be kept.
+func (h *LambdaHandler) addItemsToCart(authHeader, accountID, sellerID, sessionID, currency string, items []cart.CartItem, adjust bool) events.APIGatewayProxyResponse {
+	currentCart, resp, ok := h.openCart(authHeader, accountID, sellerID, sessionID, currency)
+	if !ok {
+		return resp
+	}
+	var lines []int
+	for _, item := range items {
+		lines = append(lines, addItem(currentCart, item))
+	}
+
+	if err := h.priceItems(authHeader, accountID, currentCart); err != nil {
+		return h.pricingErrorResponse(err)
+	}
+	for _, line := range lines {
+		if resp, ok := h.applyQuantityRules(authHeader, accountID, currentCart, line, adjust); !ok {
+			return resp
+		}
+	}
+
+	if err := h.cartService.SaveCart(currentCart); err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to save cart")
+	}
+	return h.jsonResponse(http.StatusOK, currentCart)
+}
+
+func (h *LambdaHandler) jsonResponse(statusCode int, body interface{}) events.APIGatewayProxyResponse {
+	respBody, _ := json.Marshal(body)
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
+			"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		},
+		Body: string(respBody),
+	}
+}
+
 func (h *LambdaHandler) errorResponse(statusCode int, message string) events.APIGatewayProxyResponse {
 	respBody, _ := json.Marshal(map[string]string{"message": message})
 	return events.APIGatewayProxyResponse{
@@ -521,3 +1105,236 @@ func (h *LambdaHandler) errorResponse(statusCode int, message string) events.API
 		Body: string(respBody),
 	}
 }
+
+// priceItems sets each cart item's unit price to what the customer pays the
+// seller at the item's quantity, as resolved by catalog-service from the
+// seller's price lists and quantity breaks, in the cart's currency. Calling
+// it after every quantity change moves lines between tiers. A cart without a
+// currency takes that of its first product.
+func (h *LambdaHandler) priceItems(authHeader, customerID string, c *cart.Cart) error {
+	items := c.Items
+	if len(items) == 0 {
+		return nil
+	}
+	lines := make([]catalog.PriceLine, len(items))
+	for i, item := range items {
+		lines[i] = catalog.PriceLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+	}
+	prices, err := h.catalogClient.ResolvePrices(authHeader, c.SellerID, customerID, c.Currency, lines)
+	if err != nil {
+		return err
+	}
+	if len(prices) != len(items) {
+		return fmt.Errorf("catalog priced %d of %d items", len(prices), len(items))
+	}
+	c.Currency = prices[0].Currency
+	for i, p := range prices {
+		items[i].Price = p.Price
+		items[i].ListPrice = p.ListPrice
+		items[i].PriceListID = p.PriceListID
+		items[i].ProductRevision = p.Revision
+		items[i].CustomerPartNumber = p.CustomerPartNumber
+		items[i].QuantityRules = nil
+		if r := p.QuantityRules; r != nil {
+			items[i].QuantityRules = &cart.QuantityRules{MinQuantity: r.MinQuantity, Multiple: r.Multiple, MaxQuantity: r.MaxQuantity}
+		}
+		items[i].PriceTier = nil
+		if p.Tier != nil {
+			items[i].PriceTier = &cart.PriceTier{MinQuantity: p.Tier.MinQuantity, MaxQuantity: p.Tier.MaxQuantity, Price: p.Tier.Price}
+		}
+		items[i].Components = nil
+		for _, comp := range p.Components {
+			items[i].Components = append(items[i].Components, cart.BundleComponent{ProductID: comp.ProductID, VariantID: comp.VariantID, Quantity: comp.Quantity})
+		}
+		items[i].ExchangeRate = nil
+		if r := p.ExchangeRate; r != nil {
+			items[i].ExchangeRate = &cart.ExchangeRate{From: r.From, To: r.To, Rate: r.Rate, AsOf: r.AsOf, Source: r.Source}
+		}
+	}
+	return nil
+}
+
+// sourceItems splits cart items across the seller's warehouses, nearest to
+// shipTo first. Items that cannot be filled are returned as short.
+func (h *LambdaHandler) sourceItems(authHeader, sellerID string, items []cart.CartItem, shipTo *account.Address) ([]cart.CartItem, []cart.CartItem, error) {
+	var productIDs []string
+	lines := make([]sourcing.Line, len(items))
+	for i, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+		lines[i] = sourcing.Line{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+	}
+
+	availability, err := h.catalogClient.GetAvailability(authHeader, sellerID, productIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	var warehouses []sourcing.Warehouse
+	for _, wh := range availability.Warehouses {
+		var coords *sourcing.Coords
+		if c := wh.Address.Coords; c.Lat != 0 || c.Lng != 0 {
+			coords = &sourcing.Coords{Lat: c.Lat, Lng: c.Lng}
+		}
+		warehouses = append(warehouses, sourcing.Warehouse{ID: wh.ID, Coords: coords})
+	}
+	var stock []sourcing.Stock
+	for _, s := range availability.Stock {
+		stock = append(stock, sourcing.Stock{ProductID: s.ProductID, VariantID: s.VariantID, WarehouseID: s.WarehouseID, Available: s.Available})
+	}
+	var destination *sourcing.Coords
+	if shipTo != nil && (shipTo.Coords.Lat != 0 || shipTo.Coords.Lng != 0) {
+		destination = &sourcing.Coords{Lat: shipTo.Coords.Lat, Lng: shipTo.Coords.Lng}
+	}
+
+	plan := sourcing.Source(lines, warehouses, stock, destination)
+	var short []cart.CartItem
+	for _, i := range plan.Short {
+		short = append(short, items[i])
+	}
+	var sourced []cart.CartItem
+	seen := map[int]bool{}
+	for _, a := range plan.Allocations {
+		item := items[a.Line]
+		item.WarehouseID = a.WarehouseID
+		item.Quantity = a.Quantity
+		if seen[a.Line] {
+			item.ID = primitive.NewObjectID() // a split line becomes a line of its own
+		}
+		seen[a.Line] = true
+		sourced = append(sourced, item)
+	}
+	return sourced, short, nil
+}
+
+func (h *LambdaHandler) insufficientStockResponse(lines interface{}) events.APIGatewayProxyResponse {
+	respBody, _ := json.Marshal(map[string]interface{}{"message": "Insufficient stock", "lines": lines})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusConflict,
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
+			"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		},
+		Body: string(respBody),
+	}
+}
+
+// pricingErrorResponse answers a failed priceItems call. Products that can no
+// longer be ordered are reported to the caller with 422.
+func (h *LambdaHandler) pricingErrorResponse(err error) events.APIGatewayProxyResponse {
+	var unavailable *catalog.UnavailableProductsError
+	if !errors.As(err, &unavailable) {
+		log.Printf("Failed to resolve prices: %v", err)
+		return h.errorResponse(http.StatusBadGateway, "Failed to resolve prices")
+	}
+	respBody, _ := json.Marshal(map[string]interface{}{"message": "Products are not available", "productIds": unavailable.ProductIDs})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusUnprocessableEntity,
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
+			"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		},
+		Body: string(respBody),
+	}
+}
+
+// applyQuantityRules checks the cart line at index i, which the request just
+// added or changed, against its product's quantity rules. A breaking
+// quantity is refused, or moved to the nearest allowed quantity and repriced
+// when adjust is set. It returns false with the response to send when the
+// line cannot be kept.
+func (h *LambdaHandler) applyQuantityRules(authHeader, customerID string, c *cart.Cart, i int, adjust bool) (events.APIGatewayProxyResponse, bool) {
+	item := &c.Items[i]
+	v := cart.CheckQuantity(*item)
+	if v == nil {
+		return events.APIGatewayProxyResponse{}, true
+	}
+	if !adjust || v.AllowedQuantity == 0 {
+		return h.quantityRulesResponse([]cart.QuantityViolation{*v}), false
+	}
+
+	item.Quantity = v.AllowedQuantity
+	c.Adjustments = append(c.Adjustments, cart.QuantityAdjustment{
+		ItemID:    item.ID.Hex(),
+		ProductID: item.ProductID,
+		VariantID: item.VariantID,
+		From:      v.Quantity,
+		To:        v.AllowedQuantity,
+		Reason:    v.Reason,
+	})
+	// The new quantity may fall in another price tier
+	if err := h.priceItems(authHeader, customerID, c); err != nil {
+		return h.pricingErrorResponse(err), false
+	}
+	return events.APIGatewayProxyResponse{}, true
+}
+
+// quantityRulesResponse reports lines whose quantities break their products'
+// quantity rules with 422.
+func (h *LambdaHandler) quantityRulesResponse(violations []cart.QuantityViolation) events.APIGatewayProxyResponse {
+	respBody, _ := json.Marshal(map[string]interface{}{"message": "Quantity rules not met", "lines": violations})
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusUnprocessableEntity,
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS",
+			"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		},
+		Body: string(respBody),
+	}
+}
+
+// releaseReservation gives back the stock held for an abandoned quote.
+func (h *LambdaHandler) releaseReservation(reservationID string) {
+	if reservationID == "" {
+		return
+	}
+	if err := h.catalogClient.Release(h.serviceAuthHeader(), reservationID); err != nil && err != catalog.ErrReservationNotOpen {
+		log.Printf("Failed to release reservation %s: %v", reservationID, err)
+	}
+}
+
+// serviceTokenTTL bounds the service tokens checkout signs for each call.
+const serviceTokenTTL = 5 * time.Minute
+
+// serviceAuthHeader signs a short-lived token with the "service" role, which
+// catalog-service requires to hold, commit and release stock. Buyers' tokens
+// cannot, so a buyer cannot hold stock outside a quote or release another
+// buyer's.
+func (h *LambdaHandler) serviceAuthHeader() string {
+	claims := jwt.MapClaims{
+		"user": map[string]interface{}{
+			"id":   "checkout-service",
+			"role": "service",
+		},
+		"exp": time.Now().Add(serviceTokenTTL).Unix(),
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.jwtSecret))
+	return "Bearer " + token
+}
+
+// validCurrency reports whether code looks like an ISO 4217 currency code.
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/syed/businesscart/checkout-service/internal/account"
+	"github.com/syed/businesscart/checkout-service/internal/cart"
+	"github.com/syed/businesscart/checkout-service/internal/list"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListRequest represents the request body for creating or replacing a saved
+// list or order guide.
+type ListRequest struct {
+	SellerID   string      `json:"sellerId"` // customers only; a guide belongs to the calling seller
+	Name       string      `json:"name"`
+	Items      []list.Item `json:"items"`
+	SharedWith []string    `json:"sharedWith"`
+}
+
+// handleListRequest serves saved lists and order guides. Customers keep
+// lists of their own per seller and may share them with other accounts of
+// their organization; sellers curate guides and push them to customers.
+// Either kind can be added to the cart in one call.
+func (h *LambdaHandler) handleListRequest(request events.APIGatewayProxyRequest, accountID string, role string, associateCompanyIDs []string, sessionID string) (events.APIGatewayProxyResponse, error) {
+	if role != "customer" && role != "company" {
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+	parts := strings.Split(strings.Trim(request.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && request.HTTPMethod == "GET":
+		lists, err := h.listService.GetLists(accountID, request.QueryStringParameters["sellerId"])
+		if err != nil {
+			return h.errorResponse(http.StatusInternalServerError, "Failed to get lists"), nil
+		}
+		return h.jsonResponse(http.StatusOK, lists), nil
+	case len(parts) == 1 && request.HTTPMethod == "POST":
+		return h.handleCreateListRequest(request, accountID, role, associateCompanyIDs)
+	case len(parts) == 1:
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed"), nil
+	}
+
+	listID, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return h.errorResponse(http.StatusBadRequest, "Invalid list ID"), nil
+	}
+	l, err := h.listService.GetList(listID)
+	if errors.Is(err, list.ErrNotFound) {
+		return h.errorResponse(http.StatusNotFound, "List not found"), nil
+	}
+	if err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to get list"), nil
+	}
+	if !l.Visible(accountID) {
+		return h.errorResponse(http.StatusNotFound, "List not found"), nil
+	}
+
+	if len(parts) == 3 && parts[2] == "cart" && request.HTTPMethod == "POST" {
+		return h.handleAddListToCartRequest(request, accountID, role, associateCompanyIDs, sessionID, l)
+	}
+	if len(parts) != 2 {
+		return h.errorResponse(http.StatusNotFound, "Route not found"), nil
+	}
+	switch request.HTTPMethod {
+	case "GET":
+		return h.jsonResponse(http.StatusOK, l), nil
+	case "PUT":
+		return h.handleUpdateListRequest(request, accountID, l)
+	case "DELETE":
+		// Those the list is shared with may use it but not remove it
+		if l.OwnerID != accountID {
+			return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+		}
+		if err := h.listService.DeleteList(l.ID); err != nil {
+			return h.errorResponse(http.StatusInternalServerError, "Failed to delete list"), nil
+		}
+		return h.jsonResponse(http.StatusOK, map[string]string{"message": "List deleted"}), nil
+	}
+	return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed"), nil
+}
+
+func (h *LambdaHandler) handleCreateListRequest(request events.APIGatewayProxyRequest, accountID string, role string, associateCompanyIDs []string) (events.APIGatewayProxyResponse, error) {
+	var req ListRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return h.errorResponse(http.StatusBadRequest, "Invalid request body"), nil
+	}
+
+	l := &list.List{OwnerID: accountID}
+	if role == "company" {
+		l.Kind = list.KindGuide
+		l.SellerID = accountID
+	} else {
+		if !containsString(associateCompanyIDs, req.SellerID) {
+			return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+		}
+		l.Kind = list.KindList
+		l.SellerID = req.SellerID
+	}
+	if msg := applyListRequest(l, req); msg != "" {
+		return h.errorResponse(http.StatusBadRequest, msg), nil
+	}
+	if resp, ok := h.checkSharedWith(request.Headers["Authorization"], l); !ok {
+		return resp, nil
+	}
+
+	if err := h.listService.CreateList(l); err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to create list"), nil
+	}
+	return h.jsonResponse(http.StatusCreated, l), nil
+}
+
+func (h *LambdaHandler) handleUpdateListRequest(request events.APIGatewayProxyRequest, accountID string, l *list.List) (events.APIGatewayProxyResponse, error) {
+	if l.OwnerID != accountID {
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+	var req ListRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return h.errorResponse(http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if msg := applyListRequest(l, req); msg != "" {
+		return h.errorResponse(http.StatusBadRequest, msg), nil
+	}
+	if resp, ok := h.checkSharedWith(request.Headers["Authorization"], l); !ok {
+		return resp, nil
+	}
+
+	if err := h.listService.UpdateList(l); err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to update list"), nil
+	}
+	return h.jsonResponse(http.StatusOK, l), nil
+}
+
+// applyListRequest copies the name, items and sharing of req onto l. It
+// returns what is wrong with the request, or "" when it is valid. Products
+// are checked against the catalog when the list is added to a cart.
+func applyListRequest(l *list.List, req ListRequest) string {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "name is required"
+	}
+	type key struct{ productID, variantID string }
+	seen := map[key]bool{}
+	for _, item := range req.Items {
+		if item.ProductID == "" {
+			return "every item needs a productId"
+		}
+		if item.Quantity < 1 {
+			return "item quantities must be at least 1"
+		}
+		k := key{item.ProductID, item.VariantID}
+		if seen[k] {
+			return "product " + item.ProductID + " is on the list twice"
+		}
+		seen[k] = true
+	}
+	var sharedWith []string
+	for _, id := range req.SharedWith {
+		if _, err := primitive.ObjectIDFromHex(id); err != nil {
+			return "sharedWith must list account IDs"
+		}
+		if id != l.OwnerID && !containsString(sharedWith, id) {
+			sharedWith = append(sharedWith, id)
+		}
+	}
+
+	l.Name = name
+	l.Items = req.Items
+	if l.Items == nil {
+		l.Items = []list.Item{}
+	}
+	l.SharedWith = sharedWith
+	return ""
+}
+
+// checkSharedWith makes sure a list is only shared with accounts that may
+// use it: a guide with customers linked to its seller, and a saved list with
+// customers of the owner's organization, taken to be those with the owner's
+// email domain, who also buy from the list's seller. It writes the response
+// and returns false otherwise.
+func (h *LambdaHandler) checkSharedWith(authHeader string, l *list.List) (events.APIGatewayProxyResponse, bool) {
+	if len(l.SharedWith) == 0 {
+		return events.APIGatewayProxyResponse{}, true
+	}
+	if l.Kind == list.KindGuide {
+		for _, id := range l.SharedWith {
+			_, err := h.accountClient.GetTradingTerms(authHeader, id, l.SellerID)
+			if errors.Is(err, account.ErrNotFound) {
+				return h.errorResponse(http.StatusBadRequest, "sharedWith: "+id+" is not one of your customers"), false
+			}
+			if err != nil {
+				log.Printf("Failed to check customer %s of seller %s: %v", id, l.SellerID, err)
+				return h.errorResponse(http.StatusBadGateway, "Failed to check sharedWith"), false
+			}
+		}
+		return events.APIGatewayProxyResponse{}, true
+	}
+
+	owner, err := h.accountClient.GetAccount(authHeader, l.OwnerID)
+	if err != nil {
+		log.Printf("Failed to get account %s: %v", l.OwnerID, err)
+		return h.errorResponse(http.StatusBadGateway, "Failed to check sharedWith"), false
+	}
+	domain := emailDomain(owner.Email)
+	for _, id := range l.SharedWith {
+		acc, err := h.accountClient.GetAccount(authHeader, id)
+		if errors.Is(err, account.ErrNotFound) {
+			return h.errorResponse(http.StatusBadRequest, "sharedWith: account "+id+" not found"), false
+		}
+		if err != nil {
+			log.Printf("Failed to get account %s: %v", id, err)
+			return h.errorResponse(http.StatusBadGateway, "Failed to check sharedWith"), false
+		}
+		if acc.Role != "customer" || !acc.LinkedTo(l.SellerID) || domain == "" || !strings.EqualFold(emailDomain(acc.Email), domain) {
+			return h.errorResponse(http.StatusBadRequest, "sharedWith: "+id+" is not a customer of your organization buying from this seller"), false
+		}
+	}
+	return events.APIGatewayProxyResponse{}, true
+}
+
+// emailDomain returns what follows the @ of an email address, or "".
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return email[at+1:]
+}
+
+// handleAddListToCartRequest adds every item of a list to the customer's cart
+// with the list's seller at its usual quantity, merging into lines already
+// in the cart. Items are priced and checked against quantity rules as if
+// added one by one; "adjustQuantity" applies to all of them.
+func (h *LambdaHandler) handleAddListToCartRequest(request events.APIGatewayProxyRequest, accountID string, role string, associateCompanyIDs []string, sessionID string, l *list.List) (events.APIGatewayProxyResponse, error) {
+	if role != "customer" || !containsString(associateCompanyIDs, l.SellerID) {
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+	var req struct {
+		AdjustQuantity bool   `json:"adjustQuantity"`
+		Currency       string `json:"currency"`
+	}
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return h.errorResponse(http.StatusBadRequest, "Invalid request body"), nil
+		}
+	}
+	if len(l.Items) == 0 {
+		return h.errorResponse(http.StatusBadRequest, "List is empty"), nil
+	}
+
+	var items []cart.CartItem
+	for _, item := range l.Items {
+		items = append(items, cart.CartItem{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity, SellerID: l.SellerID})
+	}
+	return h.addItemsToCart(request.Headers["Authorization"], accountID, l.SellerID, sessionID, req.Currency, items, req.AdjustQuantity), nil
+}
@@ -0,0 +1,567 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/syed/businesscart/checkout-service/internal/account"
+	"github.com/syed/businesscart/checkout-service/internal/cart"
+	"github.com/syed/businesscart/checkout-service/internal/order"
+	"github.com/syed/businesscart/checkout-service/internal/punchout"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PunchOutBuyerRequest represents the request body for registering a
+// procurement system that punches out on behalf of one of the seller's
+// customers.
+type PunchOutBuyerRequest struct {
+	CustomerID   string `json:"customerId"`
+	Name         string `json:"name"`
+	Domain       string `json:"domain"`
+	Identity     string `json:"identity"`
+	SharedSecret string `json:"sharedSecret"`
+}
+
+// isPunchOutDocumentRoute reports whether a request is one of the cXML
+// documents procurement systems post. They authenticate with the shared
+// secret in the document rather than a JWT.
+func isPunchOutDocumentRoute(request events.APIGatewayProxyRequest) bool {
+	if request.HTTPMethod != "POST" {
+		return false
+	}
+	switch strings.TrimSuffix(request.Path, "/") {
+	case "/punchout/setup", "/punchout/start", "/punchout/orders":
+		return true
+	}
+	return false
+}
+
+// handlePunchOutDocumentRequest serves the routes of isPunchOutDocumentRoute.
+func (h *LambdaHandler) handlePunchOutDocumentRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch strings.TrimSuffix(request.Path, "/") {
+	case "/punchout/setup":
+		return h.handlePunchOutSetupRequest(request)
+	case "/punchout/start":
+		return h.handlePunchOutStartRequest(request)
+	default:
+		return h.handlePunchOutOrderRequest(request)
+	}
+}
+
+// handlePunchOutRequest serves the authenticated PunchOut routes: sellers
+// manage the buyers allowed to punch out, and a shopper returns the cart of
+// their session to the procurement system.
+func (h *LambdaHandler) handlePunchOutRequest(request events.APIGatewayProxyRequest, accountID string, role string, sessionID string) (events.APIGatewayProxyResponse, error) {
+	parts := strings.Split(strings.Trim(request.Path, "/"), "/")
+	if len(parts) == 4 && parts[1] == "sessions" && parts[3] == "return" && request.HTTPMethod == "POST" {
+		return h.handlePunchOutReturnRequest(request, accountID, sessionID, parts[2])
+	}
+	if len(parts) < 2 || parts[1] != "buyers" {
+		return h.errorResponse(http.StatusNotFound, "Route not found"), nil
+	}
+	if role != "company" {
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+
+	switch {
+	case len(parts) == 2 && request.HTTPMethod == "GET":
+		buyers, err := h.punchoutService.GetBuyers(accountID)
+		if err != nil {
+			return h.errorResponse(http.StatusInternalServerError, "Failed to get buyers"), nil
+		}
+		return h.jsonResponse(http.StatusOK, buyers), nil
+	case len(parts) == 2 && request.HTTPMethod == "POST":
+		return h.handleCreatePunchOutBuyerRequest(request, accountID)
+	case len(parts) == 3 && request.HTTPMethod == "DELETE":
+		buyerID, err := primitive.ObjectIDFromHex(parts[2])
+		if err != nil {
+			return h.errorResponse(http.StatusBadRequest, "Invalid buyer ID"), nil
+		}
+		buyer, err := h.punchoutService.GetBuyer(buyerID)
+		if err != nil || buyer.SellerID != accountID {
+			return h.errorResponse(http.StatusNotFound, "Buyer not found"), nil
+		}
+		if err := h.punchoutService.DeleteBuyer(buyerID); err != nil {
+			return h.errorResponse(http.StatusInternalServerError, "Failed to delete buyer"), nil
+		}
+		return h.jsonResponse(http.StatusOK, map[string]string{"message": "Buyer deleted"}), nil
+	}
+	return h.errorResponse(http.StatusNotFound, "Route not found"), nil
+}
+
+func (h *LambdaHandler) handleCreatePunchOutBuyerRequest(request events.APIGatewayProxyRequest, sellerID string) (events.APIGatewayProxyResponse, error) {
+	var req PunchOutBuyerRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return h.errorResponse(http.StatusBadRequest, "Invalid request body"), nil
+	}
+	req.Domain = strings.TrimSpace(req.Domain)
+	req.Identity = strings.TrimSpace(req.Identity)
+	if req.CustomerID == "" || req.Domain == "" || req.Identity == "" {
+		return h.errorResponse(http.StatusBadRequest, "customerId, domain and identity are required"), nil
+	}
+	if len(req.SharedSecret) < 12 {
+		return h.errorResponse(http.StatusBadRequest, "sharedSecret must be at least 12 characters"), nil
+	}
+	// The buyer places orders as the customer, so it must be one of the
+	// seller's own
+	_, err := h.accountClient.GetTradingTerms(request.Headers["Authorization"], req.CustomerID, sellerID)
+	if errors.Is(err, account.ErrNotFound) {
+		return h.errorResponse(http.StatusBadRequest, "customerId: "+req.CustomerID+" is not one of your customers"), nil
+	}
+	if err != nil {
+		log.Printf("Failed to check customer %s of seller %s: %v", req.CustomerID, sellerID, err)
+		return h.errorResponse(http.StatusBadGateway, "Failed to check customerId"), nil
+	}
+
+	buyer := &punchout.Buyer{
+		SellerID:   sellerID,
+		CustomerID: req.CustomerID,
+		Name:       strings.TrimSpace(req.Name),
+		Domain:     req.Domain,
+		Identity:   req.Identity,
+	}
+	buyer.SetSecret(req.SharedSecret)
+	err = h.punchoutService.CreateBuyer(buyer)
+	if errors.Is(err, punchout.ErrBuyerExists) {
+		return h.errorResponse(http.StatusConflict, "A buyer with this domain and identity is already registered"), nil
+	}
+	if err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to create buyer"), nil
+	}
+	return h.jsonResponse(http.StatusCreated, buyer), nil
+}
+
+// handlePunchOutSetupRequest answers a PunchOutSetupRequest with the start
+// page the buyer's browser opens to shop. The page carries a one-time token
+// for handlePunchOutStartRequest. Edit and inspect requests bring the lines
+// of a cart returned earlier, which the session reopens.
+func (h *LambdaHandler) handlePunchOutSetupRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	doc, err := punchout.Parse(requestBody(request))
+	if err != nil || doc.Request == nil || doc.Request.PunchOutSetupRequest == nil {
+		return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", "expected a PunchOutSetupRequest"), nil
+	}
+	buyer, resp, ok := h.authenticateBuyer(doc)
+	if !ok {
+		return resp, nil
+	}
+
+	setup := doc.Request.PunchOutSetupRequest
+	formPostURL := strings.TrimSpace(setup.BrowserFormPost.URL)
+	if u, err := url.Parse(formPostURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", "BrowserFormPost URL is required"), nil
+	}
+	operation := strings.ToLower(setup.Operation)
+	if operation == "" {
+		operation = punchout.OperationCreate
+	}
+	if operation != punchout.OperationCreate && operation != punchout.OperationEdit && operation != punchout.OperationInspect {
+		return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", fmt.Sprintf("unsupported operation %q", setup.Operation)), nil
+	}
+
+	session := &punchout.Session{
+		BuyerID:            buyer.ID,
+		SellerID:           buyer.SellerID,
+		CustomerID:         buyer.CustomerID,
+		Operation:          operation,
+		BuyerCookie:        setup.BuyerCookie,
+		BrowserFormPostURL: formPostURL,
+	}
+	if operation != punchout.OperationCreate {
+		for _, line := range setup.ItemOut {
+			quantity, ok := line.WholeQuantity()
+			if !ok || line.ItemID.SupplierPartID == "" {
+				return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", "every ItemOut needs a SupplierPartID and a whole quantity"), nil
+			}
+			session.Items = append(session.Items, punchout.SessionItem{
+				ProductID: line.ItemID.SupplierPartID,
+				VariantID: line.ItemID.SupplierPartAuxiliaryID,
+				Quantity:  quantity,
+			})
+		}
+	}
+	token, hash := punchout.NewStartToken()
+	session.StartHash = hash
+	if err := h.punchoutService.CreateSession(session); err != nil {
+		log.Printf("Failed to create punchout session for buyer %s: %v", buyer.ID.Hex(), err)
+		return h.cxmlStatusResponse(punchout.StatusInternal, "Internal Server Error", "failed to create session"), nil
+	}
+
+	startURL, err := url.Parse(h.punchOutStartURL)
+	if err != nil {
+		log.Printf("Invalid PunchOut start URL %q: %v", h.punchOutStartURL, err)
+		return h.cxmlStatusResponse(punchout.StatusInternal, "Internal Server Error", "start page is not configured"), nil
+	}
+	query := startURL.Query()
+	query.Set("token", token)
+	startURL.RawQuery = query.Encode()
+
+	out := punchout.NewResponse(punchout.StatusOK, "OK")
+	out.Response.PunchOutSetupResponse = &punchout.PunchOutSetupResponse{}
+	out.Response.PunchOutSetupResponse.StartPage.URL = startURL.String()
+	return h.cxmlResponse(out), nil
+}
+
+// handlePunchOutStartRequest redeems the one-time token of a start page for
+// a customer token scoped to the session's seller, valid until the session
+// expires. The token shops in a cart of the session's own, which starts with
+// the session's lines, so that what goes back to the procurement system is
+// only what was shopped in this session and the customer's own cart with
+// the seller is left alone.
+func (h *LambdaHandler) handlePunchOutStartRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(requestBody(request), &req); err != nil || req.Token == "" {
+		return h.errorResponse(http.StatusBadRequest, "token is required"), nil
+	}
+	session, err := h.punchoutService.StartSession(req.Token)
+	if errors.Is(err, punchout.ErrSessionNotFound) {
+		return h.errorResponse(http.StatusUnauthorized, "Unauthorized: PunchOut link is invalid, used or expired"), nil
+	}
+	if err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to start session"), nil
+	}
+
+	token, err := h.punchOutToken(session.CustomerID, session.SellerID, session.ID.Hex(), session.ExpiresAt)
+	if err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to start session"), nil
+	}
+	authHeader := "Bearer " + token
+
+	sessionCart := &cart.Cart{
+		AccountID: session.CustomerID,
+		SellerID:  session.SellerID,
+		SessionID: session.ID.Hex(),
+		Items:     []cart.CartItem{},
+		ExpiresAt: &session.ExpiresAt,
+	}
+	if err := h.cartService.SaveCart(sessionCart); err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to create cart"), nil
+	}
+	if len(session.Items) > 0 {
+		var items []cart.CartItem
+		for _, item := range session.Items {
+			items = append(items, cart.CartItem{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity, SellerID: session.SellerID})
+		}
+		if resp := h.addItemsToCart(authHeader, session.CustomerID, session.SellerID, sessionCart.SessionID, "", items, true); resp.StatusCode != http.StatusOK {
+			// The buyer can still shop; the lines that could not be
+			// reopened are simply not in the cart.
+			log.Printf("Failed to reopen cart of punchout session %s: %s", session.ID.Hex(), resp.Body)
+		}
+	}
+
+	return h.jsonResponse(http.StatusOK, map[string]interface{}{
+		"token":     token,
+		"sessionId": session.ID.Hex(),
+		"sellerId":  session.SellerID,
+		"operation": session.Operation,
+		"expiresAt": session.ExpiresAt,
+	}), nil
+}
+
+// handlePunchOutReturnRequest prices the session's cart and returns it as
+// a PunchOutOrderMessage, with the URL the storefront posts it to from the
+// buyer's browser (as the cxml-urlencoded form field). The session ends and
+// its cart is removed; the order itself arrives later as an OrderRequest.
+func (h *LambdaHandler) handlePunchOutReturnRequest(request events.APIGatewayProxyRequest, accountID string, tokenSessionID string, sessionIDStr string) (events.APIGatewayProxyResponse, error) {
+	sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
+	if err != nil {
+		return h.errorResponse(http.StatusBadRequest, "Invalid session ID"), nil
+	}
+	// Only the token the session started with may return it
+	if tokenSessionID != sessionIDStr {
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+	session, err := h.punchoutService.GetSession(sessionID)
+	if errors.Is(err, punchout.ErrSessionNotFound) || (err == nil && session.CustomerID != accountID) {
+		return h.errorResponse(http.StatusNotFound, "Session not found"), nil
+	}
+	if err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to get session"), nil
+	}
+	if session.Status != punchout.SessionStarted || time.Now().After(session.ExpiresAt) {
+		return h.errorResponse(http.StatusGone, "Session is no longer open"), nil
+	}
+
+	authHeader := request.Headers["Authorization"]
+	currentCart, err := h.cartService.GetCart(accountID, session.SellerID, sessionIDStr)
+	if err != nil && err.Error() != "cart not found" {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to get cart"), nil
+	}
+	if currentCart == nil {
+		currentCart = &cart.Cart{AccountID: accountID, SellerID: session.SellerID, SessionID: sessionIDStr, Items: []cart.CartItem{}}
+	}
+	// Prices are what the procurement system approves, so they must be
+	// current
+	if err := h.priceItems(authHeader, accountID, currentCart); err != nil {
+		return h.pricingErrorResponse(err), nil
+	}
+
+	doc := punchout.New()
+	doc.Message = &punchout.Message{PunchOutOrderMessage: punchOutOrderMessage(session, currentCart)}
+	body, err := punchout.Marshal(doc)
+	if err != nil {
+		return h.errorResponse(http.StatusInternalServerError, "Failed to build cXML"), nil
+	}
+
+	if err := h.punchoutService.ReturnSession(sessionID); err != nil {
+		return h.errorResponse(http.StatusGone, "Session is no longer open"), nil
+	}
+	_ = h.cartService.DeleteCart(accountID, session.SellerID, sessionIDStr)
+
+	return h.jsonResponse(http.StatusOK, map[string]string{
+		"browserFormPostUrl": session.BrowserFormPostURL,
+		"cxml":               string(body),
+	}), nil
+}
+
+// punchOutOrderMessage describes a cart as the procurement system sees it.
+// Inspect sessions only allow the buyer to look at the cart again.
+func punchOutOrderMessage(session *punchout.Session, c *cart.Cart) *punchout.PunchOutOrderMessage {
+	msg := &punchout.PunchOutOrderMessage{BuyerCookie: session.BuyerCookie}
+	msg.Header.OperationAllowed = punchout.OperationEdit
+	if session.Operation == punchout.OperationInspect {
+		msg.Header.OperationAllowed = punchout.OperationInspect
+	}
+	msg.Header.Total.Money = punchout.Money{Currency: c.Currency, Value: punchout.FormatMoney(cart.Subtotal(c.Items), cart.MinorUnits(c.Currency))}
+	for _, item := range c.Items {
+		description := item.Name
+		if description == "" {
+			description = item.ProductID
+		}
+		msg.ItemIn = append(msg.ItemIn, punchout.ItemIn{
+			Quantity: strconv.Itoa(item.Quantity),
+			ItemID: punchout.ItemID{
+				SupplierPartID:          item.ProductID,
+				SupplierPartAuxiliaryID: item.VariantID,
+				BuyerPartID:             item.CustomerPartNumber,
+			},
+			ItemDetail: punchout.ItemDetail{
+				UnitPrice:     punchout.Money{Currency: c.Currency, Value: punchout.FormatMoney(item.Price, cart.MinorUnits(c.Currency))},
+				Description:   description,
+				UnitOfMeasure: "EA",
+			},
+		})
+	}
+	return msg
+}
+
+// handlePunchOutOrderRequest places the order of a cXML OrderRequest on the
+// customer's account with the seller. The buyer's order ID is kept as the
+// purchase order number, so a resent request answers with the order already
+// placed instead of placing it twice. Lines are priced as for any other
+// order, and the order is refused if any costs more than the buyer approved.
+func (h *LambdaHandler) handlePunchOutOrderRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	doc, err := punchout.Parse(requestBody(request))
+	if err != nil || doc.Request == nil || doc.Request.OrderRequest == nil {
+		return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", "expected an OrderRequest"), nil
+	}
+	buyer, resp, ok := h.authenticateBuyer(doc)
+	if !ok {
+		return resp, nil
+	}
+
+	orderRequest := doc.Request.OrderRequest
+	purchaseOrder := strings.TrimSpace(orderRequest.Header.OrderID)
+	if purchaseOrder == "" {
+		return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", "orderID is required"), nil
+	}
+	if t := orderRequest.Header.Type; t != "" && t != "new" {
+		return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", fmt.Sprintf("%s orders are not supported; contact the seller", t)), nil
+	}
+	if len(orderRequest.ItemOut) == 0 {
+		return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", "the order has no lines"), nil
+	}
+
+	existing, err := h.orderService.GetOrderByPurchaseOrder(buyer.CustomerID, buyer.SellerID, purchaseOrder)
+	if err == nil {
+		return h.cxmlStatusResponse(punchout.StatusOK, "OK", "order "+existing.ID.Hex()+" was already placed for this purchase order"), nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return h.cxmlStatusResponse(punchout.StatusInternal, "Internal Server Error", "failed to check for the order"), nil
+	}
+
+	// The buyer's system has no customer session; act as the customer for
+	// the few minutes it takes to place the order.
+	token, err := h.punchOutToken(buyer.CustomerID, buyer.SellerID, "", time.Now().Add(5*time.Minute))
+	if err != nil {
+		return h.cxmlStatusResponse(punchout.StatusInternal, "Internal Server Error", "failed to place the order"), nil
+	}
+	authHeader := "Bearer " + token
+
+	orderCart := &cart.Cart{
+		AccountID: buyer.CustomerID,
+		SellerID:  buyer.SellerID,
+		Currency:  strings.ToUpper(orderRequest.Header.Total.Money.Currency),
+		Items:     []cart.CartItem{},
+	}
+	if orderCart.Currency != "" && !validCurrency(orderCart.Currency) {
+		return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", "Total currency must be a three-letter ISO 4217 code"), nil
+	}
+	approved := make([]float64, len(orderRequest.ItemOut)) // unit price per line, or -1
+	for i, line := range orderRequest.ItemOut {
+		quantity, ok := line.WholeQuantity()
+		if !ok || line.ItemID.SupplierPartID == "" {
+			return h.cxmlStatusResponse(punchout.StatusBadRequest, "Bad Request", "every ItemOut needs a SupplierPartID and a whole quantity"), nil
+		}
+		orderCart.Items = append(orderCart.Items, cart.CartItem{
+			ID:        primitive.NewObjectID(),
+			ProductID: line.ItemID.SupplierPartID,
+			VariantID: line.ItemID.SupplierPartAuxiliaryID,
+			Quantity:  quantity,
+			SellerID:  buyer.SellerID,
+		})
+		approved[i] = -1
+		if price, err := strconv.ParseFloat(line.ItemDetail.UnitPrice.Value, 64); err == nil {
+			approved[i] = price
+		}
+	}
+
+	newQuote, resp, ok := h.priceQuote(authHeader, orderCart)
+	if !ok {
+		return h.cxmlFromResponse(resp), nil
+	}
+	for i, item := range newQuote.Items {
+		if approved[i] >= 0 && cart.Round(item.Price, newQuote.Currency) > cart.Round(approved[i], newQuote.Currency) {
+			decimals := cart.MinorUnits(newQuote.Currency)
+			return h.cxmlStatusResponse(punchout.StatusConflict, "Conflict", fmt.Sprintf("line %d: the price of %s is now %s, above the %s approved", i+1, item.ProductID, punchout.FormatMoney(item.Price, decimals), punchout.FormatMoney(approved[i], decimals))), nil
+		}
+	}
+
+	if resp, ok := h.reserveQuote(authHeader, newQuote, punchOutShipTo(orderRequest.Header.ShipTo)); !ok {
+		return h.cxmlFromResponse(resp), nil
+	}
+	createdOrder, resp, ok := h.placeOrder(authHeader, newQuote, order.PaymentMethodOnAccount, "", purchaseOrder)
+	if !ok {
+		h.releaseReservation(newQuote.ReservationID)
+		// A resend that got past the check above while the first order was
+		// being placed loses on the unique index; answer it the same way.
+		if resp.StatusCode == http.StatusConflict {
+			if existing, err := h.orderService.GetOrderByPurchaseOrder(buyer.CustomerID, buyer.SellerID, purchaseOrder); err == nil {
+				return h.cxmlStatusResponse(punchout.StatusOK, "OK", "order "+existing.ID.Hex()+" was already placed for this purchase order"), nil
+			}
+		}
+		return h.cxmlFromResponse(resp), nil
+	}
+
+	log.Printf("PunchOut buyer %s placed order %s for purchase order %s", buyer.ID.Hex(), createdOrder.ID.Hex(), purchaseOrder)
+	return h.cxmlStatusResponse(punchout.StatusOK, "OK", "order "+createdOrder.ID.Hex()+" placed"), nil
+}
+
+// authenticateBuyer checks the credentials of a cXML request. It returns
+// false with the cXML response to send when they are not accepted.
+func (h *LambdaHandler) authenticateBuyer(doc *punchout.CXML) (*punchout.Buyer, events.APIGatewayProxyResponse, bool) {
+	buyer, err := h.punchoutService.Authenticate(doc.Header)
+	if errors.Is(err, punchout.ErrUnauthorized) {
+		return nil, h.cxmlStatusResponse(punchout.StatusUnauthorized, "Unauthorized", "unknown credential or wrong shared secret"), false
+	}
+	if err != nil {
+		log.Printf("Failed to authenticate punchout buyer: %v", err)
+		return nil, h.cxmlStatusResponse(punchout.StatusInternal, "Internal Server Error", "failed to check credentials"), false
+	}
+	return buyer, events.APIGatewayProxyResponse{}, true
+}
+
+// punchOutToken mints a customer token limited to one seller. Tokens of a
+// shopping session name it in the "punchout" claim, which also keeps them
+// from placing orders themselves.
+func (h *LambdaHandler) punchOutToken(customerID, sellerID, sessionID string, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"user": map[string]interface{}{
+			"id":                    customerID,
+			"role":                  "customer",
+			"associate_company_ids": []string{sellerID},
+		},
+		"exp": expiresAt.Unix(),
+	}
+	if sessionID != "" {
+		claims["punchout"] = sessionID
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.jwtSecret))
+}
+
+// punchOutShipTo converts a cXML ship-to address, or returns nil to ship to
+// the customer's address.
+func punchOutShipTo(shipTo *punchout.ShipTo) *account.Address {
+	if shipTo == nil {
+		return nil
+	}
+	postal := shipTo.Address.PostalAddress
+	if len(postal.Streets) == 0 && postal.City == "" {
+		return nil
+	}
+	return &account.Address{
+		Street: strings.Join(postal.Streets, ", "),
+		City:   postal.City,
+		State:  postal.State,
+		Zip:    postal.PostalCode,
+	}
+}
+
+// requestBody returns the raw body of a request, which API Gateway base64
+// encodes for content types it treats as binary.
+func requestBody(request events.APIGatewayProxyRequest) []byte {
+	if request.IsBase64Encoded {
+		if body, err := base64.StdEncoding.DecodeString(request.Body); err == nil {
+			return body
+		}
+	}
+	return []byte(request.Body)
+}
+
+// cxmlFromResponse restates a JSON error response of the checkout helpers
+// as a cXML status. Failures upstream may be retried; refusals may not.
+func (h *LambdaHandler) cxmlFromResponse(resp events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal([]byte(resp.Body), &body)
+	if body.Message == "" {
+		body.Message = resp.Body
+	}
+	code := resp.StatusCode
+	switch {
+	case code == http.StatusBadGateway || code == http.StatusServiceUnavailable:
+		code = punchout.StatusTemporary
+	case code >= 500:
+		code = punchout.StatusInternal
+	case code == http.StatusNotFound || code == http.StatusGone || code == http.StatusUnprocessableEntity:
+		code = punchout.StatusBadRequest
+	}
+	return h.cxmlStatusResponse(code, http.StatusText(resp.StatusCode), body.Message)
+}
+
+func (h *LambdaHandler) cxmlStatusResponse(code int, text, detail string) events.APIGatewayProxyResponse {
+	doc := punchout.NewResponse(code, text)
+	doc.Response.Status.Body = detail
+	return h.cxmlResponse(doc)
+}
+
+// cxmlResponse writes a cXML document. cXML carries its status in the
+// document, so the HTTP status is always 200.
+func (h *LambdaHandler) cxmlResponse(doc *punchout.CXML) events.APIGatewayProxyResponse {
+	body, err := punchout.Marshal(doc)
+	if err != nil {
+		log.Printf("Failed to marshal cXML: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":                "text/xml; charset=UTF-8",
+			"Access-Control-Allow-Origin": "*",
+		},
+		Body: string(body),
+	}
+}
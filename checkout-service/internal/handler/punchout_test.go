@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/syed/businesscart/checkout-service/internal/account"
+	"github.com/syed/businesscart/checkout-service/internal/catalog"
+	"github.com/syed/businesscart/checkout-service/internal/order"
+	"github.com/syed/businesscart/checkout-service/internal/punchout"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	testSellerID   = "seller-1"
+	testCustomerID = "customer-1"
+	testSecret     = "acme-punchout-secret" // that of the testdata documents
+)
+
+// memoryPunchout keeps buyers and sessions in memory. Calls the tests do not
+// expect go to the nil punchoutStore and panic.
+type memoryPunchout struct {
+	punchoutStore
+	buyers   []*punchout.Buyer
+	sessions []*punchout.Session
+}
+
+func (m *memoryPunchout) Authenticate(h *punchout.Header) (*punchout.Buyer, error) {
+	return punchout.Authenticate(h, func(domain, identity string) (*punchout.Buyer, error) {
+		for _, b := range m.buyers {
+			if b.Domain == domain && b.Identity == identity {
+				return b, nil
+			}
+		}
+		return nil, nil
+	})
+}
+
+func (m *memoryPunchout) CreateSession(session *punchout.Session) error {
+	session.ID = primitive.NewObjectID()
+	session.Status = punchout.SessionOpen
+	session.CreatedAt = time.Now()
+	session.ExpiresAt = session.CreatedAt.Add(punchout.SessionTTL)
+	m.sessions = append(m.sessions, session)
+	return nil
+}
+
+// memoryOrders keeps orders in memory, like memoryPunchout.
+type memoryOrders struct {
+	orderStore
+	orders []*order.Order
+}
+
+func (m *memoryOrders) GetOrderByPurchaseOrder(accountID, sellerID, purchaseOrder string) (*order.Order, error) {
+	for _, o := range m.orders {
+		if o.AccountID == accountID && o.SellerID == sellerID && o.PurchaseOrder == purchaseOrder {
+			return o, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+// newPunchOutHandler returns a handler whose buyer for the testdata
+// credential has the shared secret secret. Calls to account-service and
+// catalog-service go to accountSrv and catalogSrv.
+func newPunchOutHandler(t *testing.T, secret string, accountSrv, catalogSrv *httptest.Server) (*LambdaHandler, *memoryPunchout, *memoryOrders) {
+	t.Helper()
+	buyer := &punchout.Buyer{
+		ID:         primitive.NewObjectID(),
+		SellerID:   testSellerID,
+		CustomerID: testCustomerID,
+		Domain:     punchout.NormalizeDomain("NetworkID"),
+		Identity:   "AN01000000147",
+	}
+	buyer.SetSecret(secret)
+	store := &memoryPunchout{buyers: []*punchout.Buyer{buyer}}
+	orders := &memoryOrders{}
+	h := &LambdaHandler{
+		orderService:     orders,
+		punchoutService:  store,
+		accountClient:    account.NewClient(accountSrv.URL),
+		catalogClient:    catalog.NewClient(catalogSrv.URL),
+		jwtSecret:        "test-secret",
+		punchOutStartURL: "https://shop.example/punchout",
+	}
+	return h, store, orders
+}
+
+// unexpectedServer fails the test if anything calls it.
+func unexpectedServer(t *testing.T, name string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected call to %s: %s %s", name, r.Method, r.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func postFixture(t *testing.T, h *LambdaHandler, path, name string) *punchout.CXML {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("..", "punchout", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := h.handlePunchOutDocumentRequest(events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: path, Body: string(body)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("HTTP status = %d, want 200 with the status in the cXML", resp.StatusCode)
+	}
+	doc, err := punchout.Parse([]byte(resp.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Response == nil {
+		t.Fatalf("no Response in %s", resp.Body)
+	}
+	return doc
+}
+
+func TestPunchOutSetup(t *testing.T) {
+	h, store, _ := newPunchOutHandler(t, testSecret, unexpectedServer(t, "account-service"), unexpectedServer(t, "catalog-service"))
+
+	doc := postFixture(t, h, "/punchout/setup", "punchout-setup-request.xml")
+	if doc.Response.Status.Code != punchout.StatusOK {
+		t.Fatalf("status = %+v, want 200", doc.Response.Status)
+	}
+	if len(store.sessions) != 1 {
+		t.Fatalf("%d sessions created, want 1", len(store.sessions))
+	}
+	session := store.sessions[0]
+	if session.CustomerID != testCustomerID || session.SellerID != testSellerID || session.BuyerCookie != "34234234ADFSDF234234" || session.Operation != punchout.OperationCreate {
+		t.Errorf("session = %+v", session)
+	}
+	if doc.Response.PunchOutSetupResponse == nil {
+		t.Fatal("no PunchOutSetupResponse")
+	}
+	startURL, err := url.Parse(doc.Response.PunchOutSetupResponse.StartPage.URL)
+	if err != nil || !strings.HasPrefix(startURL.String(), h.punchOutStartURL+"?") || startURL.Query().Get("token") == "" {
+		t.Errorf("StartPage URL = %q, want the start page with a token", doc.Response.PunchOutSetupResponse.StartPage.URL)
+	}
+}
+
+func TestPunchOutWrongSecret(t *testing.T) {
+	for path, fixture := range map[string]string{
+		"/punchout/setup":  "punchout-setup-request.xml",
+		"/punchout/orders": "order-request.xml",
+	} {
+		h, store, _ := newPunchOutHandler(t, "another-shared-secret", unexpectedServer(t, "account-service"), unexpectedServer(t, "catalog-service"))
+
+		doc := postFixture(t, h, path, fixture)
+		if doc.Response.Status.Code != punchout.StatusUnauthorized {
+			t.Errorf("%s: status = %+v, want 401", path, doc.Response.Status)
+		}
+		if len(store.sessions) != 0 {
+			t.Errorf("%s: a session was created", path)
+		}
+	}
+}
+
+func TestPunchOutOrderResent(t *testing.T) {
+	h, _, orders := newPunchOutHandler(t, testSecret, unexpectedServer(t, "account-service"), unexpectedServer(t, "catalog-service"))
+	placed := &order.Order{ID: primitive.NewObjectID(), AccountID: testCustomerID, SellerID: testSellerID, PurchaseOrder: "PO-2026-004417"}
+	orders.orders = append(orders.orders, placed)
+
+	doc := postFixture(t, h, "/punchout/orders", "order-request.xml")
+	if doc.Response.Status.Code != punchout.StatusOK {
+		t.Fatalf("status = %+v, want 200", doc.Response.Status)
+	}
+	if !strings.Contains(doc.Response.Status.Body, placed.ID.Hex()) {
+		t.Errorf("status body = %q, want it to name order %s", doc.Response.Status.Body, placed.ID.Hex())
+	}
+	if len(orders.orders) != 1 {
+		t.Errorf("%d orders, want the one already placed", len(orders.orders))
+	}
+}
+
+func TestPunchOutOrderPriceAboveApproved(t *testing.T) {
+	accountSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/"+testCustomerID+"/relationships/"+testSellerID {
+			t.Errorf("unexpected call to account-service: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(account.TradingTerms{SellerID: testSellerID, CustomerID: testCustomerID, Status: account.RelationshipActive, Currency: "USD"})
+	}))
+	defer accountSrv.Close()
+	// The glasses approved at 24.90 now cost 24.95
+	catalogSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pricing/resolve" {
+			t.Errorf("unexpected call to catalog-service: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode([]catalog.Price{
+			{ProductID: "66f1a2b3c4d5e6f708192a3b", VariantID: "66f1a2b3c4d5e6f708192a3c", ListPrice: 24.95, Price: 24.95, Currency: "USD"},
+			{ProductID: "66f1a2b3c4d5e6f708192a40", ListPrice: 8.80, Price: 8.80, Currency: "USD"},
+		})
+	}))
+	defer catalogSrv.Close()
+	h, _, orders := newPunchOutHandler(t, testSecret, accountSrv, catalogSrv)
+
+	doc := postFixture(t, h, "/punchout/orders", "order-request.xml")
+	if doc.Response.Status.Code != punchout.StatusConflict {
+		t.Fatalf("status = %+v, want 409", doc.Response.Status)
+	}
+	if !strings.Contains(doc.Response.Status.Body, "line 1") || !strings.Contains(doc.Response.Status.Body, "24.95") {
+		t.Errorf("status body = %q, want it to name line 1 and its price", doc.Response.Status.Body)
+	}
+	if len(orders.orders) != 0 {
+		t.Errorf("%d orders placed, want none", len(orders.orders))
+	}
+}
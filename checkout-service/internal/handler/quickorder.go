@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/syed/businesscart/checkout-service/internal/cart"
+)
+
+// QuickOrderRequest represents the request body for adding items to the cart
+// by the customer's own part numbers.
+type QuickOrderRequest struct {
+	SellerID string `json:"sellerId"`
+	Lines    []struct {
+		PartNumber string `json:"partNumber"`
+		Quantity   int    `json:"quantity"`
+	} `json:"lines"`
+	AdjustQuantity bool   `json:"adjustQuantity"`
+	Currency       string `json:"currency"`
+}
+
+// handleQuickOrderRequest adds lines keyed by the customer's part numbers to
+// their cart with a seller, as a purchasing system would send them. Part
+// numbers that map to no product are refused with 422 before anything is
+// added.
+func (h *LambdaHandler) handleQuickOrderRequest(request events.APIGatewayProxyRequest, accountID string, associateCompanyIDs []string, sessionID string) (events.APIGatewayProxyResponse, error) {
+	var req QuickOrderRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return h.errorResponse(http.StatusBadRequest, "Invalid request body"), nil
+	}
+	if !containsString(associateCompanyIDs, req.SellerID) {
+		return h.errorResponse(http.StatusForbidden, "Forbidden"), nil
+	}
+	if len(req.Lines) == 0 {
+		return h.errorResponse(http.StatusBadRequest, "lines are required"), nil
+	}
+	var codes []string
+	for i, line := range req.Lines {
+		req.Lines[i].PartNumber = strings.TrimSpace(line.PartNumber)
+		if req.Lines[i].PartNumber == "" || line.Quantity < 1 {
+			return h.errorResponse(http.StatusBadRequest, "every line needs a partNumber and a quantity of at least 1"), nil
+		}
+		codes = append(codes, req.Lines[i].PartNumber)
+	}
+
+	authHeader := request.Headers["Authorization"]
+	partNumbers, err := h.catalogClient.GetPartNumbers(authHeader, req.SellerID, codes)
+	if err != nil {
+		log.Printf("Failed to look up part numbers: %v", err)
+		return h.errorResponse(http.StatusBadGateway, "Failed to look up part numbers"), nil
+	}
+	byCode := map[string]cart.CartItem{}
+	for _, pn := range partNumbers {
+		byCode[pn.PartNumber] = cart.CartItem{ProductID: pn.ProductID, VariantID: pn.VariantID, SellerID: req.SellerID}
+	}
+	unknown := []string{}
+	for _, line := range req.Lines {
+		if _, ok := byCode[line.PartNumber]; !ok && !containsString(unknown, line.PartNumber) {
+			unknown = append(unknown, line.PartNumber)
+		}
+	}
+	if len(unknown) > 0 {
+		return h.jsonResponse(http.StatusUnprocessableEntity, map[string]interface{}{"message": "Unknown part numbers", "partNumbers": unknown}), nil
+	}
+
+	var items []cart.CartItem
+	for _, line := range req.Lines {
+		item := byCode[line.PartNumber]
+		item.Quantity = line.Quantity
+		items = append(items, item)
+	}
+	return h.addItemsToCart(authHeader, accountID, req.SellerID, sessionID, req.Currency, items, req.AdjustQuantity), nil
+}
@@ -0,0 +1,48 @@
+package list
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	KindList  = "list"  // saved by a customer
+	KindGuide = "guide" // curated by the seller for chosen customers
+)
+
+// List is a named set of products a customer reorders from one seller: a
+// customer's saved list, or an order guide the seller pushes to customers.
+type List struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Kind     string             `bson:"kind" json:"kind"`
+	SellerID string             `bson:"sellerId" json:"sellerId"`
+	OwnerID  string             `bson:"ownerId" json:"ownerId"` // the customer, or the seller for a guide
+	Name     string             `bson:"name" json:"name"`
+	Items    []Item             `bson:"items" json:"items"`
+	// SharedWith names the other accounts that may use the list: members of
+	// the customer's organization, or the customers a guide is pushed to
+	SharedWith []string  `bson:"sharedWith,omitempty" json:"sharedWith,omitempty"`
+	CreatedAt  time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Item is a product on a list with the quantity usually ordered.
+type Item struct {
+	ProductID string `bson:"productId" json:"productId"`
+	VariantID string `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	Quantity  int    `bson:"quantity" json:"quantity"`
+}
+
+// Visible reports whether accountID may see and use the list.
+func (l *List) Visible(accountID string) bool {
+	if l.OwnerID == accountID {
+		return true
+	}
+	for _, id := range l.SharedWith {
+		if id == accountID {
+			return true
+		}
+	}
+	return false
+}
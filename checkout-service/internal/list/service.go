@@ -0,0 +1,89 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrNotFound = errors.New("list not found")
+
+type Service struct {
+	collection *mongo.Collection
+}
+
+func NewService(db *mongo.Database) *Service {
+	return &Service{collection: db.Collection("lists")}
+}
+
+func (s *Service) CreateList(l *List) error {
+	l.ID = primitive.NewObjectID()
+	l.CreatedAt = time.Now()
+	l.UpdatedAt = l.CreatedAt
+	_, err := s.collection.InsertOne(context.Background(), l)
+	return err
+}
+
+func (s *Service) GetList(id primitive.ObjectID) (*List, error) {
+	var l List
+	err := s.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&l)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// GetLists returns the lists an account owns or has been given, by name,
+// optionally for one seller only.
+func (s *Service) GetLists(accountID, sellerID string) ([]*List, error) {
+	filter := bson.M{"$or": bson.A{bson.M{"ownerId": accountID}, bson.M{"sharedWith": accountID}}}
+	if sellerID != "" {
+		filter["sellerId"] = sellerID
+	}
+	cursor, err := s.collection.Find(context.Background(), filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	lists := []*List{}
+	if err := cursor.All(context.Background(), &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// UpdateList replaces the name, items and sharing of a list.
+func (s *Service) UpdateList(l *List) error {
+	l.UpdatedAt = time.Now()
+	res, err := s.collection.UpdateOne(context.Background(),
+		bson.M{"_id": l.ID},
+		bson.M{"$set": bson.M{"name": l.Name, "items": l.Items, "sharedWith": l.SharedWith, "updatedAt": l.UpdatedAt}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Service) DeleteList(id primitive.ObjectID) error {
+	res, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
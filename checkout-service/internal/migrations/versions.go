@@ -0,0 +1,144 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrations lists every migration of the service. Append new entries with
+// the next version number; never renumber or edit an applied one.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "reshape legacy documents: userId -> accountId, companyId -> sellerId",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range []string{"carts", "quotes", "orders"} {
+				if err := renameLegacyOwnerFields(ctx, db.Collection(name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "one cart per account and seller",
+		Up: createIndexes("carts",
+			mongo.IndexModel{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "sellerId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		),
+	},
+	{
+		Version:     3,
+		Description: "quotes TTL on expiresAt",
+		Up: createIndexes("quotes",
+			mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			mongo.IndexModel{Keys: bson.D{{Key: "accountId", Value: 1}}},
+		),
+	},
+	{
+		Version:     4,
+		Description: "orders by customer, by seller and outstanding credit",
+		Up: createIndexes("orders",
+			mongo.IndexModel{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "createdAt", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerId", Value: 1}, {Key: "createdAt", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "sellerId", Value: 1}, {Key: "paymentStatus", Value: 1}}},
+		),
+	},
+	{
+		Version:     5,
+		Description: "quotes by account and seller, to release replaced quotes",
+		Up: createIndexes("quotes",
+			mongo.IndexModel{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "sellerId", Value: 1}}},
+		),
+	},
+	{
+		Version:     6,
+		Description: "saved lists and order guides by owner and by account shared with",
+		Up: createIndexes("lists",
+			mongo.IndexModel{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "sellerId", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "sharedWith", Value: 1}, {Key: "sellerId", Value: 1}}},
+		),
+	},
+	{
+		Version:     7,
+		Description: "punchout buyers by credential and by seller",
+		Up: createIndexes("punchoutbuyers",
+			mongo.IndexModel{Keys: bson.D{{Key: "domain", Value: 1}, {Key: "identity", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "sellerId", Value: 1}}},
+		),
+	},
+	{
+		Version:     8,
+		Description: "punchout sessions by start token, TTL on expiresAt",
+		Up: createIndexes("punchoutsessions",
+			mongo.IndexModel{Keys: bson.D{{Key: "startHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		),
+	},
+	{
+		Version:     9,
+		Description: "one order per customer purchase order number",
+		Up: createIndexes("orders",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "accountId", Value: 1}, {Key: "sellerId", Value: 1}, {Key: "purchaseOrder", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"purchaseOrder": bson.M{"$type": "string"}}),
+			},
+		),
+	},
+	{
+		Version:     10,
+		Description: "one cart per account, seller and PunchOut session, TTL on session carts",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndex(ctx, db.Collection("carts"), "accountId_1_sellerId_1"); err != nil {
+				return err
+			}
+			// Carts without a session index sessionId as null, so each
+			// account still has one cart of its own per seller
+			return createIndexes("carts",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "accountId", Value: 1}, {Key: "sellerId", Value: 1}, {Key: "sessionId", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			)(ctx, db)
+		},
+	},
+}
+
+// renameLegacyOwnerFields moves documents written with the PRD field names
+// (userId/companyId) to the names the service reads (accountId/sellerId),
+// including the companyId of each line item.
+func renameLegacyOwnerFields(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.UpdateMany(ctx,
+		bson.M{"accountId": bson.M{"$exists": false}, "userId": bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{"userId": "accountId"}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpdateMany(ctx,
+		bson.M{"sellerId": bson.M{"$exists": false}, "companyId": bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{"companyId": "sellerId"}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpdateMany(ctx,
+		bson.M{"items.companyId": bson.M{"$exists": true}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"items": bson.M{"$map": bson.M{
+				"input": "$items",
+				"as":    "item",
+				"in": bson.M{"$mergeObjects": bson.A{
+					"$$item",
+					bson.M{"sellerId": bson.M{"$ifNull": bson.A{"$$item.sellerId", "$$item.companyId"}}},
+				}},
+			}}}}},
+			{{Key: "$unset", Value: "items.companyId"}},
+		},
+	)
+	return err
+}
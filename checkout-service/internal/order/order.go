@@ -3,21 +3,50 @@ package order
 import (
 	"time"
 
+	"github.com/syed/businesscart/checkout-service/internal/account"
 	"github.com/syed/businesscart/checkout-service/internal/cart"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Order struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	QuoteID       primitive.ObjectID `bson:"quoteId" json:"quoteId"`
-	AccountID     string             `bson:"accountId" json:"accountId"`
-	SellerID      string             `bson:"sellerId" json:"sellerId"`
-	Items         []cart.CartItem    `bson:"items" json:"items"`
-	Subtotal      float64            `bson:"subtotal" json:"subtotal"`
-	ShippingCost  float64            `bson:"shippingCost" json:"shippingCost"`
-	TaxAmount     float64            `bson:"taxAmount" json:"taxAmount"`
-	GrandTotal    float64            `bson:"grandTotal" json:"grandTotal"`
-	PaymentMethod string             `bson:"paymentMethod" json:"paymentMethod"`
-	TransactionID string             `bson:"transactionId" json:"transactionId"`
-	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	QuoteID        primitive.ObjectID `bson:"quoteId" json:"quoteId"`
+	AccountID      string             `bson:"accountId" json:"accountId"`
+	SellerID       string             `bson:"sellerId" json:"sellerId"`
+	Items          []cart.CartItem    `bson:"items" json:"items"`
+	Subtotal       float64            `bson:"subtotal" json:"subtotal"`
+	DiscountAmount float64            `bson:"discountAmount,omitempty" json:"discountAmount,omitempty"`
+	ShippingCost   float64            `bson:"shippingCost" json:"shippingCost"`
+	TaxAmount      float64            `bson:"taxAmount" json:"taxAmount"`
+	GrandTotal     float64            `bson:"grandTotal" json:"grandTotal"`
+	Currency       string             `bson:"currency,omitempty" json:"currency,omitempty"`
+	SellerTotal    float64            `bson:"sellerTotal,omitempty" json:"sellerTotal,omitempty"` // GrandTotal in SellerCurrency, held against credit
+	SellerCurrency string             `bson:"sellerCurrency,omitempty" json:"sellerCurrency,omitempty"`
+	ShippingMethod string             `bson:"shippingMethod,omitempty" json:"shippingMethod,omitempty"`
+	ShipTo         *account.Address   `bson:"shipTo,omitempty" json:"shipTo,omitempty"`
+	SalesRep       string             `bson:"salesRep,omitempty" json:"salesRep,omitempty"`
+	PaymentMethod  string             `bson:"paymentMethod" json:"paymentMethod"`
+	TransactionID  string             `bson:"transactionId" json:"transactionId"`
+	PaymentStatus  string             `bson:"paymentStatus,omitempty" json:"paymentStatus,omitempty"`
+	DueAt          *time.Time         `bson:"dueAt,omitempty" json:"dueAt,omitempty"`
+	PaidAt         *time.Time         `bson:"paidAt,omitempty" json:"paidAt,omitempty"`
+	CreditHold     bool               `bson:"creditHold,omitempty" json:"creditHold,omitempty"`
+	PurchaseOrder  string             `bson:"purchaseOrder,omitempty" json:"purchaseOrder,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+
+	// ExchangeRates the lines were converted at, as quoted
+	ExchangeRates []cart.ExchangeRate `bson:"exchangeRates,omitempty" json:"exchangeRates,omitempty"`
+
+	// Fulfilment is what to pick and ship, with bundle lines expanded into
+	// their components
+	Fulfilment []cart.FulfilmentLine `bson:"fulfilment,omitempty" json:"fulfilment,omitempty"`
 }
+
+// PaymentMethodOnAccount places an order against the customer's credit with
+// the seller instead of charging a payment gateway.
+const PaymentMethodOnAccount = "on_account"
+
+const (
+	PaymentStatusPaid   = "paid"
+	PaymentStatusUnpaid = "unpaid"
+)
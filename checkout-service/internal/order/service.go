@@ -2,12 +2,18 @@ package order
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ErrPurchaseOrderExists is returned by CreateOrder when the customer already
+// placed an order with the seller under the same purchase order number.
+var ErrPurchaseOrderExists = errors.New("an order was already placed for this purchase order")
+
 type Service struct {
 	collection      *mongo.Collection
 	usersCollection *mongo.Collection
@@ -23,6 +29,9 @@ func NewService(db *mongo.Database) *Service {
 func (s *Service) CreateOrder(order *Order) (*Order, error) {
 	order.CreatedAt = time.Now()
 	_, err := s.collection.InsertOne(context.Background(), order)
+	if order.PurchaseOrder != "" && mongo.IsDuplicateKeyError(err) {
+		return nil, ErrPurchaseOrderExists
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -56,3 +65,91 @@ func (s *Service) GetOrders(userId string, role string, companyId string) ([]*Or
 	}
 	return orders, nil
 }
+
+func (s *Service) GetOrder(orderID primitive.ObjectID) (*Order, error) {
+	var order Order
+	err := s.collection.FindOne(context.Background(), bson.M{"_id": orderID}).Decode(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// OutstandingBalance sums the unpaid on-account orders a customer holds with a
+// seller, in the seller's currency. Open orders and unpaid invoices both
+// consume available credit. Orders from before SellerTotal was kept count
+// their GrandTotal.
+func (s *Service) OutstandingBalance(accountID, sellerID string) (float64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"accountId":     accountID,
+			"sellerId":      sellerID,
+			"paymentMethod": PaymentMethodOnAccount,
+			"paymentStatus": PaymentStatusUnpaid,
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$sellerTotal", "$grandTotal"}}}}}},
+	}
+	cursor, err := s.collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	var result []struct {
+		Total float64 `bson:"total"`
+	}
+	if err := cursor.All(context.Background(), &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Total, nil
+}
+
+// MarkPaid records payment of an on-account order, releasing its credit.
+func (s *Service) MarkPaid(orderID primitive.ObjectID, transactionID string) error {
+	now := time.Now()
+	_, err := s.collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": orderID},
+		bson.M{"$set": bson.M{
+			"paymentStatus": PaymentStatusPaid,
+			"transactionId": transactionID,
+			"paidAt":        now,
+			"creditHold":    false,
+		}},
+	)
+	return err
+}
+
+// HoldCredit puts an order on credit hold for the seller to release.
+func (s *Service) HoldCredit(orderID primitive.ObjectID) error {
+	_, err := s.collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": orderID},
+		bson.M{"$set": bson.M{"creditHold": true}},
+	)
+	return err
+}
+
+// DeleteOrder removes an order that was rolled back before anyone saw it.
+func (s *Service) DeleteOrder(orderID primitive.ObjectID) error {
+	_, err := s.collection.DeleteOne(context.Background(), bson.M{"_id": orderID})
+	return err
+}
+
+// GetOrderByPurchaseOrder finds the order a customer placed with a seller
+// under a purchase order number, or returns mongo.ErrNoDocuments.
+func (s *Service) GetOrderByPurchaseOrder(accountID, sellerID, purchaseOrder string) (*Order, error) {
+	var order Order
+	err := s.collection.FindOne(context.Background(), bson.M{
+		"accountId":     accountID,
+		"sellerId":      sellerID,
+		"purchaseOrder": purchaseOrder,
+	}).Decode(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
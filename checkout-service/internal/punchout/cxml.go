@@ -0,0 +1,252 @@
+// Package punchout implements cXML PunchOut for procurement systems: buyers
+// authenticated by shared secret shop the catalog in a session, get their
+// cart back as a PunchOutOrderMessage and send orders as OrderRequests.
+package punchout
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Version is the cXML version of the documents the service writes.
+const Version = "1.2.014"
+
+const doctype = `<!DOCTYPE cXML SYSTEM "http://xml.cxml.org/schemas/cXML/` + Version + `/cXML.dtd">`
+
+// cXML status codes the service answers with.
+const (
+	StatusOK           = 200
+	StatusBadRequest   = 400
+	StatusUnauthorized = 401
+	StatusPayment      = 402
+	StatusForbidden    = 403
+	StatusConflict     = 409
+	StatusInternal     = 500
+	StatusTemporary    = 560 // the request may be retried later
+)
+
+// CXML is a cXML envelope: a request with its header, a response, or a
+// message.
+type CXML struct {
+	XMLName   xml.Name  `xml:"cXML"`
+	PayloadID string    `xml:"payloadID,attr"`
+	Timestamp string    `xml:"timestamp,attr"`
+	Lang      string    `xml:"xml:lang,attr,omitempty"`
+	Header    *Header   `xml:"Header,omitempty"`
+	Request   *Request  `xml:"Request,omitempty"`
+	Response  *Response `xml:"Response,omitempty"`
+	Message   *Message  `xml:"Message,omitempty"`
+}
+
+type Header struct {
+	From   Party  `xml:"From"`
+	To     Party  `xml:"To"`
+	Sender Sender `xml:"Sender"`
+}
+
+type Party struct {
+	Credentials []Credential `xml:"Credential"`
+}
+
+type Sender struct {
+	Credential Credential `xml:"Credential"`
+	UserAgent  string     `xml:"UserAgent"`
+}
+
+// Credential identifies a party within a domain such as NetworkID or DUNS.
+// The sender's carries the shared secret.
+type Credential struct {
+	Domain       string `xml:"domain,attr"`
+	Identity     string `xml:"Identity"`
+	SharedSecret string `xml:"SharedSecret,omitempty"`
+}
+
+type Request struct {
+	DeploymentMode       string                `xml:"deploymentMode,attr,omitempty"`
+	PunchOutSetupRequest *PunchOutSetupRequest `xml:"PunchOutSetupRequest,omitempty"`
+	OrderRequest         *OrderRequest         `xml:"OrderRequest,omitempty"`
+}
+
+// Setup operations. Edit and inspect reopen a cart returned earlier, sent
+// back as ItemOut lines.
+const (
+	OperationCreate  = "create"
+	OperationEdit    = "edit"
+	OperationInspect = "inspect"
+)
+
+type PunchOutSetupRequest struct {
+	Operation       string      `xml:"operation,attr"`
+	BuyerCookie     string      `xml:"BuyerCookie"`
+	Extrinsics      []Extrinsic `xml:"Extrinsic"`
+	BrowserFormPost struct {
+		URL string `xml:"URL"`
+	} `xml:"BrowserFormPost"`
+	ShipTo  *ShipTo   `xml:"ShipTo,omitempty"`
+	ItemOut []ItemOut `xml:"ItemOut"`
+}
+
+type Extrinsic struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type OrderRequest struct {
+	Header  OrderRequestHeader `xml:"OrderRequestHeader"`
+	ItemOut []ItemOut          `xml:"ItemOut"`
+}
+
+type OrderRequestHeader struct {
+	OrderID   string  `xml:"orderID,attr"`
+	OrderDate string  `xml:"orderDate,attr"`
+	Type      string  `xml:"type,attr"` // new | update | delete
+	Total     Total   `xml:"Total"`
+	ShipTo    *ShipTo `xml:"ShipTo,omitempty"`
+}
+
+type ShipTo struct {
+	Address Address `xml:"Address"`
+}
+
+type Address struct {
+	Name          string        `xml:"Name"`
+	PostalAddress PostalAddress `xml:"PostalAddress"`
+}
+
+type PostalAddress struct {
+	Streets    []string `xml:"Street"`
+	City       string   `xml:"City"`
+	State      string   `xml:"State"`
+	PostalCode string   `xml:"PostalCode"`
+	Country    struct {
+		ISOCountryCode string `xml:"isoCountryCode,attr"`
+		Name           string `xml:",chardata"`
+	} `xml:"Country"`
+}
+
+// ItemOut is a line the buyer sends: an order line, or a line of a cart
+// being edited.
+type ItemOut struct {
+	Quantity   string     `xml:"quantity,attr"`
+	LineNumber string     `xml:"lineNumber,attr,omitempty"`
+	ItemID     ItemID     `xml:"ItemID"`
+	ItemDetail ItemDetail `xml:"ItemDetail"`
+}
+
+// ItemIn is a line the service sends back in a PunchOutOrderMessage.
+type ItemIn struct {
+	Quantity   string     `xml:"quantity,attr"`
+	ItemID     ItemID     `xml:"ItemID"`
+	ItemDetail ItemDetail `xml:"ItemDetail"`
+}
+
+// ItemID identifies an item: SupplierPartID is the product ID and
+// SupplierPartAuxiliaryID the variant ID. BuyerPartID is the customer's own
+// part number, when they have one.
+type ItemID struct {
+	SupplierPartID          string `xml:"SupplierPartID"`
+	SupplierPartAuxiliaryID string `xml:"SupplierPartAuxiliaryID,omitempty"`
+	BuyerPartID             string `xml:"BuyerPartID,omitempty"`
+}
+
+type ItemDetail struct {
+	UnitPrice     Money  `xml:"UnitPrice>Money"`
+	Description   string `xml:"Description"`
+	UnitOfMeasure string `xml:"UnitOfMeasure"`
+}
+
+type Total struct {
+	Money Money `xml:"Money"`
+}
+
+type Money struct {
+	Currency string `xml:"currency,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type Response struct {
+	Status                Status                 `xml:"Status"`
+	PunchOutSetupResponse *PunchOutSetupResponse `xml:"PunchOutSetupResponse,omitempty"`
+}
+
+type Status struct {
+	Code int    `xml:"code,attr"`
+	Text string `xml:"text,attr"`
+	Body string `xml:",chardata"`
+}
+
+type PunchOutSetupResponse struct {
+	StartPage struct {
+		URL string `xml:"URL"`
+	} `xml:"StartPage"`
+}
+
+type Message struct {
+	PunchOutOrderMessage *PunchOutOrderMessage `xml:"PunchOutOrderMessage,omitempty"`
+}
+
+type PunchOutOrderMessage struct {
+	BuyerCookie string `xml:"BuyerCookie"`
+	Header      struct {
+		OperationAllowed string `xml:"operationAllowed,attr"`
+		Total            Total  `xml:"Total"`
+	} `xml:"PunchOutOrderMessageHeader"`
+	ItemIn []ItemIn `xml:"ItemIn"`
+}
+
+// Parse reads a cXML document.
+func Parse(body []byte) (*CXML, error) {
+	var doc CXML
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid cXML: %w", err)
+	}
+	return &doc, nil
+}
+
+// Marshal writes doc with the XML declaration and cXML doctype.
+func Marshal(doc *CXML) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(xml.Header + doctype + "\n" + string(body)), nil
+}
+
+// New returns an empty envelope with a fresh payload ID and timestamp.
+func New() *CXML {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	now := time.Now()
+	return &CXML{
+		PayloadID: fmt.Sprintf("%d.%s@businesscart", now.UnixNano(), hex.EncodeToString(b)),
+		Timestamp: now.Format(time.RFC3339),
+		Lang:      "en-US",
+	}
+}
+
+// NewResponse returns a response with the given status.
+func NewResponse(code int, text string) *CXML {
+	doc := New()
+	doc.Response = &Response{Status: Status{Code: code, Text: text}}
+	return doc
+}
+
+// WholeQuantity parses an ItemOut quantity, which cXML allows to be fractional.
+// Only whole quantities of at least one can be ordered.
+func (i ItemOut) WholeQuantity() (int, bool) {
+	q, err := strconv.ParseFloat(i.Quantity, 64)
+	if err != nil || q < 1 || q != float64(int(q)) {
+		return 0, false
+	}
+	return int(q), true
+}
+
+// FormatMoney writes an amount the way cXML expects it, with the decimal
+// places of its currency.
+func FormatMoney(amount float64, decimals int) string {
+	return strconv.FormatFloat(amount, 'f', decimals, 64)
+}
@@ -0,0 +1,213 @@
+package punchout
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func parseFixture(t *testing.T, name string) *CXML {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Parse(body)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", name, err)
+	}
+	return doc
+}
+
+func checkCredentials(t *testing.T, doc *CXML) {
+	t.Helper()
+	if doc.Header == nil {
+		t.Fatal("no Header")
+	}
+	want := []Credential{{Domain: "NetworkID", Identity: "AN01000000147"}}
+	if !reflect.DeepEqual(doc.Header.From.Credentials, want) {
+		t.Errorf("From = %+v, want %+v", doc.Header.From.Credentials, want)
+	}
+	if got := doc.Header.Sender.Credential; got.Domain != "NetworkID" || got.Identity != "AN01000000147" || got.SharedSecret != "acme-punchout-secret" {
+		t.Errorf("Sender = %+v, want the NetworkID credential with its shared secret", got)
+	}
+}
+
+func quantities(t *testing.T, lines []ItemOut) []int {
+	t.Helper()
+	var out []int
+	for _, line := range lines {
+		q, ok := line.WholeQuantity()
+		if !ok {
+			t.Errorf("ItemOut %s: quantity %q is not whole", line.ItemID.SupplierPartID, line.Quantity)
+		}
+		out = append(out, q)
+	}
+	return out
+}
+
+func TestParseSetupRequest(t *testing.T) {
+	doc := parseFixture(t, "punchout-setup-request.xml")
+	checkCredentials(t, doc)
+
+	if doc.Request == nil || doc.Request.PunchOutSetupRequest == nil {
+		t.Fatal("no PunchOutSetupRequest")
+	}
+	setup := doc.Request.PunchOutSetupRequest
+	if setup.Operation != OperationCreate {
+		t.Errorf("operation = %q, want %q", setup.Operation, OperationCreate)
+	}
+	if setup.BuyerCookie != "34234234ADFSDF234234" {
+		t.Errorf("BuyerCookie = %q", setup.BuyerCookie)
+	}
+	if setup.BrowserFormPost.URL != "https://procure.acme.example/punchout/return?session=9f3c" {
+		t.Errorf("BrowserFormPost URL = %q", setup.BrowserFormPost.URL)
+	}
+	if len(setup.ItemOut) != 0 {
+		t.Errorf("create setup has %d ItemOut lines, want none", len(setup.ItemOut))
+	}
+	if setup.ShipTo == nil || setup.ShipTo.Address.PostalAddress.City != "Springfield" {
+		t.Errorf("ShipTo = %+v, want the Springfield address", setup.ShipTo)
+	}
+}
+
+func TestParseSetupEditRequest(t *testing.T) {
+	doc := parseFixture(t, "punchout-setup-edit-request.xml")
+	checkCredentials(t, doc)
+
+	if doc.Request == nil || doc.Request.PunchOutSetupRequest == nil {
+		t.Fatal("no PunchOutSetupRequest")
+	}
+	setup := doc.Request.PunchOutSetupRequest
+	if setup.Operation != OperationEdit {
+		t.Errorf("operation = %q, want %q", setup.Operation, OperationEdit)
+	}
+	if setup.BuyerCookie != "34234234ADFSDF234235" {
+		t.Errorf("BuyerCookie = %q", setup.BuyerCookie)
+	}
+	if got := quantities(t, setup.ItemOut); !reflect.DeepEqual(got, []int{4, 10}) {
+		t.Errorf("ItemOut quantities = %v, want [4 10]", got)
+	}
+	wantIDs := []ItemID{
+		{SupplierPartID: "66f1a2b3c4d5e6f708192a3b", SupplierPartAuxiliaryID: "66f1a2b3c4d5e6f708192a3c"},
+		{SupplierPartID: "66f1a2b3c4d5e6f708192a40", BuyerPartID: "ACME-GLV-100"},
+	}
+	for i, want := range wantIDs {
+		if got := setup.ItemOut[i].ItemID; got != want {
+			t.Errorf("ItemOut %d ItemID = %+v, want %+v", i+1, got, want)
+		}
+	}
+}
+
+func TestParseOrderRequest(t *testing.T) {
+	doc := parseFixture(t, "order-request.xml")
+	checkCredentials(t, doc)
+
+	if doc.Request == nil || doc.Request.OrderRequest == nil {
+		t.Fatal("no OrderRequest")
+	}
+	order := doc.Request.OrderRequest
+	header := order.Header
+	if header.OrderID != "PO-2026-004417" || header.Type != "new" || header.OrderDate != "2026-10-20T10:39:12-05:00" {
+		t.Errorf("OrderRequestHeader = %+v", header)
+	}
+	if header.Total.Money != (Money{Currency: "USD", Value: "187.60"}) {
+		t.Errorf("Total = %+v, want USD 187.60", header.Total.Money)
+	}
+	if header.ShipTo == nil {
+		t.Fatal("no ShipTo")
+	}
+	postal := header.ShipTo.Address.PostalAddress
+	if !reflect.DeepEqual(postal.Streets, []string{"1 Industrial Way", "Dock 4"}) || postal.City != "Springfield" || postal.State != "IL" || postal.PostalCode != "62701" || postal.Country.ISOCountryCode != "US" {
+		t.Errorf("PostalAddress = %+v", postal)
+	}
+
+	if got := quantities(t, order.ItemOut); !reflect.DeepEqual(got, []int{4, 10}) {
+		t.Errorf("ItemOut quantities = %v, want [4 10]", got)
+	}
+	var prices []Money
+	for _, line := range order.ItemOut {
+		prices = append(prices, line.ItemDetail.UnitPrice)
+	}
+	if want := []Money{{Currency: "USD", Value: "24.90"}, {Currency: "USD", Value: "8.80"}}; !reflect.DeepEqual(prices, want) {
+		t.Errorf("unit prices = %+v, want %+v", prices, want)
+	}
+}
+
+func TestWholeQuantity(t *testing.T) {
+	for quantity, want := range map[string]int{"1": 1, "12": 12, "3.0": 3, "2.5": 0, "0": 0, "-1": 0, "": 0, "ten": 0} {
+		got, ok := ItemOut{Quantity: quantity}.WholeQuantity()
+		if got != want || ok != (want > 0) {
+			t.Errorf("WholeQuantity(%q) = %d, %v, want %d", quantity, got, ok, want)
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	doc := NewResponse(StatusConflict, "Conflict")
+	doc.Response.Status.Body = "line 1: price changed"
+	body, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Response == nil || parsed.Response.Status != doc.Response.Status {
+		t.Errorf("status after round trip = %+v, want %+v", parsed.Response, doc.Response.Status)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	buyer := &Buyer{SellerID: "seller", CustomerID: "customer", Domain: "networkid", Identity: "AN01000000147"}
+	buyer.SetSecret("acme-punchout-secret")
+	find := func(domain, identity string) (*Buyer, error) {
+		if domain == buyer.Domain && identity == buyer.Identity {
+			return buyer, nil
+		}
+		return nil, nil
+	}
+
+	header := parseFixture(t, "punchout-setup-request.xml").Header
+	if got, err := Authenticate(header, find); err != nil || got != buyer {
+		t.Errorf("Authenticate() = %v, %v, want the buyer", got, err)
+	}
+
+	header.Sender.Credential.SharedSecret = "wrong-secret"
+	if _, err := Authenticate(header, find); err != ErrUnauthorized {
+		t.Errorf("wrong secret: error = %v, want ErrUnauthorized", err)
+	}
+
+	header.From.Credentials[0].Identity = "AN00000000000"
+	header.Sender.Credential.SharedSecret = "acme-punchout-secret"
+	if _, err := Authenticate(header, find); err != ErrUnauthorized {
+		t.Errorf("unknown credential: error = %v, want ErrUnauthorized", err)
+	}
+
+	if _, err := Authenticate(nil, find); err != ErrUnauthorized {
+		t.Errorf("no header: error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	var salted, other Buyer
+	salted.SetSecret("acme-punchout-secret")
+	other.SetSecret("acme-punchout-secret")
+	if salted.SecretHash == other.SecretHash {
+		t.Error("two buyers with the same secret have the same hash")
+	}
+	legacy := Buyer{SecretHash: hashToken("acme-punchout-secret")}
+	for name, b := range map[string]*Buyer{"salted": &salted, "legacy": &legacy} {
+		if !b.CheckSecret("acme-punchout-secret") {
+			t.Errorf("%s: the right secret is rejected", name)
+		}
+		if b.CheckSecret("wrong-secret") {
+			t.Errorf("%s: a wrong secret is accepted", name)
+		}
+	}
+	if salted.LegacySecret() || !legacy.LegacySecret() {
+		t.Errorf("LegacySecret() = %v, %v, want false for the salted hash and true for the legacy one", salted.LegacySecret(), legacy.LegacySecret())
+	}
+}
@@ -0,0 +1,152 @@
+package punchout
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/scrypt"
+)
+
+// SessionTTL is how long a buyer has to shop and return the cart after
+// PunchOut setup.
+const SessionTTL = 2 * time.Hour
+
+// Buyer is a procurement system a seller lets punch out on behalf of one of
+// its customers. It is recognised by a From credential and authenticated by
+// the shared secret of the Sender credential.
+type Buyer struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SellerID   string             `bson:"sellerId" json:"sellerId"`
+	CustomerID string             `bson:"customerId" json:"customerId"`
+	Name       string             `bson:"name,omitempty" json:"name,omitempty"`
+	Domain     string             `bson:"domain" json:"domain"` // e.g. NetworkID or DUNS, stored lower-case
+	Identity   string             `bson:"identity" json:"identity"`
+	SecretHash string             `bson:"secretHash" json:"-"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// SetSecret stores a salted scrypt hash of the shared secret; the secret
+// itself is never kept.
+func (b *Buyer) SetSecret(secret string) {
+	salt := make([]byte, 16)
+	_, _ = rand.Read(salt)
+	b.SecretHash = secretPrefix + hex.EncodeToString(salt) + "$" + hex.EncodeToString(deriveSecret(secret, salt))
+}
+
+// CheckSecret reports whether secret is the buyer's shared secret. Buyers
+// registered before secrets were salted still hold a plain SHA-256 hash.
+func (b *Buyer) CheckSecret(secret string) bool {
+	encoded, ok := strings.CutPrefix(b.SecretHash, secretPrefix)
+	if !ok {
+		return subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(b.SecretHash)) == 1
+	}
+	saltHex, keyHex, ok := strings.Cut(encoded, "$")
+	if !ok {
+		return false
+	}
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return false
+	}
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(deriveSecret(secret, salt), key) == 1
+}
+
+// LegacySecret reports whether the buyer's secret still has an unsalted
+// hash, to be replaced once the buyer next authenticates.
+func (b *Buyer) LegacySecret() bool {
+	return !strings.HasPrefix(b.SecretHash, secretPrefix)
+}
+
+// secretPrefix marks a SecretHash of the form scrypt$<salt>$<key>, in hex.
+const secretPrefix = "scrypt$"
+
+func deriveSecret(secret string, salt []byte) []byte {
+	// The parameters are constant and valid, so scrypt cannot fail
+	key, _ := scrypt.Key([]byte(secret), salt, 1<<15, 8, 1, 32)
+	return key
+}
+
+// Authenticate finds the buyer named by one of the From credentials of a
+// cXML header with find, which looks a buyer up by normalized domain and
+// identity and returns nil for unknown ones, and checks the Sender's shared
+// secret against it.
+func Authenticate(h *Header, find func(domain, identity string) (*Buyer, error)) (*Buyer, error) {
+	if h == nil {
+		return nil, ErrUnauthorized
+	}
+	for _, c := range h.From.Credentials {
+		b, err := find(NormalizeDomain(c.Domain), c.Identity)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+		if !b.CheckSecret(h.Sender.Credential.SharedSecret) {
+			return nil, ErrUnauthorized
+		}
+		return b, nil
+	}
+	return nil, ErrUnauthorized
+}
+
+// Session statuses.
+const (
+	SessionOpen     = "open"     // set up, waiting for the buyer's browser
+	SessionStarted  = "started"  // the buyer is shopping
+	SessionReturned = "returned" // the cart went back to the procurement system
+)
+
+// Session is one PunchOut visit, from setup until the cart is returned.
+type Session struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	BuyerID     primitive.ObjectID `bson:"buyerId" json:"buyerId"`
+	SellerID    string             `bson:"sellerId" json:"sellerId"`
+	CustomerID  string             `bson:"customerId" json:"customerId"`
+	Operation   string             `bson:"operation" json:"operation"`
+	BuyerCookie string             `bson:"buyerCookie" json:"-"`
+	// BrowserFormPostURL is where the buyer's browser posts the cart back
+	BrowserFormPostURL string `bson:"browserFormPostUrl" json:"browserFormPostUrl"`
+	// Items of a cart reopened for edit or inspect, loaded when shopping starts
+	Items      []SessionItem `bson:"items,omitempty" json:"items,omitempty"`
+	StartHash  string        `bson:"startHash" json:"-"` // hash of the one-time start token
+	Status     string        `bson:"status" json:"status"`
+	CreatedAt  time.Time     `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  time.Time     `bson:"expiresAt" json:"expiresAt"`
+	ReturnedAt *time.Time    `bson:"returnedAt,omitempty" json:"returnedAt,omitempty"`
+}
+
+type SessionItem struct {
+	ProductID string `bson:"productId" json:"productId"`
+	VariantID string `bson:"variantId,omitempty" json:"variantId,omitempty"`
+	Quantity  int    `bson:"quantity" json:"quantity"`
+}
+
+// NewStartToken returns a random one-time token for a session's start page,
+// and the hash stored in its place.
+func NewStartToken() (token, hash string) {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	token = hex.EncodeToString(b)
+	return token, hashToken(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeDomain folds a credential domain, which cXML compares
+// case-insensitively.
+func NormalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSpace(domain))
+}
@@ -0,0 +1,150 @@
+package punchout
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrUnauthorized    = errors.New("unknown buyer or wrong shared secret")
+	ErrBuyerExists     = errors.New("credential already registered")
+	ErrSessionNotFound = errors.New("punchout session not found")
+)
+
+type Service struct {
+	buyers   *mongo.Collection
+	sessions *mongo.Collection
+}
+
+func NewService(db *mongo.Database) *Service {
+	return &Service{
+		buyers:   db.Collection("punchoutbuyers"),
+		sessions: db.Collection("punchoutsessions"),
+	}
+}
+
+func (s *Service) CreateBuyer(b *Buyer) error {
+	b.ID = primitive.NewObjectID()
+	b.Domain = NormalizeDomain(b.Domain)
+	b.CreatedAt = time.Now()
+	_, err := s.buyers.InsertOne(context.Background(), b)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrBuyerExists
+	}
+	return err
+}
+
+func (s *Service) GetBuyers(sellerID string) ([]*Buyer, error) {
+	cursor, err := s.buyers.Find(context.Background(), bson.M{"sellerId": sellerID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	buyers := []*Buyer{}
+	if err := cursor.All(context.Background(), &buyers); err != nil {
+		return nil, err
+	}
+	return buyers, nil
+}
+
+func (s *Service) GetBuyer(id primitive.ObjectID) (*Buyer, error) {
+	var b Buyer
+	if err := s.buyers.FindOne(context.Background(), bson.M{"_id": id}).Decode(&b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *Service) DeleteBuyer(id primitive.ObjectID) error {
+	_, err := s.buyers.DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
+
+// Authenticate finds the buyer named by one of the From credentials of a
+// cXML header and checks the Sender's shared secret against it. A secret
+// still kept under an unsalted hash is rehashed on the way.
+func (s *Service) Authenticate(h *Header) (*Buyer, error) {
+	b, err := Authenticate(h, func(domain, identity string) (*Buyer, error) {
+		var b Buyer
+		err := s.buyers.FindOne(context.Background(), bson.M{"domain": domain, "identity": identity}).Decode(&b)
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &b, nil
+	})
+	if err != nil || !b.LegacySecret() {
+		return b, err
+	}
+	b.SetSecret(h.Sender.Credential.SharedSecret)
+	if _, err := s.buyers.UpdateOne(context.Background(), bson.M{"_id": b.ID}, bson.M{"$set": bson.M{"secretHash": b.SecretHash}}); err != nil {
+		log.Printf("Failed to rehash the shared secret of punchout buyer %s: %v", b.ID.Hex(), err)
+	}
+	return b, nil
+}
+
+func (s *Service) CreateSession(session *Session) error {
+	session.ID = primitive.NewObjectID()
+	session.Status = SessionOpen
+	session.CreatedAt = time.Now()
+	session.ExpiresAt = session.CreatedAt.Add(SessionTTL)
+	_, err := s.sessions.InsertOne(context.Background(), session)
+	return err
+}
+
+func (s *Service) GetSession(id primitive.ObjectID) (*Session, error) {
+	var session Session
+	err := s.sessions.FindOne(context.Background(), bson.M{"_id": id}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// StartSession redeems the one-time start token of an open, unexpired
+// session and marks it started.
+func (s *Service) StartSession(token string) (*Session, error) {
+	var session Session
+	err := s.sessions.FindOneAndUpdate(context.Background(),
+		bson.M{"startHash": hashToken(token), "status": SessionOpen, "expiresAt": bson.M{"$gt": time.Now()}},
+		bson.M{"$set": bson.M{"status": SessionStarted}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ReturnSession marks a started session returned. It fails with
+// ErrSessionNotFound when the session is not being shopped.
+func (s *Service) ReturnSession(id primitive.ObjectID) error {
+	now := time.Now()
+	res, err := s.sessions.UpdateOne(context.Background(),
+		bson.M{"_id": id, "status": SessionStarted},
+		bson.M{"$set": bson.M{"status": SessionReturned, "returnedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
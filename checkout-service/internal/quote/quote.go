@@ -3,6 +3,7 @@ package quote
 import (
 	"time"
 
+	"github.com/syed/businesscart/checkout-service/internal/account"
 	"github.com/syed/businesscart/checkout-service/internal/cart"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -17,6 +18,32 @@ type Quote struct {
 	ShippingCost float64            `bson:"shippingCost" json:"shippingCost"`
 	TaxAmount    float64            `bson:"taxAmount" json:"taxAmount"`
 	GrandTotal   float64            `bson:"grandTotal" json:"grandTotal"`
+	Currency     string             `bson:"currency,omitempty" json:"currency,omitempty"`
 	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
 	ExpiresAt    time.Time          `bson:"expiresAt" json:"expiresAt"`
+
+	// Terms negotiated between the customer and the seller at quote time
+	DiscountTier          string   `bson:"discountTier,omitempty" json:"discountTier,omitempty"`
+	DiscountAmount        float64  `bson:"discountAmount" json:"discountAmount"`
+	TaxExempt             bool     `bson:"taxExempt,omitempty" json:"taxExempt,omitempty"`
+	ShippingMethod        string   `bson:"shippingMethod,omitempty" json:"shippingMethod,omitempty"`
+	AllowedPaymentMethods []string `bson:"allowedPaymentMethods,omitempty" json:"allowedPaymentMethods,omitempty"`
+	NetTermsDays          int      `bson:"netTermsDays" json:"netTermsDays"`
+	SalesRep              string   `bson:"salesRep,omitempty" json:"salesRep,omitempty"`
+
+	// ExchangeRates snapshots the rates lines were converted at, one per
+	// product currency converted from, and the seller's currency rate that
+	// shipping and SellerTotal were converted at
+	ExchangeRates []cart.ExchangeRate `bson:"exchangeRates,omitempty" json:"exchangeRates,omitempty"`
+
+	// SellerTotal is GrandTotal in the seller's currency, which credit
+	// limits are kept in
+	SellerTotal    float64 `bson:"sellerTotal,omitempty" json:"sellerTotal,omitempty"`
+	SellerCurrency string  `bson:"sellerCurrency,omitempty" json:"sellerCurrency,omitempty"`
+
+	// ShipTo is the delivery address the order was sourced for
+	ShipTo *account.Address `bson:"shipTo,omitempty" json:"shipTo,omitempty"`
+
+	// Stock held in catalog-service until the order is placed or the quote expires
+	ReservationID string `bson:"reservationId,omitempty" json:"reservationId,omitempty"`
 }
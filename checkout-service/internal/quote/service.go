@@ -9,6 +9,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// TTL is how long a quote, and the stock reserved for it, stays valid.
+const TTL = 24 * time.Hour
+
 type Service struct {
 	collection *mongo.Collection
 }
@@ -20,9 +23,13 @@ func NewService(db *mongo.Database) *Service {
 }
 
 func (s *Service) CreateQuote(quote *Quote) error {
-	quote.ID = primitive.NewObjectID()
+	if quote.ID.IsZero() {
+		quote.ID = primitive.NewObjectID()
+	}
 	quote.CreatedAt = time.Now()
-	quote.ExpiresAt = time.Now().Add(24 * time.Hour) // Quotes expire in 24 hours
+	if quote.ExpiresAt.IsZero() {
+		quote.ExpiresAt = quote.CreatedAt.Add(TTL)
+	}
 	_, err := s.collection.InsertOne(context.Background(), quote)
 	return err
 }
@@ -36,6 +43,21 @@ func (s *Service) GetQuote(quoteID primitive.ObjectID) (*Quote, error) {
 	return &quote, nil
 }
 
+// GetQuotesForCart returns the quotes an account holds with a seller.
+func (s *Service) GetQuotesForCart(accountID, sellerID string) ([]*Quote, error) {
+	cursor, err := s.collection.Find(context.Background(), bson.M{"accountId": accountID, "sellerId": sellerID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var quotes []*Quote
+	if err := cursor.All(context.Background(), &quotes); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
 func (s *Service) DeleteQuote(quoteID string) error {
 	objID, err := primitive.ObjectIDFromHex(quoteID)
 	if err != nil {
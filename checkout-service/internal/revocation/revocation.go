@@ -0,0 +1,28 @@
+// Package revocation checks access tokens against those account-service has
+// revoked before they expire: ended impersonations and signed-out sessions.
+package revocation
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Service reads the revoked token IDs account-service keeps in its database.
+type Service struct {
+	collection *mongo.Collection
+}
+
+// NewService creates a revocation service over account-service's database.
+func NewService(accountDB *mongo.Database) *Service {
+	return &Service{collection: accountDB.Collection("revokedtokens")}
+}
+
+// IsRevoked reports whether access tokens carrying id, as their "jti" or
+// "sid" claim, have been revoked.
+func (s *Service) IsRevoked(id string) (bool, error) {
+	n, err := s.collection.CountDocuments(context.Background(), bson.M{"_id": id}, options.Count().SetLimit(1))
+	return n > 0, err
+}
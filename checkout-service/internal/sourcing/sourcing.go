@@ -0,0 +1,170 @@
+// Package sourcing decides which warehouses fulfil an order.
+package sourcing
+
+import (
+	"math"
+	"sort"
+)
+
+const earthRadiusKm = 6371.0
+
+type Coords struct {
+	Lat float64
+	Lng float64
+}
+
+// Warehouse is a candidate stock location. A nil Coords means the location is
+// unknown; such warehouses are used after every located one.
+type Warehouse struct {
+	ID     string
+	Coords *Coords
+}
+
+// Stock is the available quantity of a product or variant at a warehouse. An
+// empty WarehouseID is the seller's stock without a location.
+type Stock struct {
+	ProductID   string
+	VariantID   string
+	WarehouseID string
+	Available   int
+}
+
+// Line is a quantity of a product or variant to fulfil.
+type Line struct {
+	ProductID string
+	VariantID string
+	Quantity  int
+}
+
+// Allocation assigns part or all of Lines[Line] to a warehouse.
+type Allocation struct {
+	Line        int
+	WarehouseID string
+	Quantity    int
+}
+
+// Plan is the outcome of sourcing. Short lists the indexes of lines that
+// cannot be filled from the available stock.
+type Plan struct {
+	Allocations []Allocation
+	Short       []int
+}
+
+type itemKey struct {
+	productID string
+	variantID string
+}
+
+// Source allocates lines to warehouses. Warehouses are ranked by distance to
+// shipTo. The nearest warehouse that can fill every line ships the whole
+// order; otherwise each line is filled from the nearest warehouses with
+// stock, split across several if needed. Lines without any stock record are
+// not inventory tracked and are allocated without a warehouse.
+func Source(lines []Line, warehouses []Warehouse, stock []Stock, shipTo *Coords) Plan {
+	ranked := rank(warehouses, shipTo)
+	// Stock without a location is the last resort.
+	ranked = append(ranked, "")
+
+	available := map[itemKey]map[string]int{}
+	for _, s := range stock {
+		k := itemKey{s.ProductID, s.VariantID}
+		if available[k] == nil {
+			available[k] = map[string]int{}
+		}
+		available[k][s.WarehouseID] += s.Available
+	}
+
+	var plan Plan
+	var tracked []int
+	for i, line := range lines {
+		if available[itemKey{line.ProductID, line.VariantID}] == nil {
+			plan.Allocations = append(plan.Allocations, Allocation{Line: i, Quantity: line.Quantity})
+			continue
+		}
+		tracked = append(tracked, i)
+	}
+
+	// Prefer a single shipment
+	for _, wh := range ranked {
+		fills := true
+		for _, i := range tracked {
+			if available[itemKey{lines[i].ProductID, lines[i].VariantID}][wh] < lines[i].Quantity {
+				fills = false
+				break
+			}
+		}
+		if fills {
+			for _, i := range tracked {
+				plan.Allocations = append(plan.Allocations, Allocation{Line: i, WarehouseID: wh, Quantity: lines[i].Quantity})
+			}
+			sortAllocations(plan.Allocations)
+			return plan
+		}
+	}
+
+	// Split shipment, nearest stock first
+	for _, i := range tracked {
+		byWarehouse := available[itemKey{lines[i].ProductID, lines[i].VariantID}]
+		remaining := lines[i].Quantity
+		var allocations []Allocation
+		for _, wh := range ranked {
+			if remaining == 0 {
+				break
+			}
+			take := byWarehouse[wh]
+			if take <= 0 {
+				continue
+			}
+			if take > remaining {
+				take = remaining
+			}
+			allocations = append(allocations, Allocation{Line: i, WarehouseID: wh, Quantity: take})
+			byWarehouse[wh] -= take
+			remaining -= take
+		}
+		if remaining > 0 {
+			plan.Short = append(plan.Short, i)
+			continue
+		}
+		plan.Allocations = append(plan.Allocations, allocations...)
+	}
+	sortAllocations(plan.Allocations)
+	return plan
+}
+
+// rank orders warehouse IDs by distance to shipTo; warehouses without
+// coordinates, or all of them when shipTo is unknown, keep their given order.
+func rank(warehouses []Warehouse, shipTo *Coords) []string {
+	sorted := make([]Warehouse, len(warehouses))
+	copy(sorted, warehouses)
+	if shipTo != nil {
+		sort.SliceStable(sorted, func(a, b int) bool {
+			return distance(sorted[a].Coords, shipTo) < distance(sorted[b].Coords, shipTo)
+		})
+	}
+	ids := make([]string, 0, len(sorted))
+	for _, wh := range sorted {
+		ids = append(ids, wh.ID)
+	}
+	return ids
+}
+
+func distance(c *Coords, shipTo *Coords) float64 {
+	if c == nil {
+		return math.Inf(1)
+	}
+	return DistanceKm(*c, *shipTo)
+}
+
+// DistanceKm is the great-circle distance between two points.
+func DistanceKm(a, b Coords) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+func sortAllocations(allocations []Allocation) {
+	sort.SliceStable(allocations, func(a, b int) bool { return allocations[a].Line < allocations[b].Line })
+}
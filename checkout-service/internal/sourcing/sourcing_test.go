@@ -0,0 +1,148 @@
+package sourcing
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+var (
+	chicago     = Warehouse{ID: "chicago", Coords: &Coords{Lat: 41.88, Lng: -87.63}}
+	dallas      = Warehouse{ID: "dallas", Coords: &Coords{Lat: 32.78, Lng: -96.80}}
+	newark      = Warehouse{ID: "newark", Coords: &Coords{Lat: 40.73, Lng: -74.17}}
+	unlocated   = Warehouse{ID: "unlocated"}
+	springfield = &Coords{Lat: 39.78, Lng: -89.65} // nearest Chicago, then Dallas, then Newark
+)
+
+func TestSource(t *testing.T) {
+	warehouses := []Warehouse{newark, unlocated, dallas, chicago}
+	tests := []struct {
+		name   string
+		lines  []Line
+		stock  []Stock
+		shipTo *Coords
+		want   Plan
+	}{
+		{
+			name:  "nearest warehouse ships the whole order",
+			lines: []Line{{ProductID: "a", Quantity: 2}, {ProductID: "b", Quantity: 3}},
+			stock: []Stock{
+				{ProductID: "a", WarehouseID: "newark", Available: 10},
+				{ProductID: "b", WarehouseID: "newark", Available: 10},
+				{ProductID: "a", WarehouseID: "chicago", Available: 2},
+				{ProductID: "b", WarehouseID: "chicago", Available: 3},
+			},
+			shipTo: springfield,
+			want:   Plan{Allocations: []Allocation{{Line: 0, WarehouseID: "chicago", Quantity: 2}, {Line: 1, WarehouseID: "chicago", Quantity: 3}}},
+		},
+		{
+			name:  "a farther single shipment over a nearer split",
+			lines: []Line{{ProductID: "a", Quantity: 2}, {ProductID: "b", Quantity: 3}},
+			stock: []Stock{
+				{ProductID: "a", WarehouseID: "chicago", Available: 5},
+				{ProductID: "a", WarehouseID: "newark", Available: 5},
+				{ProductID: "b", WarehouseID: "newark", Available: 5},
+			},
+			shipTo: springfield,
+			want:   Plan{Allocations: []Allocation{{Line: 0, WarehouseID: "newark", Quantity: 2}, {Line: 1, WarehouseID: "newark", Quantity: 3}}},
+		},
+		{
+			name:  "split by line when no warehouse fills every line",
+			lines: []Line{{ProductID: "a", Quantity: 2}, {ProductID: "b", Quantity: 3}},
+			stock: []Stock{
+				{ProductID: "a", WarehouseID: "chicago", Available: 5},
+				{ProductID: "b", WarehouseID: "dallas", Available: 5},
+			},
+			shipTo: springfield,
+			want:   Plan{Allocations: []Allocation{{Line: 0, WarehouseID: "chicago", Quantity: 2}, {Line: 1, WarehouseID: "dallas", Quantity: 3}}},
+		},
+		{
+			name:  "one line split across warehouses, nearest first",
+			lines: []Line{{ProductID: "a", Quantity: 8}},
+			stock: []Stock{
+				{ProductID: "a", WarehouseID: "newark", Available: 5},
+				{ProductID: "a", WarehouseID: "chicago", Available: 5},
+			},
+			shipTo: springfield,
+			want:   Plan{Allocations: []Allocation{{Line: 0, WarehouseID: "chicago", Quantity: 5}, {Line: 0, WarehouseID: "newark", Quantity: 3}}},
+		},
+		{
+			name:  "located warehouses before unlocated ones and stock without a location",
+			lines: []Line{{ProductID: "a", Quantity: 9}},
+			stock: []Stock{
+				{ProductID: "a", Available: 5},
+				{ProductID: "a", WarehouseID: "unlocated", Available: 3},
+				{ProductID: "a", WarehouseID: "dallas", Available: 2},
+			},
+			shipTo: springfield,
+			want: Plan{Allocations: []Allocation{
+				{Line: 0, WarehouseID: "dallas", Quantity: 2},
+				{Line: 0, WarehouseID: "unlocated", Quantity: 3},
+				{Line: 0, WarehouseID: "", Quantity: 4},
+			}},
+		},
+		{
+			name:  "given order without a destination",
+			lines: []Line{{ProductID: "a", Quantity: 1}},
+			stock: []Stock{
+				{ProductID: "a", WarehouseID: "chicago", Available: 5},
+				{ProductID: "a", WarehouseID: "newark", Available: 5},
+			},
+			want: Plan{Allocations: []Allocation{{Line: 0, WarehouseID: "newark", Quantity: 1}}},
+		},
+		{
+			name:  "variants are stocked apart",
+			lines: []Line{{ProductID: "a", VariantID: "red", Quantity: 2}, {ProductID: "a", VariantID: "blue", Quantity: 2}},
+			stock: []Stock{
+				{ProductID: "a", VariantID: "red", WarehouseID: "chicago", Available: 5},
+				{ProductID: "a", VariantID: "blue", WarehouseID: "dallas", Available: 5},
+			},
+			shipTo: springfield,
+			want:   Plan{Allocations: []Allocation{{Line: 0, WarehouseID: "chicago", Quantity: 2}, {Line: 1, WarehouseID: "dallas", Quantity: 2}}},
+		},
+		{
+			name:   "untracked lines ship without a warehouse",
+			lines:  []Line{{ProductID: "service", Quantity: 1}, {ProductID: "a", Quantity: 2}},
+			stock:  []Stock{{ProductID: "a", WarehouseID: "dallas", Available: 5}},
+			shipTo: springfield,
+			want:   Plan{Allocations: []Allocation{{Line: 0, Quantity: 1}, {Line: 1, WarehouseID: "dallas", Quantity: 2}}},
+		},
+		{
+			name:  "short lines are left out",
+			lines: []Line{{ProductID: "a", Quantity: 20}, {ProductID: "b", Quantity: 1}},
+			stock: []Stock{
+				{ProductID: "a", WarehouseID: "chicago", Available: 5},
+				{ProductID: "a", WarehouseID: "newark", Available: 5},
+				{ProductID: "b", WarehouseID: "dallas", Available: 1},
+			},
+			shipTo: springfield,
+			want:   Plan{Allocations: []Allocation{{Line: 1, WarehouseID: "dallas", Quantity: 1}}, Short: []int{0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Source(tt.lines, warehouses, tt.stock, tt.shipTo); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Source() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Coords
+		want float64
+	}{
+		{"same point", Coords{Lat: 51.5, Lng: -0.12}, Coords{Lat: 51.5, Lng: -0.12}, 0},
+		{"London to Paris", Coords{Lat: 51.5074, Lng: -0.1278}, Coords{Lat: 48.8566, Lng: 2.3522}, 343.5},
+		{"quarter of the equator", Coords{Lat: 0, Lng: 0}, Coords{Lat: 0, Lng: 90}, math.Pi * earthRadiusKm / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DistanceKm(tt.a, tt.b); math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("DistanceKm() = %.1f, want %.1f", got, tt.want)
+			}
+		})
+	}
+}
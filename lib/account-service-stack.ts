@@ -82,6 +82,25 @@ export class AccountServiceStack extends cdk.Stack {
     accountById.addMethod("PATCH", integ);
     accountById.addMethod("DELETE", integ);
     accountById.addMethod("PUT", integ);
+    accountById.addResource("impersonate").addMethod("POST", integ);
+
+    const sessions = accountById.addResource("sessions");
+    sessions.addMethod("GET", integ);
+    sessions.addMethod("DELETE", integ);
+    sessions.addResource("{sessionId}").addMethod("DELETE", integ);
+
+    const credit = accountById.addResource("credit");
+    credit.addMethod("GET", integ);
+    credit.addMethod("PUT", integ);
+
+    const relationships = accountById.addResource("relationships");
+    relationships.addMethod("GET", integ);
+    const relationshipBySeller = relationships.addResource("{sellerId}");
+    relationshipBySeller.addMethod("GET", integ);
+    relationshipBySeller.addMethod("PATCH", integ);
+
+    this.api.root.addResource("impersonations").addMethod("GET", integ);
+    this.api.root.addResource("impersonation").addResource("end").addMethod("POST", integ);
 
     const codes = this.api.root.addResource("codes");
     codes.addMethod("POST", integ);
@@ -1,6 +1,7 @@
 import * as cdk from 'aws-cdk-lib';
 import * as apigateway from 'aws-cdk-lib/aws-apigateway';
 import * as lambda from 'aws-cdk-lib/aws-lambda';
+import * as s3 from 'aws-cdk-lib/aws-s3';
 import * as path from 'path';
 import { Construct } from 'constructs';
 
@@ -8,29 +9,57 @@ export class CatalogServiceStack extends cdk.Stack {
   constructor(scope: Construct, id: string, props?: cdk.StackProps) {
     super(scope, id, props);
 
+    // Product images and thumbnails. The bucket is private: clients download
+    // them through the service's GET /media route.
+    const mediaBucket = new s3.Bucket(this, 'CatalogMedia', {
+      blockPublicAccess: s3.BlockPublicAccess.BLOCK_ALL,
+      enforceSSL: true,
+    });
+
+    const catalogServiceCode = lambda.Code.fromAsset(path.join(__dirname, '..', 'catalog-service'), {
+      bundling: {
+        image: lambda.Runtime.GO_1_X.bundlingImage,
+        command: [
+          'bash',
+          '-c',
+          'go build -o /asset-output/bootstrap ./cmd/server/main.go',
+        ],
+        user: 'root',
+      },
+    });
+    const catalogServiceEnvironment = {
+      MONGO_URI: process.env.MONGO_URI || '',
+      JWT_SECRET: process.env.JWT_SECRET || '',
+      JWT_REFRESH_SECRET: process.env.JWT_REFRESH_SECRET || '',
+      BLOB_STORE: 's3',
+      S3_BUCKET: mediaBucket.bucketName,
+      NODE_ENV: 'development',
+    };
+
+    // Import worker: the same binary, invoked asynchronously with one import
+    // job at a time, with time for the largest uploads
+    const importWorkerLambda = new lambda.Function(this, 'CatalogImportWorker', {
+      runtime: lambda.Runtime.GO_1_X,
+      handler: 'bootstrap',
+      code: catalogServiceCode,
+      environment: catalogServiceEnvironment,
+      timeout: cdk.Duration.minutes(15),
+    });
+    mediaBucket.grantReadWrite(importWorkerLambda);
+
     // Catalog Service Lambda
     const catalogServiceLambda = new lambda.Function(this, 'CatalogService', {
       runtime: lambda.Runtime.GO_1_X,
       handler: 'bootstrap',
-      code: lambda.Code.fromAsset(path.join(__dirname, '..', 'catalog-service'), {
-        bundling: {
-          image: lambda.Runtime.GO_1_X.bundlingImage,
-          command: [
-            'bash',
-            '-c',
-            'go build -o /asset-output/bootstrap ./cmd/server/main.go',
-          ],
-          user: 'root',
-        },
-      }),
+      code: catalogServiceCode,
       environment: {
-        MONGO_URI: process.env.MONGO_URI || '',
-        JWT_SECRET: process.env.JWT_SECRET || '',
-        JWT_REFRESH_SECRET: process.env.JWT_REFRESH_SECRET || '',
-        NODE_ENV: 'development',
+        ...catalogServiceEnvironment,
+        IMPORT_FUNCTION: importWorkerLambda.functionName,
       },
       timeout: cdk.Duration.seconds(30),
     });
+    mediaBucket.grantReadWrite(catalogServiceLambda);
+    importWorkerLambda.grantInvoke(catalogServiceLambda);
 
     // API Gateway
     const api = new apigateway.RestApi(this, 'CatalogApi', {
@@ -39,11 +68,52 @@ export class CatalogServiceStack extends cdk.Stack {
       deployOptions: {
         stageName: 'dev',
       },
+      // Image uploads and downloads pass through as binary
+      binaryMediaTypes: ['image/*', 'multipart/form-data'],
     });
 
+    // Images are downloaded through the API. The URL is built from the API's
+    // ID rather than api.url, which would make the function depend on its
+    // own deployment.
+    const mediaURL = `https://${api.restApiId}.execute-api.${this.region}.${this.urlSuffix}/dev/media`;
+    catalogServiceLambda.addEnvironment('S3_PUBLIC_URL', mediaURL);
+    importWorkerLambda.addEnvironment('S3_PUBLIC_URL', mediaURL);
+
     // API Routes
     const products = api.root.addResource('products');
     const productId = products.addResource('{productId}');
+    const productImages = productId.addResource('images');
+    const productImageId = productImages.addResource('{imageId}');
+    const mediaFile = api.root.addResource('media').addResource('{proxy+}');
+    const productExport = products.addResource('export');
+    const productChanges = products.addResource('changes');
+    const productImports = products.addResource('imports');
+    const productImportId = productImports.addResource('{importId}');
+    const productHistory = productId.addResource('history');
+    const productRevision = productHistory.addResource('{revision}');
+    const productInventory = productId.addResource('inventory');
+    const inventoryAdjustments = productInventory.addResource('adjustments');
+    const inventory = api.root.addResource('inventory');
+    const availability = inventory.addResource('availability');
+    const reservations = inventory.addResource('reservations');
+    const reservationId = reservations.addResource('{reservationId}');
+    const reservationCommit = reservationId.addResource('commit');
+    const reservationRelease = reservationId.addResource('release');
+    const warehouses = api.root.addResource('warehouses');
+    const warehouseId = warehouses.addResource('{warehouseId}');
+    const priceLists = api.root.addResource('price-lists');
+    const priceListId = priceLists.addResource('{priceListId}');
+    const customerGroups = api.root.addResource('customer-groups');
+    const customerGroupId = customerGroups.addResource('{groupId}');
+    const pricingResolve = api.root.addResource('pricing').addResource('resolve');
+    const exchangeRates = api.root.addResource('exchange-rates');
+    const categories = api.root.addResource('categories');
+    const categoryId = categories.addResource('{categoryId}');
+    const categoryMove = categoryId.addResource('move');
+    const visibilityRules = api.root.addResource('visibility-rules');
+    const visibilityRuleId = visibilityRules.addResource('{ruleId}');
+    const partNumbers = api.root.addResource('part-numbers');
+    const partNumberId = partNumbers.addResource('{partNumberId}');
 
     // Integrations
     const catalogIntegration = new apigateway.LambdaIntegration(catalogServiceLambda);
@@ -51,11 +121,66 @@ export class CatalogServiceStack extends cdk.Stack {
     products.addMethod('GET', catalogIntegration);
     productId.addMethod('GET', catalogIntegration);
     productId.addMethod('PUT', catalogIntegration);
+    productId.addMethod('PATCH', catalogIntegration);
     productId.addMethod('DELETE', catalogIntegration);
+    productImages.addMethod('POST', catalogIntegration);
+    productImages.addMethod('PUT', catalogIntegration);
+    productImageId.addMethod('DELETE', catalogIntegration);
+    mediaFile.addMethod('GET', catalogIntegration);
+    productExport.addMethod('GET', catalogIntegration);
+
+    productChanges.addMethod('GET', catalogIntegration);
+    productImports.addMethod('POST', catalogIntegration);
+    productImports.addMethod('GET', catalogIntegration);
+    productImportId.addMethod('GET', catalogIntegration);
+    productHistory.addMethod('GET', catalogIntegration);
+    productRevision.addMethod('GET', catalogIntegration);
+    productInventory.addMethod('GET', catalogIntegration);
+    inventoryAdjustments.addMethod('GET', catalogIntegration);
+    inventoryAdjustments.addMethod('POST', catalogIntegration);
+    availability.addMethod('POST', catalogIntegration);
+    reservations.addMethod('POST', catalogIntegration);
+    reservationId.addMethod('GET', catalogIntegration);
+    reservationCommit.addMethod('POST', catalogIntegration);
+    reservationRelease.addMethod('POST', catalogIntegration);
+    warehouses.addMethod('POST', catalogIntegration);
+    warehouses.addMethod('GET', catalogIntegration);
+    warehouseId.addMethod('GET', catalogIntegration);
+    warehouseId.addMethod('PUT', catalogIntegration);
+    warehouseId.addMethod('DELETE', catalogIntegration);
+    for (const [collection, item] of [[priceLists, priceListId], [customerGroups, customerGroupId], [categories, categoryId], [visibilityRules, visibilityRuleId], [partNumbers, partNumberId]]) {
+      collection.addMethod('POST', catalogIntegration);
+      collection.addMethod('GET', catalogIntegration);
+      item.addMethod('GET', catalogIntegration);
+      item.addMethod('PUT', catalogIntegration);
+      item.addMethod('DELETE', catalogIntegration);
+      collection.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'POST', 'OPTIONS'] });
+      item.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'PUT', 'DELETE', 'OPTIONS'] });
+    }
+    pricingResolve.addMethod('POST', catalogIntegration);
+    exchangeRates.addMethod('GET', catalogIntegration);
+    categoryMove.addMethod('POST', catalogIntegration);
 
     // CORS
     products.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'POST', 'OPTIONS'] });
-    productId.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'PUT', 'DELETE', 'OPTIONS'] });
+    productId.addCorsPreflight({
+      allowOrigins: ['*'],
+      allowMethods: ['GET', 'PUT', 'PATCH', 'DELETE', 'OPTIONS'],
+      allowHeaders: [...apigateway.Cors.DEFAULT_HEADERS, 'If-Match'],
+    });
+    productImages.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['POST', 'PUT', 'OPTIONS'] });
+    productImageId.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['DELETE', 'OPTIONS'] });
+    productExport.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'OPTIONS'] });
+    productChanges.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'OPTIONS'] });
+    exchangeRates.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'OPTIONS'] });
+    productImports.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'POST', 'OPTIONS'] });
+    productImportId.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'OPTIONS'] });
+    productHistory.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'OPTIONS'] });
+    productRevision.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'OPTIONS'] });
+    productInventory.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'OPTIONS'] });
+    inventoryAdjustments.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'POST', 'OPTIONS'] });
+    warehouses.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'POST', 'OPTIONS'] });
+    warehouseId.addCorsPreflight({ allowOrigins: ['*'], allowMethods: ['GET', 'PUT', 'DELETE', 'OPTIONS'] });
 
     // Output API Endpoint
     new cdk.CfnOutput(this, 'CatalogApiUrl', {
@@ -32,6 +32,9 @@ export class CheckoutServiceStack extends cdk.Stack {
         MONGO_URI: process.env.MONGO_URI || '',
         JWT_SECRET: process.env.JWT_SECRET || '',
         JWT_REFRESH_SECRET: process.env.JWT_REFRESH_SECRET || '',
+        ACCOUNT_SERVICE_URL: process.env.ACCOUNT_SERVICE_URL || '',
+        CATALOG_SERVICE_URL: process.env.CATALOG_SERVICE_URL || '',
+        PUNCHOUT_START_URL: process.env.PUNCHOUT_START_URL || '',
         NODE_ENV: 'development',
         
       },
@@ -58,6 +61,7 @@ export class CheckoutServiceStack extends cdk.Stack {
     cartResource.addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Add item to cart
     cartResource.addMethod('GET', new apigw.LambdaIntegration(this.handler));  // Get cart
     cartResource.addMethod('DELETE', new apigw.LambdaIntegration(this.handler)); // Clear cart
+    cartResource.addResource('quick-order').addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Add items by customer part number
 
     // Add /cart/{itemId} resource and methods
     const cartItemResource = cartResource.addResource('{itemId}');
@@ -76,5 +80,35 @@ export class CheckoutServiceStack extends cdk.Stack {
     const ordersResource = this.api.root.addResource('orders');
     ordersResource.addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Place an order from a quote
     ordersResource.addMethod('GET', new apigw.LambdaIntegration(this.handler)); // Get all orders
+
+    const orderIdResource = ordersResource.addResource('{orderId}');
+    orderIdResource.addResource('payments').addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Record payment of an on-account order
+
+    // Add /credit resource and methods
+    const creditResource = this.api.root.addResource('credit');
+    creditResource.addMethod('GET', new apigw.LambdaIntegration(this.handler)); // Credit utilization per seller
+
+    // Add /lists resource and methods
+    const listsResource = this.api.root.addResource('lists');
+    listsResource.addMethod('GET', new apigw.LambdaIntegration(this.handler)); // Saved lists and order guides
+    listsResource.addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Create a list or guide
+
+    const listIdResource = listsResource.addResource('{listId}');
+    listIdResource.addMethod('GET', new apigw.LambdaIntegration(this.handler)); // Get list by ID
+    listIdResource.addMethod('PUT', new apigw.LambdaIntegration(this.handler)); // Replace a list
+    listIdResource.addMethod('DELETE', new apigw.LambdaIntegration(this.handler)); // Delete a list
+    listIdResource.addResource('cart').addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Add a list to the cart
+
+    // Add /punchout resources and methods. setup, start and orders take no
+    // JWT: procurement systems authenticate with the cXML shared secret.
+    const punchoutResource = this.api.root.addResource('punchout');
+    punchoutResource.addResource('setup').addMethod('POST', new apigw.LambdaIntegration(this.handler)); // cXML PunchOutSetupRequest
+    punchoutResource.addResource('start').addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Redeem a start page token
+    punchoutResource.addResource('orders').addMethod('POST', new apigw.LambdaIntegration(this.handler)); // cXML OrderRequest
+    punchoutResource.addResource('sessions').addResource('{sessionId}').addResource('return').addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Return the cart as a PunchOutOrderMessage
+    const punchoutBuyersResource = punchoutResource.addResource('buyers');
+    punchoutBuyersResource.addMethod('GET', new apigw.LambdaIntegration(this.handler)); // Seller's punchout buyers
+    punchoutBuyersResource.addMethod('POST', new apigw.LambdaIntegration(this.handler)); // Register a punchout buyer
+    punchoutBuyersResource.addResource('{buyerId}').addMethod('DELETE', new apigw.LambdaIntegration(this.handler)); // Remove a punchout buyer
   }
 }
\ No newline at end of file
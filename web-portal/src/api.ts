@@ -123,7 +123,9 @@ export const getProducts = async (): Promise<Product[]> => {
 };
 
 export const updateProduct = async (id: string, data: Partial<Product>): Promise<Product> => {
-  const response = await api.put(`${CATALOG_API_URL}/products/${id}`, data);
+  const response = await api.patch(`${CATALOG_API_URL}/products/${id}`, data, {
+    headers: { 'Content-Type': 'application/merge-patch+json' },
+  });
   return response.data;
 };
 
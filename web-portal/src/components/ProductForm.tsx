@@ -103,7 +103,9 @@ const ProductForm = () => {
     setIsLoading(true);
     try {
       if (editingId) {
-        await updateProduct(editingId, formData as Product);
+        // Only send the fields the form edits; ownership and images are not patchable
+        const { name, price, description } = formData;
+        await updateProduct(editingId, { name, price, description });
         toast.success('Product updated successfully');
       } else {
         await createProduct(formData as Omit<Product, '_id'>);